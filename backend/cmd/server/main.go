@@ -1,13 +1,22 @@
 package main
 
 import (
-	"fmt"
-	"log"
+	"context"
+	"flag"
+	"log/slog"
+	"net/http"
 	"os"
 	"kursovaya_backend/internal/config"
 	"kursovaya_backend/internal/database"
 	"kursovaya_backend/internal/handlers"
+	"kursovaya_backend/internal/logging"
+	"kursovaya_backend/internal/mailer"
+	"kursovaya_backend/internal/metrics"
+	"kursovaya_backend/internal/middleware"
+	"kursovaya_backend/internal/repository"
+	"kursovaya_backend/internal/secrets"
 	"kursovaya_backend/internal/service"
+	"kursovaya_backend/pkg/api"
 	"kursovaya_backend/internal/routes"
 	"kursovaya_backend/pkg/utils"
 	"github.com/gin-gonic/gin"
@@ -22,33 +31,124 @@ func main() {
 	// Загружаем конфигурацию
 	cfg := config.Load()
 
+	// Настраиваем лог до остальных компонентов, чтобы их записи были в одном формате
+	logging.Setup(cfg)
+	cfg.Validate()
+
 	// Устанавливаем ключи для утилит
 	utils.SetJWTKey(cfg.JWTSecret)
+	keyring, err := cfg.Keyring()
+	if err != nil {
+		fatal("Invalid encryption keys", err)
+	}
+	utils.SetEncryptionKeyring(keyring)
 
 	// Подключаемся к базе данных
-	database.Connect(cfg)
+	db, err := database.Connect(context.Background(), cfg)
+	if err != nil {
+		fatal("Failed to connect to database", err)
+	}
+	defer db.Close()
+
+	// Создаем репозитории
+	repos := repository.NewSQL(db)
+
+	// server rotate-keys перешифровывает секреты основным ключом и завершается
+	if len(os.Args) > 1 && os.Args[1] == "rotate-keys" {
+		rotateKeys(repos, keyring, os.Args[2:])
+		return
+	}
 
 	// Инициализируем администратора
-	if err := service.InitializeAdmin(); err != nil {
-		log.Fatal("Failed to initialize admin:", err)
+	if err := service.NewAdminService(repos, cfg).InitializeAdmin(context.Background()); err != nil {
+		fatal("Failed to initialize admin", err)
+	}
+
+	// Почта для писем сброса пароля и подтверждения email
+	mail, err := mailer.New(cfg)
+	if err != nil {
+		fatal("Failed to configure mailer", err)
+	}
+
+	// Хранилище токенов маркетплейсов
+	secretStore, err := secrets.New(cfg)
+	if err != nil {
+		fatal("Failed to configure secret store", err)
 	}
 
-	// Инициализируем сервисы
-	service.InitStoreService(cfg)
+	// Метрики Prometheus: HTTP-запросы, запросы к маркетплейсам, загрузки
+	// товаров, пул соединений и число сущностей в базе
+	m := metrics.New()
+	m.CollectDBStats(db)
+	m.CollectCatalogue(repos)
+	api.SetRequestObserver(m.ObserveMarketplaceRequest)
+	if cfg.MetricsAddr != "" {
+		go serveMetrics(cfg.MetricsAddr, m.Handler(cfg.MetricsToken))
+	}
 
 	// Создаем Gin роутер
-	r := gin.Default()
+	r := gin.New()
+	// Адрес клиента из X-Forwarded-For принимается только от доверенных
+	// прокси, иначе клиент мог бы подменить его и обойти лимиты по IP
+	if err := r.SetTrustedProxies(cfg.TrustedProxyList()); err != nil {
+		fatal("Invalid TRUSTED_PROXIES", err)
+	}
 
-	// Добавляем глобальный обработчик ошибок
+	// Идентификатор запроса, лог запросов, восстановление после паники
+	// и глобальный обработчик ошибок
+	r.Use(middleware.RequestID(), middleware.RequestLogger(), gin.CustomRecovery(recoverPanic))
 	r.Use(handlers.GlobalErrorHandler())
 
 	// Подключаем маршруты
-	routes.SetupRoutes(r, cfg)
+	routes.SetupRoutes(r, cfg, repos, mail, secretStore, m)
 
 	// Запускаем сервер
 	port := ":" + cfg.Port
-	log.Printf("Server starting on port %s", cfg.Port)
+	slog.Info("Server starting", "port", cfg.Port)
 	if err := r.Run(port); err != nil {
-		log.Fatal(fmt.Sprintf("Failed to start server on port %s:", port), err)
+		fatal("Failed to start server", err, "port", cfg.Port)
+	}
+}
+
+// serveMetrics отдает метрики на отдельном адресе addr, который в отличие от
+// основного порта можно не публиковать наружу
+func serveMetrics(addr string, handler http.Handler) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", handler)
+	slog.Info("Metrics server starting", "addr", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		fatal("Failed to start metrics server", err, "addr", addr)
+	}
+}
+
+// fatal записывает ошибку запуска в лог и завершает процесс
+func fatal(msg string, err error, attrs ...any) {
+	slog.Error(msg, append(attrs, "error", err)...)
+	os.Exit(1)
+}
+
+// recoverPanic записывает панику обработчика в лог и отвечает 500
+func recoverPanic(c *gin.Context, recovered any) {
+	slog.ErrorContext(c.Request.Context(), "Panic while handling request", "panic", recovered)
+	c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+		"error":      "Внутренняя ошибка сервера",
+		"request_id": utils.RequestIDFromContext(c.Request.Context()),
+	})
+}
+
+// rotateKeys выполняет команду rotate-keys
+func rotateKeys(repos *repository.Repositories, keyring *utils.Keyring, args []string) {
+	flags := flag.NewFlagSet("rotate-keys", flag.ExitOnError)
+	batchSize := flags.Int("batch-size", 100, "сколько магазинов перешифровывать за один запрос")
+	flags.Parse(args)
+
+	result, err := service.NewKeyRotationService(repos, keyring).Rotate(context.Background(), *batchSize)
+	if err != nil {
+		fatal("Key rotation failed", err)
+	}
+	slog.Info("Key rotation finished", "key_id", keyring.PrimaryKeyID(),
+		"rotated", result.Rotated, "skipped", result.Skipped, "failed", result.Failed)
+	if result.Failed > 0 {
+		os.Exit(1)
 	}
-}
\ No newline at end of file
+}
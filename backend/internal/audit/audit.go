@@ -0,0 +1,134 @@
+// Package audit записывает события журнала аудита. Инициатор действия и
+// сведения о клиенте передаются через контекст запроса: их устанавливают
+// middleware, а сервисы и обработчики только вызывают Record.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/repository"
+)
+
+// Actor - инициатор действия
+type Actor struct {
+	Type     string // models.ActorUser, ActorAdmin, ActorAPIKey или ActorSystem
+	ID       int    // пользователь (и для API-ключа) или администратор
+	APIKeyID int
+	// ImpersonatorID - администратор, действующий от имени пользователя ID
+	ImpersonatorID int
+}
+
+// Client - сведения о клиенте, выполнившем запрос
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+type actorKey struct{}
+type clientKey struct{}
+
+// WithActor добавляет инициатора действия в контекст
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom возвращает инициатора из контекста. Без него действие считается
+// выполненным сервером (запуск, команды, неаутентифицированные запросы).
+func ActorFrom(ctx context.Context) Actor {
+	if actor, ok := ctx.Value(actorKey{}).(Actor); ok {
+		return actor
+	}
+	return Actor{Type: models.ActorSystem}
+}
+
+// WithClient добавляет сведения о клиенте в контекст
+func WithClient(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// ClientFrom возвращает сведения о клиенте из контекста
+func ClientFrom(ctx context.Context) Client {
+	client, _ := ctx.Value(clientKey{}).(Client)
+	return client
+}
+
+// Entry - действие над целью. TargetID может быть числом или строкой.
+// Before и After сериализуются в JSON и не должны содержать секретов.
+type Entry struct {
+	Action     string
+	TargetType string
+	TargetID   any
+	Before     any
+	After      any
+}
+
+// Record записывает событие в журнал и дублирует его в лог записью "Audit event".
+// Внутри транзакции передается ее репозиторий, чтобы событие фиксировалось
+// или откатывалось вместе с изменением.
+func Record(ctx context.Context, events repository.AuditRepository, entry Entry) error {
+	actor := ActorFrom(ctx)
+	client := ClientFrom(ctx)
+	event := &models.AuditEvent{
+		ActorType:  actor.Type,
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		IP:         client.IP,
+		UserAgent:  client.UserAgent,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if actor.ID != 0 {
+		event.ActorID = &actor.ID
+	}
+	if actor.APIKeyID != 0 {
+		event.APIKeyID = &actor.APIKeyID
+	}
+	if actor.ImpersonatorID != 0 {
+		event.ImpersonatorID = &actor.ImpersonatorID
+	}
+	if entry.TargetID != nil {
+		event.TargetID = fmt.Sprint(entry.TargetID)
+	}
+
+	var err error
+	if event.Before, err = snapshot(entry.Before); err != nil {
+		return err
+	}
+	if event.After, err = snapshot(entry.After); err != nil {
+		return err
+	}
+	if err := events.Create(ctx, event); err != nil {
+		return fmt.Errorf("ошибка записи события аудита: %w", err)
+	}
+
+	attrs := []any{
+		"action", event.Action,
+		"actor_type", event.ActorType,
+		"actor_id", actor.ID,
+		"target_type", event.TargetType,
+		"target_id", event.TargetID,
+		"ip", event.IP,
+		"before", event.Before,
+		"after", event.After,
+	}
+	if actor.ImpersonatorID != 0 {
+		attrs = append(attrs, "impersonator_id", actor.ImpersonatorID)
+	}
+	slog.InfoContext(ctx, "Audit event", attrs...)
+	return nil
+}
+
+func snapshot(state any) (json.RawMessage, error) {
+	if state == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сериализации снимка аудита: %w", err)
+	}
+	return data, nil
+}
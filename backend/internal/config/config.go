@@ -1,50 +1,321 @@
 package config
 
 import (
-	"log"
+	"log/slog"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"kursovaya_backend/pkg/utils"
 )
 
 type Config struct {
-	DBHost        string
-	DBPort        string
-	DBUser        string
-	DBPassword    string
-	DBName        string
-	JWTSecret     string
-	EncryptionKey string
-	AllowOrigins  string
-	Port          string
-}
-
-// Validate ensures that required configuration values are set
+	DBDriver   string
+	SQLitePath string
+	DBHost     string
+	DBPort     string
+	DBUser     string
+	DBPassword string
+	DBName     string
+	// DatabaseURL - полная строка подключения к PostgreSQL, имеет приоритет над DB_HOST/DB_PORT/...
+	DatabaseURL   string
+	DBSSLMode     string
+	DBSSLRootCert string
+	DBSSLCert     string
+	DBSSLKey      string
+	// Настройки пула соединений sql.DB
+	DBMaxOpenConns    int
+	DBMaxIdleConns    int
+	DBConnMaxLifetime time.Duration
+	DBConnMaxIdleTime time.Duration
+	// Повторные попытки подключения при старте (например, пока PostgreSQL поднимается)
+	DBConnectRetries int
+	DBConnectBackoff time.Duration
+	JWTSecret        string
+	// Время жизни access-токена и refresh-токена сессии
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// AdminRequire2FA запрещает администраторам работу без подключенной двухфакторной аутентификации
+	AdminRequire2FA bool
+	EncryptionKey   string
+	AllowOrigins    string
+	// AppURL - адрес фронтенда, на который ведут ссылки из писем
+	AppURL string
+	// Отправка писем: Mailer - "log", "file" или "smtp"
+	Mailer       string
+	MailDir      string
+	MailFrom     string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	Port         string
+
+	// Защита от перебора паролей: LoginAttemptStore - "memory" (один экземпляр)
+	// или "database" (общие счетчики для нескольких реплик)
+	LoginAttemptStore string
+	// LoginMaxFailures / LoginIPMaxFailures - неудач подряд до блокировки
+	// учетной записи / IP-адреса в пределах LoginFailureWindow, 0 отключает блокировку
+	LoginMaxFailures     int
+	LoginIPMaxFailures   int
+	LoginFailureWindow   time.Duration
+	LoginLockoutDuration time.Duration
+	// Пауза после неудачи удваивается с каждой неудачей от LoginDelayBase до LoginDelayMax
+	LoginDelayBase time.Duration
+	LoginDelayMax  time.Duration
+
+	// EncryptionKeys - ключи шифрования вида "id1:секрет1,id2:секрет2", новые
+	// данные шифруются ключом EncryptionKeyID. Без них используется EncryptionKey.
+	EncryptionKeys  string
+	EncryptionKeyID string
+
+	// Хранилище токенов маркетплейсов: SecretStore - "database" (зашифрованными
+	// в таблице stores), "vault" (HashiCorp Vault KV v2) или "file" (каталог SecretsDir)
+	SecretStore     string
+	SecretsDir      string
+	VaultAddr       string
+	VaultToken      string
+	VaultNamespace  string
+	VaultKVMount    string
+	VaultPathPrefix string
+
+	// Первый администратор: с AdminDefaultPassword создается "admin" с обязательной
+	// сменой пароля, иначе первый администратор создается по одноразовому токену
+	// AdminBootstrapToken (или сгенерированному при запуске), действующему AdminBootstrapTokenTTL
+	AdminDefaultPassword   string
+	AdminBootstrapToken    string
+	AdminBootstrapTokenTTL time.Duration
+
+	// RequireEmailVerification закрывает рабочие маршруты (магазины, товары,
+	// сопоставления) для пользователей с неподтвержденным email
+	RequireEmailVerification bool
+
+	// ImpersonationTTL - время жизни токена администратора для входа от имени пользователя
+	ImpersonationTTL time.Duration
+
+	// StatsCacheTTL - время, в течение которого статистика админ-панели
+	// выдается из кэша без повторных запросов к базе
+	StatsCacheTTL time.Duration
+
+	// Ограничение частоты запросов: RateLimitStore - "memory" (один экземпляр)
+	// или "database" (общие корзины для нескольких реплик). Лимиты задаются в
+	// запросах в минуту, *Burst - сколько запросов можно выполнить подряд;
+	// 0 отключает лимит. RateLimitIP действует на публичных маршрутах,
+	// RateLimitProducts - дополнительно на загрузку товаров с маркетплейсов.
+	RateLimitStore         string
+	RateLimitUser          int
+	RateLimitUserBurst     int
+	RateLimitAPIKey        int
+	RateLimitAPIKeyBurst   int
+	RateLimitIP            int
+	RateLimitIPBurst       int
+	RateLimitProducts      int
+	RateLimitProductsBurst int
+
+	// Лог: LogLevel - "debug", "info", "warn" или "error", LogFormat - "json" или "text"
+	LogLevel  string
+	LogFormat string
+
+	// Метрики Prometheus: MetricsAddr - отдельный адрес для /metrics (например,
+	// ":9090"), иначе /metrics доступен на основном порту. MetricsToken -
+	// Bearer-токен для доступа к /metrics; на основном порту без токена
+	// метрики отключены.
+	MetricsAddr  string
+	MetricsToken string
+
+	// TrustedProxies - адреса и подсети обратных прокси через запятую,
+	// например "10.0.0.5,172.18.0.0/16". Только от них принимаются заголовки
+	// X-Forwarded-For и X-Real-IP; по умолчанию не доверяем никому, и адрес
+	// клиента для лимитов и блокировок входа берется из соединения.
+	TrustedProxies string
+}
+
+// Validate ensures that required configuration values are set. It only logs
+// warnings, so it is called after the logger is configured from LOG_LEVEL and
+// LOG_FORMAT.
 func (c *Config) Validate() {
 	if c.JWTSecret == "" || c.JWTSecret == "default-secret-key-change-in-production" {
-		log.Println("[WARNING] JWT_SECRET is using default value - this is insecure for production")
+		slog.Warn("JWT_SECRET is using default value - this is insecure for production")
 	}
 	if c.EncryptionKey == "" || c.EncryptionKey == "default-encryption-key-change-in-production" {
-		log.Println("[WARNING] ENCRYPTION_KEY is using default value - this is insecure for production")
+		slog.Warn("ENCRYPTION_KEY is using default value - this is insecure for production")
+	}
+	if c.EncryptionKeys != "" && c.EncryptionKeyID == "" {
+		slog.Warn("ENCRYPTION_KEY_ID is not set - it is required when ENCRYPTION_KEYS lists several keys")
+	}
+	if c.DBDriver != "postgres" && c.DBDriver != "sqlite" {
+		slog.Warn("DB_DRIVER is not supported, use 'postgres' or 'sqlite'", "value", c.DBDriver)
+	}
+	if c.DBDriver == "postgres" && c.DatabaseURL == "" && (c.DBPassword == "" || c.DBPassword == "password") {
+		slog.Warn("DB_PASSWORD is using default value - this is insecure for production")
 	}
-	if c.DBPassword == "" || c.DBPassword == "password" {
-		log.Println("[WARNING] DB_PASSWORD is using default value - this is insecure for production")
+	if c.DBDriver == "postgres" && c.DatabaseURL == "" && c.DBSSLMode == "disable" && c.DBHost != "postgres" && c.DBHost != "localhost" && c.DBHost != "127.0.0.1" {
+		slog.Warn("DB_SSLMODE is 'disable' for a remote database host - traffic is not encrypted")
+	}
+	if c.AccessTokenTTL >= c.RefreshTokenTTL {
+		slog.Warn("ACCESS_TOKEN_TTL should be shorter than REFRESH_TOKEN_TTL")
+	}
+	if c.Mailer == "smtp" && c.SMTPHost == "" {
+		slog.Warn("MAILER is 'smtp' but SMTP_HOST is not set")
+	}
+	if c.LoginAttemptStore != "memory" && c.LoginAttemptStore != "database" {
+		slog.Warn("LOGIN_ATTEMPT_STORE is not supported, use 'memory' or 'database'", "value", c.LoginAttemptStore)
+	}
+	if c.LoginMaxFailures <= 0 {
+		slog.Warn("LOGIN_MAX_FAILURES is 0 - accounts are never locked after failed logins")
+	}
+	if c.SecretStore == "vault" && (c.VaultAddr == "" || c.VaultToken == "") {
+		slog.Warn("SECRET_STORE is 'vault' but VAULT_ADDR or VAULT_TOKEN is not set")
+	}
+	if c.AdminBootstrapToken != "" && len(c.AdminBootstrapToken) < 32 {
+		slog.Warn("ADMIN_BOOTSTRAP_TOKEN should be at least 32 characters long")
+	}
+	if c.ImpersonationTTL > time.Hour {
+		slog.Warn("IMPERSONATION_TTL is longer than an hour - impersonation tokens should be short-lived")
+	}
+	if c.RateLimitStore != "memory" && c.RateLimitStore != "database" {
+		slog.Warn("RATE_LIMIT_STORE is not supported, use 'memory' or 'database'", "value", c.RateLimitStore)
+	}
+	if c.RateLimitProducts <= 0 {
+		slog.Warn("RATE_LIMIT_PRODUCTS is 0 - product loading is not limited and may exhaust marketplace API quotas")
+	}
+	switch strings.ToLower(c.LogLevel) {
+	case "debug", "info", "warn", "warning", "error":
+	default:
+		slog.Warn("LOG_LEVEL is not supported, use 'debug', 'info', 'warn' or 'error'", "value", c.LogLevel)
+	}
+	if c.LogFormat != "json" && c.LogFormat != "text" {
+		slog.Warn("LOG_FORMAT is not supported, use 'json' or 'text'", "value", c.LogFormat)
+	}
+	if c.DBMaxOpenConns > 0 && c.DBMaxIdleConns > c.DBMaxOpenConns {
+		slog.Warn("DB_MAX_IDLE_CONNS is greater than DB_MAX_OPEN_CONNS and will be capped")
+	}
+	if c.MetricsToken != "" && len(c.MetricsToken) < 32 {
+		slog.Warn("METRICS_TOKEN should be at least 32 characters long")
+	}
+}
+
+// TrustedProxyList возвращает адреса и подсети из TRUSTED_PROXIES; nil,
+// если прокси не заданы
+func (c *Config) TrustedProxyList() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(c.TrustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
 	}
+	return proxies
+}
+
+// legacyEncryptionKeyID - идентификатор ENCRYPTION_KEY, если ENCRYPTION_KEYS не задан
+const legacyEncryptionKeyID = "1"
+
+// Keyring собирает ключи шифрования токенов магазинов и секретов 2FA. Если
+// ENCRYPTION_KEYS не задан, единственным ключом с идентификатором "1"
+// становится ENCRYPTION_KEY. ENCRYPTION_KEY в любом случае нужен, чтобы
+// читать данные, зашифрованные до появления версий ключей.
+func (c *Config) Keyring() (*utils.Keyring, error) {
+	if c.EncryptionKeys == "" {
+		return utils.NewKeyring(legacyEncryptionKeyID, map[string]string{legacyEncryptionKeyID: c.EncryptionKey}, c.EncryptionKey)
+	}
+
+	secrets, err := utils.ParseEncryptionKeys(c.EncryptionKeys)
+	if err != nil {
+		return nil, err
+	}
+	primaryID := c.EncryptionKeyID
+	if primaryID == "" && len(secrets) == 1 {
+		for id := range secrets {
+			primaryID = id
+		}
+	}
+	return utils.NewKeyring(primaryID, secrets, c.EncryptionKey)
 }
 
 func Load() *Config {
 	cfg := &Config{
-		DBHost:        getEnv("DB_HOST", "postgres"),
-		DBPort:        getEnv("DB_PORT", "5432"),
-		DBUser:        getEnv("DB_USER", "postgres"),
-		DBPassword:    getEnv("DB_PASSWORD", "password"),
-		DBName:        getEnv("DB_NAME", "marketplace_tracker"),
-		JWTSecret:     getEnv("JWT_SECRET", "default-secret-key-change-in-production"),
-		EncryptionKey: getEnv("ENCRYPTION_KEY", "default-encryption-key-change-in-production"),
-		AllowOrigins:  getEnv("ALLOW_ORIGINS", ""),
-		Port:          getEnv("PORT", "8080"),
-	}
+		DBDriver:          getEnv("DB_DRIVER", "postgres"),
+		SQLitePath:        getEnv("SQLITE_PATH", "data.db"),
+		DBHost:            getEnv("DB_HOST", "postgres"),
+		DBPort:            getEnv("DB_PORT", "5432"),
+		DBUser:            getEnv("DB_USER", "postgres"),
+		DBPassword:        getEnv("DB_PASSWORD", "password"),
+		DBName:            getEnv("DB_NAME", "marketplace_tracker"),
+		DatabaseURL:       getEnv("DATABASE_URL", ""),
+		DBSSLMode:         getEnv("DB_SSLMODE", "disable"),
+		DBSSLRootCert:     getEnv("DB_SSLROOTCERT", ""),
+		DBSSLCert:         getEnv("DB_SSLCERT", ""),
+		DBSSLKey:          getEnv("DB_SSLKEY", ""),
+		DBMaxOpenConns:    getEnvInt("DB_MAX_OPEN_CONNS", 25),
+		DBMaxIdleConns:    getEnvInt("DB_MAX_IDLE_CONNS", 25),
+		DBConnMaxLifetime: getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+		DBConnMaxIdleTime: getEnvDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+		DBConnectRetries:  getEnvInt("DB_CONNECT_RETRIES", 10),
+		DBConnectBackoff:  getEnvDuration("DB_CONNECT_BACKOFF", time.Second),
+		JWTSecret:         getEnv("JWT_SECRET", "default-secret-key-change-in-production"),
+		AccessTokenTTL:    getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL:   getEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		AdminRequire2FA:   getEnvBool("ADMIN_REQUIRE_2FA", false),
+		EncryptionKey:     getEnv("ENCRYPTION_KEY", "default-encryption-key-change-in-production"),
+		AllowOrigins:      getEnv("ALLOW_ORIGINS", ""),
+		AppURL:            getEnv("APP_URL", "http://localhost:3000"),
+		Mailer:            getEnv("MAILER", "log"),
+		MailDir:           getEnv("MAIL_DIR", "mail"),
+		MailFrom:          getEnv("MAIL_FROM", "Marketplace Tracker <no-reply@localhost>"),
+		SMTPHost:          getEnv("SMTP_HOST", ""),
+		SMTPPort:          getEnv("SMTP_PORT", "587"),
+		SMTPUsername:      getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:      getEnv("SMTP_PASSWORD", ""),
+		Port:              getEnv("PORT", "8080"),
+
+		LoginAttemptStore:    getEnv("LOGIN_ATTEMPT_STORE", "memory"),
+		LoginMaxFailures:     getEnvInt("LOGIN_MAX_FAILURES", 5),
+		LoginIPMaxFailures:   getEnvInt("LOGIN_IP_MAX_FAILURES", 20),
+		LoginFailureWindow:   getEnvDuration("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+		LoginLockoutDuration: getEnvDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		LoginDelayBase:       getEnvDuration("LOGIN_DELAY_BASE", time.Second),
+		LoginDelayMax:        getEnvDuration("LOGIN_DELAY_MAX", 30*time.Second),
+
+		EncryptionKeys:  getEnv("ENCRYPTION_KEYS", ""),
+		EncryptionKeyID: getEnv("ENCRYPTION_KEY_ID", ""),
+
+		SecretStore:     getEnv("SECRET_STORE", "database"),
+		SecretsDir:      getEnv("SECRETS_DIR", "secrets"),
+		VaultAddr:       getEnv("VAULT_ADDR", ""),
+		VaultToken:      getEnv("VAULT_TOKEN", ""),
+		VaultNamespace:  getEnv("VAULT_NAMESPACE", ""),
+		VaultKVMount:    getEnv("VAULT_KV_MOUNT", "secret"),
+		VaultPathPrefix: getEnv("VAULT_PATH_PREFIX", "marketplace-tracker/stores"),
+
+		AdminDefaultPassword:   getEnv("ADMIN_DEFAULT_PASSWORD", ""),
+		AdminBootstrapToken:    getEnv("ADMIN_BOOTSTRAP_TOKEN", ""),
+		AdminBootstrapTokenTTL: getEnvDuration("ADMIN_BOOTSTRAP_TOKEN_TTL", 24*time.Hour),
+
+		RequireEmailVerification: getEnvBool("REQUIRE_EMAIL_VERIFICATION", false),
+
+		ImpersonationTTL: getEnvDuration("IMPERSONATION_TTL", 15*time.Minute),
+
+		StatsCacheTTL: getEnvDuration("STATS_CACHE_TTL", time.Minute),
+
+		RateLimitStore:         getEnv("RATE_LIMIT_STORE", "memory"),
+		RateLimitUser:          getEnvInt("RATE_LIMIT_USER", 300),
+		RateLimitUserBurst:     getEnvInt("RATE_LIMIT_USER_BURST", 60),
+		RateLimitAPIKey:        getEnvInt("RATE_LIMIT_API_KEY", 120),
+		RateLimitAPIKeyBurst:   getEnvInt("RATE_LIMIT_API_KEY_BURST", 30),
+		RateLimitIP:            getEnvInt("RATE_LIMIT_IP", 60),
+		RateLimitIPBurst:       getEnvInt("RATE_LIMIT_IP_BURST", 20),
+		RateLimitProducts:      getEnvInt("RATE_LIMIT_PRODUCTS", 6),
+		RateLimitProductsBurst: getEnvInt("RATE_LIMIT_PRODUCTS_BURST", 3),
 
-	// Validate configuration after loading
-	cfg.Validate()
+		LogLevel:  getEnv("LOG_LEVEL", "info"),
+		LogFormat: getEnv("LOG_FORMAT", "json"),
+
+		MetricsAddr:  getEnv("METRICS_ADDR", ""),
+		MetricsToken: getEnv("METRICS_TOKEN", ""),
+
+		TrustedProxies: getEnv("TRUSTED_PROXIES", ""),
+	}
 
 	return cfg
 }
@@ -54,4 +325,45 @@ func getEnv(key, defaultValue string) string {
 		return value
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Warn("Environment variable must be an integer, using default", "key", key, "default", defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// getEnvBool читает логическое значение в формате strconv.ParseBool ("true", "1", "false", ...)
+func getEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		slog.Warn("Environment variable must be true or false, using default", "key", key, "default", defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// getEnvDuration читает длительность в формате time.ParseDuration (например, "30s", "5m")
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Warn("Environment variable must be a duration like 30s or 5m, using default", "key", key, "default", defaultValue.String())
+		return defaultValue
+	}
+	return parsed
+}
@@ -1,100 +1,204 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
-	"log"
+	"log/slog"
+	"strings"
+	"time"
 	"kursovaya_backend/internal/config"
 	_ "github.com/lib/pq"
+	"modernc.org/sqlite"
 )
 
-var DB *sql.DB
+// DBTX - общий набор методов *sql.DB и *sql.Tx, через который работают репозитории
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+const (
+	// DriverPostgres - PostgreSQL через lib/pq (по умолчанию)
+	DriverPostgres = "postgres"
+	// DriverSQLite - встроенная SQLite на чистом Go для локального запуска
+	DriverSQLite = "sqlite"
+)
 
-func Connect(cfg *config.Config) {
+// maxConnectBackoff ограничивает паузу между попытками подключения
+const maxConnectBackoff = 30 * time.Second
+
+// Connect открывает соединение с выбранной базой данных, дожидается ее
+// готовности и применяет миграции
+func Connect(ctx context.Context, cfg *config.Config) (*sql.DB, error) {
+	var db *sql.DB
 	var err error
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName)
 
-	DB, err = sql.Open("postgres", connStr)
+	switch cfg.DBDriver {
+	case DriverPostgres, "":
+		db, err = openPostgres(cfg)
+	case DriverSQLite:
+		db, err = openSQLite(cfg)
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %s", cfg.DBDriver)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err := pingWithRetry(ctx, db.PingContext, cfg.DBConnectRetries, cfg.DBConnectBackoff); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "Connected to database", "driver", driverName(cfg.DBDriver))
+
+	// Применяем миграции схемы
+	if err := Migrate(ctx, db, cfg.DBDriver); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+	slog.InfoContext(ctx, "Database schema is up to date")
+
+	return db, nil
+}
+
+// pingWithRetry проверяет соединение, повторяя попытки с экспоненциальной паузой
+func pingWithRetry(ctx context.Context, ping func(ctx context.Context) error, attempts int, backoff time.Duration) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	delay := backoff
+	for attempt := 1; ; attempt++ {
+		err := ping(ctx)
+		if err == nil {
+			return nil
+		}
+		if attempt >= attempts {
+			return fmt.Errorf("database is unavailable after %d attempts: %w", attempt, err)
+		}
+
+		slog.WarnContext(ctx, "Database is not ready, retrying", "attempt", attempt, "attempts", attempts, "retry_in", delay.String(), "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > maxConnectBackoff {
+			delay = maxConnectBackoff
+		}
+	}
+}
+
+func openPostgres(cfg *config.Config) (*sql.DB, error) {
+	db, err := sql.Open("postgres", PostgresDSN(cfg))
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	db.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	db.SetConnMaxLifetime(cfg.DBConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.DBConnMaxIdleTime)
+	return db, nil
+}
+
+// PostgresDSN возвращает DATABASE_URL, если он задан, иначе собирает
+// строку подключения из отдельных параметров
+func PostgresDSN(cfg *config.Config) string {
+	if cfg.DatabaseURL != "" {
+		return cfg.DatabaseURL
 	}
 
-	if err = DB.Ping(); err != nil {
-		log.Fatal("Failed to ping database:", err)
+	sslMode := cfg.DBSSLMode
+	if sslMode == "" {
+		sslMode = "disable"
 	}
 
-	log.Println("Connected to PostgreSQL database")
+	params := []struct{ key, value string }{
+		{"host", cfg.DBHost},
+		{"port", cfg.DBPort},
+		{"user", cfg.DBUser},
+		{"password", cfg.DBPassword},
+		{"dbname", cfg.DBName},
+		{"sslmode", sslMode},
+		{"sslrootcert", cfg.DBSSLRootCert},
+		{"sslcert", cfg.DBSSLCert},
+		{"sslkey", cfg.DBSSLKey},
+	}
+
+	var parts []string
+	for _, param := range params {
+		if param.value == "" {
+			continue
+		}
+		parts = append(parts, param.key+"="+quoteDSNValue(param.value))
+	}
+	return strings.Join(parts, " ")
+}
 
-	// Создаем таблицы
-	createTables()
+// quoteDSNValue экранирует значение для формата key=value библиотеки lib/pq
+func quoteDSNValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+	return "'" + escaped + "'"
 }
 
-func createTables() {
-	// Таблица пользователей
-	userTable := `
-	CREATE TABLE IF NOT EXISTS users (
-		id SERIAL PRIMARY KEY,
-		email VARCHAR(255) UNIQUE NOT NULL,
-		password TEXT NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	);`
-
-	// Таблица магазинов
-	storeTable := `
-	CREATE TABLE IF NOT EXISTS stores (
-		id SERIAL PRIMARY KEY,
-		user_id INTEGER NOT NULL,
-		store_type VARCHAR(50) NOT NULL,  -- 'wb' или 'ozon'
-		api_token TEXT NOT NULL,          -- зашифрованный токен
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id)
-	);`
-
-	// Таблица товаров
-	productTable := `
-	CREATE TABLE IF NOT EXISTS products (
-		id SERIAL PRIMARY KEY,
-		store_id INTEGER NOT NULL,
-		external_id VARCHAR(255) NOT NULL,  -- ID товара в WB/Ozon
-		name TEXT NOT NULL,
-		price INTEGER,
-		quantity INTEGER,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		CONSTRAINT fk_store FOREIGN KEY(store_id) REFERENCES stores(id)
-	);`
-
-	// Таблица сопоставлений
-	mappingTable := `
-	CREATE TABLE IF NOT EXISTS product_mappings (
-		id SERIAL PRIMARY KEY,
-		product1_id INTEGER NOT NULL,  -- Товар из WB
-		product2_id INTEGER NOT NULL,  -- Товар из Ozon
-		user_id INTEGER NOT NULL,      -- Кому принадлежит
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		UNIQUE(product1_id, product2_id),  -- Один товар не может быть сопоставлен дважды
-		CONSTRAINT fk_product1 FOREIGN KEY(product1_id) REFERENCES products(id),
-		CONSTRAINT fk_product2 FOREIGN KEY(product2_id) REFERENCES products(id),
-		CONSTRAINT fk_mapping_user FOREIGN KEY(user_id) REFERENCES users(id)
-	);`
-
-	// Таблица администраторов
-	adminTable := `
-	CREATE TABLE IF NOT EXISTS admins (
-		id SERIAL PRIMARY KEY,
-		username VARCHAR(255) UNIQUE NOT NULL,
-		password TEXT NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	);`
-
-	// Выполняем создание таблиц
-	for _, query := range []string{userTable, storeTable, productTable, mappingTable, adminTable} {
-		_, err := DB.Exec(query)
-		if err != nil {
-			log.Fatal("Failed to create table:", err)
+func init() {
+	// Встроенная LOWER в SQLite меняет регистр только латиницы. Поиск без учета
+	// регистра должен работать и для кириллицы, как в PostgreSQL.
+	sqlite.MustRegisterDeterministicScalarFunction("lower", 1, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		switch value := args[0].(type) {
+		case nil:
+			return nil, nil
+		case string:
+			return strings.ToLower(value), nil
+		case []byte:
+			return strings.ToLower(string(value)), nil
+		default:
+			return strings.ToLower(fmt.Sprint(value)), nil
 		}
+	})
+}
+
+// DriverOf возвращает драйвер (DriverPostgres или DriverSQLite), через
+// который открыта db
+func DriverOf(db *sql.DB) string {
+	if _, ok := db.Driver().(*sqlite.Driver); ok {
+		return DriverSQLite
+	}
+	return DriverPostgres
+}
+
+// OpenSQLite открывает файл SQLite с включенными внешними ключами
+func OpenSQLite(path string) (*sql.DB, error) {
+	// _txlock=immediate берет блокировку на запись в начале транзакции,
+	// busy_timeout заставляет конкурирующие соединения ждать, а не падать
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_txlock=immediate", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
 	}
 
-	log.Println("Database tables created successfully")
-}
\ No newline at end of file
+	// SQLite допускает только одного писателя, поэтому используем одно соединение
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+func openSQLite(cfg *config.Config) (*sql.DB, error) {
+	return OpenSQLite(cfg.SQLitePath)
+}
+
+func driverName(driver string) string {
+	if driver == DriverSQLite {
+		return "SQLite"
+	}
+	return "PostgreSQL"
+}
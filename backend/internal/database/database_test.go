@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kursovaya_backend/internal/config"
+)
+
+// Тест сборки строки подключения из отдельных параметров
+func TestPostgresDSNFromParts(t *testing.T) {
+	// Подготовка
+	cfg := &config.Config{
+		DBHost:        "db.internal",
+		DBPort:        "5432",
+		DBUser:        "tracker",
+		DBPassword:    "p@ss word'",
+		DBName:        "marketplace_tracker",
+		DBSSLMode:     "verify-full",
+		DBSSLRootCert: "/certs/ca.pem",
+	}
+
+	// Выполнение
+	dsn := PostgresDSN(cfg)
+
+	// Проверка
+	expected := `host=db.internal port=5432 user=tracker password='p@ss word\'' dbname=marketplace_tracker sslmode=verify-full sslrootcert=/certs/ca.pem`
+	if dsn != expected {
+		t.Errorf("Ожидается DSN %q, получен %q", expected, dsn)
+	}
+}
+
+// Тест приоритета DATABASE_URL над отдельными параметрами
+func TestPostgresDSNPrefersDatabaseURL(t *testing.T) {
+	cfg := &config.Config{
+		DatabaseURL: "postgres://user:secret@db:5432/app?sslmode=require",
+		DBHost:      "ignored",
+	}
+
+	if dsn := PostgresDSN(cfg); dsn != cfg.DatabaseURL {
+		t.Errorf("Ожидается DATABASE_URL, получен %q", dsn)
+	}
+}
+
+// Тест повторных попыток подключения, пока база не станет доступна
+func TestPingWithRetryEventuallySucceeds(t *testing.T) {
+	// Подготовка
+	calls := 0
+	ping := func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+
+	// Выполнение
+	err := pingWithRetry(context.Background(), ping, 5, time.Millisecond)
+
+	// Проверка
+	if err != nil {
+		t.Errorf("Ожидается успешное подключение, получена ошибка: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Ожидается 3 попытки, выполнено %d", calls)
+	}
+}
+
+// Тест ошибки после исчерпания попыток
+func TestPingWithRetryGivesUp(t *testing.T) {
+	// Подготовка
+	calls := 0
+	refused := errors.New("connection refused")
+	ping := func(ctx context.Context) error {
+		calls++
+		return refused
+	}
+
+	// Выполнение
+	err := pingWithRetry(context.Background(), ping, 3, time.Millisecond)
+
+	// Проверка
+	if !errors.Is(err, refused) {
+		t.Errorf("Ожидается исходная ошибка подключения, получена %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Ожидается 3 попытки, выполнено %d", calls)
+	}
+}
@@ -0,0 +1,487 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// migration - версия схемы, применяемая один раз в отдельной транзакции
+type migration struct {
+	version    int
+	name       string
+	statements []string
+	// migrate переносит данные после выполнения statements (необязательно)
+	migrate func(ctx context.Context, tx *sql.Tx) error
+}
+
+// migrations - история изменений схемы. Новые миграции добавляются только в конец,
+// уже примененные миграции менять нельзя.
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create_tables",
+		statements: []string{
+			// Таблица пользователей
+			`CREATE TABLE IF NOT EXISTS users (
+				id SERIAL PRIMARY KEY,
+				email VARCHAR(255) UNIQUE NOT NULL,
+				password TEXT NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`,
+			// Таблица магазинов
+			`CREATE TABLE IF NOT EXISTS stores (
+				id SERIAL PRIMARY KEY,
+				user_id INTEGER NOT NULL,
+				store_type VARCHAR(50) NOT NULL,  -- 'wb' или 'ozon'
+				api_token TEXT NOT NULL,          -- зашифрованный токен
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id)
+			)`,
+			// Таблица товаров
+			`CREATE TABLE IF NOT EXISTS products (
+				id SERIAL PRIMARY KEY,
+				store_id INTEGER NOT NULL,
+				external_id VARCHAR(255) NOT NULL,  -- ID товара в WB/Ozon
+				name TEXT NOT NULL,
+				price INTEGER,
+				quantity INTEGER,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				CONSTRAINT fk_store FOREIGN KEY(store_id) REFERENCES stores(id)
+			)`,
+			// Таблица сопоставлений
+			`CREATE TABLE IF NOT EXISTS product_mappings (
+				id SERIAL PRIMARY KEY,
+				product1_id INTEGER NOT NULL,  -- Товар из WB
+				product2_id INTEGER NOT NULL,  -- Товар из Ozon
+				user_id INTEGER NOT NULL,      -- Кому принадлежит
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				UNIQUE(product1_id, product2_id),  -- Один товар не может быть сопоставлен дважды
+				CONSTRAINT fk_product1 FOREIGN KEY(product1_id) REFERENCES products(id),
+				CONSTRAINT fk_product2 FOREIGN KEY(product2_id) REFERENCES products(id),
+				CONSTRAINT fk_mapping_user FOREIGN KEY(user_id) REFERENCES users(id)
+			)`,
+			// Таблица администраторов
+			`CREATE TABLE IF NOT EXISTS admins (
+				id SERIAL PRIMARY KEY,
+				username VARCHAR(255) UNIQUE NOT NULL,
+				password TEXT NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`,
+		},
+	},
+	{
+		version: 2,
+		name:    "add_lookup_indexes",
+		statements: []string{
+			// Товары магазина и поиск товара по ID маркетплейса
+			`CREATE INDEX IF NOT EXISTS idx_products_store_id ON products (store_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_products_external_id ON products (external_id)`,
+			// Магазины пользователя (используется в JOIN сохраненных товаров)
+			`CREATE INDEX IF NOT EXISTS idx_stores_user_id ON stores (user_id)`,
+			// Сопоставления пользователя от новых к старым
+			`CREATE INDEX IF NOT EXISTS idx_product_mappings_user_id ON product_mappings (user_id, created_at DESC)`,
+			// product1_id уже покрыт UNIQUE(product1_id, product2_id), нужен индекс для поиска по второму товару
+			`CREATE INDEX IF NOT EXISTS idx_product_mappings_product2_id ON product_mappings (product2_id)`,
+		},
+	},
+	{
+		version: 3,
+		name:    "create_sessions",
+		statements: []string{
+			// Refresh-токены пользователей. Каждая ротация добавляет строку в ту же
+			// семью (family_id), отзыв сессии помечает все строки семьи.
+			`CREATE TABLE IF NOT EXISTS sessions (
+				id SERIAL PRIMARY KEY,
+				user_id INTEGER NOT NULL,
+				family_id VARCHAR(64) NOT NULL,
+				token_hash VARCHAR(64) UNIQUE NOT NULL,  -- SHA-256 refresh-токена
+				expires_at TIMESTAMP NOT NULL,
+				used_at TIMESTAMP,                       -- токен обменян на новый
+				revoked_at TIMESTAMP,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				CONSTRAINT fk_session_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_sessions_family_id ON sessions (family_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id)`,
+		},
+	},
+	{
+		version: 4,
+		name:    "add_roles",
+		statements: []string{
+			// Роли из пакета rbac; существующие учетные записи сохраняют полный доступ
+			`ALTER TABLE users ADD COLUMN role VARCHAR(32) NOT NULL DEFAULT 'owner'`,
+			`ALTER TABLE admins ADD COLUMN role VARCHAR(32) NOT NULL DEFAULT 'superadmin'`,
+		},
+	},
+	{
+		version: 5,
+		name:    "create_organizations",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS organizations (
+				id SERIAL PRIMARY KEY,
+				name VARCHAR(255) NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`,
+			// Участники организации и их роли (viewer, editor, owner)
+			`CREATE TABLE IF NOT EXISTS organization_members (
+				organization_id INTEGER NOT NULL,
+				user_id INTEGER NOT NULL,
+				role VARCHAR(32) NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (organization_id, user_id),
+				CONSTRAINT fk_member_organization FOREIGN KEY(organization_id) REFERENCES organizations(id) ON DELETE CASCADE,
+				CONSTRAINT fk_member_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_organization_members_user_id ON organization_members (user_id)`,
+			// Приглашения по email, хранится только SHA-256 токена
+			`CREATE TABLE IF NOT EXISTS organization_invitations (
+				id SERIAL PRIMARY KEY,
+				organization_id INTEGER NOT NULL,
+				email VARCHAR(255) NOT NULL,
+				role VARCHAR(32) NOT NULL,
+				token_hash VARCHAR(64) UNIQUE NOT NULL,
+				invited_by INTEGER NOT NULL,
+				expires_at TIMESTAMP NOT NULL,
+				accepted_at TIMESTAMP,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				CONSTRAINT fk_invitation_organization FOREIGN KEY(organization_id) REFERENCES organizations(id) ON DELETE CASCADE
+			)`,
+			// Магазины и сопоставления принадлежат организации, user_id остается автором
+			`ALTER TABLE stores ADD COLUMN organization_id INTEGER REFERENCES organizations(id)`,
+			`ALTER TABLE product_mappings ADD COLUMN organization_id INTEGER REFERENCES organizations(id)`,
+			`CREATE INDEX IF NOT EXISTS idx_stores_organization_id ON stores (organization_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_product_mappings_organization_id ON product_mappings (organization_id, created_at DESC)`,
+		},
+		migrate: createPersonalOrganizations,
+	},
+	{
+		version: 6,
+		name:    "create_two_factor",
+		statements: []string{
+			// Настройки TOTP пользователей и администраторов, subject_type - "user" или "admin".
+			// Секрет хранится зашифрованным, last_used_step защищает от повторного использования кода.
+			`CREATE TABLE IF NOT EXISTS two_factor (
+				subject_type VARCHAR(16) NOT NULL,
+				subject_id INTEGER NOT NULL,
+				secret TEXT NOT NULL,
+				confirmed_at TIMESTAMP,
+				last_used_step BIGINT NOT NULL DEFAULT 0,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (subject_type, subject_id)
+			)`,
+			// Одноразовые коды восстановления, хранится только SHA-256
+			`CREATE TABLE IF NOT EXISTS recovery_codes (
+				id SERIAL PRIMARY KEY,
+				subject_type VARCHAR(16) NOT NULL,
+				subject_id INTEGER NOT NULL,
+				code_hash VARCHAR(64) NOT NULL,
+				used_at TIMESTAMP,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_recovery_codes_subject ON recovery_codes (subject_type, subject_id)`,
+		},
+	},
+	{
+		version: 7,
+		name:    "create_user_tokens",
+		statements: []string{
+			`ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP`,
+			// Существующие пользователи регистрировались без подтверждения и
+			// не получали писем, поэтому считаются подтвержденными, иначе
+			// REQUIRE_EMAIL_VERIFICATION закрыл бы им доступ
+			`UPDATE users SET email_verified_at = COALESCE(created_at, CURRENT_TIMESTAMP) WHERE email_verified_at IS NULL`,
+			// Одноразовые токены сброса пароля и подтверждения email, хранится только SHA-256
+			`CREATE TABLE IF NOT EXISTS user_tokens (
+				id SERIAL PRIMARY KEY,
+				user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				purpose VARCHAR(32) NOT NULL,
+				token_hash VARCHAR(64) UNIQUE NOT NULL,
+				expires_at TIMESTAMP NOT NULL,
+				used_at TIMESTAMP,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_user_tokens_user_id ON user_tokens (user_id, purpose)`,
+		},
+	},
+	{
+		version: 8,
+		name:    "create_login_attempts",
+		statements: []string{
+			// Неудачные попытки входа по учетным записям и IP-адресам для защиты от перебора паролей
+			`CREATE TABLE IF NOT EXISTS login_attempts (
+				id SERIAL PRIMARY KEY,
+				attempt_key VARCHAR(320) UNIQUE NOT NULL,
+				failures INTEGER NOT NULL DEFAULT 0,
+				last_failure_at TIMESTAMP NOT NULL,
+				locked_until TIMESTAMP
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_login_attempts_last_failure_at ON login_attempts (last_failure_at)`,
+		},
+	},
+	{
+		version: 9,
+		name:    "create_api_keys",
+		statements: []string{
+			// Персональные API-ключи, хранится только SHA-256; scopes - разрешения через запятую
+			`CREATE TABLE IF NOT EXISTS api_keys (
+				id SERIAL PRIMARY KEY,
+				user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				name VARCHAR(100) NOT NULL,
+				prefix VARCHAR(16) NOT NULL,
+				key_hash VARCHAR(64) UNIQUE NOT NULL,
+				scopes TEXT NOT NULL,
+				expires_at TIMESTAMP,
+				last_used_at TIMESTAMP,
+				revoked_at TIMESTAMP,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys (user_id)`,
+		},
+	},
+	{
+		version: 10,
+		name:    "admin_accounts",
+		statements: []string{
+			`ALTER TABLE admins ADD COLUMN must_change_password BOOLEAN NOT NULL DEFAULT FALSE`,
+			`ALTER TABLE admins ADD COLUMN disabled_at TIMESTAMP`,
+			// Одноразовые токены создания первого администратора, хранится только SHA-256
+			`CREATE TABLE IF NOT EXISTS admin_bootstrap_tokens (
+				id SERIAL PRIMARY KEY,
+				token_hash VARCHAR(64) UNIQUE NOT NULL,
+				expires_at TIMESTAMP NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`,
+		},
+	},
+	{
+		version: 11,
+		name:    "audit_events",
+		statements: []string{
+			// Журнал аудита только дополняется. Внешних ключей нет намеренно:
+			// события должны пережить удаление пользователей и магазинов.
+			`CREATE TABLE IF NOT EXISTS audit_events (
+				id SERIAL PRIMARY KEY,
+				actor_type VARCHAR(20) NOT NULL,     -- 'user', 'admin', 'api_key' или 'system'
+				actor_id INTEGER,                    -- пользователь или администратор
+				api_key_id INTEGER,                  -- ключ, если действие выполнено по API-ключу
+				action VARCHAR(100) NOT NULL,
+				target_type VARCHAR(50) NOT NULL DEFAULT '',
+				target_id VARCHAR(255) NOT NULL DEFAULT '',
+				before_state TEXT,                   -- JSON до изменения
+				after_state TEXT,                    -- JSON после изменения
+				ip VARCHAR(64) NOT NULL DEFAULT '',
+				user_agent TEXT NOT NULL DEFAULT '',
+				created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at)`,
+			`CREATE INDEX IF NOT EXISTS idx_audit_events_actor ON audit_events (actor_type, actor_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_audit_events_target ON audit_events (target_type, target_id)`,
+		},
+	},
+	{
+		version: 12,
+		name:    "user_status",
+		statements: []string{
+			// Статус 'active', 'suspended' или 'deleted'; ожидание подтверждения
+			// email определяется по email_verified_at у активных пользователей
+			`ALTER TABLE users ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'active'`,
+			`ALTER TABLE users ADD COLUMN status_reason TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE users ADD COLUMN status_changed_at TIMESTAMP`,
+			// Время удаления персональных данных по запросу пользователя
+			`ALTER TABLE users ADD COLUMN erased_at TIMESTAMP`,
+			`CREATE INDEX IF NOT EXISTS idx_users_status ON users (status)`,
+		},
+	},
+	{
+		version: 13,
+		name:    "audit_impersonator",
+		statements: []string{
+			// Администратор, выполнивший действие от имени пользователя
+			`ALTER TABLE audit_events ADD COLUMN impersonator_id INTEGER`,
+		},
+	},
+	{
+		version: 14,
+		name:    "sync_runs",
+		statements: []string{
+			// Результаты загрузки товаров магазинов для статистики. Внешнего ключа
+			// нет: статистика по маркетплейсам учитывает и удаленные магазины.
+			`CREATE TABLE IF NOT EXISTS sync_runs (
+				id SERIAL PRIMARY KEY,
+				store_id INTEGER NOT NULL,
+				store_type VARCHAR(50) NOT NULL,
+				status VARCHAR(20) NOT NULL,                     -- 'success' или 'failed'
+				error_category VARCHAR(50) NOT NULL DEFAULT '',
+				products INTEGER NOT NULL DEFAULT 0,
+				duration_ms BIGINT NOT NULL DEFAULT 0,
+				started_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_sync_runs_started_at ON sync_runs (started_at)`,
+			`CREATE INDEX IF NOT EXISTS idx_sync_runs_store_id ON sync_runs (store_id, status)`,
+		},
+	},
+	{
+		version: 15,
+		name:    "rate_limits",
+		statements: []string{
+			// Корзины токенов ограничения частоты запросов, общие для реплик.
+			// Время хранится в миллисекундах Unix для одинаковой арифметики в
+			// PostgreSQL и SQLite; allowed - результат последнего списания,
+			// который возвращает тот же запрос, что пополняет корзину.
+			`CREATE TABLE IF NOT EXISTS rate_limits (
+				bucket_key VARCHAR(255) PRIMARY KEY,
+				tokens DOUBLE PRECISION NOT NULL,
+				allowed INTEGER NOT NULL DEFAULT 1,
+				updated_at_ms BIGINT NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_rate_limits_updated_at ON rate_limits (updated_at_ms)`,
+		},
+	},
+	{
+		version: 16,
+		name:    "admin_token_version",
+		statements: []string{
+			// Версия токенов администратора: токены с другой версией не
+			// принимаются, поэтому смена пароля и отключение отзывают их
+			`ALTER TABLE admins ADD COLUMN token_version INTEGER NOT NULL DEFAULT 0`,
+		},
+	},
+}
+
+// createPersonalOrganizations создает каждому существующему пользователю личную
+// организацию, где он владелец, и переносит в нее его магазины и сопоставления
+func createPersonalOrganizations(ctx context.Context, tx *sql.Tx) error {
+	type user struct {
+		id    int
+		email string
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, email FROM users
+		WHERE id NOT IN (SELECT user_id FROM organization_members)
+		ORDER BY id
+	`)
+	if err != nil {
+		return err
+	}
+	var users []user
+	for rows.Next() {
+		var u user
+		if err := rows.Scan(&u.id, &u.email); err != nil {
+			rows.Close()
+			return err
+		}
+		users = append(users, u)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, u := range users {
+		var organizationID int
+		if err := tx.QueryRowContext(ctx,
+			"INSERT INTO organizations (name) VALUES ($1) RETURNING id", u.email,
+		).Scan(&organizationID); err != nil {
+			return err
+		}
+
+		statements := []string{
+			"INSERT INTO organization_members (organization_id, user_id, role) VALUES ($1, $2, 'owner')",
+			"UPDATE stores SET organization_id = $1 WHERE user_id = $2 AND organization_id IS NULL",
+			"UPDATE product_mappings SET organization_id = $1 WHERE user_id = $2 AND organization_id IS NULL",
+		}
+		for _, statement := range statements {
+			if _, err := tx.ExecContext(ctx, statement, organizationID, u.id); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Migrate применяет все еще не примененные миграции по порядку
+func Migrate(ctx context.Context, db *sql.DB, driver string) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	applied := make(map[int]bool)
+	rows, err := db.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return err
+		}
+		applied[version] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+		if err := applyMigration(ctx, db, driver, m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+		slog.InfoContext(ctx, "Applied database migration", "version", m.version, "name", m.name)
+	}
+
+	return nil
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, driver string, m migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range m.statements {
+		if _, err := tx.ExecContext(ctx, dialect(statement, driver)); err != nil {
+			return err
+		}
+	}
+
+	if m.migrate != nil {
+		if err := m.migrate(ctx, tx); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO schema_migrations (version, name) VALUES ($1, $2)",
+		m.version, m.name,
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// dialect адаптирует DDL, написанный для PostgreSQL, под выбранный драйвер.
+// Схема отличается только типом автоинкрементного ключа.
+func dialect(query, driver string) string {
+	if driver == DriverSQLite {
+		return strings.ReplaceAll(query, "SERIAL PRIMARY KEY", "INTEGER PRIMARY KEY AUTOINCREMENT")
+	}
+	return query
+}
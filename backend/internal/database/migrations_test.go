@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+// Тест переноса существующих магазинов и сопоставлений в личные организации
+func TestMigrateCreatesPersonalOrganizations(t *testing.T) {
+	// Подготовка: схема до появления организаций с данными одного пользователя
+	ctx := context.Background()
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "migrate.db"))
+	if err != nil {
+		t.Fatalf("Ошибка открытия SQLite: %v", err)
+	}
+	defer db.Close()
+
+	all := migrations
+	migrations = all[:4]
+	err = Migrate(ctx, db, DriverSQLite)
+	migrations = all
+	if err != nil {
+		t.Fatalf("Ошибка применения миграций: %v", err)
+	}
+
+	statements := []string{
+		"INSERT INTO users (email, password) VALUES ('owner@example.com', 'hash')",
+		"INSERT INTO stores (user_id, store_type, api_token) VALUES (1, 'wb', 'token')",
+		"INSERT INTO stores (user_id, store_type, api_token) VALUES (1, 'ozon', 'token')",
+		"INSERT INTO products (store_id, external_id, name, price, quantity) VALUES (1, 'a', 'A', 1, 1)",
+		"INSERT INTO products (store_id, external_id, name, price, quantity) VALUES (2, 'b', 'B', 1, 1)",
+		"INSERT INTO product_mappings (product1_id, product2_id, user_id) VALUES (1, 2, 1)",
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("Ошибка подготовки данных: %v", err)
+		}
+	}
+
+	// Выполнение
+	if err := Migrate(ctx, db, DriverSQLite); err != nil {
+		t.Fatalf("Ошибка применения миграции организаций: %v", err)
+	}
+
+	// Проверка
+	var organizationID int
+	var name, role string
+	err = db.QueryRow(`
+		SELECT o.id, o.name, m.role FROM organizations o
+		JOIN organization_members m ON m.organization_id = o.id
+		WHERE m.user_id = 1
+	`).Scan(&organizationID, &name, &role)
+	if err != nil {
+		t.Fatalf("Ожидается личная организация пользователя: %v", err)
+	}
+	if name != "owner@example.com" || role != "owner" {
+		t.Errorf("Ожидается организация owner@example.com с ролью owner, получено %q, %q", name, role)
+	}
+
+	for _, table := range []string{"stores", "product_mappings"} {
+		var unassigned int
+		if err := db.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE organization_id IS NULL OR organization_id != $1", organizationID).Scan(&unassigned); err != nil {
+			t.Fatalf("Ошибка проверки %s: %v", table, err)
+		}
+		if unassigned != 0 {
+			t.Errorf("Ожидается, что все записи %s перенесены в организацию, не перенесено %d", table, unassigned)
+		}
+	}
+}
+
+// Тест подтверждения email пользователей, зарегистрированных до его появления
+func TestMigrateVerifiesExistingUsers(t *testing.T) {
+	ctx := context.Background()
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "migrate.db"))
+	if err != nil {
+		t.Fatalf("Ошибка открытия SQLite: %v", err)
+	}
+	defer db.Close()
+
+	all := migrations
+	migrations = all[:6]
+	err = Migrate(ctx, db, DriverSQLite)
+	migrations = all
+	if err != nil {
+		t.Fatalf("Ошибка применения миграций: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO users (email, password) VALUES ('legacy@example.com', 'hash')"); err != nil {
+		t.Fatalf("Ошибка подготовки данных: %v", err)
+	}
+
+	if err := Migrate(ctx, db, DriverSQLite); err != nil {
+		t.Fatalf("Ошибка применения миграций: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO users (email, password) VALUES ('new@example.com', 'hash')"); err != nil {
+		t.Fatalf("Ошибка создания пользователя: %v", err)
+	}
+
+	var legacy, created bool
+	err = db.QueryRow(`SELECT
+		EXISTS (SELECT 1 FROM users WHERE email = 'legacy@example.com' AND email_verified_at IS NOT NULL),
+		EXISTS (SELECT 1 FROM users WHERE email = 'new@example.com' AND email_verified_at IS NULL)`,
+	).Scan(&legacy, &created)
+	if err != nil {
+		t.Fatalf("Ошибка проверки пользователей: %v", err)
+	}
+	if !legacy || !created {
+		t.Errorf("Ожидается подтвержденный email только у существующего пользователя: существующий %t, новый без подтверждения %t", legacy, created)
+	}
+}
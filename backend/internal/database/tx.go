@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// MaxTxAttempts - сколько раз транзакция перезапускается при конфликте сериализации
+const MaxTxAttempts = 5
+
+// RunInTx выполняет fn в транзакции с уровнем изоляции SERIALIZABLE.
+// Если fn возвращает ошибку, транзакция откатывается; при конфликте
+// сериализации или взаимной блокировке транзакция выполняется заново.
+// Поэтому fn может быть вызвана несколько раз: она не должна иметь побочных
+// эффектов вне транзакции, а переменные, которые она заполняет, должна
+// сбрасывать в начале каждого вызова.
+func RunInTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	return retryOnConflict(ctx, MaxTxAttempts, func() error {
+		tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+		if err != nil {
+			return err
+		}
+
+		if err := fn(tx); err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+				slog.ErrorContext(ctx, "Ошибка отката транзакции", "error", rbErr)
+			}
+			return err
+		}
+
+		return tx.Commit()
+	})
+}
+
+// retryOnConflict повторяет fn, пока она завершается конфликтом сериализации
+func retryOnConflict(ctx context.Context, attempts int, fn func() error) error {
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err = fn()
+		if err == nil || !IsSerializationFailure(err) {
+			return err
+		}
+
+		// Небольшая нарастающая пауза, чтобы конкурирующая транзакция успела завершиться
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt*attempt) * 5 * time.Millisecond):
+		}
+	}
+	return fmt.Errorf("транзакция не выполнена после %d попыток: %w", attempts, err)
+}
+
+// IsSerializationFailure проверяет, что ошибка вызвана конфликтом параллельных транзакций
+func IsSerializationFailure(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// 40001 serialization_failure, 40P01 deadlock_detected
+		return pqErr.Code == "40001" || pqErr.Code == "40P01"
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		// База занята другим писателем дольше busy_timeout
+		code := sqliteErr.Code() & 0xff
+		return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+// Тест повторного выполнения транзакции при конфликте сериализации
+func TestRetryOnConflictRetriesSerializationFailure(t *testing.T) {
+	// Подготовка
+	calls := 0
+	fn := func() error {
+		calls++
+		if calls < 3 {
+			return &pq.Error{Code: "40001"}
+		}
+		return nil
+	}
+
+	// Выполнение
+	err := retryOnConflict(context.Background(), MaxTxAttempts, fn)
+
+	// Проверка
+	if err != nil {
+		t.Errorf("Ожидается успешное выполнение после повторов, получена ошибка: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Ожидается 3 попытки, выполнено %d", calls)
+	}
+}
+
+// Тест отсутствия повторов для обычных ошибок
+func TestRetryOnConflictStopsOnOtherErrors(t *testing.T) {
+	// Подготовка
+	calls := 0
+	expected := errors.New("бизнес-ошибка")
+
+	// Выполнение
+	err := retryOnConflict(context.Background(), MaxTxAttempts, func() error {
+		calls++
+		return expected
+	})
+
+	// Проверка
+	if !errors.Is(err, expected) {
+		t.Errorf("Ожидается исходная ошибка, получена %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Ожидается одна попытка, выполнено %d", calls)
+	}
+}
+
+// Тест ограничения количества попыток
+func TestRetryOnConflictGivesUp(t *testing.T) {
+	// Выполнение
+	err := retryOnConflict(context.Background(), 2, func() error {
+		return &pq.Error{Code: "40P01"}
+	})
+
+	// Проверка
+	if !IsSerializationFailure(err) {
+		t.Errorf("Ожидается ошибка конфликта после исчерпания попыток, получена %v", err)
+	}
+}
@@ -1,11 +1,13 @@
 package errors
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"math"
 	"net/http"
 	"runtime"
-	"strings"
+	"time"
 )
 
 // AppError - структура для хранения информации об ошибке
@@ -13,6 +15,8 @@ type AppError struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
 	Details string `json:"details,omitempty"`
+	// RetryAfter - через сколько секунд можно повторить запрос (заголовок Retry-After)
+	RetryAfter int `json:"-"`
 }
 
 // Error возвращает строковое представление ошибки
@@ -74,6 +78,16 @@ func InternalServerError(message string, details string) *AppError {
 	}
 }
 
+// TooManyRequests создает ошибку с кодом 429; retryAfter округляется вверх до секунд
+func TooManyRequests(message string, details string, retryAfter time.Duration) *AppError {
+	return &AppError{
+		Code:       http.StatusTooManyRequests,
+		Message:    message,
+		Details:    details,
+		RetryAfter: int(math.Ceil(retryAfter.Seconds())),
+	}
+}
+
 // ValidationError создает ошибку валидации с кодом 422
 func ValidationError(message string, details string) *AppError {
 	return &AppError{
@@ -83,39 +97,37 @@ func ValidationError(message string, details string) *AppError {
 	}
 }
 
-// LogError логирует ошибку с информацией о месте возникновения
-func LogError(err error) {
+// LogError записывает ошибку в лог с идентификатором запроса из ctx
+func LogError(ctx context.Context, err error) {
 	if err == nil {
 		return
 	}
-
-	// Получаем информацию о вызывающей функции
-	pc, file, line, ok := runtime.Caller(1)
-	if !ok {
-		log.Printf("[ERROR] %v\n", err)
-		return
-	}
-
-	fn := runtime.FuncForPC(pc)
-	fileName := strings.Split(file, "/") // Берем только имя файла, а не весь путь
-	log.Printf("[ERROR] %s:%d %s() - %v\n", fileName[len(fileName)-1], line, fn.Name(), err)
+	logRecord(ctx, slog.LevelError, err.Error())
 }
 
-// LogAppError логирует AppError с дополнительной информацией
-func LogAppError(err *AppError) {
+// LogAppError записывает AppError в лог: ошибки сервера с уровнем error,
+// ошибки клиента - с уровнем warn
+func LogAppError(ctx context.Context, err *AppError) {
 	if err == nil {
 		return
 	}
+	level := slog.LevelWarn
+	if err.Code >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	logRecord(ctx, level, err.Message, slog.Int("code", err.Code), slog.String("details", err.Details))
+}
 
-	// Получаем информацию о вызывающей функции
-	pc, file, line, ok := runtime.Caller(1)
-	if !ok {
-		log.Printf("[ERROR] code=%d, message=%s, details=%s\n", err.Code, err.Message, err.Details)
+// logRecord пишет запись от имени вызвавшего LogError или LogAppError, чтобы
+// на уровне debug в записи было его место в коде
+func logRecord(ctx context.Context, level slog.Level, message string, attrs ...slog.Attr) {
+	logger := slog.Default()
+	if !logger.Enabled(ctx, level) {
 		return
 	}
-
-	fn := runtime.FuncForPC(pc)
-	fileName := strings.Split(file, "/") // Берем только имя файла, а не весь путь
-	log.Printf("[ERROR] %s:%d %s() - code=%d, message=%s, details=%s\n", 
-		fileName[len(fileName)-1], line, fn.Name(), err.Code, err.Message, err.Details)
-}
\ No newline at end of file
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:]) // пропускаем Callers, logRecord и LogError/LogAppError
+	record := slog.NewRecord(time.Now(), level, message, pcs[0])
+	record.AddAttrs(attrs...)
+	_ = logger.Handler().Handle(ctx, record)
+}
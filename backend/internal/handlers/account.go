@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"kursovaya_backend/internal/mailer"
+	"kursovaya_backend/internal/service"
+)
+
+type AccountHandler struct {
+	accountService *service.AccountService
+}
+
+func NewAccountHandler(accountService *service.AccountService) *AccountHandler {
+	return &AccountHandler{
+		accountService: accountService,
+	}
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// ForgotPassword отправляет письмо для сброса пароля. Ответ одинаков для
+// зарегистрированных и неизвестных адресов.
+func (h *AccountHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+
+	if err := h.accountService.RequestPasswordReset(c.Request.Context(), req.Email, mailer.Language(c.GetHeader("Accept-Language"))); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Если адрес зарегистрирован, на него отправлено письмо со ссылкой для сброса пароля"})
+}
+
+// ResetPassword задает новый пароль по токену из письма
+func (h *AccountHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+
+	if err := h.accountService.ResetPassword(c.Request.Context(), req.Token, req.Password); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Пароль изменен, войдите с новым паролем"})
+}
+
+// VerifyEmail подтверждает email по токену из письма
+func (h *AccountHandler) VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+
+	if err := h.accountService.VerifyEmail(c.Request.Context(), req.Token); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email подтвержден"})
+}
+
+// ResendVerification повторно отправляет письмо для подтверждения email текущего пользователя
+func (h *AccountHandler) ResendVerification(c *gin.Context) {
+	if err := h.accountService.SendVerification(c.Request.Context(), c.GetInt("user_id"), mailer.Language(c.GetHeader("Accept-Language"))); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Письмо для подтверждения email отправлено"})
+}
@@ -1,15 +1,28 @@
 package handlers
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"kursovaya_backend/internal/errors"
+	"kursovaya_backend/internal/models"
 	"kursovaya_backend/internal/service"
 	"kursovaya_backend/pkg/utils"
 	"github.com/gin-gonic/gin"
 )
 
-type AdminHandler struct{}
+type AdminHandler struct {
+	adminService     *service.AdminService
+	twoFactorService *service.TwoFactorService
+	lockoutService   *service.LockoutService
+}
+
+func NewAdminHandler(adminService *service.AdminService, twoFactorService *service.TwoFactorService, lockoutService *service.LockoutService) *AdminHandler {
+	return &AdminHandler{
+		adminService:     adminService,
+		twoFactorService: twoFactorService,
+		lockoutService:   lockoutService,
+	}
+}
 
 type AdminLoginRequest struct {
 	Username string `json:"username" validate:"required"`
@@ -19,8 +32,9 @@ type AdminLoginRequest struct {
 type AdminLoginResponse struct {
 	Token  string `json:"token"`
 	Admin  struct {
-		ID       int    `json:"id"`
-		Username string `json:"username"`
+		ID                 int    `json:"id"`
+		Username           string `json:"username"`
+		MustChangePassword bool   `json:"must_change_password"`
 	} `json:"admin"`
 }
 
@@ -28,40 +42,96 @@ func (h *AdminHandler) Login(c *gin.Context) {
 	var req AdminLoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		appErr := errors.BadRequest("Некорректный формат данных", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
 	// Валидация структуры
 	if validationErrors := utils.ValidateStruct(&req); len(validationErrors) > 0 {
 		appErr := errors.ValidationError("Ошибка валидации данных", "")
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "errors": validationErrors})
+		errors.LogAppError(c.Request.Context(), appErr)
+		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "errors": validationErrors, "request_id": requestID(c)})
 		return
 	}
 
 	// Логируем попытку входа (без пароля для безопасности)
-	log.Printf("Попытка входа администратора с логином: %s", req.Username)
+	slog.InfoContext(c.Request.Context(), "Попытка входа администратора", "username", req.Username)
 
-	admin, err := service.AuthenticateAdmin(req.Username, req.Password)
+	// Заблокированная учетная запись или IP не проверяют пароль вовсе
+	if err := h.lockoutService.Check(c.Request.Context(), utils.SubjectAdmin, req.Username, c.ClientIP()); err != nil {
+		c.Error(err)
+		return
+	}
+
+	admin, err := h.adminService.AuthenticateAdmin(c.Request.Context(), req.Username, req.Password)
 	if err != nil {
+		if lockErr := h.lockoutService.Failure(c.Request.Context(), utils.SubjectAdmin, req.Username, c.ClientIP()); lockErr != nil {
+			errors.LogError(c.Request.Context(), lockErr)
+		}
 		appErr := errors.Unauthorized("Ошибка аутентификации администратора", err.Error())
-		errors.LogError(err)
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		errors.LogError(c.Request.Context(), err)
+		respondError(c, appErr)
+		return
+	}
+
+	slog.InfoContext(c.Request.Context(), "Успешная аутентификация администратора", "username", req.Username)
+	if err := h.lockoutService.Success(c.Request.Context(), utils.SubjectAdmin, req.Username); err != nil {
+		errors.LogError(c.Request.Context(), err)
+	}
+
+	// При включенной двухфакторной аутентификации токен выдается только после проверки кода
+	enabled, err := h.twoFactorService.Enabled(c.Request.Context(), utils.SubjectAdmin, admin.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if enabled {
+		challenge, err := h.twoFactorService.Challenge(utils.SubjectAdmin, admin.ID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, MFARequiredResponse{
+			MFARequired: true,
+			MFAToken:    challenge.Token,
+			ExpiresIn:   challenge.ExpiresIn,
+		})
 		return
 	}
 
-	log.Printf("Успешная аутентификация администратора: %s", req.Username)
+	respondWithAdminToken(c, admin)
+}
+
+// VerifyMFA завершает вход администратора по токену второго шага и коду
+func (h *AdminHandler) VerifyMFA(c *gin.Context) {
+	var req VerifyMFARequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+
+	adminID, err := h.twoFactorService.CompleteChallenge(c.Request.Context(), h.lockoutService, utils.SubjectAdmin, req.MFAToken, req.Code, c.ClientIP())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	admin, err := h.adminService.GetAdmin(c.Request.Context(), adminID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	respondWithAdminToken(c, admin)
+}
 
-	// Генерируем JWT токен для администратора
-	// Используем тот же формат, что и для обычных пользователей
-	token, err := utils.GenerateJWT(admin.ID, "admin")
+// respondWithAdminToken выдает токен администратора после успешного входа
+// или смены пароля
+func respondWithAdminToken(c *gin.Context, admin *models.Admin) {
+	// Генерируем JWT токен для администратора (аудитория администраторов)
+	token, err := utils.GenerateAdminToken(admin.ID, admin.Role, admin.TokenVersion)
 	if err != nil {
 		appErr := errors.InternalServerError("Ошибка генерации токена для администратора", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
@@ -70,6 +140,7 @@ func (h *AdminHandler) Login(c *gin.Context) {
 	}
 	resp.Admin.ID = admin.ID
 	resp.Admin.Username = admin.Username
+	resp.Admin.MustChangePassword = admin.MustChangePassword
 
 	c.JSON(http.StatusOK, resp)
 }
\ No newline at end of file
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/repository"
+)
+
+// AdminExportQuery holds the admin list filters and the export format.
+// Sorting and paging parameters are accepted but ignored: exports contain
+// every matching row ordered by id.
+type AdminExportQuery struct {
+	AdminListQuery
+	Format string `form:"format" validate:"omitempty,oneof=csv"`
+}
+
+// csvBatchWriter writes one batch of rows to the export
+type csvBatchWriter func(rows [][]string) error
+
+// ExportUsers streams users matching the list filters as CSV
+func (h *AdminManagementHandler) ExportUsers(c *gin.Context) {
+	header := []string{"id", "email", "role", "status", "status_reason", "email_verified_at", "created_at"}
+	exportCSV(c, "users", header, func(ctx context.Context, query repository.ListQuery, write csvBatchWriter) error {
+		return h.lists.ExportUsers(ctx, query, func(users []models.User) error {
+			rows := make([][]string, 0, len(users))
+			for _, user := range users {
+				rows = append(rows, []string{
+					strconv.Itoa(user.ID), csvText(user.Email), user.Role, user.Status, csvText(user.StatusReason),
+					csvTime(user.EmailVerifiedAt), csvTime(user.CreatedAt),
+				})
+			}
+			return write(rows)
+		})
+	})
+}
+
+// ExportStores streams stores matching the list filters as CSV. Tokens are never exported.
+func (h *AdminManagementHandler) ExportStores(c *gin.Context) {
+	header := []string{"id", "organization_id", "user_id", "store_type", "created_at"}
+	exportCSV(c, "stores", header, func(ctx context.Context, query repository.ListQuery, write csvBatchWriter) error {
+		return h.lists.ExportStores(ctx, query, func(stores []models.Store) error {
+			rows := make([][]string, 0, len(stores))
+			for _, store := range stores {
+				rows = append(rows, []string{
+					strconv.Itoa(store.ID), strconv.Itoa(store.OrganizationID), strconv.Itoa(store.UserID), store.Type, csvTime(store.CreatedAt),
+				})
+			}
+			return write(rows)
+		})
+	})
+}
+
+// ExportProducts streams products matching the list filters as CSV
+func (h *AdminManagementHandler) ExportProducts(c *gin.Context) {
+	header := []string{"id", "store_id", "external_id", "name", "price", "quantity", "created_at"}
+	exportCSV(c, "products", header, func(ctx context.Context, query repository.ListQuery, write csvBatchWriter) error {
+		return h.lists.ExportProducts(ctx, query, func(products []models.Product) error {
+			rows := make([][]string, 0, len(products))
+			for _, product := range products {
+				rows = append(rows, []string{
+					strconv.Itoa(product.ID), strconv.Itoa(product.StoreID), csvText(product.ExternalID), csvText(product.Name),
+					strconv.Itoa(product.Price), strconv.Itoa(product.Quantity), csvTime(product.CreatedAt),
+				})
+			}
+			return write(rows)
+		})
+	})
+}
+
+// ExportMappings streams mappings matching the list filters as CSV
+func (h *AdminManagementHandler) ExportMappings(c *gin.Context) {
+	header := []string{"id", "organization_id", "product1_id", "product2_id", "user_id", "created_at"}
+	exportCSV(c, "mappings", header, func(ctx context.Context, query repository.ListQuery, write csvBatchWriter) error {
+		return h.lists.ExportMappings(ctx, query, func(mappings []models.ProductMapping) error {
+			rows := make([][]string, 0, len(mappings))
+			for _, mapping := range mappings {
+				rows = append(rows, []string{
+					strconv.Itoa(mapping.ID), strconv.Itoa(mapping.OrganizationID), strconv.Itoa(mapping.Product1ID),
+					strconv.Itoa(mapping.Product2ID), strconv.Itoa(mapping.UserID), csvTime(mapping.CreatedAt),
+				})
+			}
+			return write(rows)
+		})
+	})
+}
+
+// exportCSV binds the export query and streams the rows produced by export,
+// flushing the response after every batch. The status and headers are sent
+// with the first batch, so an error before it is returned as a regular JSON
+// error; a later error can only truncate the download and is logged.
+func exportCSV(c *gin.Context, name string, header []string, export func(context.Context, repository.ListQuery, csvBatchWriter) error) {
+	var query AdminExportQuery
+	if !bindQuery(c, &query) {
+		return
+	}
+
+	w := csv.NewWriter(c.Writer)
+	started := false
+	start := func() error {
+		if started {
+			return nil
+		}
+		started = true
+		filename := fmt.Sprintf("%s-%s.csv", name, time.Now().UTC().Format("20060102-150405"))
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+		c.Status(http.StatusOK)
+		// BOM lets spreadsheet applications detect UTF-8 in Cyrillic product names
+		if _, err := c.Writer.WriteString("\ufeff"); err != nil {
+			return err
+		}
+		return w.Write(header)
+	}
+	flush := func() error {
+		w.Flush()
+		c.Writer.Flush()
+		return w.Error()
+	}
+
+	err := export(c.Request.Context(), query.listQuery(), func(rows [][]string) error {
+		if err := start(); err != nil {
+			return err
+		}
+		if err := w.WriteAll(rows); err != nil {
+			return err
+		}
+		return flush()
+	})
+	if err == nil {
+		// An export without rows still gets the header row
+		if err = start(); err == nil {
+			err = flush()
+		}
+	}
+	if err != nil {
+		if !started {
+			c.Error(err)
+			return
+		}
+		slog.WarnContext(c.Request.Context(), "CSV export interrupted", "list", name, "error", err)
+	}
+}
+
+// csvText escapes user-provided text that a spreadsheet would otherwise
+// evaluate as a formula
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// csvTime formats an optional time as RFC 3339 in UTC
+func csvTime(value *time.Time) string {
+	if value == nil {
+		return ""
+	}
+	return value.UTC().Format(time.RFC3339)
+}
@@ -1,89 +1,116 @@
 package handlers
 
 import (
-	"log"
+	"context"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"kursovaya_backend/internal/database"
+	"kursovaya_backend/internal/audit"
 	"kursovaya_backend/internal/errors"
-	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/rbac"
+	"kursovaya_backend/internal/repository"
+	"kursovaya_backend/internal/service"
+	"kursovaya_backend/pkg/utils"
 )
 
 // AdminManagementHandler contains handlers for admin-specific management functions
-type AdminManagementHandler struct{}
+type AdminManagementHandler struct {
+	repos     *repository.Repositories
+	lists     *service.AdminListService
+	lifecycle *service.UserLifecycleService
+	stats     *service.StatsService
+	stores    *service.StoreService
+	twoFactor *service.TwoFactorService
+}
 
-// GetStats returns system statistics for the admin dashboard
-func (h *AdminManagementHandler) GetStats(c *gin.Context) {
-	var stats struct {
-		Users    int `json:"users"`
-		Stores   int `json:"stores"`
-		Products int `json:"products"`
-		Mappings int `json:"mappings"`
+// NewAdminManagementHandler creates a handler working through the given repositories
+func NewAdminManagementHandler(repos *repository.Repositories, lists *service.AdminListService, lifecycle *service.UserLifecycleService, stats *service.StatsService, stores *service.StoreService, twoFactor *service.TwoFactorService) *AdminManagementHandler {
+	return &AdminManagementHandler{repos: repos, lists: lists, lifecycle: lifecycle, stats: stats, stores: stores, twoFactor: twoFactor}
+}
+
+// AdminListQuery holds the query parameters shared by all admin lists.
+// Filters that do not apply to a list are ignored; times are RFC 3339 and
+// cursor is the next_cursor of the previous page (only with sort=id).
+type AdminListQuery struct {
+	Limit       int       `form:"limit" validate:"omitempty,min=1"`
+	Offset      int       `form:"offset" validate:"omitempty,min=0"`
+	Cursor      int       `form:"cursor" validate:"omitempty,min=1"`
+	Sort        string    `form:"sort"`
+	Order       string    `form:"order" validate:"omitempty,oneof=asc desc"`
+	Email       string    `form:"email"`
+	Status      string    `form:"status" validate:"omitempty,oneof=active pending_verification suspended deleted"`
+	Name        string    `form:"name"`
+	StoreType   string    `form:"store_type" validate:"omitempty,oneof=wb ozon"`
+	UserID      int       `form:"user_id" validate:"omitempty,min=1"`
+	StoreID     int       `form:"store_id" validate:"omitempty,min=1"`
+	CreatedFrom time.Time `form:"created_from" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedTo   time.Time `form:"created_to" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+func (q AdminListQuery) listQuery() repository.ListQuery {
+	return repository.ListQuery{
+		Email:       q.Email,
+		Status:      q.Status,
+		Name:        q.Name,
+		StoreType:   q.StoreType,
+		UserID:      q.UserID,
+		StoreID:     q.StoreID,
+		CreatedFrom: q.CreatedFrom,
+		CreatedTo:   q.CreatedTo,
+		Sort:        q.Sort,
+		Desc:        q.Order == "desc",
+		Limit:       q.Limit,
+		Offset:      q.Offset,
+		AfterID:     q.Cursor,
 	}
+}
 
-	// Get user count
-	err := database.DB.QueryRow("SELECT COUNT(*) FROM users").Scan(&stats.Users)
-	if err != nil {
-		appErr := errors.InternalServerError("Failed to get user statistics", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+// respondList binds the list query, loads the page and writes it
+func respondList[T any](c *gin.Context, load func(context.Context, repository.ListQuery) (*service.ListPage[T], error)) {
+	var query AdminListQuery
+	if !bindQuery(c, &query) {
 		return
 	}
 
-	// Get store count
-	err = database.DB.QueryRow("SELECT COUNT(*) FROM stores").Scan(&stats.Stores)
+	page, err := load(c.Request.Context(), query.listQuery())
 	if err != nil {
-		appErr := errors.InternalServerError("Failed to get store statistics", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		c.Error(err)
 		return
 	}
 
-	// Get product count
-	err = database.DB.QueryRow("SELECT COUNT(*) FROM products").Scan(&stats.Products)
-	if err != nil {
-		appErr := errors.InternalServerError("Failed to get product statistics", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+	c.JSON(http.StatusOK, page)
+}
+
+// AdminStatsQuery holds the statistics parameters: the period of growth and
+// sync series in days and how many days without a successful sync make a
+// store inactive
+type AdminStatsQuery struct {
+	Days         int `form:"days" validate:"omitempty,min=1,max=90"`
+	InactiveDays int `form:"inactive_days" validate:"omitempty,min=1,max=365"`
+}
+
+// GetStats returns system statistics for the admin dashboard: totals, daily
+// growth, marketplace breakdowns with sync results, top users and inactive stores
+func (h *AdminManagementHandler) GetStats(c *gin.Context) {
+	var query AdminStatsQuery
+	if !bindQuery(c, &query) {
 		return
 	}
 
-	// Get mapping count
-	err = database.DB.QueryRow("SELECT COUNT(*) FROM product_mappings").Scan(&stats.Mappings)
+	stats, err := h.stats.Get(c.Request.Context(), service.StatsQuery{Days: query.Days, InactiveDays: query.InactiveDays})
 	if err != nil {
-		appErr := errors.InternalServerError("Failed to get mapping statistics", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, stats)
 }
 
-// GetUsers returns a list of all users
+// GetUsers returns a filtered, sorted page of users
 func (h *AdminManagementHandler) GetUsers(c *gin.Context) {
-	var users []models.User
-	rows, err := database.DB.Query("SELECT id, email FROM users")
-	if err != nil {
-		appErr := errors.InternalServerError("Failed to get users", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
-		return
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var user models.User
-		if err := rows.Scan(&user.ID, &user.Email); err != nil {
-			log.Printf("Error scanning user: %v", err)
-			continue
-		}
-		users = append(users, user)
-	}
-
-	c.JSON(http.StatusOK, users)
+	respondList(c, h.lists.Users)
 }
 
 // GetUser returns a specific user by ID
@@ -91,46 +118,22 @@ func (h *AdminManagementHandler) GetUser(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		appErr := errors.BadRequest("Invalid user ID", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
-	var user models.User
-	err = database.DB.QueryRow("SELECT id, email FROM users WHERE id = $1", id).
-		Scan(&user.ID, &user.Email)
+	user, err := h.repos.Users.GetByID(c.Request.Context(), id)
 	if err != nil {
-		appErr := errors.NotFound("User not found", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		h.respondLookupError(c, "User not found", "Failed to get user", err)
 		return
 	}
 
 	c.JSON(http.StatusOK, user)
 }
 
-// GetStores returns a list of all stores
+// GetStores returns a filtered, sorted page of stores
 func (h *AdminManagementHandler) GetStores(c *gin.Context) {
-	var stores []models.Store
-	rows, err := database.DB.Query("SELECT id, user_id, store_type FROM stores")
-	if err != nil {
-		appErr := errors.InternalServerError("Failed to get stores", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
-		return
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var store models.Store
-		if err := rows.Scan(&store.ID, &store.UserID, &store.Type); err != nil {
-			log.Printf("Error scanning store: %v", err)
-			continue
-		}
-		stores = append(stores, store)
-	}
-
-	c.JSON(http.StatusOK, stores)
+	respondList(c, h.lists.Stores)
 }
 
 // GetStore returns a specific store by ID
@@ -138,46 +141,22 @@ func (h *AdminManagementHandler) GetStore(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		appErr := errors.BadRequest("Invalid store ID", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
-	var store models.Store
-	err = database.DB.QueryRow("SELECT id, user_id, store_type FROM stores WHERE id = $1", id).
-		Scan(&store.ID, &store.UserID, &store.Type)
+	store, err := h.repos.Stores.GetByID(c.Request.Context(), id)
 	if err != nil {
-		appErr := errors.NotFound("Store not found", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		h.respondLookupError(c, "Store not found", "Failed to get store", err)
 		return
 	}
 
 	c.JSON(http.StatusOK, store)
 }
 
-// GetProducts returns a list of all products
+// GetProducts returns a filtered, sorted page of products
 func (h *AdminManagementHandler) GetProducts(c *gin.Context) {
-	var products []models.Product
-	rows, err := database.DB.Query("SELECT id, store_id, external_id, name, price, quantity FROM products")
-	if err != nil {
-		appErr := errors.InternalServerError("Failed to get products", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
-		return
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var product models.Product
-		if err := rows.Scan(&product.ID, &product.StoreID, &product.ExternalID, &product.Name, &product.Price, &product.Quantity); err != nil {
-			log.Printf("Error scanning product: %v", err)
-			continue
-		}
-		products = append(products, product)
-	}
-
-	c.JSON(http.StatusOK, products)
+	respondList(c, h.lists.Products)
 }
 
 // GetProduct returns a specific product by ID
@@ -185,46 +164,22 @@ func (h *AdminManagementHandler) GetProduct(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		appErr := errors.BadRequest("Invalid product ID", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
-	var product models.Product
-	err = database.DB.QueryRow("SELECT id, store_id, external_id, name, price, quantity FROM products WHERE id = $1", id).
-		Scan(&product.ID, &product.StoreID, &product.ExternalID, &product.Name, &product.Price, &product.Quantity)
+	product, err := h.repos.Products.GetByID(c.Request.Context(), id)
 	if err != nil {
-		appErr := errors.NotFound("Product not found", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		h.respondLookupError(c, "Product not found", "Failed to get product", err)
 		return
 	}
 
 	c.JSON(http.StatusOK, product)
 }
 
-// GetMappings returns a list of all product mappings
+// GetMappings returns a filtered, sorted page of product mappings
 func (h *AdminManagementHandler) GetMappings(c *gin.Context) {
-	var mappings []models.ProductMapping
-	rows, err := database.DB.Query("SELECT id, product1_id, product2_id, user_id FROM product_mappings")
-	if err != nil {
-		appErr := errors.InternalServerError("Failed to get mappings", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
-		return
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var mapping models.ProductMapping
-		if err := rows.Scan(&mapping.ID, &mapping.Product1ID, &mapping.Product2ID, &mapping.UserID); err != nil {
-			log.Printf("Error scanning mapping: %v", err)
-			continue
-		}
-		mappings = append(mappings, mapping)
-	}
-
-	c.JSON(http.StatusOK, mappings)
+	respondList(c, h.lists.Mappings)
 }
 
 // GetMapping returns a specific product mapping by ID
@@ -232,135 +187,155 @@ func (h *AdminManagementHandler) GetMapping(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		appErr := errors.BadRequest("Invalid mapping ID", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
-	var mapping models.ProductMapping
-	err = database.DB.QueryRow("SELECT id, product1_id, product2_id, user_id FROM product_mappings WHERE id = $1", id).
-		Scan(&mapping.ID, &mapping.Product1ID, &mapping.Product2ID, &mapping.UserID)
+	mapping, err := h.repos.Mappings.GetByID(c.Request.Context(), id)
 	if err != nil {
-		appErr := errors.NotFound("Mapping not found", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		h.respondLookupError(c, "Mapping not found", "Failed to get mapping", err)
 		return
 	}
 
 	c.JSON(http.StatusOK, mapping)
 }
 
-// DeleteUser deletes a user by ID
-func (h *AdminManagementHandler) DeleteUser(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
+// UserStatusRequest is the body of the suspend and delete endpoints
+type UserStatusRequest struct {
+	Reason string `json:"reason"`
+}
+
+// SuspendUser blocks a user: their sessions are revoked, sign-in and API
+// requests are rejected and background syncs of their stores are paused
+func (h *AdminManagementHandler) SuspendUser(c *gin.Context) {
+	id, ok := pathID(c, "id", "Invalid user ID")
+	if !ok {
+		return
+	}
+	var req UserStatusRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+
+	user, err := h.lifecycle.Suspend(c.Request.Context(), id, req.Reason)
 	if err != nil {
-		appErr := errors.BadRequest("Invalid user ID", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		c.Error(err)
 		return
 	}
 
-	// Check if user exists
-	var exists int
-	err = database.DB.QueryRow("SELECT COUNT(*) FROM users WHERE id = $1", id).Scan(&exists)
-	if err != nil || exists == 0 {
-		appErr := errors.NotFound("User not found", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+	c.JSON(http.StatusOK, user)
+}
+
+// ReactivateUser lifts a suspension or undoes a soft deletion
+func (h *AdminManagementHandler) ReactivateUser(c *gin.Context) {
+	id, ok := pathID(c, "id", "Invalid user ID")
+	if !ok {
 		return
 	}
 
-	// Delete the user
-	result, err := database.DB.Exec("DELETE FROM users WHERE id = $1", id)
+	user, err := h.lifecycle.Reactivate(c.Request.Context(), id)
 	if err != nil {
-		appErr := errors.InternalServerError("Failed to delete user", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// DeleteUser marks a user as deleted. The account keeps its data and can be
+// reactivated until it is erased; the reason in the optional body is stored.
+func (h *AdminManagementHandler) DeleteUser(c *gin.Context) {
+	id, ok := pathID(c, "id", "Invalid user ID")
+	if !ok {
+		return
+	}
+	var req UserStatusRequest
+	if c.Request.ContentLength > 0 && !bindAndValidate(c, &req) {
 		return
 	}
 
-	if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected == 0 {
-		appErr := errors.NotFound("User not found", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+	if _, err := h.lifecycle.Delete(c.Request.Context(), id, req.Reason); err != nil {
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
 }
 
-// DeleteStore deletes a store by ID
-func (h *AdminManagementHandler) DeleteStore(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		appErr := errors.BadRequest("Invalid store ID", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+// EraseUser irreversibly removes the personal data of a user together with
+// the organizations, stores, products and mappings only they had access to
+func (h *AdminManagementHandler) EraseUser(c *gin.Context) {
+	id, ok := pathID(c, "id", "Invalid user ID")
+	if !ok {
 		return
 	}
 
-	// Check if store exists
-	var exists int
-	err = database.DB.QueryRow("SELECT COUNT(*) FROM stores WHERE id = $1", id).Scan(&exists)
-	if err != nil || exists == 0 {
-		appErr := errors.NotFound("Store not found", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+	if err := h.lifecycle.Erase(c.Request.Context(), id); err != nil {
+		c.Error(err)
 		return
 	}
 
-	// Delete the store
-	result, err := database.DB.Exec("DELETE FROM stores WHERE id = $1", id)
+	c.JSON(http.StatusOK, gin.H{"message": "User data erased successfully"})
+}
+
+// ResetUserTwoFactor disables two-factor authentication of a user who lost
+// access to both the authenticator app and the recovery codes
+func (h *AdminManagementHandler) ResetUserTwoFactor(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		appErr := errors.InternalServerError("Failed to delete store", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		appErr := errors.BadRequest("Invalid user ID", err.Error())
+		respondError(c, appErr)
 		return
 	}
 
-	if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected == 0 {
-		appErr := errors.NotFound("Store not found", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+	if err := h.twoFactor.Reset(c.Request.Context(), utils.SubjectUser, id); err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Store deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset successfully"})
 }
 
-// DeleteProduct deletes a product by ID
-func (h *AdminManagementHandler) DeleteProduct(c *gin.Context) {
+// DeleteStore deletes a store by ID together with its products, mappings and token
+func (h *AdminManagementHandler) DeleteStore(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		appErr := errors.BadRequest("Invalid product ID", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		appErr := errors.BadRequest("Invalid store ID", err.Error())
+		respondError(c, appErr)
 		return
 	}
 
-	// Check if product exists
-	var exists int
-	err = database.DB.QueryRow("SELECT COUNT(*) FROM products WHERE id = $1", id).Scan(&exists)
-	if err != nil || exists == 0 {
-		appErr := errors.NotFound("Product not found", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+	// Товары, сопоставления и токен в хранилище удаляются вместе с магазином
+	if err := h.stores.DeleteStoreAsAdmin(c.Request.Context(), id); err != nil {
+		h.respondLookupError(c, "Store not found", "Failed to delete store", err)
 		return
 	}
 
-	// Delete the product
-	result, err := database.DB.Exec("DELETE FROM products WHERE id = $1", id)
+	c.JSON(http.StatusOK, gin.H{"message": "Store deleted successfully"})
+}
+
+// DeleteProduct deletes a product by ID
+func (h *AdminManagementHandler) DeleteProduct(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		appErr := errors.InternalServerError("Failed to delete product", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		appErr := errors.BadRequest("Invalid product ID", err.Error())
+		respondError(c, appErr)
 		return
 	}
 
-	if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected == 0 {
-		appErr := errors.NotFound("Product not found", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+	ctx := c.Request.Context()
+	err = h.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		product, err := tx.Products.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Products.Delete(ctx, id); err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx.Audit, audit.Entry{Action: "product_deleted", TargetType: "product", TargetID: id, Before: product})
+	})
+	if err != nil {
+		h.respondLookupError(c, "Product not found", "Failed to delete product", err)
 		return
 	}
 
@@ -372,36 +347,101 @@ func (h *AdminManagementHandler) DeleteMapping(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		appErr := errors.BadRequest("Invalid mapping ID", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
-	// Check if mapping exists
-	var exists int
-	err = database.DB.QueryRow("SELECT COUNT(*) FROM product_mappings WHERE id = $1", id).Scan(&exists)
-	if err != nil || exists == 0 {
-		appErr := errors.NotFound("Mapping not found", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+	ctx := c.Request.Context()
+	err = h.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		mapping, err := tx.Mappings.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Mappings.DeleteByID(ctx, id); err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx.Audit, audit.Entry{Action: "mapping_deleted", TargetType: "mapping", TargetID: id, Before: mapping})
+	})
+	if err != nil {
+		h.respondLookupError(c, "Mapping not found", "Failed to delete mapping", err)
 		return
 	}
 
-	// Delete the mapping
-	result, err := database.DB.Exec("DELETE FROM product_mappings WHERE id = $1", id)
-	if err != nil {
-		appErr := errors.InternalServerError("Failed to delete mapping", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+	c.JSON(http.StatusOK, gin.H{"message": "Mapping deleted successfully"})
+}
+
+// SetRoleRequest is the body of role assignment endpoints
+type SetRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// GetRoles returns the available user and admin roles with their permissions
+func (h *AdminManagementHandler) GetRoles(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"user_roles":  rbac.UserRoles(),
+		"admin_roles": rbac.AdminRoles(),
+	})
+}
+
+// SetUserRole assigns a role to a user. The new role takes effect on the
+// user's next request.
+func (h *AdminManagementHandler) SetUserRole(c *gin.Context) {
+	id, req, ok := h.bindRoleRequest(c, "Invalid user ID")
+	if !ok {
 		return
 	}
 
-	if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected == 0 {
-		appErr := errors.NotFound("Mapping not found", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+	if !rbac.IsUserRole(req.Role) {
+		appErr := errors.BadRequest("Unknown user role", req.Role)
+		respondError(c, appErr)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Mapping deleted successfully"})
-}
\ No newline at end of file
+	ctx := c.Request.Context()
+	err := h.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		user, err := tx.Users.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Users.SetRole(ctx, id, req.Role); err != nil {
+			return err
+		}
+		changed := *user
+		changed.Role = req.Role
+		return audit.Record(ctx, tx.Audit, audit.Entry{Action: "user_role_changed", TargetType: "user", TargetID: id, Before: user, After: changed})
+	})
+	if err != nil {
+		h.respondLookupError(c, "User not found", "Failed to set user role", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User role updated successfully", "role": req.Role})
+}
+
+// bindRoleRequest parses the :id parameter and the role assignment body
+func (h *AdminManagementHandler) bindRoleRequest(c *gin.Context, invalidIDMessage string) (int, SetRoleRequest, bool) {
+	var req SetRoleRequest
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		appErr := errors.BadRequest(invalidIDMessage, err.Error())
+		respondError(c, appErr)
+		return 0, req, false
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		appErr := errors.BadRequest("Invalid request body", err.Error())
+		respondError(c, appErr)
+		return 0, req, false
+	}
+
+	return id, req, true
+}
+
+// respondLookupError maps repository errors to 404 or 500 responses
+func (h *AdminManagementHandler) respondLookupError(c *gin.Context, notFoundMessage, failureMessage string, err error) {
+	appErr := errors.InternalServerError(failureMessage, err.Error())
+	if err == repository.ErrNotFound {
+		appErr = errors.NotFound(notFoundMessage, err.Error())
+	}
+	respondError(c, appErr)
+}
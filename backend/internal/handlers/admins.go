@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"kursovaya_backend/internal/service"
+)
+
+// AdminAccountHandler управляет учетными записями администраторов
+type AdminAccountHandler struct {
+	adminService *service.AdminService
+}
+
+func NewAdminAccountHandler(adminService *service.AdminService) *AdminAccountHandler {
+	return &AdminAccountHandler{
+		adminService: adminService,
+	}
+}
+
+type BootstrapAdminRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Username string `json:"username" validate:"required"`
+	Password string `json:"password" validate:"required,min=8"`
+}
+
+type CreateAdminRequest struct {
+	Username string `json:"username" validate:"required"`
+	Password string `json:"password" validate:"required,min=8"`
+	Role     string `json:"role" validate:"required"`
+}
+
+type ChangeAdminPasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=8"`
+}
+
+type ResetAdminPasswordRequest struct {
+	Password string `json:"password" validate:"required,min=8"`
+}
+
+// Bootstrap создает первого суперадминистратора по одноразовому токену
+func (h *AdminAccountHandler) Bootstrap(c *gin.Context) {
+	var req BootstrapAdminRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+
+	admin, err := h.adminService.Bootstrap(c.Request.Context(), req.Token, req.Username, req.Password)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"admin": admin})
+}
+
+// ChangePassword меняет пароль текущего администратора. Смена пароля отзывает
+// выданные токены, поэтому в ответе возвращается новый токен.
+func (h *AdminAccountHandler) ChangePassword(c *gin.Context) {
+	var req ChangeAdminPasswordRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+
+	adminID := c.GetInt("user_id")
+	if err := h.adminService.ChangePassword(c.Request.Context(), adminID, req.CurrentPassword, req.NewPassword); err != nil {
+		c.Error(err)
+		return
+	}
+
+	admin, err := h.adminService.GetAdmin(c.Request.Context(), adminID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	respondWithAdminToken(c, admin)
+}
+
+// GetAdmins возвращает всех администраторов
+func (h *AdminAccountHandler) GetAdmins(c *gin.Context) {
+	admins, err := h.adminService.ListAdmins(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"admins": admins})
+}
+
+// GetAdmin возвращает администратора по ID
+func (h *AdminAccountHandler) GetAdmin(c *gin.Context) {
+	id, ok := pathID(c, "id", "Некорректный ID администратора")
+	if !ok {
+		return
+	}
+
+	admin, err := h.adminService.GetAdmin(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, admin)
+}
+
+// CreateAdmin создает администратора с временным паролем
+func (h *AdminAccountHandler) CreateAdmin(c *gin.Context) {
+	var req CreateAdminRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+
+	admin, err := h.adminService.CreateAdmin(c.Request.Context(), req.Username, req.Password, req.Role)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, admin)
+}
+
+// ResetPassword задает администратору временный пароль
+func (h *AdminAccountHandler) ResetPassword(c *gin.Context) {
+	id, ok := pathID(c, "id", "Некорректный ID администратора")
+	if !ok {
+		return
+	}
+	var req ResetAdminPasswordRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+
+	if err := h.adminService.ResetPassword(c.Request.Context(), c.GetInt("user_id"), id, req.Password); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Временный пароль задан, его нужно сменить при входе"})
+}
+
+// SetRole меняет роль администратора
+func (h *AdminAccountHandler) SetRole(c *gin.Context) {
+	id, ok := pathID(c, "id", "Некорректный ID администратора")
+	if !ok {
+		return
+	}
+	var req SetRoleRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+
+	if err := h.adminService.SetRole(c.Request.Context(), c.GetInt("user_id"), id, req.Role); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Admin role updated successfully", "role": req.Role})
+}
+
+// Disable отключает администратора
+func (h *AdminAccountHandler) Disable(c *gin.Context) {
+	h.setDisabled(c, true, "Администратор отключен")
+}
+
+// Enable снова включает администратора
+func (h *AdminAccountHandler) Enable(c *gin.Context) {
+	h.setDisabled(c, false, "Администратор включен")
+}
+
+// DeleteAdmin удаляет администратора
+func (h *AdminAccountHandler) DeleteAdmin(c *gin.Context) {
+	id, ok := pathID(c, "id", "Некорректный ID администратора")
+	if !ok {
+		return
+	}
+
+	if err := h.adminService.DeleteAdmin(c.Request.Context(), c.GetInt("user_id"), id); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Администратор удален"})
+}
+
+func (h *AdminAccountHandler) setDisabled(c *gin.Context, disabled bool, message string) {
+	id, ok := pathID(c, "id", "Некорректный ID администратора")
+	if !ok {
+		return
+	}
+
+	if err := h.adminService.SetDisabled(c.Request.Context(), c.GetInt("user_id"), id, disabled); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/service"
+)
+
+// defaultAPIKeyDays - срок действия ключа, если он не указан при создании
+const defaultAPIKeyDays = 90
+
+type APIKeyHandler struct {
+	apiKeyService *service.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+	}
+}
+
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	Scopes        []string `json:"scopes" validate:"required,min=1"`
+	ExpiresInDays int      `json:"expires_in_days" validate:"omitempty,min=1,max=365"`
+}
+
+// CreateAPIKeyResponse содержит открытый ключ; он возвращается только один раз
+type CreateAPIKeyResponse struct {
+	models.APIKey
+	Key string `json:"key"`
+}
+
+// GetAPIKeys возвращает API-ключи текущего пользователя
+func (h *APIKeyHandler) GetAPIKeys(c *gin.Context) {
+	keys, err := h.apiKeyService.List(c.Request.Context(), c.GetInt("user_id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// CreateAPIKey выпускает новый API-ключ с указанными разрешениями
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var req CreateAPIKeyRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+	if req.ExpiresInDays == 0 {
+		req.ExpiresInDays = defaultAPIKeyDays
+	}
+
+	key, plain, err := h.apiKeyService.Create(c.Request.Context(), c.GetInt("user_id"), req.Name, req.Scopes,
+		time.Duration(req.ExpiresInDays)*24*time.Hour)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, CreateAPIKeyResponse{APIKey: *key, Key: plain})
+}
+
+// RevokeAPIKey отзывает API-ключ текущего пользователя
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	id, ok := pathID(c, "id", "Некорректный ID ключа")
+	if !ok {
+		return
+	}
+
+	if err := h.apiKeyService.Revoke(c.Request.Context(), c.GetInt("user_id"), id); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API-ключ отозван"})
+}
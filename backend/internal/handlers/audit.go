@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"kursovaya_backend/internal/errors"
+	"kursovaya_backend/internal/repository"
+	"kursovaya_backend/internal/service"
+	"kursovaya_backend/pkg/utils"
+)
+
+type AuditHandler struct {
+	auditService *service.AuditService
+}
+
+func NewAuditHandler(auditService *service.AuditService) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+	}
+}
+
+// AuditQuery - параметры выборки журнала аудита. Время передается в формате
+// RFC 3339, cursor - значение next_cursor предыдущей страницы.
+type AuditQuery struct {
+	ActorType  string    `form:"actor_type" validate:"omitempty,oneof=user admin api_key system"`
+	ActorID    int       `form:"actor_id" validate:"omitempty,min=1"`
+	Action     string    `form:"action"`
+	TargetType string    `form:"target_type"`
+	TargetID   string    `form:"target_id"`
+	UserID     int       `form:"user_id" validate:"omitempty,min=1"`
+	From       time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To         time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Cursor     int       `form:"cursor" validate:"omitempty,min=1"`
+	Limit      int       `form:"limit" validate:"omitempty,min=1"`
+
+	// ImpersonatorID - действия администратора от имени пользователей
+	ImpersonatorID int `form:"impersonator_id" validate:"omitempty,min=1"`
+}
+
+func (q AuditQuery) filter() repository.AuditFilter {
+	return repository.AuditFilter{
+		ActorType:  q.ActorType,
+		ActorID:    q.ActorID,
+		Action:     q.Action,
+		TargetType: q.TargetType,
+		TargetID:   q.TargetID,
+		UserID:     q.UserID,
+		From:       q.From,
+		To:         q.To,
+		BeforeID:   q.Cursor,
+		Limit:      q.Limit,
+
+		ImpersonatorID: q.ImpersonatorID,
+	}
+}
+
+// GetAuditEvents возвращает журнал аудита с фильтрами и постраничной выборкой
+func (h *AuditHandler) GetAuditEvents(c *gin.Context) {
+	var query AuditQuery
+	if !bindQuery(c, &query) {
+		return
+	}
+
+	page, err := h.auditService.List(c.Request.Context(), query.filter())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// GetMyActivity возвращает действия текущего пользователя и действия над его учетной записью
+func (h *AuditHandler) GetMyActivity(c *gin.Context) {
+	var query AuditQuery
+	if !bindQuery(c, &query) {
+		return
+	}
+
+	page, err := h.auditService.UserActivity(c.Request.Context(), c.GetInt("user_id"), query.filter())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// bindQuery разбирает параметры строки запроса и проверяет их по тегам validate
+func bindQuery(c *gin.Context, query interface{}) bool {
+	if err := c.ShouldBindQuery(query); err != nil {
+		appErr := errors.BadRequest("Некорректные параметры запроса", err.Error())
+		respondError(c, appErr)
+		return false
+	}
+
+	if validationErrors := utils.ValidateStruct(query); len(validationErrors) > 0 {
+		appErr := errors.ValidationError("Ошибка валидации данных", "")
+		errors.LogAppError(c.Request.Context(), appErr)
+		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "errors": validationErrors, "request_id": requestID(c)})
+		return false
+	}
+	return true
+}
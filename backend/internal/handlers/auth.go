@@ -3,12 +3,30 @@ package handlers
 import (
 	"net/http"
 	"kursovaya_backend/internal/errors"
+	"kursovaya_backend/internal/mailer"
+	"kursovaya_backend/internal/models"
 	"kursovaya_backend/internal/service"
 	"kursovaya_backend/pkg/utils"
 	"github.com/gin-gonic/gin"
 )
 
-type AuthHandler struct{}
+type AuthHandler struct {
+	authService      *service.AuthService
+	sessionService   *service.SessionService
+	twoFactorService *service.TwoFactorService
+	accountService   *service.AccountService
+	lockoutService   *service.LockoutService
+}
+
+func NewAuthHandler(authService *service.AuthService, sessionService *service.SessionService, twoFactorService *service.TwoFactorService, accountService *service.AccountService, lockoutService *service.LockoutService) *AuthHandler {
+	return &AuthHandler{
+		authService:      authService,
+		sessionService:   sessionService,
+		twoFactorService: twoFactorService,
+		accountService:   accountService,
+		lockoutService:   lockoutService,
+	}
+}
 
 type RegisterRequest struct {
 	Email    string `json:"email" validate:"required,email"`
@@ -20,96 +38,227 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required,min=8"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// VerifyMFARequest - второй шаг входа: токен из ответа на вход и код
+// из приложения-аутентификатора или код восстановления
+type VerifyMFARequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+// MFARequiredResponse возвращается вместо токенов, если у учетной записи
+// включена двухфакторная аутентификация
+type MFARequiredResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
 type AuthResponse struct {
-	Token string `json:"token"`
-	User  struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	User         struct {
 		ID    int    `json:"id"`
 		Email string `json:"email"`
 	} `json:"user"`
 }
 
+type RefreshResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		appErr := errors.BadRequest("Некорректный формат данных", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
 	// Валидация структуры
 	if validationErrors := utils.ValidateStruct(&req); len(validationErrors) > 0 {
 		appErr := errors.ValidationError("Ошибка валидации данных", "")
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "errors": validationErrors})
+		errors.LogAppError(c.Request.Context(), appErr)
+		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "errors": validationErrors, "request_id": requestID(c)})
 		return
 	}
 
-	user, err := service.RegisterUser(req.Email, req.Password)
+	user, err := h.authService.RegisterUser(c.Request.Context(), req.Email, req.Password)
 	if err != nil {
 		appErr := errors.BadRequest("Ошибка регистрации", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
-		return
-	}
-
-	// Генерируем JWT токен
-	token, err := utils.GenerateJWT(user.ID, user.Email)
-	if err != nil {
-		appErr := errors.InternalServerError("Ошибка генерации токена", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
-	resp := AuthResponse{
-		Token: token,
+	// Письмо для подтверждения email. Сбой отправки не мешает регистрации:
+	// письмо можно запросить повторно.
+	if err := h.accountService.SendVerification(c.Request.Context(), user.ID, mailer.Language(c.GetHeader("Accept-Language"))); err != nil {
+		errors.LogError(c.Request.Context(), err)
 	}
-	resp.User.ID = user.ID
-	resp.User.Email = user.Email
 
-	c.JSON(http.StatusOK, resp)
+	// Открываем сессию и выдаем пару токенов
+	h.startSession(c, user)
 }
 
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		appErr := errors.BadRequest("Некорректный формат данных", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
 	// Валидация структуры
 	if validationErrors := utils.ValidateStruct(&req); len(validationErrors) > 0 {
 		appErr := errors.ValidationError("Ошибка валидации данных", "")
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "errors": validationErrors})
+		errors.LogAppError(c.Request.Context(), appErr)
+		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "errors": validationErrors, "request_id": requestID(c)})
 		return
 	}
 
-	user, err := service.AuthenticateUser(req.Email, req.Password)
+	// Заблокированная учетная запись или IP не проверяют пароль вовсе
+	if err := h.lockoutService.Check(c.Request.Context(), utils.SubjectUser, req.Email, c.ClientIP()); err != nil {
+		c.Error(err)
+		return
+	}
+
+	user, err := h.authService.AuthenticateUser(c.Request.Context(), req.Email, req.Password)
+	// Пароль верен, но учетная запись заблокирована: сообщаем причину без учета попытки
+	if appErr, ok := err.(*errors.AppError); ok && appErr.Code == http.StatusForbidden {
+		c.Error(appErr)
+		return
+	}
 	if err != nil {
+		if lockErr := h.lockoutService.Failure(c.Request.Context(), utils.SubjectUser, req.Email, c.ClientIP()); lockErr != nil {
+			errors.LogError(c.Request.Context(), lockErr)
+		}
 		appErr := errors.Unauthorized("Ошибка аутентификации", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
+		return
+	}
+
+	if err := h.lockoutService.Success(c.Request.Context(), utils.SubjectUser, req.Email); err != nil {
+		errors.LogError(c.Request.Context(), err)
+	}
+
+	// При включенной двухфакторной аутентификации токены выдаются только после проверки кода
+	enabled, err := h.twoFactorService.Enabled(c.Request.Context(), utils.SubjectUser, user.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if enabled {
+		challenge, err := h.twoFactorService.Challenge(utils.SubjectUser, user.ID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, MFARequiredResponse{
+			MFARequired: true,
+			MFAToken:    challenge.Token,
+			ExpiresIn:   challenge.ExpiresIn,
+		})
+		return
+	}
+
+	h.startSession(c, user)
+}
+
+// VerifyMFA завершает вход по токену второго шага и коду двухфакторной аутентификации
+func (h *AuthHandler) VerifyMFA(c *gin.Context) {
+	var req VerifyMFARequest
+	if !bindAndValidate(c, &req) {
 		return
 	}
 
-	// Генерируем JWT токен
-	token, err := utils.GenerateJWT(user.ID, user.Email)
+	userID, err := h.twoFactorService.CompleteChallenge(c.Request.Context(), h.lockoutService, utils.SubjectUser, req.MFAToken, req.Code, c.ClientIP())
 	if err != nil {
-		appErr := errors.InternalServerError("Ошибка генерации токена", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		c.Error(err)
+		return
+	}
+
+	user, err := h.authService.GetUser(c.Request.Context(), userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	h.startSession(c, user)
+}
+
+// startSession открывает сессию и отвечает парой токенов
+func (h *AuthHandler) startSession(c *gin.Context, user *models.User) {
+	tokens, err := h.sessionService.StartSession(c.Request.Context(), user)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
 	resp := AuthResponse{
-		Token: token,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
 	}
 	resp.User.ID = user.ID
 	resp.User.Email = user.Email
 
 	c.JSON(http.StatusOK, resp)
-}
\ No newline at end of file
+}
+// Refresh обменивает refresh-токен на новую пару токенов
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		appErr := errors.BadRequest("Некорректный формат данных", err.Error())
+		respondError(c, appErr)
+		return
+	}
+
+	if validationErrors := utils.ValidateStruct(&req); len(validationErrors) > 0 {
+		appErr := errors.ValidationError("Ошибка валидации данных", "")
+		errors.LogAppError(c.Request.Context(), appErr)
+		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "errors": validationErrors, "request_id": requestID(c)})
+		return
+	}
+
+	tokens, err := h.sessionService.Refresh(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, RefreshResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+	})
+}
+
+// Logout завершает сессию, к которой относится refresh-токен
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		appErr := errors.BadRequest("Некорректный формат данных", err.Error())
+		respondError(c, appErr)
+		return
+	}
+
+	if validationErrors := utils.ValidateStruct(&req); len(validationErrors) > 0 {
+		appErr := errors.ValidationError("Ошибка валидации данных", "")
+		errors.LogAppError(c.Request.Context(), appErr)
+		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "errors": validationErrors, "request_id": requestID(c)})
+		return
+	}
+
+	if err := h.sessionService.Logout(c.Request.Context(), req.RefreshToken); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Сессия завершена"})
+}
@@ -1,8 +1,11 @@
 package handlers
 
 import (
-	"kursovaya_backend/internal/errors"
+	"strconv"
+
 	"github.com/gin-gonic/gin"
+	"kursovaya_backend/internal/errors"
+	"kursovaya_backend/pkg/utils"
 )
 
 // GlobalErrorHandler обрабатывает все необработанные ошибки
@@ -16,22 +19,15 @@ func GlobalErrorHandler() gin.HandlerFunc {
 			err := c.Errors[0].Err
 
 			// Проверяем, является ли ошибка нашего типа AppError
-			if appErr, ok := err.(*errors.AppError); ok {
-				// Уже обработанная ошибка, отправляем как есть
-				errors.LogAppError(appErr) // Логируем ошибку
-				c.JSON(appErr.Code, gin.H{
-					"error":  appErr.Message,
-					"details": appErr.Details,
-				})
-			} else {
+			appErr, ok := err.(*errors.AppError)
+			if !ok {
 				// Неизвестная ошибка, создаем стандартную
-				unknownErr := errors.InternalServerError("Неизвестная ошибка", err.Error())
-				errors.LogAppError(unknownErr) // Логируем ошибку
-				c.JSON(unknownErr.Code, gin.H{
-					"error":  unknownErr.Message,
-					"details": unknownErr.Details,
-				})
+				appErr = errors.InternalServerError("Неизвестная ошибка", err.Error())
+			}
+			if appErr.RetryAfter > 0 {
+				c.Header("Retry-After", strconv.Itoa(appErr.RetryAfter))
 			}
+			respondError(c, appErr)
 
 			// Прерываем выполнение других обработчиков
 			c.Abort()
@@ -39,6 +35,22 @@ func GlobalErrorHandler() gin.HandlerFunc {
 	}
 }
 
+// respondError записывает ошибку в лог и отправляет ее клиенту вместе с
+// идентификатором запроса, по которому ее можно найти в логе
+func respondError(c *gin.Context, appErr *errors.AppError) {
+	errors.LogAppError(c.Request.Context(), appErr)
+	c.JSON(appErr.Code, gin.H{
+		"error":      appErr.Message,
+		"details":    appErr.Details,
+		"request_id": requestID(c),
+	})
+}
+
+// requestID возвращает идентификатор текущего запроса
+func requestID(c *gin.Context) string {
+	return utils.RequestIDFromContext(c.Request.Context())
+}
+
 // ValidationErrorHandler обрабатывает ошибки валидации
 func ValidationErrorHandler(errs []string) error {
 	if len(errs) == 0 {
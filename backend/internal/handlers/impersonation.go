@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"kursovaya_backend/internal/service"
+)
+
+// ImpersonationHandler выдает администратору токен для входа от имени
+// пользователя и завершает такой вход
+type ImpersonationHandler struct {
+	impersonationService *service.ImpersonationService
+}
+
+func NewImpersonationHandler(impersonationService *service.ImpersonationService) *ImpersonationHandler {
+	return &ImpersonationHandler{
+		impersonationService: impersonationService,
+	}
+}
+
+// ImpersonateRequest - необязательное тело запроса входа от имени пользователя.
+// Write разрешает изменяющие запросы, по умолчанию вход только для чтения.
+type ImpersonateRequest struct {
+	Reason string `json:"reason" validate:"max=500"`
+	Write  bool   `json:"write"`
+}
+
+// Impersonate выдает администратору короткоживущий токен пользователя :id
+func (h *ImpersonationHandler) Impersonate(c *gin.Context) {
+	userID, ok := pathID(c, "id", "Некорректный ID пользователя")
+	if !ok {
+		return
+	}
+	var req ImpersonateRequest
+	if c.Request.ContentLength > 0 && !bindAndValidate(c, &req) {
+		return
+	}
+
+	impersonation, err := h.impersonationService.Start(c.Request.Context(), c.GetInt("user_id"), userID, req.Reason, req.Write)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, impersonation)
+}
+
+// EndImpersonation завершает вход от имени пользователя, по токену которого выполнен запрос
+func (h *ImpersonationHandler) EndImpersonation(c *gin.Context) {
+	if err := h.impersonationService.End(c.Request.Context(), c.GetInt("user_id"), c.GetString("session_id")); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Вход от имени пользователя завершен"})
+}
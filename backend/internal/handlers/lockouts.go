@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"kursovaya_backend/internal/service"
+)
+
+// LockoutHandler показывает администратору блокировки входа и снимает их
+type LockoutHandler struct {
+	lockoutService *service.LockoutService
+}
+
+func NewLockoutHandler(lockoutService *service.LockoutService) *LockoutHandler {
+	return &LockoutHandler{
+		lockoutService: lockoutService,
+	}
+}
+
+// UnlockRequest - ключ блокировки из списка: "user:<email>", "admin:<логин>" или "ip:<адрес>"
+type UnlockRequest struct {
+	Key string `json:"key" validate:"required"`
+}
+
+// GetLockouts возвращает действующие блокировки входа
+func (h *LockoutHandler) GetLockouts(c *gin.Context) {
+	lockouts, err := h.lockoutService.ListLocked(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"lockouts": lockouts})
+}
+
+// Unlock снимает блокировку входа до истечения ее срока
+func (h *LockoutHandler) Unlock(c *gin.Context) {
+	var req UnlockRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+
+	if err := h.lockoutService.Unlock(c.Request.Context(), req.Key); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Блокировка снята"})
+}
@@ -1,25 +1,23 @@
 package handlers
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
 	"net/http"
 	"strconv"
-	"kursovaya_backend/internal/database"
 	"kursovaya_backend/internal/errors"
+	"kursovaya_backend/internal/models"
 	"kursovaya_backend/internal/service"
 	"github.com/gin-gonic/gin"
 )
 
 type MappingHandler struct {
 	mappingService *service.MappingService
-	db             *sql.DB
 }
 
-func NewMappingHandler() *MappingHandler {
+func NewMappingHandler(mappingService *service.MappingService) *MappingHandler {
 	return &MappingHandler{
-		mappingService: service.NewMappingService(),
-		db:             database.DB,
+		mappingService: mappingService,
 	}
 }
 
@@ -59,70 +57,37 @@ type CreateMappingResponse struct {
 }
 
 func (h *MappingHandler) GetMappings(c *gin.Context) {
-	// Получаем ID пользователя из контекста (после аутентификации)
-	userID, exists := c.Get("user_id")
+	// Получаем активную организацию из контекста (после OrganizationMiddleware)
+	organizationID, exists := c.Get("organization_id")
 	if !exists {
-		appErr := errors.InternalServerError("Пользователь не найден в контексте", "User not found in context")
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
-		return
-	}
-
-	userIDInt, ok := userID.(int)
-	if !ok {
-		appErr := errors.InternalServerError("Ошибка получения ID пользователя", "User ID type is incorrect")
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		appErr := errors.InternalServerError("Организация не найдена в контексте", "Organization not found in context")
+		respondError(c, appErr)
 		return
 	}
 
-	if userIDInt <= 0 {
-		appErr := errors.BadRequest("Некорректный ID пользователя", "User ID must be positive")
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+	// Получаем сопоставления организации из базы данных
+	mappings, err := h.mappingService.GetMappingsByOrganization(c.Request.Context(), organizationID.(int))
+	if err != nil {
+		appErr := errors.InternalServerError("Ошибка получения сопоставлений", err.Error())
+		respondError(c, appErr)
 		return
 	}
 
-	// Получаем сопоставления пользователя из базы данных
-	mappings, err := h.mappingService.GetMappingsByUser(userIDInt)
+	// Загружаем все товары сопоставлений одним запросом
+	products, err := h.mappingService.GetMappedProducts(c.Request.Context(), organizationID.(int))
 	if err != nil {
-		appErr := errors.InternalServerError("Ошибка получения сопоставлений", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		appErr := errors.InternalServerError("Ошибка получения информации о товарах", err.Error())
+		respondError(c, appErr)
 		return
 	}
 
 	// Преобразуем в формат с детальной информацией о товарах
 	detailedMappings := make([]MappingDetail, len(mappings))
 	for i, mapping := range mappings {
-		// Загружаем детали товара 1
-		product1, err := h.getProductDetail(mapping.Product1ID)
-		if err != nil {
-			// Вместо возврата ошибки, логируем и продолжаем с пустым значением
-			appErr := errors.InternalServerError("Ошибка получения информации о товаре 1", err.Error())
-			errors.LogAppError(appErr)
-			product1 = ProductDetail{
-				ID: mapping.Product1ID, // Указываем ID, чтобы пользователь знал, какой товар не удалось загрузить
-				Name: "Ошибка загрузки товара",
-			}
-		}
-
-		// Загружаем детали товара 2
-		product2, err := h.getProductDetail(mapping.Product2ID)
-		if err != nil {
-			// Вместо возврата ошибки, логируем и продолжаем с пустым значением
-			appErr := errors.InternalServerError("Ошибка получения информации о товаре 2", err.Error())
-			errors.LogAppError(appErr)
-			product2 = ProductDetail{
-				ID: mapping.Product2ID, // Указываем ID, чтобы пользователь знал, какой товар не удалось загрузить
-				Name: "Ошибка загрузки товара",
-			}
-		}
-
 		detailedMappings[i] = MappingDetail{
 			ID:       mapping.ID,
-			Product1: product1,
-			Product2: product2,
+			Product1: productDetail(c.Request.Context(), products, mapping.Product1ID),
+			Product2: productDetail(c.Request.Context(), products, mapping.Product2ID),
 			UserID:   mapping.UserID,
 		}
 	}
@@ -132,52 +97,47 @@ func (h *MappingHandler) GetMappings(c *gin.Context) {
 	})
 }
 
-// getProductDetail возвращает детали товара по ID
-func (h *MappingHandler) getProductDetail(productID int) (ProductDetail, error) {
-	var product ProductDetail
-
-	// Валидация ID продукта
-	if productID <= 0 {
-		return ProductDetail{}, errors.BadRequest("Некорректный ID товара", "Product ID must be positive")
-	}
-
-	row := h.db.QueryRow(
-		"SELECT id, store_id, external_id, name, price, quantity FROM products WHERE id = $1",
-		productID,
-	)
-
-	err := row.Scan(&product.ID, &product.StoreID, &product.ExternalID, &product.Name, &product.Price, &product.Quantity)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return ProductDetail{}, errors.NotFound(fmt.Sprintf("товар с ID %d не найден", productID), "")
+// productDetail возвращает детали товара из загруженного набора
+func productDetail(ctx context.Context, products map[int]models.Product, productID int) ProductDetail {
+	product, ok := products[productID]
+	if !ok {
+		// Вместо возврата ошибки, логируем и продолжаем с пустым значением
+		appErr := errors.NotFound(fmt.Sprintf("товар с ID %d не найден", productID), "")
+		errors.LogAppError(ctx, appErr)
+		return ProductDetail{
+			ID:   productID, // Указываем ID, чтобы пользователь знал, какой товар не удалось загрузить
+			Name: "Ошибка загрузки товара",
 		}
-		return ProductDetail{}, errors.InternalServerError("Ошибка получения информации о товаре", err.Error())
 	}
 
-	return product, nil
+	return ProductDetail{
+		ID:         product.ID,
+		StoreID:    product.StoreID,
+		ExternalID: product.ExternalID,
+		Name:       product.Name,
+		Price:      product.Price,
+		Quantity:   product.Quantity,
+	}
 }
 
 func (h *MappingHandler) CreateMapping(c *gin.Context) {
 	var req CreateMappingRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		appErr := errors.BadRequest("Некорректный формат данных", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
 	// Валидация запроса
 	if req.Product1ID <= 0 || req.Product2ID <= 0 {
 		appErr := errors.BadRequest("ID товаров должны быть положительными числами", "Product IDs must be positive integers")
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
 	if req.Product1ID == req.Product2ID {
 		appErr := errors.BadRequest("Нельзя сопоставить товар с самим собой", "Cannot map a product to itself")
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
@@ -185,32 +145,28 @@ func (h *MappingHandler) CreateMapping(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
 		appErr := errors.InternalServerError("Пользователь не найден в контексте", "User not found in context")
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
 	userIDInt, ok := userID.(int)
 	if !ok {
 		appErr := errors.InternalServerError("Ошибка получения ID пользователя", "User ID type is incorrect")
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
 	if userIDInt <= 0 {
 		appErr := errors.BadRequest("Некорректный ID пользователя", "User ID must be positive")
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
 	// Создаем сопоставление
-	mapping, err := h.mappingService.CreateMapping(req.Product1ID, req.Product2ID, userIDInt)
+	mapping, err := h.mappingService.CreateMapping(c.Request.Context(), req.Product1ID, req.Product2ID, userIDInt)
 	if err != nil {
 		appErr := errors.BadRequest("Ошибка создания сопоставления", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
@@ -229,15 +185,13 @@ func (h *MappingHandler) DeleteMapping(c *gin.Context) {
 	mappingID, err := strconv.Atoi(mappingIDStr)
 	if err != nil {
 		appErr := errors.BadRequest("Некорректный ID сопоставления", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
 	if mappingID <= 0 {
 		appErr := errors.BadRequest("ID сопоставления должен быть положительным числом", "Mapping ID must be a positive integer")
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
@@ -245,32 +199,28 @@ func (h *MappingHandler) DeleteMapping(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
 		appErr := errors.InternalServerError("Пользователь не найден в контексте", "User not found in context")
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
 	userIDInt, ok := userID.(int)
 	if !ok {
 		appErr := errors.InternalServerError("Ошибка получения ID пользователя", "User ID type is incorrect")
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
 	if userIDInt <= 0 {
 		appErr := errors.BadRequest("Некорректный ID пользователя", "User ID must be positive")
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
 	// Удаляем сопоставление
-	err = h.mappingService.DeleteMapping(mappingID, userIDInt)
+	err = h.mappingService.DeleteMapping(c.Request.Context(), mappingID, userIDInt)
 	if err != nil {
 		appErr := errors.InternalServerError("Ошибка удаления сопоставления", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
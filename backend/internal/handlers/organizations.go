@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"kursovaya_backend/internal/errors"
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/service"
+	"kursovaya_backend/pkg/utils"
+)
+
+type OrganizationHandler struct {
+	organizationService *service.OrganizationService
+}
+
+func NewOrganizationHandler(organizationService *service.OrganizationService) *OrganizationHandler {
+	return &OrganizationHandler{
+		organizationService: organizationService,
+	}
+}
+
+type CreateOrganizationRequest struct {
+	Name string `json:"name" validate:"required,max=255"`
+}
+
+type InviteMemberRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required"`
+}
+
+// InvitationResponse содержит приглашение и его токен. Токен показывается
+// только один раз: его нужно передать приглашенному пользователю.
+type InvitationResponse struct {
+	Invitation *models.Invitation `json:"invitation"`
+	Token      string             `json:"token"`
+}
+
+type AcceptInvitationRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type SetMemberRoleRequest struct {
+	Role string `json:"role" validate:"required"`
+}
+
+// GetOrganizations возвращает организации пользователя и его роль в каждой
+func (h *OrganizationHandler) GetOrganizations(c *gin.Context) {
+	memberships, err := h.organizationService.ListOrganizations(c.Request.Context(), c.GetInt("user_id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"organizations": memberships})
+}
+
+// CreateOrganization создает организацию, владельцем которой становится пользователь
+func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
+	var req CreateOrganizationRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+
+	organization, err := h.organizationService.CreateOrganization(c.Request.Context(), c.GetInt("user_id"), req.Name)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, organization)
+}
+
+// GetMembers возвращает участников организации
+func (h *OrganizationHandler) GetMembers(c *gin.Context) {
+	organizationID, ok := pathID(c, "id", "Некорректный ID организации")
+	if !ok {
+		return
+	}
+
+	members, err := h.organizationService.ListMembers(c.Request.Context(), organizationID, c.GetInt("user_id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"members": members})
+}
+
+// InviteMember приглашает пользователя в организацию по email
+func (h *OrganizationHandler) InviteMember(c *gin.Context) {
+	organizationID, ok := pathID(c, "id", "Некорректный ID организации")
+	if !ok {
+		return
+	}
+
+	var req InviteMemberRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+
+	invitation, token, err := h.organizationService.InviteMember(c.Request.Context(), organizationID, c.GetInt("user_id"), req.Email, req.Role)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, InvitationResponse{Invitation: invitation, Token: token})
+}
+
+// AcceptInvitation добавляет пользователя в организацию по токену приглашения
+func (h *OrganizationHandler) AcceptInvitation(c *gin.Context) {
+	var req AcceptInvitationRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+
+	member, err := h.organizationService.AcceptInvitation(c.Request.Context(), c.GetInt("user_id"), req.Token)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
+
+// SetMemberRole меняет роль участника организации
+func (h *OrganizationHandler) SetMemberRole(c *gin.Context) {
+	organizationID, ok := pathID(c, "id", "Некорректный ID организации")
+	if !ok {
+		return
+	}
+	memberID, ok := pathID(c, "userId", "Некорректный ID участника")
+	if !ok {
+		return
+	}
+
+	var req SetMemberRoleRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+
+	if err := h.organizationService.SetMemberRole(c.Request.Context(), organizationID, c.GetInt("user_id"), memberID, req.Role); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Роль участника изменена", "role": req.Role})
+}
+
+// RemoveMember исключает участника из организации или выводит из нее самого пользователя
+func (h *OrganizationHandler) RemoveMember(c *gin.Context) {
+	organizationID, ok := pathID(c, "id", "Некорректный ID организации")
+	if !ok {
+		return
+	}
+	memberID, ok := pathID(c, "userId", "Некорректный ID участника")
+	if !ok {
+		return
+	}
+
+	if err := h.organizationService.RemoveMember(c.Request.Context(), organizationID, c.GetInt("user_id"), memberID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Участник исключен из организации"})
+}
+
+// pathID разбирает положительный числовой параметр пути
+func pathID(c *gin.Context, name, message string) (int, bool) {
+	id, err := strconv.Atoi(c.Param(name))
+	if err != nil || id <= 0 {
+		appErr := errors.BadRequest(message, "Path parameter "+name+" must be a positive integer")
+		respondError(c, appErr)
+		return 0, false
+	}
+	return id, true
+}
+
+// bindAndValidate разбирает JSON тела запроса и проверяет его по тегам validate
+func bindAndValidate(c *gin.Context, req interface{}) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		appErr := errors.BadRequest("Некорректный формат данных", err.Error())
+		respondError(c, appErr)
+		return false
+	}
+
+	if validationErrors := utils.ValidateStruct(req); len(validationErrors) > 0 {
+		appErr := errors.ValidationError("Ошибка валидации данных", "")
+		errors.LogAppError(c.Request.Context(), appErr)
+		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "errors": validationErrors, "request_id": requestID(c)})
+		return false
+	}
+	return true
+}
@@ -12,9 +12,9 @@ type ProductHandler struct {
 	productService *service.ProductService
 }
 
-func NewProductHandler() *ProductHandler {
+func NewProductHandler(productService *service.ProductService) *ProductHandler {
 	return &ProductHandler{
-		productService: service.NewProductService(),
+		productService: productService,
 	}
 }
 
@@ -27,32 +27,28 @@ func (h *ProductHandler) GetProducts(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
 		appErr := errors.InternalServerError("Пользователь не найден в контексте", "User not found in context")
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
 	userIDInt, ok := userID.(int)
 	if !ok {
 		appErr := errors.InternalServerError("Ошибка получения ID пользователя", "User ID type is incorrect")
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
 	if userIDInt <= 0 {
 		appErr := errors.BadRequest("Некорректный ID пользователя", "User ID must be positive")
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
 	// Получаем товары из маркетплейсов
-	products, err := h.productService.GetProductsByUser(userIDInt)
+	products, err := h.productService.GetProductsByOrganization(c.Request.Context(), c.GetInt("organization_id"), userIDInt)
 	if err != nil {
 		appErr := errors.InternalServerError("Ошибка получения товаров", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
@@ -62,29 +58,19 @@ func (h *ProductHandler) GetProducts(c *gin.Context) {
 }
 
 func (h *ProductHandler) GetSavedProducts(c *gin.Context) {
-	// Получаем ID пользователя из контекста (после аутентификации)
-	userID, exists := c.Get("user_id")
+	// Получаем активную организацию из контекста (после OrganizationMiddleware)
+	organizationID, exists := c.Get("organization_id")
 	if !exists {
-		appErr := errors.InternalServerError("Пользователь не найден в контексте", "User not found in context")
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
-		return
-	}
-
-	userIDInt, ok := userID.(int)
-	if !ok {
-		appErr := errors.InternalServerError("Ошибка получения ID пользователя", "User ID type is incorrect")
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		appErr := errors.InternalServerError("Организация не найдена в контексте", "Organization not found in context")
+		respondError(c, appErr)
 		return
 	}
 
-	// Получаем сохраненные товары из базы данных
-	products, err := h.productService.GetSavedProducts(userIDInt)
+	// Получаем сохраненные товары организации из базы данных
+	products, err := h.productService.GetSavedProducts(c.Request.Context(), organizationID.(int))
 	if err != nil {
 		appErr := errors.InternalServerError("Ошибка получения сохраненных товаров", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
@@ -9,36 +9,41 @@ import (
 	"kursovaya_backend/internal/service"
 )
 
-type StoreHandler struct{}
+type StoreHandler struct {
+	storeService *service.StoreService
+}
+
+func NewStoreHandler(storeService *service.StoreService) *StoreHandler {
+	return &StoreHandler{
+		storeService: storeService,
+	}
+}
 
-// GetStores возвращает список магазинов пользователя
+// GetStores возвращает список магазинов активной организации
 func (h *StoreHandler) GetStores(c *gin.Context) {
-	userID, exists := c.Get("user_id")
+	organizationID, exists := c.Get("organization_id")
 	if !exists {
-		appErr := errors.Unauthorized("Не авторизован", "")
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		appErr := errors.InternalServerError("Организация не найдена в контексте", "Organization not found in context")
+		respondError(c, appErr)
 		return
 	}
 
-	stores, err := service.GetStoresByUser(userID.(int))
+	stores, err := h.storeService.GetStoresByOrganization(c.Request.Context(), organizationID.(int))
 	if err != nil {
 		appErr := errors.InternalServerError("Ошибка получения магазинов", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
 	c.JSON(http.StatusOK, stores)
 }
 
-// AddStore добавляет новый магазин
+// AddStore добавляет новый магазин в активную организацию
 func (h *StoreHandler) AddStore(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
 		appErr := errors.Unauthorized("Не авторизован", "")
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
@@ -49,16 +54,18 @@ func (h *StoreHandler) AddStore(c *gin.Context) {
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		appErr := errors.BadRequest("Неправильный формат данных", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
-	store, err := service.AddStore(userID.(int), req.Type, req.APIToken)
+	store, err := h.storeService.AddStore(c.Request.Context(), c.GetInt("organization_id"), userID.(int), req.Type, req.APIToken)
 	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			respondError(c, appErr)
+			return
+		}
 		appErr := errors.InternalServerError("Ошибка добавления магазина", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
@@ -70,30 +77,26 @@ func (h *StoreHandler) DeleteStore(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
 		appErr := errors.Unauthorized("Не авторизован", "")
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
 	storeID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		appErr := errors.BadRequest("Некорректный ID магазина", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
-	err = service.DeleteStore(storeID, userID.(int))
+	err = h.storeService.DeleteStore(c.Request.Context(), storeID, userID.(int))
 	if err != nil {
-		if err.Error() == "магазин не найден или не принадлежит пользователю" {
-			appErr := errors.Forbidden("Магазин не найден или не принадлежит пользователю", err.Error())
-			errors.LogAppError(appErr)
-			c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		// Сервис сообщает об отсутствии прав ошибкой 403, ее код сохраняется
+		if appErr, ok := err.(*errors.AppError); ok {
+			respondError(c, appErr)
 			return
 		}
 		appErr := errors.InternalServerError("Ошибка удаления магазина", err.Error())
-		errors.LogAppError(appErr)
-		c.JSON(appErr.Code, gin.H{"error": appErr.Message, "details": appErr.Details})
+		respondError(c, appErr)
 		return
 	}
 
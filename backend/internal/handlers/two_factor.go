@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"kursovaya_backend/internal/service"
+)
+
+// TwoFactorHandler управляет двухфакторной аутентификацией текущей учетной
+// записи. Один и тот же обработчик подключается для пользователей и для
+// администраторов, subjectType определяет, чья это учетная запись.
+type TwoFactorHandler struct {
+	twoFactorService *service.TwoFactorService
+	subjectType      string
+}
+
+func NewTwoFactorHandler(twoFactorService *service.TwoFactorService, subjectType string) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		twoFactorService: twoFactorService,
+		subjectType:      subjectType,
+	}
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// GetStatus сообщает, включена ли двухфакторная аутентификация и обязательна ли она
+func (h *TwoFactorHandler) GetStatus(c *gin.Context) {
+	enabled, err := h.twoFactorService.Enabled(c.Request.Context(), h.subjectType, c.GetInt("user_id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"enabled": enabled, "required": h.twoFactorService.Required(h.subjectType)})
+}
+
+// Setup создает секрет и возвращает URI для QR-кода приложения-аутентификатора
+func (h *TwoFactorHandler) Setup(c *gin.Context) {
+	setup, err := h.twoFactorService.Setup(c.Request.Context(), h.subjectType, c.GetInt("user_id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, setup)
+}
+
+// Confirm включает двухфакторную аутентификацию по первому коду из приложения
+func (h *TwoFactorHandler) Confirm(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+
+	codes, err := h.twoFactorService.Confirm(c.Request.Context(), h.subjectType, c.GetInt("user_id"), req.Code)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// Disable отключает двухфакторную аутентификацию после проверки кода
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+
+	if err := h.twoFactorService.Disable(c.Request.Context(), h.subjectType, c.GetInt("user_id"), req.Code); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Двухфакторная аутентификация отключена"})
+}
+
+// RegenerateRecoveryCodes заменяет коды восстановления новыми
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if !bindAndValidate(c, &req) {
+		return
+	}
+
+	codes, err := h.twoFactorService.RegenerateRecoveryCodes(c.Request.Context(), h.subjectType, c.GetInt("user_id"), req.Code)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
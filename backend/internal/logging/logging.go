@@ -0,0 +1,80 @@
+// Package logging настраивает структурированный лог на log/slog. Записи,
+// сделанные с контекстом запроса (slog.InfoContext и т. д.), получают
+// request_id, поэтому все записи одного запроса можно найти вместе. Вызовы
+// пакета log после Setup тоже попадают в slog, но без контекста.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"kursovaya_backend/internal/config"
+	"kursovaya_backend/pkg/utils"
+)
+
+// Форматы лога
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Setup создает логгер по LOG_LEVEL и LOG_FORMAT и делает его логгером по
+// умолчанию для slog и пакета log
+func Setup(cfg *config.Config) *slog.Logger {
+	logger := New(os.Stdout, cfg.LogLevel, cfg.LogFormat)
+	slog.SetDefault(logger)
+	return logger
+}
+
+// New создает логгер, пишущий в w. level - "debug", "info", "warn" или
+// "error"; на уровне debug в записи добавляется место вызова. format -
+// FormatJSON или FormatText.
+func New(w io.Writer, level, format string) *slog.Logger {
+	options := &slog.HandlerOptions{Level: ParseLevel(level)}
+	options.AddSource = options.Level == slog.LevelDebug
+
+	var handler slog.Handler
+	if format == FormatText {
+		handler = slog.NewTextHandler(w, options)
+	} else {
+		handler = slog.NewJSONHandler(w, options)
+	}
+	return slog.New(contextHandler{handler})
+}
+
+// ParseLevel разбирает уровень лога; неизвестный уровень считается info
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// contextHandler добавляет в записи идентификатор запроса из контекста
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := utils.RequestIDFromContext(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
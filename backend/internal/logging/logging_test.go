@@ -0,0 +1,43 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"kursovaya_backend/pkg/utils"
+)
+
+// Тест уровня, формата и идентификатора запроса в записях лога
+func TestNew(t *testing.T) {
+	var out bytes.Buffer
+	logger := New(&out, "warn", FormatJSON)
+	ctx := utils.WithRequestID(context.Background(), "req-1")
+
+	logger.InfoContext(ctx, "skipped")
+	logger.WarnContext(ctx, "Store sync failed", "store_id", 5)
+	logger.Warn("No context")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Ожидались 2 записи уровня warn, получено: %s", out.String())
+	}
+	var record map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("Запись не в формате JSON: %v", err)
+	}
+	if record["msg"] != "Store sync failed" || record["request_id"] != "req-1" || record["store_id"] != float64(5) {
+		t.Errorf("Неожиданная запись: %v", record)
+	}
+	if strings.Contains(lines[1], "request_id") {
+		t.Errorf("Запись без контекста получила идентификатор запроса: %s", lines[1])
+	}
+
+	out.Reset()
+	New(&out, "info", FormatText).InfoContext(ctx, "Text")
+	if !strings.Contains(out.String(), "msg=Text") || !strings.Contains(out.String(), "request_id=req-1") {
+		t.Errorf("Неожиданная текстовая запись: %s", out.String())
+	}
+}
@@ -0,0 +1,53 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// localFrom - отправитель писем, которые никуда не отправляются
+const localFrom = "no-reply@localhost"
+
+// LogMailer выводит письма в журнал сервера вместо отправки
+type LogMailer struct{}
+
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	slog.InfoContext(ctx, "Письмо", "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+	return nil
+}
+
+// FileMailer сохраняет письма в каталог файлами .eml, которые открываются
+// любым почтовым клиентом
+type FileMailer struct {
+	dir string
+	now func() time.Time
+}
+
+func NewFileMailer(dir string) *FileMailer {
+	return &FileMailer{dir: dir, now: time.Now}
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	if err := os.MkdirAll(m.dir, 0o700); err != nil {
+		return err
+	}
+
+	now := m.now()
+	recipient := strings.NewReplacer("@", "_at_", "/", "_", "\\", "_").Replace(msg.To)
+	path := filepath.Join(m.dir, fmt.Sprintf("%s-%s.eml", now.Format("20060102-150405.000000000"), recipient))
+	if err := os.WriteFile(path, format(localFrom, msg, now), 0o600); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "Письмо сохранено", "to", msg.To, "path", path)
+	return nil
+}
@@ -0,0 +1,63 @@
+// Package mailer отправляет письма пользователям: через SMTP в рабочем
+// окружении или в файлы и журнал при локальной разработке
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"time"
+
+	"kursovaya_backend/internal/config"
+)
+
+// Message - письмо в виде простого текста
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer отправляет письма
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Драйверы почты, выбираются переменной окружения MAILER
+const (
+	DriverLog  = "log"
+	DriverFile = "file"
+	DriverSMTP = "smtp"
+)
+
+// New создает почтовый драйвер по настройкам
+func New(cfg *config.Config) (Mailer, error) {
+	switch cfg.Mailer {
+	case DriverLog, "":
+		return NewLogMailer(), nil
+	case DriverFile:
+		return NewFileMailer(cfg.MailDir), nil
+	case DriverSMTP:
+		if cfg.SMTPHost == "" {
+			return nil, fmt.Errorf("SMTP_HOST is required for MAILER=smtp")
+		}
+		return NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom), nil
+	}
+	return nil, fmt.Errorf("unknown MAILER %q, use log, file or smtp", cfg.Mailer)
+}
+
+// format собирает письмо в формате RFC 5322 с телом в UTF-8
+func format(from string, msg Message, date time.Time) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(msg.Body)
+	return buf.Bytes()
+}
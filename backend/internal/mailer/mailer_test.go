@@ -0,0 +1,87 @@
+package mailer
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderTemplates(t *testing.T) {
+	data := TemplateData{
+		Email:     "user@example.com",
+		Link:      "http://localhost:3000/reset-password?token=abc",
+		ExpiresAt: time.Date(2024, 1, 2, 15, 4, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name     string
+		language string
+		subject  string
+		expires  string
+	}{
+		{TemplatePasswordReset, "ru", "Сброс пароля", "02.01.2024 15:04"},
+		{TemplatePasswordReset, "en", "password reset", "Jan 2, 2024 15:04"},
+		{TemplateEmailVerification, "ru", "Подтвердите email", "02.01.2024 15:04"},
+		{TemplateEmailVerification, "en", "Confirm your email", "Jan 2, 2024 15:04"},
+	}
+
+	for _, tt := range tests {
+		msg, err := Render(tt.name, tt.language, data.Email, data)
+		if err != nil {
+			t.Fatalf("%s.%s: ошибка заполнения шаблона: %v", tt.name, tt.language, err)
+		}
+		if msg.To != data.Email || !strings.Contains(msg.Subject, tt.subject) {
+			t.Errorf("%s.%s: неожиданные получатель или тема: %+v", tt.name, tt.language, msg)
+		}
+		if !strings.Contains(msg.Body, data.Link) || !strings.Contains(msg.Body, tt.expires) {
+			t.Errorf("%s.%s: ожидаются ссылка и срок действия в тексте:\n%s", tt.name, tt.language, msg.Body)
+		}
+	}
+
+	if _, err := Render(TemplatePasswordReset, "de", data.Email, data); err == nil {
+		t.Error("Ожидается ошибка для неподдерживаемого языка")
+	}
+}
+
+func TestLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", "ru"},
+		{"en-US,en;q=0.9", "en"},
+		{"de-DE, en;q=0.8", "en"},
+		{"ru-RU,ru;q=0.9,en;q=0.8", "ru"},
+		{"fr", "ru"},
+	}
+
+	for _, tt := range tests {
+		if got := Language(tt.header); got != tt.want {
+			t.Errorf("Language(%q) = %q, ожидается %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	m := NewFileMailer(dir)
+
+	err := m.Send(context.Background(), Message{To: "user@example.com", Subject: "Сброс пароля", Body: "Текст письма\n"})
+	if err != nil {
+		t.Fatalf("Ошибка сохранения письма: %v", err)
+	}
+
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 || !strings.HasSuffix(files[0].Name(), "user_at_example.com.eml") {
+		t.Fatalf("Ожидается один файл .eml, получено %v", files)
+	}
+	content, _ := os.ReadFile(dir + "/" + files[0].Name())
+	if !strings.Contains(string(content), "To: user@example.com\r\n") || !strings.Contains(string(content), "Subject: =?utf-8?q?") {
+		t.Errorf("Неожиданные заголовки письма:\n%s", content)
+	}
+	if !strings.HasSuffix(string(content), "\r\n\r\nТекст письма\n") {
+		t.Errorf("Ожидается текст после заголовков:\n%s", content)
+	}
+}
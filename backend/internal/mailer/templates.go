@@ -0,0 +1,84 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//go:embed templates/*.tmpl
+var templateFiles embed.FS
+
+// templates - разобранные шаблоны по имени файла. Каждый файл разбирается
+// отдельно, потому что во всех файлах определены блоки subject и body.
+var templates = parseTemplates()
+
+func parseTemplates() map[string]*template.Template {
+	files, err := templateFiles.ReadDir("templates")
+	if err != nil {
+		panic(err)
+	}
+
+	result := make(map[string]*template.Template, len(files))
+	for _, file := range files {
+		result[file.Name()] = template.Must(template.ParseFS(templateFiles, "templates/"+file.Name()))
+	}
+	return result
+}
+
+// Шаблоны писем, для каждого есть файлы templates/<шаблон>.<язык>.tmpl
+const (
+	TemplatePasswordReset     = "password_reset"
+	TemplateEmailVerification = "email_verification"
+)
+
+// DefaultLanguage используется, если клиент не указал поддерживаемый язык
+const DefaultLanguage = "ru"
+
+var languages = []string{"ru", "en"}
+
+// TemplateData - данные для подстановки в шаблон письма
+type TemplateData struct {
+	Email string
+	// Link - ссылка на страницу фронтенда с токеном
+	Link      string
+	ExpiresAt time.Time
+}
+
+// Render заполняет шаблон на указанном языке и возвращает готовое письмо
+func Render(name, language, to string, data TemplateData) (Message, error) {
+	tmpl, ok := templates[fmt.Sprintf("%s.%s.tmpl", name, language)]
+	if !ok {
+		return Message{}, fmt.Errorf("template %s for language %s not found", name, language)
+	}
+
+	var subject, body bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := tmpl.ExecuteTemplate(&body, "body", data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		Body:    strings.TrimSpace(body.String()) + "\n",
+	}, nil
+}
+
+// Language выбирает язык писем по заголовку Accept-Language
+func Language(acceptLanguage string) string {
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		for _, language := range languages {
+			if tag == language || strings.HasPrefix(tag, language+"-") {
+				return language
+			}
+		}
+	}
+	return DefaultLanguage
+}
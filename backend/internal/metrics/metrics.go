@@ -0,0 +1,203 @@
+// Package metrics собирает метрики сервера и отдает их в формате Prometheus:
+// HTTP-запросы, запросы к API маркетплейсов, загрузки товаров, пул
+// соединений с базой, среда выполнения Go и число пользователей, магазинов,
+// товаров и сопоставлений.
+package metrics
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"kursovaya_backend/internal/repository"
+)
+
+// Границы корзин времени выполнения в секундах: от быстрых запросов к базе
+// до загрузок товаров, упирающихся в таймаут клиента маркетплейса (30s)
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Metrics - метрики сервера. Методы Observe* безопасно вызывать у nil, чтобы
+// сервисы можно было создавать без метрик.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests        *prometheus.CounterVec
+	httpDuration        *prometheus.HistogramVec
+	marketplaceRequests *prometheus.CounterVec
+	marketplaceDuration *prometheus.HistogramVec
+	syncRuns            *prometheus.CounterVec
+	syncDuration        *prometheus.HistogramVec
+}
+
+// New создает метрики сервера вместе с метриками среды выполнения Go и процесса
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests by method, route and status.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency by method, route and status.",
+			Buckets: durationBuckets,
+		}, []string{"method", "route", "status"}),
+		marketplaceRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "marketplace_requests_total",
+			Help: "Requests to marketplace APIs by marketplace, endpoint and status (error if no response).",
+		}, []string{"marketplace", "endpoint", "status"}),
+		marketplaceDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "marketplace_request_duration_seconds",
+			Help:    "Marketplace API request latency by marketplace, endpoint and status.",
+			Buckets: durationBuckets,
+		}, []string{"marketplace", "endpoint", "status"}),
+		syncRuns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sync_runs_total",
+			Help: "Store product syncs by marketplace, status and error category.",
+		}, []string{"marketplace", "status", "error_category"}),
+		syncDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "sync_duration_seconds",
+			Help:    "Store product sync duration by marketplace and status.",
+			Buckets: durationBuckets,
+		}, []string{"marketplace", "status"}),
+	}
+	m.registry.MustRegister(
+		m.httpRequests, m.httpDuration,
+		m.marketplaceRequests, m.marketplaceDuration,
+		m.syncRuns, m.syncDuration,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler возвращает обработчик /metrics; непустой token требуется в
+// заголовке "Authorization: Bearer <token>"
+func (m *Metrics) Handler(token string) http.Handler {
+	handler := promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+	if token == "" {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(req.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, req)
+	})
+}
+
+// ObserveHTTPRequest учитывает HTTP-запрос к маршруту route
+func (m *Metrics) ObserveHTTPRequest(method, route string, status int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	code := strconv.Itoa(status)
+	m.httpRequests.WithLabelValues(method, route, code).Inc()
+	m.httpDuration.WithLabelValues(method, route, code).Observe(duration.Seconds())
+}
+
+// ObserveMarketplaceRequest учитывает запрос к API маркетплейса; подходит как
+// api.RequestObserver. Код 0 означает, что ответ не получен.
+func (m *Metrics) ObserveMarketplaceRequest(marketplace, endpoint string, status int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	code := "error"
+	if status != 0 {
+		code = strconv.Itoa(status)
+	}
+	m.marketplaceRequests.WithLabelValues(marketplace, endpoint, code).Inc()
+	m.marketplaceDuration.WithLabelValues(marketplace, endpoint, code).Observe(duration.Seconds())
+}
+
+// ObserveSync учитывает загрузку товаров магазина маркетплейса marketplace
+// с результатом status (models.SyncSucceeded или models.SyncFailed)
+func (m *Metrics) ObserveSync(marketplace, status, errorCategory string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.syncRuns.WithLabelValues(marketplace, status, errorCategory).Inc()
+	m.syncDuration.WithLabelValues(marketplace, status).Observe(duration.Seconds())
+}
+
+// CollectDBStats добавляет метрики пула соединений db (go_sql_* с меткой
+// db_name="main")
+func (m *Metrics) CollectDBStats(db *sql.DB) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, "main"))
+}
+
+// CollectCatalogue добавляет число пользователей, магазинов, товаров и
+// сопоставлений, которые запрашиваются из repos при каждом сборе метрик
+func (m *Metrics) CollectCatalogue(repos *repository.Repositories) {
+	m.registry.MustRegister(newCatalogueCollector(repos))
+}
+
+// catalogueNamespace отличает метрики каталога от одноименных метрик других
+// сервисов на том же Prometheus
+const catalogueNamespace = "kursovaya"
+
+// catalogueCollector выводит число сущностей в базе. Если запрос к базе не
+// удался, соответствующие метрики не выводятся.
+type catalogueCollector struct {
+	repos *repository.Repositories
+
+	users    *prometheus.Desc
+	stores   *prometheus.Desc
+	products *prometheus.Desc
+	mappings *prometheus.Desc
+}
+
+func newCatalogueCollector(repos *repository.Repositories) *catalogueCollector {
+	return &catalogueCollector{
+		repos:    repos,
+		users:    prometheus.NewDesc(prometheus.BuildFQName(catalogueNamespace, "", "users"), "Registered users.", nil, nil),
+		stores:   prometheus.NewDesc(prometheus.BuildFQName(catalogueNamespace, "", "stores"), "Stores by marketplace.", []string{"marketplace"}, nil),
+		products: prometheus.NewDesc(prometheus.BuildFQName(catalogueNamespace, "", "products"), "Saved products by marketplace.", []string{"marketplace"}, nil),
+		mappings: prometheus.NewDesc(prometheus.BuildFQName(catalogueNamespace, "", "mappings"), "Product mappings.", nil, nil),
+	}
+}
+
+// Describe реализует prometheus.Collector
+func (c *catalogueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.users
+	ch <- c.stores
+	ch <- c.products
+	ch <- c.mappings
+}
+
+// Collect реализует prometheus.Collector
+func (c *catalogueCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx := context.Background()
+
+	if users, err := c.repos.Users.Count(ctx); err != nil {
+		slog.ErrorContext(ctx, "Error counting users for metrics", "error", err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(c.users, prometheus.GaugeValue, float64(users))
+	}
+
+	if mappings, err := c.repos.Mappings.Count(ctx); err != nil {
+		slog.ErrorContext(ctx, "Error counting mappings for metrics", "error", err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(c.mappings, prometheus.GaugeValue, float64(mappings))
+	}
+
+	counts, err := c.repos.Stats.CountByStoreType(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error counting stores for metrics", "error", err)
+		return
+	}
+	for _, count := range counts {
+		ch <- prometheus.MustNewConstMetric(c.stores, prometheus.GaugeValue, float64(count.Stores), count.StoreType)
+		ch <- prometheus.MustNewConstMetric(c.products, prometheus.GaugeValue, float64(count.Products), count.StoreType)
+	}
+}
@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/repository"
+	_ "modernc.org/sqlite"
+)
+
+// Тест вывода метрик в формате Prometheus и доступа к ним по токену
+func TestMetricsHandler(t *testing.T) {
+	ctx := context.Background()
+	repos := repository.NewMemory()
+	user, err := repos.Users.Create(ctx, "user@example.com", "hash")
+	if err != nil {
+		t.Fatalf("Ошибка создания пользователя: %v", err)
+	}
+	for _, storeType := range []string{"wb", "wb", "ozon"} {
+		if _, err := repos.Stores.Create(ctx, 0, user.ID, storeType, "token"); err != nil {
+			t.Fatalf("Ошибка создания магазина: %v", err)
+		}
+	}
+
+	m := New()
+	m.CollectCatalogue(repos)
+	m.ObserveHTTPRequest("GET", "/api/v1/stores/:id", 200, 30*time.Millisecond)
+	m.ObserveHTTPRequest("GET", "/api/v1/stores/:id", 200, 2*time.Second)
+	m.ObserveMarketplaceRequest("wb", "/content/v2/cards/list", 429, time.Second)
+	m.ObserveMarketplaceRequest("ozon", "/v2/product/list", 0, 30*time.Second)
+	m.ObserveSync("wb", models.SyncFailed, "rate_limit", time.Second)
+	// Метрики без экземпляра не учитываются и не паникуют
+	var none *Metrics
+	none.ObserveSync("wb", models.SyncSucceeded, "", time.Second)
+
+	handler := m.Handler("secret")
+	scrape := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, authorization := range []string{"", "Bearer wrong", "secret"} {
+		if w := scrape(authorization); w.Code != http.StatusUnauthorized {
+			t.Errorf("Метрики выданы с заголовком %q: %d", authorization, w.Code)
+		}
+	}
+
+	w := scrape("Bearer secret")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("Неожиданный ответ %d: %v", w.Code, w.Header())
+	}
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	families, err := parser.TextToMetricFamilies(w.Body)
+	if err != nil {
+		t.Fatalf("Ответ не разбирается как текстовый формат Prometheus: %v", err)
+	}
+
+	stores := `route="/api/v1/stores/:id"`
+	tests := []struct {
+		name   string
+		kind   dto.MetricType
+		labels string
+		want   float64
+	}{
+		{"http_requests_total", dto.MetricType_COUNTER, `method="GET",` + stores + `,status="200"`, 2},
+		{"http_request_duration_seconds", dto.MetricType_HISTOGRAM, `method="GET",` + stores + `,status="200",le="0.025"`, 0},
+		{"http_request_duration_seconds", dto.MetricType_HISTOGRAM, `method="GET",` + stores + `,status="200",le="0.05"`, 1},
+		{"http_request_duration_seconds", dto.MetricType_HISTOGRAM, `method="GET",` + stores + `,status="200",le="2.5"`, 2},
+		{"http_request_duration_seconds", dto.MetricType_HISTOGRAM, `method="GET",` + stores + `,status="200",sum`, 2.03},
+		{"http_request_duration_seconds", dto.MetricType_HISTOGRAM, `method="GET",` + stores + `,status="200",count`, 2},
+		{"marketplace_requests_total", dto.MetricType_COUNTER, `endpoint="/v2/product/list",marketplace="ozon",status="error"`, 1},
+		{"marketplace_requests_total", dto.MetricType_COUNTER, `endpoint="/content/v2/cards/list",marketplace="wb",status="429"`, 1},
+		{"marketplace_request_duration_seconds", dto.MetricType_HISTOGRAM, `endpoint="/v2/product/list",marketplace="ozon",status="error",le="30"`, 1},
+		{"sync_runs_total", dto.MetricType_COUNTER, `error_category="rate_limit",marketplace="wb",status="failed"`, 1},
+		{"sync_duration_seconds", dto.MetricType_HISTOGRAM, `marketplace="wb",status="failed",count`, 1},
+		{"kursovaya_users", dto.MetricType_GAUGE, "", 1},
+		{"kursovaya_stores", dto.MetricType_GAUGE, `marketplace="ozon"`, 1},
+		{"kursovaya_stores", dto.MetricType_GAUGE, `marketplace="wb"`, 2},
+		{"kursovaya_products", dto.MetricType_GAUGE, `marketplace="wb"`, 0},
+		{"kursovaya_mappings", dto.MetricType_GAUGE, "", 0},
+	}
+	for _, tt := range tests {
+		family := families[tt.name]
+		if family == nil || family.GetType() != tt.kind {
+			t.Errorf("Нет метрики %s типа %v", tt.name, tt.kind)
+			continue
+		}
+		got, ok := sampleValues(family)[tt.labels]
+		if !ok || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s{%s} = %v (найдено: %v), ожидалось %v", tt.name, tt.labels, got, ok, tt.want)
+		}
+	}
+	// Метрики среды выполнения Go и процесса собираются библиотекой
+	if families["go_goroutines"] == nil {
+		t.Error("Нет метрик среды выполнения Go")
+	}
+}
+
+// sampleValues возвращает значения рядов метрики по меткам в виде
+// name="value",..., упорядоченным по имени. У гистограммы корзины
+// дополняются меткой le, а сумма и число наблюдений - суффиксами sum и count.
+func sampleValues(family *dto.MetricFamily) map[string]float64 {
+	values := make(map[string]float64)
+	for _, metric := range family.GetMetric() {
+		var pairs []string
+		for _, label := range metric.GetLabel() {
+			pairs = append(pairs, fmt.Sprintf("%s=%q", label.GetName(), label.GetValue()))
+		}
+		key := func(extra ...string) string {
+			return strings.Join(append(slices.Clone(pairs), extra...), ",")
+		}
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			values[key()] = metric.GetCounter().GetValue()
+		case dto.MetricType_GAUGE:
+			values[key()] = metric.GetGauge().GetValue()
+		case dto.MetricType_HISTOGRAM:
+			histogram := metric.GetHistogram()
+			for _, bucket := range histogram.GetBucket() {
+				values[key(fmt.Sprintf("le=%q", strconv.FormatFloat(bucket.GetUpperBound(), 'g', -1, 64)))] = float64(bucket.GetCumulativeCount())
+			}
+			values[key("sum")] = histogram.GetSampleSum()
+			values[key("count")] = float64(histogram.GetSampleCount())
+		}
+	}
+	return values
+}
+
+// Тест подключения метрик пула соединений с базой
+func TestCollectDBStats(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Ошибка открытия базы: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(3)
+
+	m := New()
+	m.CollectDBStats(db)
+	if got := testutil.CollectAndCount(m.registry, "go_sql_max_open_connections"); got != 1 {
+		t.Errorf("Ожидается 1 ряд go_sql_max_open_connections, получено %d", got)
+	}
+}
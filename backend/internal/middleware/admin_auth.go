@@ -1,21 +1,23 @@
 package middleware
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"kursovaya_backend/internal/database"
+	"kursovaya_backend/internal/audit"
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/repository"
 	"kursovaya_backend/pkg/utils"
 )
 
 // AdminAuthMiddleware проверяет, является ли пользователь администратором
-func AdminAuthMiddleware() gin.HandlerFunc {
+func AdminAuthMiddleware(admins repository.AdminRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+			errorJSON(c, http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
 			c.Abort()
 			return
 		}
@@ -23,44 +25,77 @@ func AdminAuthMiddleware() gin.HandlerFunc {
 		// Проверяем формат заголовка Authorization
 		tokenParts := strings.Split(authHeader, " ")
 		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
+			errorJSON(c, http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
 			c.Abort()
 			return
 		}
 
 		tokenString := tokenParts[1]
 
-		// Проверяем токен
-		userID, _, err := utils.ValidateJWT(tokenString)  // Используем только userID, email не нужен для админ-аутентификации
+		// Проверяем токен. Принимаются только токены администраторов: токен пользователя с тем же ID не подходит
+		claims, err := utils.ParseAdminToken(tokenString)
 		if err != nil {
-			log.Printf("Admin authentication error: %v", err)
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			slog.WarnContext(c.Request.Context(), "Admin authentication error", "error", err)
+			errorJSON(c, http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			c.Abort()
 			return
 		}
 
-		// Проверим, существует ли пользователь с таким ID в таблице admins
-		var adminExists int
-		err = database.DB.QueryRow("SELECT COUNT(*) FROM admins WHERE id = $1", userID).
-			Scan(&adminExists)
+		// Загружаем администратора: роль берется из базы, а не из токена,
+		// чтобы изменение роли действовало сразу
+		admin, err := admins.GetByID(c.Request.Context(), claims.UserID)
+		if err == repository.ErrNotFound {
+			// Если администратор не найден в таблице admins, доступ запрещен
+			errorJSON(c, http.StatusForbidden, gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
 		if err != nil {
-			log.Printf("Error checking admin status: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error verifying admin status"})
+			slog.ErrorContext(c.Request.Context(), "Error checking admin status", "error", err)
+			errorJSON(c, http.StatusInternalServerError, gin.H{"error": "Error verifying admin status"})
+			c.Abort()
+			return
+		}
+
+		// Отключенный администратор теряет доступ сразу, даже с действующим токеном
+		if admin.DisabledAt != nil {
+			errorJSON(c, http.StatusForbidden, gin.H{"error": "Admin account is disabled"})
 			c.Abort()
 			return
 		}
 
-		// Если пользователь не найден в таблице admins, значит он не администратор
-		if adminExists == 0 {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		// Токен, выданный до смены пароля или отключения, отозван
+		if claims.TokenVersion != admin.TokenVersion {
+			errorJSON(c, http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
 			c.Abort()
 			return
 		}
 
 		// Устанавливаем информацию о пользователе в контекст
-		c.Set("user_id", userID)
-		c.Set("username", "admin") // Для администратора используем имя "admin"
+		c.Set("user_id", admin.ID)
+		c.Set("username", admin.Username)
 		c.Set("is_admin", true)
+		c.Set("role", admin.Role)
+		c.Set("must_change_password", admin.MustChangePassword)
+		setAuditActor(c, audit.Actor{Type: models.ActorAdmin, ID: admin.ID})
+
+		c.Next()
+	}
+}
+
+// RequireAdminPasswordChanged не пропускает администратора, которому нужно
+// сменить временный пароль. Вызывается после AdminAuthMiddleware; смена
+// пароля и настройка 2FA подключаются без нее.
+func RequireAdminPasswordChanged() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetBool("must_change_password") {
+			errorJSON(c, http.StatusForbidden, gin.H{
+				"error":                    "Password must be changed",
+				"password_change_required": true,
+			})
+			c.Abort()
+			return
+		}
 
 		c.Next()
 	}
@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/rbac"
+	"kursovaya_backend/internal/repository"
+	"kursovaya_backend/pkg/utils"
+)
+
+// Регрессионный тест: пользователь, чей users.id совпадает с admins.id,
+// не должен получать доступ к админ-маршрутам, а токен администратора -
+// к пользовательским
+func TestTokenAudienceWithCollidingIDs(t *testing.T) {
+	utils.SetJWTKey("test-secret-key-with-at-least-32-characters")
+	ctx := context.Background()
+	repos := repository.NewMemory()
+
+	user, _ := repos.Users.Create(ctx, "user@example.com", "hash")
+	admin, _ := repos.Admins.Create(ctx, "admin", "hash", rbac.DefaultAdminRole, false)
+	if user.ID != admin.ID {
+		t.Fatalf("Тест требует совпадающих ID, получено user=%d admin=%d", user.ID, admin.ID)
+	}
+	if err := repos.Sessions.Create(ctx, &models.Session{UserID: user.ID, FamilyID: "family", TokenHash: "hash", ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+
+	userToken, err := utils.GenerateAccessToken(user.ID, user.Email, user.Role, "family", time.Minute)
+	if err != nil {
+		t.Fatalf("Ошибка генерации токена пользователя: %v", err)
+	}
+	adminToken, err := utils.GenerateAdminToken(admin.ID, admin.Role, admin.TokenVersion)
+	if err != nil {
+		t.Fatalf("Ошибка генерации токена администратора: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/admin", AdminAuthMiddleware(repos.Admins), func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/user", AuthMiddleware(repos.Sessions, repos.Users, nil), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name  string
+		path  string
+		token string
+		want  int
+	}{
+		{"user token on admin route", "/admin", userToken, http.StatusUnauthorized},
+		{"admin token on user route", "/user", adminToken, http.StatusUnauthorized},
+		{"admin token on admin route", "/admin", adminToken, http.StatusOK},
+		{"user token on user route", "/user", userToken, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("Ожидается %d, получено %d", tt.want, w.Code)
+			}
+		})
+	}
+}
+
+// Тест состояния учетной записи: отключенный администратор теряет доступ
+// сразу, токены, выданные до смены пароля, отозваны, а с временным паролем
+// администратор получает доступ только к смене пароля
+func TestAdminAccountState(t *testing.T) {
+	utils.SetJWTKey("test-secret-key-with-at-least-32-characters")
+	ctx := context.Background()
+	repos := repository.NewMemory()
+
+	active, _ := repos.Admins.Create(ctx, "active", "hash", rbac.DefaultAdminRole, false)
+	temporary, _ := repos.Admins.Create(ctx, "temporary", "hash", rbac.DefaultAdminRole, true)
+	disabled, _ := repos.Admins.Create(ctx, "disabled", "hash", rbac.DefaultAdminRole, false)
+	disabledAt := time.Now()
+	repos.Admins.SetDisabled(ctx, disabled.ID, &disabledAt)
+	// Токен выдается по состоянию до сброса пароля
+	revoked, _ := repos.Admins.Create(ctx, "revoked", "hash", rbac.DefaultAdminRole, false)
+	repos.Admins.SetPassword(ctx, revoked.ID, "new-hash", true)
+	reenabled, _ := repos.Admins.Create(ctx, "reenabled", "hash", rbac.DefaultAdminRole, false)
+	repos.Admins.SetDisabled(ctx, reenabled.ID, &disabledAt)
+	repos.Admins.SetDisabled(ctx, reenabled.ID, nil)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	group := r.Group("/admin", AdminAuthMiddleware(repos.Admins))
+	group.POST("/password", func(c *gin.Context) { c.Status(http.StatusOK) })
+	group.GET("/stats", RequireAdminPasswordChanged(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name  string
+		admin *models.Admin
+		path  string
+		want  int
+	}{
+		{"active admin", active, "/admin/stats", http.StatusOK},
+		{"temporary password on regular route", temporary, "/admin/stats", http.StatusForbidden},
+		{"temporary password on password change", temporary, "/admin/password", http.StatusOK},
+		{"disabled admin", disabled, "/admin/password", http.StatusForbidden},
+		{"token issued before password reset", revoked, "/admin/password", http.StatusUnauthorized},
+		{"token issued before disabling", reenabled, "/admin/password", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := utils.GenerateAdminToken(tt.admin.ID, tt.admin.Role, tt.admin.TokenVersion)
+			if err != nil {
+				t.Fatalf("Ошибка генерации токена: %v", err)
+			}
+			method := http.MethodGet
+			if tt.path == "/admin/password" {
+				method = http.MethodPost
+			}
+			req := httptest.NewRequest(method, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("Ожидается %d, получено %d", tt.want, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"kursovaya_backend/internal/audit"
+)
+
+// AuditContext добавляет в контекст запроса IP-адрес и User-Agent клиента
+// для журнала аудита. Подключается ко всем маршрутам до аутентификации.
+func AuditContext() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := audit.WithClient(c.Request.Context(), audit.Client{
+			IP:        c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+		})
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// setAuditActor добавляет в контекст запроса аутентифицированного инициатора
+func setAuditActor(c *gin.Context, actor audit.Actor) {
+	c.Request = c.Request.WithContext(audit.WithActor(c.Request.Context(), actor))
+}
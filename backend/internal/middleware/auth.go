@@ -1,17 +1,41 @@
 package middleware
 
 import (
+	"context"
+	"log/slog"
 	"net/http"
 	"strings"
+	"kursovaya_backend/internal/audit"
+	"kursovaya_backend/internal/errors"
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/repository"
 	"kursovaya_backend/pkg/utils"
 	"github.com/gin-gonic/gin"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// APIKeyHeader - заголовок, в котором скрипты передают персональный API-ключ
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyAuthenticator проверяет персональные API-ключи (реализуется service.APIKeyService)
+type APIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, key string) (*models.APIKey, *models.User, error)
+}
+
+// AuthMiddleware проверяет access-токен пользователя, то, что его сессия не
+// отозвана, и статус учетной записи: заблокированный пользователь получает
+// 403 с причиной блокировки, удаленный - 401. Вместо токена принимается
+// API-ключ в заголовке X-API-Key или "Authorization: ApiKey {ключ}"; тогда в
+// контекст дополнительно устанавливаются api_key_id и api_key_scopes.
+func AuthMiddleware(sessions repository.SessionRepository, users repository.UserRepository, apiKeys APIKeyAuthenticator) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if key := apiKeyFromRequest(c); key != "" {
+			authenticateAPIKey(c, apiKeys, key)
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+			errorJSON(c, http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
 			c.Abort()
 			return
 		}
@@ -21,23 +45,183 @@ func AuthMiddleware() gin.HandlerFunc {
 		if len(authHeader) >= 7 && strings.ToUpper(authHeader[:6]) == "BEARER" {
 			tokenString = authHeader[7:]
 		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization format"})
+			errorJSON(c, http.StatusUnauthorized, gin.H{"error": "Invalid authorization format"})
 			c.Abort()
 			return
 		}
 
 		// Валидируем токен
-		userID, email, err := utils.ValidateJWT(tokenString)
+		claims, err := utils.ParseUserToken(tokenString)
+		if err != nil || claims.SessionID == "" {
+			errorJSON(c, http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			c.Abort()
+			return
+		}
+
+		// Токен действует, пока не отозвана его сессия (выход или повторное использование refresh-токена)
+		revoked, err := sessions.IsFamilyRevoked(c.Request.Context(), claims.SessionID)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Error checking session status", "error", err)
+			errorJSON(c, http.StatusInternalServerError, gin.H{"error": "Error verifying session"})
+			c.Abort()
+			return
+		}
+		if revoked {
+			errorJSON(c, http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+			c.Abort()
+			return
+		}
+
+		user, err := users.GetByID(c.Request.Context(), claims.UserID)
+		if err == repository.ErrNotFound {
+			errorJSON(c, http.StatusUnauthorized, gin.H{"error": "User not found"})
+			c.Abort()
+			return
+		}
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			slog.ErrorContext(c.Request.Context(), "Error loading user", "error", err)
+			errorJSON(c, http.StatusInternalServerError, gin.H{"error": "Error verifying session"})
 			c.Abort()
 			return
 		}
+		if !allowUserStatus(c, user) {
+			return
+		}
 
 		// Добавляем информацию о пользователе в контекст
-		c.Set("user_id", userID)
-		c.Set("user_email", email)
+		c.Set("user_id", claims.UserID)
+		c.Set("user_email", claims.Email)
+		c.Set("session_id", claims.SessionID)
+		// Роль берется из базы, а не из токена, чтобы понижение роли действовало сразу
+		c.Set("role", user.Role)
+		c.Set("user_status", user.Status)
+		actor := audit.Actor{Type: models.ActorUser, ID: claims.UserID}
+
+		// Вход администратора от имени пользователя помечается в контексте,
+		// журнале аудита и логе каждого запроса
+		if claims.Act != nil {
+			c.Set("impersonator_id", claims.Act.AdminID)
+			c.Set("impersonation_read_only", claims.Act.ReadOnly)
+			actor.ImpersonatorID = claims.Act.AdminID
+			slog.InfoContext(c.Request.Context(), "Impersonated request",
+				"admin_id", claims.Act.AdminID, "admin", claims.Act.Username, "user_id", claims.UserID,
+				"read_only", claims.Act.ReadOnly, "method", c.Request.Method, "path", c.Request.URL.Path)
+		}
+		setAuditActor(c, actor)
+
+		c.Next()
+	}
+}
+
+// ImpersonationGuard ограничивает запросы администратора от имени
+// пользователя: при входе только для чтения пропускаются лишь GET, HEAD и
+// OPTIONS. Без allowWrite изменяющие запросы не пропускаются вовсе - так
+// закрываются маршруты управления учетной записью (API-ключи, 2FA,
+// организации). Подключается после AuthMiddleware.
+func ImpersonationGuard(allowWrite bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("impersonator_id"); !ok {
+			c.Next()
+			return
+		}
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if !allowWrite || c.GetBool("impersonation_read_only") {
+			errorJSON(c, http.StatusForbidden, gin.H{"error": "Impersonation session is read-only", "impersonation": true})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// allowUserStatus прерывает запрос заблокированного или удаленного пользователя
+func allowUserStatus(c *gin.Context, user *models.User) bool {
+	switch user.Status {
+	case models.UserSuspended:
+		errorJSON(c, http.StatusForbidden, gin.H{"error": "Account is suspended", "reason": user.StatusReason})
+	case models.UserDeleted:
+		errorJSON(c, http.StatusUnauthorized, gin.H{"error": "Account has been deleted"})
+	default:
+		return true
+	}
+	c.Abort()
+	return false
+}
+
+// RequireVerifiedEmail не пропускает пользователей с неподтвержденным email,
+// если enabled. Подключается после AuthMiddleware.
+func RequireVerifiedEmail(enabled bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if enabled && c.GetString("user_status") == models.UserPendingVerification {
+			errorJSON(c, http.StatusForbidden, gin.H{"error": "Email address is not verified"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// apiKeyFromRequest возвращает API-ключ из заголовков запроса или пустую строку
+func apiKeyFromRequest(c *gin.Context) string {
+	if key := c.GetHeader(APIKeyHeader); key != "" {
+		return strings.TrimSpace(key)
+	}
+	authHeader := c.GetHeader("Authorization")
+	if len(authHeader) > 7 && strings.EqualFold(authHeader[:7], "ApiKey ") {
+		return strings.TrimSpace(authHeader[7:])
+	}
+	return ""
+}
+
+// authenticateAPIKey пропускает запрос от имени владельца API-ключа
+func authenticateAPIKey(c *gin.Context, apiKeys APIKeyAuthenticator, key string) {
+	if apiKeys == nil {
+		errorJSON(c, http.StatusUnauthorized, gin.H{"error": "API keys are not accepted"})
+		c.Abort()
+		return
+	}
+
+	apiKey, user, err := apiKeys.Authenticate(c.Request.Context(), key)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok && appErr.Code == http.StatusUnauthorized {
+			errorJSON(c, http.StatusUnauthorized, gin.H{"error": appErr.Details})
+		} else {
+			slog.ErrorContext(c.Request.Context(), "Error checking API key", "error", err)
+			errorJSON(c, http.StatusInternalServerError, gin.H{"error": "Error verifying API key"})
+		}
+		c.Abort()
+		return
+	}
 
+	if !allowUserStatus(c, user) {
+		return
+	}
+
+	c.Set("user_id", user.ID)
+	c.Set("user_email", user.Email)
+	c.Set("role", user.Role)
+	c.Set("user_status", user.Status)
+	c.Set("api_key_id", apiKey.ID)
+	c.Set("api_key_scopes", apiKey.Scopes)
+	setAuditActor(c, audit.Actor{Type: models.ActorAPIKey, ID: user.ID, APIKeyID: apiKey.ID})
+
+	c.Next()
+}
+
+// RequireSession не пропускает запросы по API-ключу. Подключается к маршрутам
+// управления учетной записью (2FA, API-ключи, организации), которые не
+// покрываются разрешениями ключа.
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("api_key_id"); ok {
+			errorJSON(c, http.StatusForbidden, gin.H{"error": "This endpoint requires a user session, API keys are not accepted"})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,258 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"kursovaya_backend/internal/audit"
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/rbac"
+	"kursovaya_backend/internal/repository"
+	"kursovaya_backend/internal/service"
+	"kursovaya_backend/pkg/utils"
+)
+
+// newProtectedRouter создает роутер с одним защищенным маршрутом
+func newProtectedRouter(repos *repository.Repositories) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/protected", AuthMiddleware(repos.Sessions, repos.Users, nil), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": c.GetInt("user_id")})
+	})
+	return r
+}
+
+func requestWithToken(r *gin.Engine, token string) int {
+	req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+// Тест отклонения access-токена отозванной сессии
+func TestAuthMiddlewareRejectsRevokedSession(t *testing.T) {
+	utils.SetJWTKey("test-secret-key-with-at-least-32-characters")
+	ctx := context.Background()
+	repos := repository.NewMemory()
+	user, _ := repos.Users.Create(ctx, "user@example.com", "hash")
+	session := &models.Session{UserID: user.ID, FamilyID: "family", TokenHash: "hash", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := repos.Sessions.Create(ctx, session); err != nil {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+
+	token, err := utils.GenerateAccessToken(user.ID, user.Email, user.Role, "family", time.Minute)
+	if err != nil {
+		t.Fatalf("Ошибка генерации токена: %v", err)
+	}
+	r := newProtectedRouter(repos)
+
+	if code := requestWithToken(r, token); code != http.StatusOK {
+		t.Fatalf("Ожидается 200 для активной сессии, получено %d", code)
+	}
+
+	if err := repos.Sessions.RevokeFamily(ctx, "family", time.Now()); err != nil {
+		t.Fatalf("Ошибка отзыва сессии: %v", err)
+	}
+	if code := requestWithToken(r, token); code != http.StatusUnauthorized {
+		t.Errorf("Ожидается 401 для отозванной сессии, получено %d", code)
+	}
+}
+
+// Тест отклонения запросов заблокированного и удаленного пользователя
+func TestAuthMiddlewareRejectsInactiveUser(t *testing.T) {
+	utils.SetJWTKey("test-secret-key-with-at-least-32-characters")
+	ctx := context.Background()
+	repos := repository.NewMemory()
+	user, _ := repos.Users.Create(ctx, "user@example.com", "hash")
+	repos.Sessions.Create(ctx, &models.Session{UserID: user.ID, FamilyID: "family", TokenHash: "hash", ExpiresAt: time.Now().Add(time.Hour)})
+	token, _ := utils.GenerateAccessToken(user.ID, user.Email, user.Role, "family", time.Minute)
+	r := newProtectedRouter(repos)
+
+	tests := []struct {
+		status string
+		want   int
+	}{
+		{models.UserSuspended, http.StatusForbidden},
+		{models.UserDeleted, http.StatusUnauthorized},
+		{models.UserActive, http.StatusOK},
+	}
+	for _, tt := range tests {
+		repos.Users.SetStatus(ctx, user.ID, tt.status, "reason", time.Now())
+		if code := requestWithToken(r, token); code != tt.want {
+			t.Errorf("%s: ожидается %d, получено %d", tt.status, tt.want, code)
+		}
+	}
+}
+
+// Тест понижения роли: новая роль действует со следующего запроса,
+// хотя в access-токене осталась прежняя
+func TestAuthMiddlewareUsesCurrentRole(t *testing.T) {
+	utils.SetJWTKey("test-secret-key-with-at-least-32-characters")
+	ctx := context.Background()
+	repos := repository.NewMemory()
+	user, _ := repos.Users.Create(ctx, "user@example.com", "hash")
+	repos.Sessions.Create(ctx, &models.Session{UserID: user.ID, FamilyID: "family", TokenHash: "hash", ExpiresAt: time.Now().Add(time.Hour)})
+	token, _ := utils.GenerateAccessToken(user.ID, user.Email, rbac.RoleOwner, "family", time.Minute)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/protected", AuthMiddleware(repos.Sessions, repos.Users, nil), RequirePermission(rbac.StoresWrite), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	if code := requestWithToken(r, token); code != http.StatusOK {
+		t.Fatalf("Ожидается 200 для владельца, получено %d", code)
+	}
+	if err := repos.Users.SetRole(ctx, user.ID, rbac.RoleViewer); err != nil {
+		t.Fatalf("Ошибка смены роли: %v", err)
+	}
+	if code := requestWithToken(r, token); code != http.StatusForbidden {
+		t.Errorf("Ожидается 403 после понижения роли, получено %d", code)
+	}
+}
+
+// Тест отклонения токена без сессии (например, выпущенного до появления сессий)
+func TestAuthMiddlewareRejectsTokenWithoutSession(t *testing.T) {
+	utils.SetJWTKey("test-secret-key-with-at-least-32-characters")
+	token, err := utils.GenerateJWT(utils.SubjectUser, 1, "user@example.com", utils.SubjectUser, "", time.Minute)
+	if err != nil {
+		t.Fatalf("Ошибка генерации токена: %v", err)
+	}
+
+	if code := requestWithToken(newProtectedRouter(repository.NewMemory()), token); code != http.StatusUnauthorized {
+		t.Errorf("Ожидается 401 для токена без сессии, получено %d", code)
+	}
+}
+
+// Тест входа по API-ключу: оба заголовка, разрешения ключа и маршруты только для сессий
+func TestAuthMiddlewareAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	repos := repository.NewMemory()
+	repos.Users.Create(ctx, "user@example.com", "hash")
+	apiKeys := service.NewAPIKeyService(repos)
+	_, plain, err := apiKeys.Create(ctx, 1, "CI", []string{string(rbac.StoresRead)}, time.Hour)
+	if err != nil {
+		t.Fatalf("Ошибка создания ключа: %v", err)
+	}
+
+	r := gin.New()
+	r.Use(AuthMiddleware(repos.Sessions, repos.Users, apiKeys))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/stores", RequirePermission(rbac.StoresRead), ok)
+	r.POST("/stores", RequirePermission(rbac.StoresWrite), ok)
+	r.GET("/api-keys", RequireSession(), ok)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		header string
+		value  string
+		want   int
+	}{
+		{"Заголовок X-API-Key", http.MethodGet, "/stores", APIKeyHeader, plain, http.StatusOK},
+		{"Схема ApiKey", http.MethodGet, "/stores", "Authorization", "ApiKey " + plain, http.StatusOK},
+		{"Неверный ключ", http.MethodGet, "/stores", APIKeyHeader, plain + "x", http.StatusUnauthorized},
+		{"Разрешение вне ключа", http.MethodPost, "/stores", APIKeyHeader, plain, http.StatusForbidden},
+		{"Маршрут только для сессии", http.MethodGet, "/api-keys", APIKeyHeader, plain, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set(tt.header, tt.value)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: ожидается %d, получено %d", tt.name, tt.want, w.Code)
+		}
+	}
+}
+
+// Тест передачи инициатора и клиента в контекст запроса для журнала аудита
+func TestAuthMiddlewareSetsAuditActor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	utils.SetJWTKey("test-secret-key-with-at-least-32-characters")
+	ctx := context.Background()
+	repos := repository.NewMemory()
+	user, _ := repos.Users.Create(ctx, "user@example.com", "hash")
+	repos.Sessions.Create(ctx, &models.Session{UserID: user.ID, FamilyID: "family", TokenHash: "hash", ExpiresAt: time.Now().Add(time.Hour)})
+	token, _ := utils.GenerateAccessToken(user.ID, user.Email, user.Role, "family", time.Minute)
+
+	var actor audit.Actor
+	var client audit.Client
+	r := gin.New()
+	r.Use(AuditContext())
+	r.GET("/protected", AuthMiddleware(repos.Sessions, repos.Users, nil), func(c *gin.Context) {
+		actor = audit.ActorFrom(c.Request.Context())
+		client = audit.ClientFrom(c.Request.Context())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("User-Agent", "test-agent")
+	req.RemoteAddr = "10.0.0.1:12345"
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	if actor != (audit.Actor{Type: models.ActorUser, ID: user.ID}) {
+		t.Errorf("Ожидается инициатор-пользователь %d, получено %+v", user.ID, actor)
+	}
+	if client != (audit.Client{IP: "10.0.0.1", UserAgent: "test-agent"}) {
+		t.Errorf("Неожиданные сведения о клиенте: %+v", client)
+	}
+}
+
+// Тест входа от имени пользователя: инициатор помечен администратором, а
+// изменяющие запросы пропускаются только с правом изменений
+func TestImpersonationGuard(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	utils.SetJWTKey("test-secret-key-with-at-least-32-characters")
+	ctx := context.Background()
+	repos := repository.NewMemory()
+	user, _ := repos.Users.Create(ctx, "user@example.com", "hash")
+	repos.Sessions.Create(ctx, &models.Session{UserID: user.ID, FamilyID: "imp-family", TokenHash: "hash", ExpiresAt: time.Now().Add(time.Hour)})
+	readOnly, _ := utils.GenerateImpersonationToken(user.ID, user.Role, "imp-family", utils.Impersonator{AdminID: 7, Username: "support", ReadOnly: true}, time.Minute)
+	writable, _ := utils.GenerateImpersonationToken(user.ID, user.Role, "imp-family", utils.Impersonator{AdminID: 7, Username: "root"}, time.Minute)
+
+	var actor audit.Actor
+	r := gin.New()
+	r.Use(AuditContext(), AuthMiddleware(repos.Sessions, repos.Users, nil))
+	ok := func(c *gin.Context) {
+		actor = audit.ActorFrom(c.Request.Context())
+		c.Status(http.StatusOK)
+	}
+	r.GET("/stores", ImpersonationGuard(true), ok)
+	r.POST("/stores", ImpersonationGuard(true), ok)
+	r.POST("/api-keys", ImpersonationGuard(false), ok)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{"Чтение", http.MethodGet, "/stores", readOnly, http.StatusOK},
+		{"Изменение только для чтения", http.MethodPost, "/stores", readOnly, http.StatusForbidden},
+		{"Изменение с правом изменений", http.MethodPost, "/stores", writable, http.StatusOK},
+		{"Управление учетной записью", http.MethodPost, "/api-keys", writable, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: ожидается %d, получено %d", tt.name, tt.want, w.Code)
+		}
+	}
+
+	if actor != (audit.Actor{Type: models.ActorUser, ID: user.ID, ImpersonatorID: 7}) {
+		t.Errorf("Ожидается инициатор с администратором 7, получено %+v", actor)
+	}
+}
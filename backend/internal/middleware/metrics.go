@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"kursovaya_backend/internal/metrics"
+)
+
+// unmatchedRoute - метка маршрута для запросов, не совпавших ни с одним
+// маршрутом, чтобы произвольные пути не создавали новые ряды метрик
+const unmatchedRoute = "unmatched"
+
+// Metrics учитывает запросы в метриках HTTP по шаблону маршрута
+// (/api/v1/stores/:id), а не по фактическому пути
+func Metrics(m *metrics.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		m.ObserveHTTPRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/repository"
+)
+
+// OrganizationHeader - заголовок, в котором клиент выбирает активную организацию
+const OrganizationHeader = "X-Organization-ID"
+
+// OrganizationMiddleware определяет активную организацию пользователя. Она
+// берется из заголовка X-Organization-ID, а без него - организация по
+// умолчанию (личная). Устанавливает в контекст organization_id и
+// organization_role; вызывается после AuthMiddleware.
+func OrganizationMiddleware(organizations repository.OrganizationRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("user_id")
+		ctx := c.Request.Context()
+
+		var organizationID int
+		if header := c.GetHeader(OrganizationHeader); header != "" {
+			id, err := strconv.Atoi(header)
+			if err != nil || id <= 0 {
+				errorJSON(c, http.StatusBadRequest, gin.H{"error": "Invalid " + OrganizationHeader + " header"})
+				c.Abort()
+				return
+			}
+			organizationID = id
+		}
+
+		var member *models.OrganizationMember
+		var err error
+		if organizationID > 0 {
+			member, err = organizations.GetMember(ctx, organizationID, userID)
+		} else {
+			member, err = organizations.GetDefaultMembership(ctx, userID)
+		}
+		if err == repository.ErrNotFound {
+			errorJSON(c, http.StatusForbidden, gin.H{"error": "Organization access denied"})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Error resolving organization", "error", err)
+			errorJSON(c, http.StatusInternalServerError, gin.H{"error": "Error resolving organization"})
+			c.Abort()
+			return
+		}
+
+		c.Set("organization_id", member.OrganizationID)
+		c.Set("organization_role", member.Role)
+
+		c.Next()
+	}
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"kursovaya_backend/internal/rbac"
+	"kursovaya_backend/internal/repository"
+)
+
+// Тест выбора активной организации и ограничения прав ролью в ней
+func TestOrganizationMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	repos := repository.NewMemory()
+	user, _ := repos.Users.Create(ctx, "user@example.com", "hash")
+	personal, _ := repos.Organizations.Create(ctx, "Личная")
+	team, _ := repos.Organizations.Create(ctx, "Команда")
+	foreign, _ := repos.Organizations.Create(ctx, "Чужая")
+	repos.Organizations.AddMember(ctx, personal.ID, user.ID, rbac.RoleOwner)
+	repos.Organizations.AddMember(ctx, team.ID, user.ID, rbac.RoleViewer)
+
+	r := gin.New()
+	r.POST("/stores",
+		func(c *gin.Context) {
+			c.Set("user_id", user.ID)
+			c.Set("role", rbac.RoleOwner)
+		},
+		OrganizationMiddleware(repos.Organizations),
+		RequirePermission(rbac.StoresWrite),
+		func(c *gin.Context) { c.Status(http.StatusOK) },
+	)
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"личная организация по умолчанию", "", http.StatusOK},
+		{"владелец выбранной организации", strconv.Itoa(personal.ID), http.StatusOK},
+		{"наблюдатель в команде", strconv.Itoa(team.ID), http.StatusForbidden},
+		{"чужая организация", strconv.Itoa(foreign.ID), http.StatusForbidden},
+		{"некорректный заголовок", "abc", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/stores", nil)
+		if tt.header != "" {
+			req.Header.Set(OrganizationHeader, tt.header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: ожидается %d, получено %d", tt.name, tt.want, w.Code)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"kursovaya_backend/internal/rbac"
+)
+
+// RequirePermission пропускает запрос, только если роль из контекста (ее
+// устанавливают AuthMiddleware и AdminAuthMiddleware) имеет все перечисленные разрешения.
+// Если OrganizationMiddleware определила активную организацию, разрешения
+// должна давать и роль пользователя в ней, а при входе по API-ключу
+// разрешение должно быть среди разрешений ключа.
+func RequirePermission(permissions ...rbac.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		organizationRole, inOrganization := c.Get("organization_role")
+		scopes, byAPIKey := c.Get("api_key_scopes")
+		for _, permission := range permissions {
+			allowed := rbac.HasPermission(role, permission)
+			if inOrganization {
+				allowed = allowed && rbac.HasPermission(organizationRole.(string), permission)
+			}
+			if byAPIKey {
+				allowed = allowed && hasScope(scopes.([]string), permission)
+			}
+			if !allowed {
+				errorJSON(c, http.StatusForbidden, gin.H{
+					"error":      "Insufficient permissions",
+					"permission": permission,
+				})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// hasScope проверяет, что разрешение входит в разрешения API-ключа
+func hasScope(scopes []string, permission rbac.Permission) bool {
+	for _, scope := range scopes {
+		if scope == string(permission) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"kursovaya_backend/internal/rbac"
+)
+
+func TestRequirePermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		role string
+		want int
+	}{
+		{rbac.RoleOwner, http.StatusOK},
+		{rbac.RoleEditor, http.StatusForbidden},
+		{rbac.RoleViewer, http.StatusForbidden},
+		{"", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		r := gin.New()
+		r.POST("/stores",
+			func(c *gin.Context) { c.Set("role", tt.role) },
+			RequirePermission(rbac.StoresRead, rbac.StoresWrite),
+			func(c *gin.Context) { c.Status(http.StatusOK) },
+		)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/stores", nil))
+		if w.Code != tt.want {
+			t.Errorf("Роль %q: ожидается %d, получено %d", tt.role, tt.want, w.Code)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"kursovaya_backend/internal/errors"
+	"kursovaya_backend/internal/ratelimit"
+)
+
+// Заголовки ответа с состоянием лимита запросов
+const (
+	RateLimitLimitHeader     = "RateLimit-Limit"
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	RateLimitResetHeader     = "RateLimit-Reset"
+	RateLimitPolicyHeader    = "RateLimit-Policy"
+)
+
+// RateLimits - лимиты запросов по API-ключу, пользователя и анонимного клиента
+type RateLimits struct {
+	APIKey ratelimit.Limit
+	User   ratelimit.Limit
+	IP     ratelimit.Limit
+}
+
+// RateLimit ограничивает частоту запросов. Корзина выбирается по API-ключу,
+// если запрос выполнен с ним, иначе по пользователю или администратору из
+// контекста (поэтому middleware подключается после аутентификации), иначе
+// по IP-адресу клиента. В ответ добавляются заголовки RateLimit-*; если
+// лимитов в цепочке несколько, заголовки описывают самый исчерпанный из них.
+// Превышение лимита возвращает 429 с Retry-After. Недоступное хранилище
+// корзин не блокирует запросы.
+func RateLimit(limiter *ratelimit.Limiter, limits RateLimits) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, key := limits.IP, "ip:"+c.ClientIP()
+		if apiKeyID, ok := c.Get("api_key_id"); ok {
+			limit, key = limits.APIKey, fmt.Sprintf("api_key:%v", apiKeyID)
+		} else if userID, ok := c.Get("user_id"); ok {
+			limit, key = limits.User, fmt.Sprintf("user:%v", userID)
+			if c.GetBool("is_admin") {
+				key = fmt.Sprintf("admin:%v", userID)
+			}
+		}
+
+		if !limit.Enabled() {
+			c.Next()
+			return
+		}
+		result, err := limiter.Allow(c.Request.Context(), limit, key)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Error checking rate limit", "error", err)
+			c.Next()
+			return
+		}
+
+		setRateLimitHeaders(c, limit, result)
+		if !result.Allowed {
+			c.Error(errors.TooManyRequests("Слишком много запросов, повторите позже", "Rate limit exceeded", result.RetryAfter))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// setRateLimitHeaders выставляет заголовки RateLimit-*, если предыдущий лимит
+// в цепочке не оставил меньше запросов
+func setRateLimitHeaders(c *gin.Context, limit ratelimit.Limit, result ratelimit.Result) {
+	if previous := c.Writer.Header().Get(RateLimitRemainingHeader); previous != "" && result.Allowed {
+		if remaining, err := strconv.Atoi(previous); err == nil && remaining <= result.Remaining {
+			return
+		}
+	}
+	c.Header(RateLimitLimitHeader, strconv.Itoa(result.Limit))
+	c.Header(RateLimitRemainingHeader, strconv.Itoa(result.Remaining))
+	c.Header(RateLimitResetHeader, strconv.Itoa(ceilSeconds(result.Reset)))
+	c.Header(RateLimitPolicyHeader, fmt.Sprintf("%d;w=60;burst=%d", limit.PerMinute, result.Limit))
+}
+
+// ceilSeconds округляет длительность вверх до секунд
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
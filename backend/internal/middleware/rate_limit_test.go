@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"kursovaya_backend/internal/handlers"
+	"kursovaya_backend/internal/ratelimit"
+	"kursovaya_backend/internal/repository"
+)
+
+// Тест лимитов запросов по пользователю, API-ключу и IP-адресу
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limits := RateLimits{
+		APIKey: ratelimit.Limit{Name: "api", PerMinute: 60, Burst: 1},
+		User:   ratelimit.Limit{Name: "api", PerMinute: 60, Burst: 3},
+		IP:     ratelimit.Limit{Name: "api", PerMinute: 60, Burst: 1},
+	}
+	products := ratelimit.Limit{Name: "products", PerMinute: 1, Burst: 1}
+	limiter := ratelimit.New(repository.NewMemoryRateLimits(), limits.APIKey, limits.User, limits.IP, products)
+
+	r := gin.New()
+	r.Use(handlers.GlobalErrorHandler())
+	// Заголовки X-User и X-Key заменяют аутентификацию
+	r.Use(func(c *gin.Context) {
+		if user := c.GetHeader("X-User"); user != "" {
+			c.Set("user_id", user)
+		}
+		if key := c.GetHeader("X-Key"); key != "" {
+			c.Set("api_key_id", key)
+		}
+	}, RateLimit(limiter, limits))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/stores", ok)
+	r.GET("/products", RateLimit(limiter, RateLimits{APIKey: products, User: products}), ok)
+
+	request := func(path string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	user := map[string]string{"X-User": "1"}
+
+	w := request("/stores", user)
+	if w.Code != http.StatusOK || w.Header().Get(RateLimitLimitHeader) != "3" || w.Header().Get(RateLimitRemainingHeader) != "2" ||
+		w.Header().Get(RateLimitResetHeader) != "1" || w.Header().Get(RateLimitPolicyHeader) != "60;w=60;burst=3" {
+		t.Errorf("Неожиданный ответ %d с заголовками %v", w.Code, w.Header())
+	}
+
+	// Лимит загрузки товаров строже общего, поэтому заголовки описывают его
+	w = request("/products", user)
+	if w.Code != http.StatusOK || w.Header().Get(RateLimitLimitHeader) != "1" || w.Header().Get(RateLimitRemainingHeader) != "0" {
+		t.Errorf("Ожидаются заголовки лимита товаров, получено %d, %v", w.Code, w.Header())
+	}
+	w = request("/products", user)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+		t.Errorf("Ожидается 429 с Retry-After 60, получено %d, %v", w.Code, w.Header())
+	}
+
+	// Общий лимит пользователя исчерпан тремя запросами, включая отклоненный
+	if w := request("/stores", user); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("Ожидается 429 с Retry-After 1, получено %d, %v", w.Code, w.Header())
+	}
+
+	// Другой пользователь, API-ключ того же пользователя и анонимный клиент считаются отдельно
+	for _, headers := range []map[string]string{{"X-User": "2"}, {"X-User": "1", "X-Key": "7"}, nil} {
+		if w := request("/stores", headers); w.Code != http.StatusOK {
+			t.Errorf("%v: ожидается отдельный лимит, получено %d", headers, w.Code)
+		}
+	}
+	if w := request("/stores", nil); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Ожидается лимит по IP-адресу, получено %d", w.Code)
+	}
+}
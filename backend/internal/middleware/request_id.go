@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"kursovaya_backend/pkg/utils"
+)
+
+// RequestID принимает идентификатор запроса из заголовка X-Request-ID или
+// генерирует новый, если заголовка нет или он некорректен. Идентификатор
+// добавляется в контекст запроса (его получают сервисы, лог и запросы к
+// маркетплейсам) и возвращается клиенту в том же заголовке. Подключается
+// первым, до остальных middleware.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(utils.RequestIDHeader)
+		if !utils.ValidRequestID(id) {
+			id = utils.NewRequestID()
+		}
+		c.Request = c.Request.WithContext(utils.WithRequestID(c.Request.Context(), id))
+		c.Header(utils.RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// RequestLogger записывает в лог каждый запрос: маршрут, статус, время
+// выполнения и инициатора. Ответы 5xx записываются с уровнем error.
+func RequestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		attrs := []any{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"route", c.FullPath(),
+			"status", status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"ip", c.ClientIP(),
+		}
+		if userID, ok := c.Get("user_id"); ok {
+			if c.GetBool("is_admin") {
+				attrs = append(attrs, "admin_id", userID)
+			} else {
+				attrs = append(attrs, "user_id", userID)
+			}
+		}
+		if apiKeyID, ok := c.Get("api_key_id"); ok {
+			attrs = append(attrs, "api_key_id", apiKeyID)
+		}
+
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.Log(c.Request.Context(), level, "HTTP request", attrs...)
+	}
+}
+
+// errorJSON отправляет ответ с ошибкой, добавляя в него идентификатор запроса
+func errorJSON(c *gin.Context, code int, body gin.H) {
+	body["request_id"] = utils.RequestIDFromContext(c.Request.Context())
+	c.JSON(code, body)
+}
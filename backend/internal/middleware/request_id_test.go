@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"kursovaya_backend/internal/errors"
+	"kursovaya_backend/internal/handlers"
+	"kursovaya_backend/internal/logging"
+	"kursovaya_backend/pkg/utils"
+)
+
+// Тест идентификатора запроса в заголовке, ответе с ошибкой и логе
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var logs bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(logging.New(&logs, "info", logging.FormatJSON))
+
+	r := gin.New()
+	r.Use(RequestID(), RequestLogger(), handlers.GlobalErrorHandler())
+	r.GET("/ok", func(c *gin.Context) {
+		c.String(http.StatusOK, utils.RequestIDFromContext(c.Request.Context()))
+	})
+	r.GET("/fail", func(c *gin.Context) {
+		c.Error(errors.NotFound("Магазин не найден", "store not found"))
+	})
+
+	request := func(path, id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if id != "" {
+			req.Header.Set(utils.RequestIDHeader, id)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Корректный идентификатор клиента сохраняется
+	w := request("/ok", "client-id-1")
+	if w.Header().Get(utils.RequestIDHeader) != "client-id-1" || w.Body.String() != "client-id-1" {
+		t.Errorf("Идентификатор клиента не сохранен: заголовок %q, контекст %q", w.Header().Get(utils.RequestIDHeader), w.Body.String())
+	}
+
+	// Некорректный или отсутствующий идентификатор заменяется новым
+	for _, id := range []string{"", "bad id\n", strings.Repeat("a", 129)} {
+		w = request("/ok", id)
+		generated := w.Header().Get(utils.RequestIDHeader)
+		if generated == id || !utils.ValidRequestID(generated) || w.Body.String() != generated {
+			t.Errorf("Для %q получен идентификатор %q", id, generated)
+		}
+	}
+
+	// Идентификатор возвращается в ответе с ошибкой и попадает в каждую запись лога
+	logs.Reset()
+	w = request("/fail", "client-id-2")
+	var body map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || w.Code != http.StatusNotFound || body["request_id"] != "client-id-2" {
+		t.Errorf("Неожиданный ответ %d: %s", w.Code, w.Body.String())
+	}
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Ожидались запись об ошибке и запись о запросе, получено: %s", logs.String())
+	}
+	for _, line := range lines {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil || record["request_id"] != "client-id-2" {
+			t.Errorf("Запись лога без идентификатора запроса: %s", line)
+		}
+	}
+}
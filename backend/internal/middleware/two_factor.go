@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"kursovaya_backend/internal/repository"
+	"kursovaya_backend/pkg/utils"
+)
+
+// RequireAdminTwoFactor не пропускает администратора без подключенной
+// двухфакторной аутентификации, если она обязательна (ADMIN_REQUIRE_2FA).
+// Вызывается после AdminAuthMiddleware; маршруты настройки 2FA подключаются
+// без нее, иначе администратор не сможет ее включить.
+func RequireAdminTwoFactor(twoFactor repository.TwoFactorRepository, required bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !required {
+			c.Next()
+			return
+		}
+
+		setting, err := twoFactor.Get(c.Request.Context(), utils.SubjectAdmin, c.GetInt("user_id"))
+		if err != nil && err != repository.ErrNotFound {
+			slog.ErrorContext(c.Request.Context(), "Error checking admin two-factor status", "error", err)
+			errorJSON(c, http.StatusInternalServerError, gin.H{"error": "Error verifying two-factor authentication"})
+			c.Abort()
+			return
+		}
+		if setting == nil || setting.ConfirmedAt == nil {
+			errorJSON(c, http.StatusForbidden, gin.H{
+				"error":              "Two-factor authentication must be enabled",
+				"mfa_setup_required": true,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/repository"
+	"kursovaya_backend/pkg/utils"
+)
+
+// Тест обязательной 2FA: администратор без подтвержденной настройки не допускается
+func TestRequireAdminTwoFactor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	repos := repository.NewMemory()
+
+	// Администратор 1 подтвердил 2FA, 2 только начал настройку, у 3 ее нет
+	repos.TwoFactor.Create(ctx, &models.TwoFactor{SubjectType: utils.SubjectAdmin, SubjectID: 1, Secret: "secret"})
+	repos.TwoFactor.Confirm(ctx, utils.SubjectAdmin, 1, time.Now())
+	repos.TwoFactor.Create(ctx, &models.TwoFactor{SubjectType: utils.SubjectAdmin, SubjectID: 2, Secret: "secret"})
+
+	tests := []struct {
+		name     string
+		adminID  int
+		required bool
+		want     int
+	}{
+		{"2FA подключена", 1, true, http.StatusOK},
+		{"2FA не подтверждена", 2, true, http.StatusForbidden},
+		{"2FA не настроена", 3, true, http.StatusForbidden},
+		{"2FA не обязательна", 3, false, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		r := gin.New()
+		r.GET("/admin/stats",
+			func(c *gin.Context) { c.Set("user_id", tt.adminID) },
+			RequireAdminTwoFactor(repos.TwoFactor, tt.required),
+			func(c *gin.Context) { c.Status(http.StatusOK) },
+		)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/stats", nil))
+		if w.Code != tt.want {
+			t.Errorf("%s: ожидается %d, получено %d", tt.name, tt.want, w.Code)
+		}
+	}
+}
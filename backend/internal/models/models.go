@@ -1,16 +1,44 @@
 package models
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type User struct {
 	ID       int    `json:"id"`
 	Email    string `json:"email"`
 	Password string `json:"password"` // Только для регистрации/входа
+	Role     string `json:"role"`     // Роль из пакета rbac
+	// EmailVerifiedAt - время подтверждения email, nil для неподтвержденного адреса
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	// Status - одно из значений UserActive, UserPendingVerification, UserSuspended, UserDeleted
+	Status string `json:"status"`
+	// StatusReason - причина блокировки или удаления, указанная администратором
+	StatusReason    string     `json:"status_reason,omitempty"`
+	StatusChangedAt *time.Time `json:"status_changed_at,omitempty"`
+	// ErasedAt - время удаления персональных данных, после него учетную запись не восстановить
+	ErasedAt  *time.Time `json:"erased_at,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
 }
 
+// Статусы учетной записи пользователя
+const (
+	UserActive = "active"
+	// UserPendingVerification - активный пользователь с неподтвержденным email;
+	// в базе хранится как UserActive
+	UserPendingVerification = "pending_verification"
+	UserSuspended           = "suspended"
+	UserDeleted             = "deleted"
+)
+
 type Store struct {
-	ID       int    `json:"id"`
-	UserID   int    `json:"user_id"`
-	Type     string `json:"type"`      // "wb" или "ozon"
-	APIToken string `json:"api_token"` // Токен от маркетплейса
+	ID             int        `json:"id"`
+	OrganizationID int        `json:"organization_id"` // Организация-владелец
+	UserID         int        `json:"user_id"`         // Пользователь, добавивший магазин
+	Type           string     `json:"type"`            // "wb" или "ozon"
+	APIToken       string     `json:"api_token"`       // Токен от маркетплейса
+	CreatedAt      *time.Time `json:"created_at,omitempty"`
 }
 
 type Product struct {
@@ -20,17 +48,174 @@ type Product struct {
 	Name       string `json:"name"`
 	Price      int    `json:"price"`
 	Quantity   int    `json:"quantity"`
+	// CreatedAt заполняется для товаров, сохраненных в БД
+	CreatedAt *time.Time `json:"created_at,omitempty"`
 }
 
 type ProductMapping struct {
-	ID         int `json:"id"`
-	Product1ID int `json:"product1_id"` // Товар из WB
-	Product2ID int `json:"product2_id"` // Товар из Ozon
-	UserID     int `json:"user_id"`
+	ID             int        `json:"id"`
+	OrganizationID int        `json:"organization_id"` // Организация-владелец
+	Product1ID     int        `json:"product1_id"`     // Товар из WB
+	Product2ID     int        `json:"product2_id"`     // Товар из Ozon
+	UserID         int        `json:"user_id"`         // Пользователь, создавший сопоставление
+	CreatedAt      *time.Time `json:"created_at,omitempty"`
 }
 
 type Admin struct {
 	ID       int    `json:"id"`
 	Username string `json:"username"`
 	Password string `json:"password"` // Только для регистрации/входа
+	Role     string `json:"role"`     // Роль из пакета rbac
+	// MustChangePassword - пароль задан другим администратором и должен быть сменен при входе
+	MustChangePassword bool       `json:"must_change_password"`
+	DisabledAt         *time.Time `json:"disabled_at,omitempty"`
+	// TokenVersion увеличивается при смене пароля и отключении; выданные
+	// раньше токены администратора перестают приниматься
+	TokenVersion int       `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Session - refresh-токен пользователя. Токены одной цепочки ротации
+// объединены общим FamilyID, который также передается в access-токене.
+type Session struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	FamilyID  string     `json:"family_id"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Organization - команда, которой принадлежат магазины и сопоставления
+type Organization struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// OrganizationMember - участие пользователя в организации с ролью из пакета rbac
+type OrganizationMember struct {
+	OrganizationID   int    `json:"organization_id"`
+	OrganizationName string `json:"organization_name,omitempty"`
+	UserID           int    `json:"user_id"`
+	Email            string `json:"email,omitempty"`
+	Role             string `json:"role"`
+}
+
+// Invitation - приглашение в организацию. Принять его может только
+// пользователь с указанным email, предъявив одноразовый токен.
+type Invitation struct {
+	ID             int        `json:"id"`
+	OrganizationID int        `json:"organization_id"`
+	Email          string     `json:"email"`
+	Role           string     `json:"role"`
+	TokenHash      string     `json:"-"`
+	InvitedBy      int        `json:"invited_by"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	AcceptedAt     *time.Time `json:"accepted_at,omitempty"`
+}
+
+// TwoFactor - настройка TOTP пользователя или администратора. Пока ConfirmedAt
+// не задан, настройка начата, но вход по-прежнему выполняется только по паролю.
+type TwoFactor struct {
+	SubjectType string     `json:"subject_type"`
+	SubjectID   int        `json:"subject_id"`
+	Secret      string     `json:"-"` // Зашифрованный секрет в base32
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty"`
+	// LastUsedStep - последний принятый 30-секундный шаг TOTP
+	LastUsedStep int64 `json:"-"`
+}
+
+// Назначения одноразовых токенов пользователя
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+)
+
+// UserToken - одноразовый токен, отправляемый пользователю по email.
+// В базе хранится только хеш, открытый токен есть лишь в письме.
+type UserToken struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	Purpose   string     `json:"purpose"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+// LoginAttempt - счетчик неудачных попыток входа для учетной записи или IP-адреса.
+// Key имеет вид "user:<email>", "admin:<логин>" или "ip:<адрес>".
+type LoginAttempt struct {
+	ID            int        `json:"-"`
+	Key           string     `json:"key"`
+	Failures      int        `json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+}
+
+// APIKey - персональный ключ пользователя для доступа к API из скриптов.
+// Открытый ключ показывается один раз при создании, в базе хранится только хеш,
+// а Prefix - начало ключа, по которому его можно узнать в списке.
+type APIKey struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Типы инициаторов событий аудита
+const (
+	ActorUser   = "user"
+	ActorAdmin  = "admin"
+	ActorAPIKey = "api_key"
+	ActorSystem = "system" // Действие сервера без запроса: запуск, команды, блокировки
+)
+
+// AuditEvent - запись журнала аудита. Журнал только дополняется: события не
+// изменяются и не удаляются. Before и After - JSON-снимки цели до и после
+// изменения, без токенов и хешей паролей.
+type AuditEvent struct {
+	ID        int    `json:"id"`
+	ActorType string `json:"actor_type"`
+	ActorID   *int   `json:"actor_id,omitempty"`
+	APIKeyID  *int   `json:"api_key_id,omitempty"`
+	// ImpersonatorID - администратор, действовавший от имени пользователя ActorID
+	ImpersonatorID *int            `json:"impersonator_id,omitempty"`
+	Action         string          `json:"action"`
+	TargetType     string          `json:"target_type,omitempty"`
+	TargetID       string          `json:"target_id,omitempty"`
+	Before         json.RawMessage `json:"before,omitempty"`
+	After          json.RawMessage `json:"after,omitempty"`
+	IP             string          `json:"ip,omitempty"`
+	UserAgent      string          `json:"user_agent,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	// Impersonated отмечает в истории пользователя действия, которые от его
+	// имени выполнил администратор; ImpersonatorID там скрывается
+	Impersonated bool `json:"impersonated,omitempty"`
+}
+
+// Результаты загрузки товаров магазина
+const (
+	SyncSucceeded = "success"
+	SyncFailed    = "failed"
+)
+
+// SyncRun - запись о загрузке товаров магазина из маркетплейса. Магазин может
+// быть уже удален, поэтому тип маркетплейса сохраняется вместе с записью.
+type SyncRun struct {
+	ID        int    `json:"id"`
+	StoreID   int    `json:"store_id"`
+	StoreType string `json:"store_type"`
+	Status    string `json:"status"` // SyncSucceeded или SyncFailed
+	// ErrorCategory - категория ошибки неудачной загрузки: auth, rate_limit и т. д.
+	ErrorCategory string    `json:"error_category,omitempty"`
+	Products      int       `json:"products"`
+	DurationMs    int64     `json:"duration_ms"`
+	StartedAt     time.Time `json:"started_at"`
 }
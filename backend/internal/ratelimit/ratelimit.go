@@ -0,0 +1,121 @@
+// Package ratelimit ограничивает частоту запросов по алгоритму token bucket.
+// У каждого клиента своя корзина: она пополняется равномерно, каждый запрос
+// забирает из нее токен, а пустая корзина означает отказ до пополнения.
+// Корзины хранятся в repository.RateLimitRepository - в памяти одного
+// экземпляра или в базе, общей для реплик.
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"kursovaya_backend/internal/repository"
+)
+
+// cleanupInterval - как часто удаляются неиспользуемые корзины
+const cleanupInterval = 10 * time.Minute
+
+// Limit - лимит запросов: PerMinute запросов в минуту в среднем и до Burst
+// запросов подряд. Name входит в ключ корзины, поэтому у лимитов с разными
+// именами корзины независимы. Лимит с PerMinute 0 отключен.
+type Limit struct {
+	Name      string
+	PerMinute int
+	Burst     int
+}
+
+// Enabled сообщает, ограничивает ли лимит запросы
+func (l Limit) Enabled() bool {
+	return l.PerMinute > 0
+}
+
+// capacity - емкость корзины, не меньше одного запроса
+func (l Limit) capacity() int {
+	return max(l.Burst, 1)
+}
+
+// interval - время пополнения корзины на один токен
+func (l Limit) interval() time.Duration {
+	return time.Minute / time.Duration(l.PerMinute)
+}
+
+// Result - результат проверки лимита для заголовков ответа
+type Result struct {
+	Allowed bool
+	// Limit - емкость корзины, Remaining - сколько запросов подряд еще можно выполнить
+	Limit     int
+	Remaining int
+	// Reset - через сколько корзина пополнится полностью
+	Reset time.Duration
+	// RetryAfter - через сколько появится токен, если запрос отклонен
+	RetryAfter time.Duration
+}
+
+// Limiter проверяет лимиты и время от времени удаляет корзины, которые уже
+// пополнились бы полностью: новая корзина создается полной, так что
+// удаление не меняет результат, но не дает хранилищу расти с числом клиентов
+type Limiter struct {
+	buckets repository.RateLimitRepository
+	now     func() time.Time
+	// idle - время полного пополнения самой медленной корзины
+	idle time.Duration
+
+	mu          sync.Mutex
+	nextCleanup time.Time
+}
+
+// New создает проверку лимитов limits с корзинами в buckets
+func New(buckets repository.RateLimitRepository, limits ...Limit) *Limiter {
+	l := &Limiter{buckets: buckets, now: time.Now}
+	for _, limit := range limits {
+		if limit.Enabled() {
+			l.idle = max(l.idle, time.Duration(limit.capacity())*limit.interval())
+		}
+	}
+	return l
+}
+
+// Allow забирает токен из корзины клиента key для лимита limit. Отключенный
+// лимит пропускает любой запрос.
+func (l *Limiter) Allow(ctx context.Context, limit Limit, key string) (Result, error) {
+	if !limit.Enabled() {
+		return Result{Allowed: true}, nil
+	}
+
+	now := l.now()
+	capacity, interval := limit.capacity(), limit.interval()
+	allowed, tokens, err := l.buckets.Take(ctx, limit.Name+":"+key, capacity, interval, now)
+	if err != nil {
+		return Result{}, err
+	}
+	l.cleanup(ctx, now)
+
+	result := Result{
+		Allowed:   allowed,
+		Limit:     capacity,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration((float64(capacity) - tokens) * float64(interval)),
+	}
+	if !allowed {
+		result.RetryAfter = time.Duration((1 - tokens) * float64(interval))
+	}
+	return result, nil
+}
+
+// cleanup удаляет неиспользуемые корзины не чаще раза в cleanupInterval
+func (l *Limiter) cleanup(ctx context.Context, now time.Time) {
+	l.mu.Lock()
+	if now.Before(l.nextCleanup) {
+		l.mu.Unlock()
+		return
+	}
+	l.nextCleanup = now.Add(cleanupInterval)
+	l.mu.Unlock()
+
+	if err := l.buckets.DeleteStale(ctx, now.Add(-max(l.idle, cleanupInterval))); err != nil {
+		slog.ErrorContext(ctx, "Ошибка очистки корзин ограничения запросов", "error", err)
+	}
+}
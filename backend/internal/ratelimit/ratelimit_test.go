@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kursovaya_backend/internal/repository"
+)
+
+// Тест выдачи токенов, расчета сброса и очистки корзин
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	limit := Limit{Name: "api", PerMinute: 60, Burst: 2}
+	limiter := New(repository.NewMemoryRateLimits(), limit)
+	limiter.now = func() time.Time { return now }
+
+	result, err := limiter.Allow(ctx, limit, "user:1")
+	if err != nil {
+		t.Fatalf("Ошибка проверки лимита: %v", err)
+	}
+	if !result.Allowed || result.Limit != 2 || result.Remaining != 1 || result.Reset != time.Second {
+		t.Errorf("Неожиданный результат первого запроса: %+v", result)
+	}
+	limiter.Allow(ctx, limit, "user:1")
+	result, _ = limiter.Allow(ctx, limit, "user:1")
+	if result.Allowed || result.Remaining != 0 || result.RetryAfter != time.Second || result.Reset != 2*time.Second {
+		t.Errorf("Ожидается отказ на секунду, получено %+v", result)
+	}
+
+	// Лимит с тем же ключом, но другим именем, считается отдельно
+	if result, _ := limiter.Allow(ctx, Limit{Name: "products", PerMinute: 1}, "user:1"); !result.Allowed || result.Limit != 1 {
+		t.Errorf("Ожидается отдельная корзина емкостью 1, получено %+v", result)
+	}
+
+	// Через полсекунды токена еще нет
+	now = now.Add(500 * time.Millisecond)
+	if result, _ := limiter.Allow(ctx, limit, "user:1"); result.Allowed || result.RetryAfter != 500*time.Millisecond {
+		t.Errorf("Ожидается отказ на полсекунды, получено %+v", result)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if result, _ := limiter.Allow(ctx, limit, "user:1"); !result.Allowed || result.Remaining != 0 {
+		t.Errorf("Ожидается пополнение через секунду, получено %+v", result)
+	}
+
+	// Отключенный лимит пропускает любой запрос
+	for i := 0; i < 10; i++ {
+		if result, _ := limiter.Allow(ctx, Limit{Name: "off"}, "user:1"); !result.Allowed {
+			t.Fatal("Отключенный лимит не должен отклонять запросы")
+		}
+	}
+}
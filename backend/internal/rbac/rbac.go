@@ -0,0 +1,132 @@
+// Package rbac описывает роли и разрешения пользователей и администраторов
+package rbac
+
+// Permission - разрешение на действие в формате "ресурс:действие"
+type Permission string
+
+// Разрешения пользователей на работу с данными организации. Роль пользователя
+// в организации задается отдельно для каждого членства теми же значениями.
+const (
+	StoresRead    Permission = "stores:read"
+	StoresWrite   Permission = "stores:write"
+	ProductsRead  Permission = "products:read"
+	ProductsWrite Permission = "products:write"
+	MappingsRead  Permission = "mappings:read"
+	MappingsWrite Permission = "mappings:write"
+	MembersWrite  Permission = "members:write"
+)
+
+// Разрешения администраторов
+const (
+	AdminStatsRead      Permission = "admin:stats:read"
+	AdminUsersRead      Permission = "admin:users:read"
+	AdminUsersWrite     Permission = "admin:users:write"
+	AdminUsersDelete    Permission = "admin:users:delete"
+	AdminStoresRead     Permission = "admin:stores:read"
+	AdminStoresDelete   Permission = "admin:stores:delete"
+	AdminProductsRead   Permission = "admin:products:read"
+	AdminProductsDelete Permission = "admin:products:delete"
+	AdminMappingsRead   Permission = "admin:mappings:read"
+	AdminMappingsDelete Permission = "admin:mappings:delete"
+	AdminRolesRead      Permission = "admin:roles:read"
+	AdminRolesWrite     Permission = "admin:roles:write"
+	AdminAdminsRead     Permission = "admin:admins:read"
+	AdminAdminsWrite    Permission = "admin:admins:write"
+	AdminAuditRead      Permission = "admin:audit:read"
+
+	// AdminUsersImpersonate - вход от имени пользователя только для чтения;
+	// для изменяющих запросов от его имени нужно также AdminUsersWrite
+	AdminUsersImpersonate Permission = "admin:users:impersonate"
+)
+
+// Роли пользователей
+const (
+	// RoleViewer только просматривает магазины, товары и сопоставления
+	RoleViewer = "viewer"
+	// RoleEditor дополнительно сохраняет товары и управляет сопоставлениями
+	RoleEditor = "editor"
+	// RoleOwner полностью управляет данными, включая магазины и участников организации
+	RoleOwner = "owner"
+
+	// DefaultUserRole назначается при регистрации
+	DefaultUserRole = RoleOwner
+)
+
+// Роли администраторов
+const (
+	// RoleSupport просматривает данные всех пользователей без права изменения
+	RoleSupport = "support"
+	// RoleSuperadmin имеет все административные разрешения
+	RoleSuperadmin = "superadmin"
+
+	// DefaultAdminRole назначается первому администратору
+	DefaultAdminRole = RoleSuperadmin
+)
+
+// userRoles и adminRoles - разрешения каждой роли
+var (
+	userRoles = map[string][]Permission{
+		RoleViewer: {StoresRead, ProductsRead, MappingsRead},
+		RoleEditor: {StoresRead, ProductsRead, MappingsRead, ProductsWrite, MappingsWrite},
+		RoleOwner:  {StoresRead, ProductsRead, MappingsRead, ProductsWrite, MappingsWrite, StoresWrite, MembersWrite},
+	}
+	adminRoles = map[string][]Permission{
+		RoleSupport: {
+			AdminStatsRead, AdminUsersRead, AdminStoresRead, AdminProductsRead, AdminMappingsRead, AdminRolesRead,
+			AdminUsersImpersonate,
+		},
+		RoleSuperadmin: {
+			AdminStatsRead, AdminUsersRead, AdminStoresRead, AdminProductsRead, AdminMappingsRead, AdminRolesRead,
+			AdminUsersWrite, AdminUsersDelete, AdminStoresDelete, AdminProductsDelete, AdminMappingsDelete, AdminRolesWrite,
+			AdminAdminsRead, AdminAdminsWrite, AdminAuditRead, AdminUsersImpersonate,
+		},
+	}
+)
+
+// IsUserRole проверяет, что роль может быть назначена пользователю
+func IsUserRole(role string) bool {
+	_, ok := userRoles[role]
+	return ok
+}
+
+// IsAdminRole проверяет, что роль может быть назначена администратору
+func IsAdminRole(role string) bool {
+	_, ok := adminRoles[role]
+	return ok
+}
+
+// Permissions возвращает разрешения роли или nil для неизвестной роли
+func Permissions(role string) []Permission {
+	if permissions, ok := userRoles[role]; ok {
+		return permissions
+	}
+	return adminRoles[role]
+}
+
+// HasPermission проверяет, что роль имеет разрешение
+func HasPermission(role string, permission Permission) bool {
+	for _, p := range Permissions(role) {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// UserRoles возвращает роли пользователей с их разрешениями
+func UserRoles() map[string][]Permission {
+	return copyRoles(userRoles)
+}
+
+// AdminRoles возвращает роли администраторов с их разрешениями
+func AdminRoles() map[string][]Permission {
+	return copyRoles(adminRoles)
+}
+
+func copyRoles(roles map[string][]Permission) map[string][]Permission {
+	result := make(map[string][]Permission, len(roles))
+	for role, permissions := range roles {
+		result[role] = append([]Permission(nil), permissions...)
+	}
+	return result
+}
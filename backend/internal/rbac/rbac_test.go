@@ -0,0 +1,47 @@
+package rbac
+
+import "testing"
+
+func TestRolePermissions(t *testing.T) {
+	tests := []struct {
+		role       string
+		permission Permission
+		want       bool
+	}{
+		{RoleViewer, StoresRead, true},
+		{RoleViewer, MappingsWrite, false},
+		{RoleEditor, MappingsWrite, true},
+		{RoleEditor, StoresWrite, false},
+		{RoleOwner, StoresWrite, true},
+		{RoleOwner, MembersWrite, true},
+		{RoleEditor, MembersWrite, false},
+		{RoleOwner, AdminUsersRead, false},
+		{RoleSupport, AdminUsersRead, true},
+		{RoleSupport, AdminUsersDelete, false},
+		{RoleSuperadmin, AdminUsersDelete, true},
+		{RoleSupport, AdminUsersWrite, false},
+		{RoleSuperadmin, AdminUsersWrite, true},
+		{RoleSuperadmin, StoresWrite, false},
+		{RoleSuperadmin, AdminAdminsWrite, true},
+		{RoleSupport, AdminAdminsRead, false},
+		{RoleSuperadmin, AdminAuditRead, true},
+		{RoleSupport, AdminAuditRead, false},
+		{"unknown", StoresRead, false},
+		{"", StoresRead, false},
+	}
+
+	for _, tt := range tests {
+		if got := HasPermission(tt.role, tt.permission); got != tt.want {
+			t.Errorf("HasPermission(%q, %q) = %v, ожидается %v", tt.role, tt.permission, got, tt.want)
+		}
+	}
+}
+
+func TestRoleKinds(t *testing.T) {
+	if !IsUserRole(DefaultUserRole) || IsUserRole(RoleSuperadmin) {
+		t.Error("Роли администраторов не должны назначаться пользователям")
+	}
+	if !IsAdminRole(DefaultAdminRole) || IsAdminRole(RoleOwner) {
+		t.Error("Роли пользователей не должны назначаться администраторам")
+	}
+}
@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"kursovaya_backend/internal/database"
+	"kursovaya_backend/internal/models"
+)
+
+// AdminRepository описывает хранилище администраторов
+type AdminRepository interface {
+	Create(ctx context.Context, username, passwordHash, role string, mustChangePassword bool) (*models.Admin, error)
+	// GetCredentials возвращает администратора и хеш его пароля по логину
+	GetCredentials(ctx context.Context, username string) (*models.Admin, string, error)
+	// GetPasswordHash возвращает хеш пароля администратора по ID
+	GetPasswordHash(ctx context.Context, id int) (string, error)
+	Exists(ctx context.Context, id int) (bool, error)
+	GetByID(ctx context.Context, id int) (*models.Admin, error)
+	// List возвращает всех администраторов по возрастанию ID
+	List(ctx context.Context) ([]models.Admin, error)
+	// SetRole меняет роль администратора
+	SetRole(ctx context.Context, id int, role string) error
+	// SetPassword меняет хеш пароля и признак обязательной смены пароля
+	// и увеличивает версию токенов, отзывая выданные токены
+	SetPassword(ctx context.Context, id int, passwordHash string, mustChangePassword bool) error
+	// SetDisabled отключает администратора с момента at или включает при nil.
+	// Отключение увеличивает версию токенов, чтобы после повторного включения
+	// выданные раньше токены не стали снова действительными.
+	SetDisabled(ctx context.Context, id int, at *time.Time) error
+	Delete(ctx context.Context, id int) error
+	Count(ctx context.Context) (int, error)
+	// CountActiveWithRole считает включенных администраторов с ролью
+	CountActiveWithRole(ctx context.Context, role string) (int, error)
+
+	// SaveBootstrapToken сохраняет токен создания первого администратора
+	// или продлевает срок действия уже сохраненного
+	SaveBootstrapToken(ctx context.Context, tokenHash string, expiresAt time.Time) error
+	// ConsumeBootstrapToken удаляет действующий токен; ErrNotFound, если
+	// токена нет или он истек. Так токен нельзя использовать дважды.
+	ConsumeBootstrapToken(ctx context.Context, tokenHash string, now time.Time) error
+	// DeleteBootstrapTokens удаляет все токены после создания первого администратора
+	DeleteBootstrapTokens(ctx context.Context) error
+}
+
+type sqlAdminRepository struct {
+	db database.DBTX
+}
+
+const adminColumns = "id, username, role, must_change_password, disabled_at, token_version, created_at"
+
+func scanAdmin(row rowScanner, extra ...any) (*models.Admin, error) {
+	var admin models.Admin
+	var disabledAt, createdAt sql.NullTime
+	dest := append([]any{&admin.ID, &admin.Username, &admin.Role, &admin.MustChangePassword, &disabledAt, &admin.TokenVersion, &createdAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	if disabledAt.Valid {
+		admin.DisabledAt = &disabledAt.Time
+	}
+	admin.CreatedAt = createdAt.Time
+	return &admin, nil
+}
+
+func (r *sqlAdminRepository) Create(ctx context.Context, username, passwordHash, role string, mustChangePassword bool) (*models.Admin, error) {
+	admin, err := scanAdmin(r.db.QueryRowContext(ctx,
+		"INSERT INTO admins (username, password, role, must_change_password) VALUES ($1, $2, $3, $4) RETURNING "+adminColumns,
+		username, passwordHash, role, mustChangePassword,
+	))
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return admin, nil
+}
+
+func (r *sqlAdminRepository) GetCredentials(ctx context.Context, username string) (*models.Admin, string, error) {
+	var hashedPassword string
+	admin, err := scanAdmin(r.db.QueryRowContext(ctx, "SELECT "+adminColumns+", password FROM admins WHERE username = $1", username), &hashedPassword)
+	if err != nil {
+		return nil, "", mapError(err)
+	}
+	return admin, hashedPassword, nil
+}
+
+func (r *sqlAdminRepository) GetPasswordHash(ctx context.Context, id int) (string, error) {
+	var hashedPassword string
+	err := r.db.QueryRowContext(ctx, "SELECT password FROM admins WHERE id = $1", id).Scan(&hashedPassword)
+	if err != nil {
+		return "", mapError(err)
+	}
+	return hashedPassword, nil
+}
+
+func (r *sqlAdminRepository) Exists(ctx context.Context, id int) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM admins WHERE id = $1", id).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *sqlAdminRepository) GetByID(ctx context.Context, id int) (*models.Admin, error) {
+	admin, err := scanAdmin(r.db.QueryRowContext(ctx, "SELECT "+adminColumns+" FROM admins WHERE id = $1", id))
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return admin, nil
+}
+
+func (r *sqlAdminRepository) List(ctx context.Context) ([]models.Admin, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+adminColumns+" FROM admins ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	admins := []models.Admin{}
+	for rows.Next() {
+		admin, err := scanAdmin(rows)
+		if err != nil {
+			return nil, err
+		}
+		admins = append(admins, *admin)
+	}
+	return admins, rows.Err()
+}
+
+func (r *sqlAdminRepository) SetRole(ctx context.Context, id int, role string) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE admins SET role = $1 WHERE id = $2", role, id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqlAdminRepository) SetPassword(ctx context.Context, id int, passwordHash string, mustChangePassword bool) error {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE admins SET password = $1, must_change_password = $2, token_version = token_version + 1 WHERE id = $3",
+		passwordHash, mustChangePassword, id,
+	)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqlAdminRepository) SetDisabled(ctx context.Context, id int, at *time.Time) error {
+	var disabledAt *time.Time
+	if at != nil {
+		utc := at.UTC()
+		disabledAt = &utc
+	}
+	query := "UPDATE admins SET disabled_at = $1 WHERE id = $2"
+	if disabledAt != nil {
+		query = "UPDATE admins SET disabled_at = $1, token_version = token_version + 1 WHERE id = $2"
+	}
+	result, err := r.db.ExecContext(ctx, query, disabledAt, id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqlAdminRepository) Delete(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM admins WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqlAdminRepository) Count(ctx context.Context) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM admins").Scan(&count)
+	return count, err
+}
+
+func (r *sqlAdminRepository) CountActiveWithRole(ctx context.Context, role string) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM admins WHERE role = $1 AND disabled_at IS NULL", role,
+	).Scan(&count)
+	return count, err
+}
+
+func (r *sqlAdminRepository) SaveBootstrapToken(ctx context.Context, tokenHash string, expiresAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO admin_bootstrap_tokens (token_hash, expires_at) VALUES ($1, $2)
+		ON CONFLICT (token_hash) DO UPDATE SET expires_at = excluded.expires_at`,
+		tokenHash, expiresAt.UTC(),
+	)
+	return err
+}
+
+func (r *sqlAdminRepository) ConsumeBootstrapToken(ctx context.Context, tokenHash string, now time.Time) error {
+	result, err := r.db.ExecContext(ctx,
+		"DELETE FROM admin_bootstrap_tokens WHERE token_hash = $1 AND expires_at > $2",
+		tokenHash, now.UTC(),
+	)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqlAdminRepository) DeleteBootstrapTokens(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM admin_bootstrap_tokens")
+	return err
+}
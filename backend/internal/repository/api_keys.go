@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"kursovaya_backend/internal/database"
+	"kursovaya_backend/internal/models"
+)
+
+// APIKeyRepository описывает хранилище персональных API-ключей
+type APIKeyRepository interface {
+	// Create сохраняет ключ и заполняет его ID и CreatedAt
+	Create(ctx context.Context, key *models.APIKey) error
+	GetByKeyHash(ctx context.Context, keyHash string) (*models.APIKey, error)
+	// ListByUser возвращает ключи пользователя, включая отозванные и истекшие
+	ListByUser(ctx context.Context, userID int) ([]models.APIKey, error)
+	// Revoke отзывает действующий ключ пользователя. Возвращает ErrNotFound,
+	// если ключа нет, он чужой или уже отозван.
+	Revoke(ctx context.Context, userID, id int, at time.Time) error
+	// TouchLastUsed обновляет время последнего использования, если оно
+	// раньше notAfter: так каждый запрос по ключу не приводит к записи в базу
+	TouchLastUsed(ctx context.Context, id int, at, notAfter time.Time) error
+}
+
+type sqlAPIKeyRepository struct {
+	db database.DBTX
+}
+
+const apiKeyColumns = "id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, revoked_at, created_at"
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	var key models.APIKey
+	var scopes string
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.KeyHash, &scopes,
+		&expiresAt, &lastUsedAt, &revokedAt, &key.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	key.Scopes = splitScopes(scopes)
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return &key, nil
+}
+
+// splitScopes разбирает разрешения, хранящиеся через запятую
+func splitScopes(scopes string) []string {
+	if scopes == "" {
+		return []string{}
+	}
+	return strings.Split(scopes, ",")
+}
+
+func (r *sqlAPIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	var expiresAt *time.Time
+	if key.ExpiresAt != nil {
+		utc := key.ExpiresAt.UTC()
+		expiresAt = &utc
+	}
+	err := r.db.QueryRowContext(ctx,
+		"INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at",
+		key.UserID, key.Name, key.Prefix, key.KeyHash, strings.Join(key.Scopes, ","), expiresAt,
+	).Scan(&key.ID, &key.CreatedAt)
+	return mapError(err)
+}
+
+func (r *sqlAPIKeyRepository) GetByKeyHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx,
+		"SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash = $1", keyHash,
+	))
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return key, nil
+}
+
+func (r *sqlAPIKeyRepository) ListByUser(ctx context.Context, userID int) ([]models.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT "+apiKeyColumns+" FROM api_keys WHERE user_id = $1 ORDER BY id", userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+func (r *sqlAPIKeyRepository) Revoke(ctx context.Context, userID, id int, at time.Time) error {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE api_keys SET revoked_at = $1 WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL",
+		at.UTC(), id, userID,
+	)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqlAPIKeyRepository) TouchLastUsed(ctx context.Context, id int, at, notAfter time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE api_keys SET last_used_at = $1 WHERE id = $2 AND (last_used_at IS NULL OR last_used_at < $3)",
+		at.UTC(), id, notAfter.UTC(),
+	)
+	return err
+}
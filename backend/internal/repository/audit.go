@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"kursovaya_backend/internal/database"
+	"kursovaya_backend/internal/models"
+)
+
+// AuditRepository описывает журнал аудита. Методов изменения и удаления
+// намеренно нет: журнал только дополняется.
+type AuditRepository interface {
+	// Create сохраняет событие и заполняет его ID
+	Create(ctx context.Context, event *models.AuditEvent) error
+	// List возвращает события по убыванию ID
+	List(ctx context.Context, filter AuditFilter) ([]models.AuditEvent, error)
+}
+
+// AuditFilter - условия выборки журнала аудита, пустые поля не ограничивают выборку
+type AuditFilter struct {
+	ActorType string
+	ActorID   int
+	// ImpersonatorID - действия, выполненные администратором от имени пользователя
+	ImpersonatorID int
+	Action         string
+	TargetType     string
+	TargetID       string
+	// UserID выбирает события, которые пользователь выполнил сам (при входе
+	// или по API-ключу), и действия над его учетной записью
+	UserID int
+	From   time.Time
+	To     time.Time
+	// BeforeID - курсор страницы: только события с меньшим ID
+	BeforeID int
+	Limit    int
+}
+
+type sqlAuditRepository struct {
+	db database.DBTX
+}
+
+const auditColumns = "id, actor_type, actor_id, api_key_id, impersonator_id, action, target_type, target_id, before_state, after_state, ip, user_agent, created_at"
+
+func scanAuditEvent(row rowScanner) (*models.AuditEvent, error) {
+	var event models.AuditEvent
+	var actorID, apiKeyID, impersonatorID sql.NullInt64
+	var before, after sql.NullString
+	err := row.Scan(&event.ID, &event.ActorType, &actorID, &apiKeyID, &impersonatorID, &event.Action, &event.TargetType, &event.TargetID,
+		&before, &after, &event.IP, &event.UserAgent, &event.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if actorID.Valid {
+		id := int(actorID.Int64)
+		event.ActorID = &id
+	}
+	if apiKeyID.Valid {
+		id := int(apiKeyID.Int64)
+		event.APIKeyID = &id
+	}
+	if impersonatorID.Valid {
+		id := int(impersonatorID.Int64)
+		event.ImpersonatorID = &id
+	}
+	if before.Valid {
+		event.Before = json.RawMessage(before.String)
+	}
+	if after.Valid {
+		event.After = json.RawMessage(after.String)
+	}
+	return &event, nil
+}
+
+// nullableJSON сохраняет пустой снимок как NULL
+func nullableJSON(state json.RawMessage) any {
+	if len(state) == 0 {
+		return nil
+	}
+	return string(state)
+}
+
+func (r *sqlAuditRepository) Create(ctx context.Context, event *models.AuditEvent) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO audit_events (actor_type, actor_id, api_key_id, impersonator_id, action, target_type, target_id, before_state, after_state, ip, user_agent, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id`,
+		event.ActorType, event.ActorID, event.APIKeyID, event.ImpersonatorID, event.Action, event.TargetType, event.TargetID,
+		nullableJSON(event.Before), nullableJSON(event.After), event.IP, event.UserAgent, event.CreatedAt.UTC(),
+	).Scan(&event.ID)
+	return mapError(err)
+}
+
+func (r *sqlAuditRepository) List(ctx context.Context, filter AuditFilter) ([]models.AuditEvent, error) {
+	var conditions []string
+	var args []any
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.ActorType != "" {
+		conditions = append(conditions, "actor_type = "+arg(filter.ActorType))
+	}
+	if filter.ActorID != 0 {
+		conditions = append(conditions, "actor_id = "+arg(filter.ActorID))
+	}
+	if filter.ImpersonatorID != 0 {
+		conditions = append(conditions, "impersonator_id = "+arg(filter.ImpersonatorID))
+	}
+	if filter.Action != "" {
+		conditions = append(conditions, "action = "+arg(filter.Action))
+	}
+	if filter.TargetType != "" {
+		conditions = append(conditions, "target_type = "+arg(filter.TargetType))
+	}
+	if filter.TargetID != "" {
+		conditions = append(conditions, "target_id = "+arg(filter.TargetID))
+	}
+	if filter.UserID != 0 {
+		conditions = append(conditions, fmt.Sprintf(
+			"((actor_type IN ('%s', '%s') AND actor_id = %s) OR (target_type = 'user' AND target_id = %s))",
+			models.ActorUser, models.ActorAPIKey, arg(filter.UserID), arg(strconv.Itoa(filter.UserID)),
+		))
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "created_at >= "+arg(filter.From.UTC()))
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "created_at < "+arg(filter.To.UTC()))
+	}
+	if filter.BeforeID != 0 {
+		conditions = append(conditions, "id < "+arg(filter.BeforeID))
+	}
+
+	query := "SELECT " + auditColumns + " FROM audit_events"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT " + arg(filter.Limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.AuditEvent{}
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *event)
+	}
+	return events, rows.Err()
+}
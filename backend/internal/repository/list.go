@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"kursovaya_backend/internal/database"
+)
+
+// ListQuery - общие параметры административных списков: фильтры, сортировка
+// и страница. Пустые поля не ограничивают выборку, а фильтры, не относящиеся
+// к списку, не учитываются.
+type ListQuery struct {
+	// Email - часть email пользователя без учета регистра
+	Email string
+	// Status - статус пользователя из models.UserActive и т. д.
+	Status string
+	// Name - часть названия товара без учета регистра
+	Name      string
+	StoreType string
+	// UserID - пользователь, добавивший магазин (для товаров - их магазин)
+	// или создавший сопоставление
+	UserID      int
+	StoreID     int
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+
+	// Sort - поле из UserSortFields, StoreSortFields и т. д., пустое - id
+	Sort  string
+	Desc  bool
+	Limit int
+	// Offset учитывается только вместе с Limit
+	Offset int
+	// AfterID - курсор при сортировке по id: только записи, идущие после
+	// записи с этим ID в выбранном порядке
+	AfterID int
+	// SkipTotal отключает подсчет общего числа записей, например при
+	// выгрузке всей таблицы порциями; List тогда возвращает total 0
+	SkipTotal bool
+}
+
+// Поля сортировки административных списков
+var (
+	UserSortFields    = []string{"id", "email", "role", "created_at"}
+	StoreSortFields   = []string{"id", "store_type", "user_id", "created_at"}
+	ProductSortFields = []string{"id", "name", "price", "quantity", "created_at"}
+	MappingSortFields = []string{"id", "user_id", "created_at"}
+)
+
+// listBuilder собирает условия WHERE административного списка
+type listBuilder struct {
+	conditions []string
+	args       []any
+}
+
+func (b *listBuilder) arg(value any) string {
+	b.args = append(b.args, value)
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+// add добавляет условие, в котором %s заменяется параметром value
+func (b *listBuilder) add(condition string, value any) {
+	b.conditions = append(b.conditions, fmt.Sprintf(condition, b.arg(value)))
+}
+
+// contains добавляет поиск подстроки без учета регистра
+func (b *listBuilder) contains(column, value string) {
+	if value == "" {
+		return
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(strings.ToLower(value))
+	b.add("LOWER("+column+`) LIKE %s ESCAPE '\'`, "%"+escaped+"%")
+}
+
+// created добавляет ограничение created_at по периоду [CreatedFrom, CreatedTo)
+func (b *listBuilder) created(column string, query ListQuery) {
+	if !query.CreatedFrom.IsZero() {
+		b.add(column+" >= %s", query.CreatedFrom.UTC())
+	}
+	if !query.CreatedTo.IsZero() {
+		b.add(column+" < %s", query.CreatedTo.UTC())
+	}
+}
+
+func (b *listBuilder) where() string {
+	if len(b.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(b.conditions, " AND ")
+}
+
+// run считает подходящие записи и выбирает страницу. from - часть запроса
+// после SELECT-списка, columns сопоставляет поля сортировки колонкам, а
+// колонка "id" служит курсором и вторым ключом сортировки.
+func (b *listBuilder) run(ctx context.Context, db database.DBTX, selectList, from string, columns map[string]string, query ListQuery) (*sql.Rows, int, error) {
+	var total int
+	if !query.SkipTotal {
+		if err := db.QueryRowContext(ctx, "SELECT COUNT(*) "+from+b.where(), b.args...).Scan(&total); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	idColumn := columns["id"]
+	direction, compare := "ASC", ">"
+	if query.Desc {
+		direction, compare = "DESC", "<"
+	}
+	if query.AfterID != 0 {
+		b.add(idColumn+" "+compare+" %s", query.AfterID)
+	}
+
+	sortColumn, ok := columns[query.Sort]
+	if !ok {
+		sortColumn = idColumn
+	}
+	sqlQuery := "SELECT " + selectList + " " + from + b.where() + " ORDER BY " + sortColumn + " " + direction
+	if sortColumn != idColumn {
+		sqlQuery += ", " + idColumn + " " + direction
+	}
+	if query.Limit > 0 {
+		sqlQuery += " LIMIT " + b.arg(query.Limit) + " OFFSET " + b.arg(query.Offset)
+	}
+
+	rows, err := db.QueryContext(ctx, sqlQuery, b.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return rows, total, nil
+}
+
+// containsFold проверяет вхождение подстроки без учета регистра, как фильтр Email и Name
+func containsFold(value, substring string) bool {
+	return strings.Contains(strings.ToLower(value), strings.ToLower(substring))
+}
+
+// inCreatedRange проверяет время создания по периоду запроса
+func inCreatedRange(createdAt *time.Time, query ListQuery) bool {
+	if createdAt == nil {
+		return query.CreatedFrom.IsZero() && query.CreatedTo.IsZero()
+	}
+	if !query.CreatedFrom.IsZero() && createdAt.Before(query.CreatedFrom) {
+		return false
+	}
+	return query.CreatedTo.IsZero() || createdAt.Before(query.CreatedTo)
+}
+
+// pageOf сортирует отфильтрованные записи и выбирает страницу так же, как
+// listBuilder.run. less сравнивает записи по полям сортировки.
+func pageOf[T any](items []T, query ListQuery, id func(T) int, less map[string]func(a, b T) bool) ([]T, int) {
+	total := len(items)
+	if query.SkipTotal {
+		total = 0
+	}
+	compare, ok := less[query.Sort]
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if query.Desc {
+			a, b = b, a
+		}
+		if ok && compare(a, b) {
+			return true
+		}
+		if ok && compare(b, a) {
+			return false
+		}
+		return id(a) < id(b)
+	})
+
+	if query.AfterID != 0 {
+		filtered := items[:0]
+		for _, item := range items {
+			if (!query.Desc && id(item) > query.AfterID) || (query.Desc && id(item) < query.AfterID) {
+				filtered = append(filtered, item)
+			}
+		}
+		items = filtered
+	}
+	if query.Limit <= 0 {
+		return items, total
+	}
+	if query.Offset >= len(items) {
+		return items[:0], total
+	}
+	items = items[query.Offset:]
+	if len(items) > query.Limit {
+		items = items[:query.Limit]
+	}
+	return items, total
+}
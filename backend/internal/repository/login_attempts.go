@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"kursovaya_backend/internal/database"
+	"kursovaya_backend/internal/models"
+)
+
+// LoginAttemptRepository описывает хранилище неудачных попыток входа
+type LoginAttemptRepository interface {
+	Get(ctx context.Context, key string) (*models.LoginAttempt, error)
+	// RegisterFailure атомарно увеличивает счетчик неудач. Если предыдущая
+	// неудача была раньше windowStart, счет начинается заново.
+	RegisterFailure(ctx context.Context, key string, at, windowStart time.Time) (*models.LoginAttempt, error)
+	// Lock блокирует вход до until и обнуляет счетчик
+	Lock(ctx context.Context, key string, until time.Time) error
+	// Reset удаляет счетчик; возвращает ErrNotFound, если его не было
+	Reset(ctx context.Context, key string) error
+	// ListLocked возвращает блокировки, действующие на момент at
+	ListLocked(ctx context.Context, at time.Time) ([]models.LoginAttempt, error)
+	// DeleteStale удаляет счетчики без неудач после before и без действующей блокировки
+	DeleteStale(ctx context.Context, before time.Time) error
+}
+
+// NewMemoryLoginAttempts создает отдельное хранилище попыток входа в памяти.
+// Подходит для одного экземпляра сервера; при нескольких репликах счетчики
+// должны храниться в базе, иначе каждая реплика считает попытки отдельно.
+func NewMemoryLoginAttempts() LoginAttemptRepository {
+	return &memoryLoginAttemptRepository{newMemoryStore()}
+}
+
+type sqlLoginAttemptRepository struct {
+	db database.DBTX
+}
+
+const loginAttemptColumns = "id, attempt_key, failures, last_failure_at, locked_until"
+
+func scanLoginAttempt(row rowScanner) (*models.LoginAttempt, error) {
+	var attempt models.LoginAttempt
+	var lockedUntil sql.NullTime
+	if err := row.Scan(&attempt.ID, &attempt.Key, &attempt.Failures, &attempt.LastFailureAt, &lockedUntil); err != nil {
+		return nil, err
+	}
+	if lockedUntil.Valid {
+		attempt.LockedUntil = &lockedUntil.Time
+	}
+	return &attempt, nil
+}
+
+func (r *sqlLoginAttemptRepository) Get(ctx context.Context, key string) (*models.LoginAttempt, error) {
+	attempt, err := scanLoginAttempt(r.db.QueryRowContext(ctx,
+		"SELECT "+loginAttemptColumns+" FROM login_attempts WHERE attempt_key = $1", key,
+	))
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return attempt, nil
+}
+
+func (r *sqlLoginAttemptRepository) RegisterFailure(ctx context.Context, key string, at, windowStart time.Time) (*models.LoginAttempt, error) {
+	// Один запрос вместо чтения и записи, чтобы параллельные попытки
+	// с разных реплик не потеряли ни одной неудачи
+	attempt, err := scanLoginAttempt(r.db.QueryRowContext(ctx, `
+		INSERT INTO login_attempts (attempt_key, failures, last_failure_at) VALUES ($1, 1, $2)
+		ON CONFLICT (attempt_key) DO UPDATE SET
+			failures = CASE WHEN login_attempts.last_failure_at < $3 THEN 1 ELSE login_attempts.failures + 1 END,
+			last_failure_at = $2
+		RETURNING `+loginAttemptColumns,
+		key, at.UTC(), windowStart.UTC(),
+	))
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return attempt, nil
+}
+
+func (r *sqlLoginAttemptRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE login_attempts SET locked_until = $1, failures = 0 WHERE attempt_key = $2",
+		until.UTC(), key,
+	)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqlLoginAttemptRepository) Reset(ctx context.Context, key string) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM login_attempts WHERE attempt_key = $1", key)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqlLoginAttemptRepository) ListLocked(ctx context.Context, at time.Time) ([]models.LoginAttempt, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT "+loginAttemptColumns+" FROM login_attempts WHERE locked_until > $1 ORDER BY locked_until, id",
+		at.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := []models.LoginAttempt{}
+	for rows.Next() {
+		attempt, err := scanLoginAttempt(rows)
+		if err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, *attempt)
+	}
+	return attempts, rows.Err()
+}
+
+func (r *sqlLoginAttemptRepository) DeleteStale(ctx context.Context, before time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		"DELETE FROM login_attempts WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < $1)",
+		before.UTC(),
+	)
+	return err
+}
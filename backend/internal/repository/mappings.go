@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+
+	"kursovaya_backend/internal/database"
+	"kursovaya_backend/internal/models"
+)
+
+// MappingRepository описывает хранилище сопоставлений товаров
+type MappingRepository interface {
+	Create(ctx context.Context, organizationID, product1ID, product2ID, userID int) (*models.ProductMapping, error)
+	// ExistsBetween проверяет наличие сопоставления между товарами в любом порядке
+	ExistsBetween(ctx context.Context, product1ID, product2ID int) (bool, error)
+	ListByOrganization(ctx context.Context, organizationID int) ([]*models.ProductMapping, error)
+	// DeleteByStore удаляет сопоставления, в которых участвуют товары магазина
+	DeleteByStore(ctx context.Context, storeID int) error
+	GetByID(ctx context.Context, id int) (*models.ProductMapping, error)
+	// List возвращает страницу сопоставлений и общее число подходящих под фильтры
+	List(ctx context.Context, query ListQuery) ([]models.ProductMapping, int, error)
+	DeleteByID(ctx context.Context, id int) error
+	Count(ctx context.Context) (int, error)
+}
+
+type sqlMappingRepository struct {
+	db database.DBTX
+}
+
+func (r *sqlMappingRepository) Create(ctx context.Context, organizationID, product1ID, product2ID, userID int) (*models.ProductMapping, error) {
+	mapping := models.ProductMapping{
+		OrganizationID: organizationID,
+		Product1ID:     product1ID,
+		Product2ID:     product2ID,
+		UserID:         userID,
+	}
+	err := r.db.QueryRowContext(ctx,
+		"INSERT INTO product_mappings (organization_id, product1_id, product2_id, user_id) VALUES ($1, $2, $3, $4) RETURNING id, created_at",
+		organizationID, product1ID, product2ID, userID,
+	).Scan(&mapping.ID, &mapping.CreatedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &mapping, nil
+}
+
+func (r *sqlMappingRepository) ExistsBetween(ctx context.Context, product1ID, product2ID int) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM product_mappings WHERE (product1_id = $1 AND product2_id = $2) OR (product1_id = $2 AND product2_id = $1)",
+		product1ID, product2ID,
+	).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *sqlMappingRepository) ListByOrganization(ctx context.Context, organizationID int) ([]*models.ProductMapping, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, organization_id, product1_id, product2_id, user_id, created_at
+		FROM product_mappings
+		WHERE organization_id = $1
+		ORDER BY created_at DESC, id DESC
+	`, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mappings []*models.ProductMapping
+	for rows.Next() {
+		var mapping models.ProductMapping
+		if err := rows.Scan(&mapping.ID, &mapping.OrganizationID, &mapping.Product1ID, &mapping.Product2ID, &mapping.UserID, &mapping.CreatedAt); err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, &mapping)
+	}
+	return mappings, rows.Err()
+}
+
+func (r *sqlMappingRepository) DeleteByStore(ctx context.Context, storeID int) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM product_mappings
+		WHERE product1_id IN (SELECT id FROM products WHERE store_id = $1)
+		   OR product2_id IN (SELECT id FROM products WHERE store_id = $1)
+	`, storeID)
+	return err
+}
+
+func (r *sqlMappingRepository) GetByID(ctx context.Context, id int) (*models.ProductMapping, error) {
+	var mapping models.ProductMapping
+	err := r.db.QueryRowContext(ctx,
+		"SELECT id, organization_id, product1_id, product2_id, user_id, created_at FROM product_mappings WHERE id = $1", id,
+	).Scan(&mapping.ID, &mapping.OrganizationID, &mapping.Product1ID, &mapping.Product2ID, &mapping.UserID, &mapping.CreatedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &mapping, nil
+}
+
+// mappingSortColumns сопоставляет MappingSortFields колонкам таблицы
+var mappingSortColumns = map[string]string{"id": "id", "user_id": "user_id", "created_at": "created_at"}
+
+func (r *sqlMappingRepository) List(ctx context.Context, query ListQuery) ([]models.ProductMapping, int, error) {
+	var b listBuilder
+	if query.UserID != 0 {
+		b.add("user_id = %s", query.UserID)
+	}
+	b.created("created_at", query)
+	rows, total, err := b.run(ctx, r.db, "id, organization_id, product1_id, product2_id, user_id, created_at", "FROM product_mappings", mappingSortColumns, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	mappings := []models.ProductMapping{}
+	for rows.Next() {
+		var mapping models.ProductMapping
+		if err := rows.Scan(&mapping.ID, &mapping.OrganizationID, &mapping.Product1ID, &mapping.Product2ID, &mapping.UserID, &mapping.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		mappings = append(mappings, mapping)
+	}
+	return mappings, total, rows.Err()
+}
+
+func (r *sqlMappingRepository) DeleteByID(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM product_mappings WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqlMappingRepository) Count(ctx context.Context) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM product_mappings").Scan(&count)
+	return count, err
+}
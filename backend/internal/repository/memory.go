@@ -0,0 +1,1938 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/rbac"
+)
+
+type memoryUser struct {
+	models.User
+	passwordHash string
+}
+
+// model возвращает пользователя без служебных полей, статус выводится как в setVerifiedAt
+func (u *memoryUser) model() *models.User {
+	user := u.User
+	user.Password = ""
+	if user.Status == models.UserActive && user.EmailVerifiedAt == nil {
+		user.Status = models.UserPendingVerification
+	}
+	return &user
+}
+
+type memoryAdmin struct {
+	models.Admin
+	passwordHash string
+}
+
+// model возвращает администратора без служебных полей
+func (a *memoryAdmin) model() *models.Admin {
+	admin := a.Admin
+	admin.Password = ""
+	return &admin
+}
+
+type memoryStoreRecord struct {
+	models.Store
+	encryptedToken string
+}
+
+// memoryStore - общее хранилище для всех репозиториев в памяти
+type memoryStore struct {
+	// txMu выстраивает транзакции в очередь, что соответствует уровню SERIALIZABLE
+	txMu          sync.Mutex
+	mu            sync.RWMutex
+	nextID        map[string]int
+	users         map[int]*memoryUser
+	admins        map[int]*memoryAdmin
+	stores        map[int]*memoryStoreRecord
+	products      map[int]*models.Product
+	mappings      map[int]*models.ProductMapping
+	sessions      map[int]*models.Session
+	organizations map[int]*models.Organization
+	// members хранит участников под синтетическим ключом, как строки таблицы
+	members       map[int]*memoryMember
+	invitations   map[int]*models.Invitation
+	twoFactor     map[int]*models.TwoFactor
+	recoveryCodes map[int]*memoryRecoveryCode
+	userTokens    map[int]*models.UserToken
+	loginAttempts map[int]*models.LoginAttempt
+	apiKeys       map[int]*models.APIKey
+	// bootstrapTokens - токены создания первого администратора
+	bootstrapTokens map[int]*memoryBootstrapToken
+	auditEvents     map[int]*models.AuditEvent
+	syncRuns        map[int]*models.SyncRun
+
+	// rateLimits - корзины ограничения частоты запросов по ключу. Они не
+	// участвуют в транзакциях и не копируются в снимок для отката.
+	rateLimits map[string]*memoryRateLimitBucket
+}
+
+type memoryRateLimitBucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+type memoryBootstrapToken struct {
+	tokenHash string
+	expiresAt time.Time
+}
+
+type memoryRecoveryCode struct {
+	subjectType string
+	subjectID   int
+	codeHash    string
+	used        bool
+}
+
+type memoryMember struct {
+	organizationID int
+	userID         int
+	role           string
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		nextID:        make(map[string]int),
+		users:         make(map[int]*memoryUser),
+		admins:        make(map[int]*memoryAdmin),
+		stores:        make(map[int]*memoryStoreRecord),
+		products:      make(map[int]*models.Product),
+		mappings:      make(map[int]*models.ProductMapping),
+		sessions:      make(map[int]*models.Session),
+		organizations: make(map[int]*models.Organization),
+		members:       make(map[int]*memoryMember),
+		invitations:   make(map[int]*models.Invitation),
+		twoFactor:     make(map[int]*models.TwoFactor),
+		recoveryCodes: make(map[int]*memoryRecoveryCode),
+		userTokens:    make(map[int]*models.UserToken),
+		loginAttempts: make(map[int]*models.LoginAttempt),
+		apiKeys:       make(map[int]*models.APIKey),
+
+		bootstrapTokens: make(map[int]*memoryBootstrapToken),
+		auditEvents:     make(map[int]*models.AuditEvent),
+		syncRuns:        make(map[int]*models.SyncRun),
+
+		rateLimits: make(map[string]*memoryRateLimitBucket),
+	}
+}
+
+// id выдает следующий идентификатор для таблицы, аналогично SERIAL
+func (s *memoryStore) id(table string) int {
+	s.nextID[table]++
+	return s.nextID[table]
+}
+
+// withinTx выполняет fn эксклюзивно и восстанавливает данные, если fn вернула ошибку
+func (s *memoryStore) withinTx(fn func() error) error {
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+
+	s.mu.RLock()
+	snapshot := s.clone()
+	s.mu.RUnlock()
+
+	if err := fn(); err != nil {
+		s.mu.Lock()
+		s.restore(snapshot)
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// clone делает копию всех таблиц для последующего отката
+func (s *memoryStore) clone() *memoryStore {
+	nextID := make(map[string]int, len(s.nextID))
+	for table, id := range s.nextID {
+		nextID[table] = id
+	}
+	return &memoryStore{
+		nextID:        nextID,
+		users:         cloneRecords(s.users),
+		admins:        cloneRecords(s.admins),
+		stores:        cloneRecords(s.stores),
+		products:      cloneRecords(s.products),
+		mappings:      cloneRecords(s.mappings),
+		sessions:      cloneRecords(s.sessions),
+		organizations: cloneRecords(s.organizations),
+		members:       cloneRecords(s.members),
+		invitations:   cloneRecords(s.invitations),
+		twoFactor:     cloneRecords(s.twoFactor),
+		recoveryCodes: cloneRecords(s.recoveryCodes),
+		userTokens:    cloneRecords(s.userTokens),
+		loginAttempts: cloneRecords(s.loginAttempts),
+		apiKeys:       cloneRecords(s.apiKeys),
+
+		bootstrapTokens: cloneRecords(s.bootstrapTokens),
+		auditEvents:     cloneRecords(s.auditEvents),
+		syncRuns:        cloneRecords(s.syncRuns),
+	}
+}
+
+// restore возвращает таблицы к состоянию снимка
+func (s *memoryStore) restore(snapshot *memoryStore) {
+	s.nextID = snapshot.nextID
+	s.users = snapshot.users
+	s.admins = snapshot.admins
+	s.stores = snapshot.stores
+	s.products = snapshot.products
+	s.mappings = snapshot.mappings
+	s.sessions = snapshot.sessions
+	s.organizations = snapshot.organizations
+	s.members = snapshot.members
+	s.invitations = snapshot.invitations
+	s.twoFactor = snapshot.twoFactor
+	s.recoveryCodes = snapshot.recoveryCodes
+	s.userTokens = snapshot.userTokens
+	s.loginAttempts = snapshot.loginAttempts
+	s.apiKeys = snapshot.apiKeys
+	s.bootstrapTokens = snapshot.bootstrapTokens
+	s.auditEvents = snapshot.auditEvents
+	s.syncRuns = snapshot.syncRuns
+}
+
+func cloneRecords[T any](m map[int]*T) map[int]*T {
+	result := make(map[int]*T, len(m))
+	for id, record := range m {
+		copied := *record
+		result[id] = &copied
+	}
+	return result
+}
+
+// sortedIDs возвращает ключи карты в порядке возрастания
+func sortedIDs[T any](m map[int]T) []int {
+	ids := make([]int, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+type memoryUserRepository struct {
+	s *memoryStore
+}
+
+func (r *memoryUserRepository) Create(ctx context.Context, email, passwordHash string) (*models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, user := range r.s.users {
+		if user.Email == email {
+			return nil, ErrDuplicate
+		}
+	}
+
+	now := time.Now().UTC()
+	user := &memoryUser{
+		User:         models.User{ID: r.s.id("users"), Email: email, Role: rbac.DefaultUserRole, Status: models.UserActive, CreatedAt: &now},
+		passwordHash: passwordHash,
+	}
+	r.s.users[user.ID] = user
+	return user.model(), nil
+}
+
+func (r *memoryUserRepository) GetByID(ctx context.Context, id int) (*models.User, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	user, ok := r.s.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return user.model(), nil
+}
+
+func (r *memoryUserRepository) GetCredentials(ctx context.Context, email string) (*models.User, string, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, user := range r.s.users {
+		if user.Email == email {
+			return user.model(), user.passwordHash, nil
+		}
+	}
+	return nil, "", ErrNotFound
+}
+
+func (r *memoryUserRepository) ExistsByEmail(ctx context.Context, email string) (bool, error) {
+	_, _, err := r.GetCredentials(ctx, email)
+	if err == ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *memoryUserRepository) List(ctx context.Context, query ListQuery) ([]models.User, int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	users := []models.User{}
+	for _, id := range sortedIDs(r.s.users) {
+		user := r.s.users[id].model()
+		if containsFold(user.Email, query.Email) && (query.Status == "" || user.Status == query.Status) && inCreatedRange(user.CreatedAt, query) {
+			users = append(users, *user)
+		}
+	}
+	users, total := pageOf(users, query, func(user models.User) int { return user.ID }, map[string]func(a, b models.User) bool{
+		"email":      func(a, b models.User) bool { return a.Email < b.Email },
+		"role":       func(a, b models.User) bool { return a.Role < b.Role },
+		"created_at": func(a, b models.User) bool { return a.CreatedAt.Before(*b.CreatedAt) },
+	})
+	return users, total, nil
+}
+
+func (r *memoryUserRepository) SetRole(ctx context.Context, id int, role string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	user, ok := r.s.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	user.Role = role
+	return nil
+}
+
+func (r *memoryUserRepository) SetPassword(ctx context.Context, id int, passwordHash string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	user, ok := r.s.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	user.passwordHash = passwordHash
+	return nil
+}
+
+func (r *memoryUserRepository) MarkEmailVerified(ctx context.Context, id int, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	user, ok := r.s.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	user.EmailVerifiedAt = &at
+	return nil
+}
+
+func (r *memoryUserRepository) SetStatus(ctx context.Context, id int, status, reason string, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	user, ok := r.s.users[id]
+	if !ok || user.ErasedAt != nil {
+		return ErrNotFound
+	}
+	user.Status = status
+	user.StatusReason = reason
+	user.StatusChangedAt = &at
+	return nil
+}
+
+func (r *memoryUserRepository) Erase(ctx context.Context, id int, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	user, ok := r.s.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	user.Email = ErasedEmail(id)
+	user.passwordHash = ""
+	user.EmailVerifiedAt = nil
+	user.Status = models.UserDeleted
+	user.StatusReason = ""
+	user.StatusChangedAt = &at
+	user.ErasedAt = &at
+	return nil
+}
+
+func (r *memoryUserRepository) Delete(ctx context.Context, id int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.users[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.s.users, id)
+
+	// Сессии и членства удаляются вместе с пользователем (ON DELETE CASCADE)
+	for sessionID, session := range r.s.sessions {
+		if session.UserID == id {
+			delete(r.s.sessions, sessionID)
+		}
+	}
+	for key, member := range r.s.members {
+		if member.userID == id {
+			delete(r.s.members, key)
+		}
+	}
+	for tokenID, token := range r.s.userTokens {
+		if token.UserID == id {
+			delete(r.s.userTokens, tokenID)
+		}
+	}
+	for keyID, key := range r.s.apiKeys {
+		if key.UserID == id {
+			delete(r.s.apiKeys, keyID)
+		}
+	}
+	return nil
+}
+
+func (r *memoryUserRepository) Count(ctx context.Context) (int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	return len(r.s.users), nil
+}
+
+type memoryAdminRepository struct {
+	s *memoryStore
+}
+
+func (r *memoryAdminRepository) Create(ctx context.Context, username, passwordHash, role string, mustChangePassword bool) (*models.Admin, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, admin := range r.s.admins {
+		if admin.Username == username {
+			return nil, ErrDuplicate
+		}
+	}
+
+	admin := &memoryAdmin{
+		Admin: models.Admin{
+			ID:                 r.s.id("admins"),
+			Username:           username,
+			Role:               role,
+			MustChangePassword: mustChangePassword,
+			CreatedAt:          time.Now().UTC(),
+		},
+		passwordHash: passwordHash,
+	}
+	r.s.admins[admin.ID] = admin
+	return admin.model(), nil
+}
+
+func (r *memoryAdminRepository) GetCredentials(ctx context.Context, username string) (*models.Admin, string, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, admin := range r.s.admins {
+		if admin.Username == username {
+			return admin.model(), admin.passwordHash, nil
+		}
+	}
+	return nil, "", ErrNotFound
+}
+
+func (r *memoryAdminRepository) GetPasswordHash(ctx context.Context, id int) (string, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	admin, ok := r.s.admins[id]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return admin.passwordHash, nil
+}
+
+func (r *memoryAdminRepository) Exists(ctx context.Context, id int) (bool, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	_, ok := r.s.admins[id]
+	return ok, nil
+}
+
+func (r *memoryAdminRepository) GetByID(ctx context.Context, id int) (*models.Admin, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	admin, ok := r.s.admins[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return admin.model(), nil
+}
+
+func (r *memoryAdminRepository) List(ctx context.Context) ([]models.Admin, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	admins := []models.Admin{}
+	for _, id := range sortedIDs(r.s.admins) {
+		admins = append(admins, *r.s.admins[id].model())
+	}
+	return admins, nil
+}
+
+func (r *memoryAdminRepository) SetRole(ctx context.Context, id int, role string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	admin, ok := r.s.admins[id]
+	if !ok {
+		return ErrNotFound
+	}
+	admin.Role = role
+	return nil
+}
+
+func (r *memoryAdminRepository) SetPassword(ctx context.Context, id int, passwordHash string, mustChangePassword bool) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	admin, ok := r.s.admins[id]
+	if !ok {
+		return ErrNotFound
+	}
+	admin.passwordHash = passwordHash
+	admin.MustChangePassword = mustChangePassword
+	admin.TokenVersion++
+	return nil
+}
+
+func (r *memoryAdminRepository) SetDisabled(ctx context.Context, id int, at *time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	admin, ok := r.s.admins[id]
+	if !ok {
+		return ErrNotFound
+	}
+	admin.DisabledAt = nil
+	if at != nil {
+		disabledAt := at.UTC()
+		admin.DisabledAt = &disabledAt
+		admin.TokenVersion++
+	}
+	return nil
+}
+
+func (r *memoryAdminRepository) Delete(ctx context.Context, id int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.admins[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.s.admins, id)
+	return nil
+}
+
+func (r *memoryAdminRepository) Count(ctx context.Context) (int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	return len(r.s.admins), nil
+}
+
+func (r *memoryAdminRepository) CountActiveWithRole(ctx context.Context, role string) (int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	count := 0
+	for _, admin := range r.s.admins {
+		if admin.Role == role && admin.DisabledAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *memoryAdminRepository) SaveBootstrapToken(ctx context.Context, tokenHash string, expiresAt time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, token := range r.s.bootstrapTokens {
+		if token.tokenHash == tokenHash {
+			token.expiresAt = expiresAt
+			return nil
+		}
+	}
+	r.s.bootstrapTokens[r.s.id("admin_bootstrap_tokens")] = &memoryBootstrapToken{tokenHash: tokenHash, expiresAt: expiresAt}
+	return nil
+}
+
+func (r *memoryAdminRepository) ConsumeBootstrapToken(ctx context.Context, tokenHash string, now time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for id, token := range r.s.bootstrapTokens {
+		if token.tokenHash == tokenHash && token.expiresAt.After(now) {
+			delete(r.s.bootstrapTokens, id)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *memoryAdminRepository) DeleteBootstrapTokens(ctx context.Context) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.bootstrapTokens = make(map[int]*memoryBootstrapToken)
+	return nil
+}
+
+type memoryStoreRepository struct {
+	s *memoryStore
+}
+
+func (r *memoryStoreRepository) Create(ctx context.Context, organizationID, userID int, storeType, encryptedToken string) (*models.Store, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := time.Now().UTC()
+	store := &memoryStoreRecord{
+		Store:          models.Store{ID: r.s.id("stores"), OrganizationID: organizationID, UserID: userID, Type: storeType, CreatedAt: &now},
+		encryptedToken: encryptedToken,
+	}
+	r.s.stores[store.ID] = store
+	result := store.Store
+	return &result, nil
+}
+
+func (r *memoryStoreRepository) ListByOrganization(ctx context.Context, organizationID int) ([]*models.Store, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var stores []*models.Store
+	for _, id := range sortedIDs(r.s.stores) {
+		if store := r.s.stores[id]; store.OrganizationID == organizationID {
+			result := store.Store
+			stores = append(stores, &result)
+		}
+	}
+	return stores, nil
+}
+
+func (r *memoryStoreRepository) GetToken(ctx context.Context, storeID int) (string, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	store, ok := r.s.stores[storeID]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return store.encryptedToken, nil
+}
+
+func (r *memoryStoreRepository) GetByID(ctx context.Context, id int) (*models.Store, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	store, ok := r.s.stores[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	result := store.Store
+	return &result, nil
+}
+
+func (r *memoryStoreRepository) List(ctx context.Context, query ListQuery) ([]models.Store, int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	stores := []models.Store{}
+	for _, id := range sortedIDs(r.s.stores) {
+		store := r.s.stores[id].Store
+		if (query.StoreType == "" || store.Type == query.StoreType) && (query.UserID == 0 || store.UserID == query.UserID) &&
+			inCreatedRange(store.CreatedAt, query) {
+			stores = append(stores, store)
+		}
+	}
+	stores, total := pageOf(stores, query, func(store models.Store) int { return store.ID }, map[string]func(a, b models.Store) bool{
+		"store_type": func(a, b models.Store) bool { return a.Type < b.Type },
+		"user_id":    func(a, b models.Store) bool { return a.UserID < b.UserID },
+		"created_at": func(a, b models.Store) bool { return a.CreatedAt.Before(*b.CreatedAt) },
+	})
+	return stores, total, nil
+}
+
+func (r *memoryStoreRepository) DeleteByID(ctx context.Context, id int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.stores[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.s.stores, id)
+	return nil
+}
+
+func (r *memoryStoreRepository) Count(ctx context.Context) (int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	return len(r.s.stores), nil
+}
+
+func (r *memoryStoreRepository) ListEncryptedTokens(ctx context.Context, afterID, limit int) ([]EncryptedValue, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var values []EncryptedValue
+	for _, id := range sortedIDs(r.s.stores) {
+		if id > afterID && len(values) < limit {
+			values = append(values, EncryptedValue{ID: id, Value: r.s.stores[id].encryptedToken})
+		}
+	}
+	return values, nil
+}
+
+func (r *memoryStoreRepository) ReplaceEncryptedToken(ctx context.Context, storeID int, oldToken, newToken string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	store, ok := r.s.stores[storeID]
+	if !ok || store.encryptedToken != oldToken {
+		return ErrNotFound
+	}
+	store.encryptedToken = newToken
+	return nil
+}
+
+type memoryProductRepository struct {
+	s *memoryStore
+}
+
+func (r *memoryProductRepository) Create(ctx context.Context, product *models.Product) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := time.Now().UTC()
+	product.ID = r.s.id("products")
+	product.CreatedAt = &now
+	stored := *product
+	r.s.products[product.ID] = &stored
+	return nil
+}
+
+func (r *memoryProductRepository) ListByOrganization(ctx context.Context, organizationID int) ([]models.Product, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	products := []models.Product{}
+	for _, id := range sortedIDs(r.s.products) {
+		product := r.s.products[id]
+		if store, ok := r.s.stores[product.StoreID]; ok && store.OrganizationID == organizationID {
+			products = append(products, *product)
+		}
+	}
+	return products, nil
+}
+
+func (r *memoryProductRepository) ListMappedByOrganization(ctx context.Context, organizationID int) ([]models.Product, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	mapped := make(map[int]bool)
+	for _, mapping := range r.s.mappings {
+		if mapping.OrganizationID == organizationID {
+			mapped[mapping.Product1ID] = true
+			mapped[mapping.Product2ID] = true
+		}
+	}
+
+	products := []models.Product{}
+	for _, id := range sortedIDs(r.s.products) {
+		if mapped[id] {
+			products = append(products, *r.s.products[id])
+		}
+	}
+	return products, nil
+}
+
+func (r *memoryProductRepository) DeleteByStore(ctx context.Context, storeID int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for id, product := range r.s.products {
+		if product.StoreID == storeID {
+			delete(r.s.products, id)
+		}
+	}
+	return nil
+}
+
+func (r *memoryProductRepository) GetByID(ctx context.Context, id int) (*models.Product, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	product, ok := r.s.products[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	result := *product
+	return &result, nil
+}
+
+func (r *memoryProductRepository) List(ctx context.Context, query ListQuery) ([]models.Product, int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	products := []models.Product{}
+	for _, id := range sortedIDs(r.s.products) {
+		product := r.s.products[id]
+		store, ok := r.s.stores[product.StoreID]
+		if !ok {
+			continue
+		}
+		if containsFold(product.Name, query.Name) && (query.StoreID == 0 || product.StoreID == query.StoreID) &&
+			(query.StoreType == "" || store.Type == query.StoreType) && (query.UserID == 0 || store.UserID == query.UserID) &&
+			inCreatedRange(product.CreatedAt, query) {
+			products = append(products, *product)
+		}
+	}
+	products, total := pageOf(products, query, func(product models.Product) int { return product.ID }, map[string]func(a, b models.Product) bool{
+		"name":       func(a, b models.Product) bool { return a.Name < b.Name },
+		"price":      func(a, b models.Product) bool { return a.Price < b.Price },
+		"quantity":   func(a, b models.Product) bool { return a.Quantity < b.Quantity },
+		"created_at": func(a, b models.Product) bool { return a.CreatedAt.Before(*b.CreatedAt) },
+	})
+	return products, total, nil
+}
+
+func (r *memoryProductRepository) Delete(ctx context.Context, id int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.products[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.s.products, id)
+	return nil
+}
+
+func (r *memoryProductRepository) Count(ctx context.Context) (int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	return len(r.s.products), nil
+}
+
+type memoryMappingRepository struct {
+	s *memoryStore
+}
+
+func (r *memoryMappingRepository) Create(ctx context.Context, organizationID, product1ID, product2ID, userID int) (*models.ProductMapping, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, mapping := range r.s.mappings {
+		if mapping.Product1ID == product1ID && mapping.Product2ID == product2ID {
+			return nil, ErrDuplicate
+		}
+	}
+
+	now := time.Now().UTC()
+	mapping := &models.ProductMapping{
+		ID:             r.s.id("product_mappings"),
+		OrganizationID: organizationID,
+		Product1ID:     product1ID,
+		Product2ID:     product2ID,
+		UserID:         userID,
+		CreatedAt:      &now,
+	}
+	r.s.mappings[mapping.ID] = mapping
+	result := *mapping
+	return &result, nil
+}
+
+func (r *memoryMappingRepository) ExistsBetween(ctx context.Context, product1ID, product2ID int) (bool, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, mapping := range r.s.mappings {
+		if (mapping.Product1ID == product1ID && mapping.Product2ID == product2ID) ||
+			(mapping.Product1ID == product2ID && mapping.Product2ID == product1ID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryMappingRepository) ListByOrganization(ctx context.Context, organizationID int) ([]*models.ProductMapping, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	// Новые сопоставления первыми, как ORDER BY created_at DESC
+	ids := sortedIDs(r.s.mappings)
+	var mappings []*models.ProductMapping
+	for i := len(ids) - 1; i >= 0; i-- {
+		if mapping := r.s.mappings[ids[i]]; mapping.OrganizationID == organizationID {
+			result := *mapping
+			mappings = append(mappings, &result)
+		}
+	}
+	return mappings, nil
+}
+
+func (r *memoryMappingRepository) DeleteByStore(ctx context.Context, storeID int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	inStore := func(productID int) bool {
+		product, ok := r.s.products[productID]
+		return ok && product.StoreID == storeID
+	}
+	for id, mapping := range r.s.mappings {
+		if inStore(mapping.Product1ID) || inStore(mapping.Product2ID) {
+			delete(r.s.mappings, id)
+		}
+	}
+	return nil
+}
+
+func (r *memoryMappingRepository) GetByID(ctx context.Context, id int) (*models.ProductMapping, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	mapping, ok := r.s.mappings[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	result := *mapping
+	return &result, nil
+}
+
+func (r *memoryMappingRepository) List(ctx context.Context, query ListQuery) ([]models.ProductMapping, int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	mappings := []models.ProductMapping{}
+	for _, id := range sortedIDs(r.s.mappings) {
+		mapping := r.s.mappings[id]
+		if (query.UserID == 0 || mapping.UserID == query.UserID) && inCreatedRange(mapping.CreatedAt, query) {
+			mappings = append(mappings, *mapping)
+		}
+	}
+	mappings, total := pageOf(mappings, query, func(mapping models.ProductMapping) int { return mapping.ID }, map[string]func(a, b models.ProductMapping) bool{
+		"user_id":    func(a, b models.ProductMapping) bool { return a.UserID < b.UserID },
+		"created_at": func(a, b models.ProductMapping) bool { return a.CreatedAt.Before(*b.CreatedAt) },
+	})
+	return mappings, total, nil
+}
+
+func (r *memoryMappingRepository) DeleteByID(ctx context.Context, id int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.mappings[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.s.mappings, id)
+	return nil
+}
+
+func (r *memoryMappingRepository) Count(ctx context.Context) (int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	return len(r.s.mappings), nil
+}
+
+type memorySessionRepository struct {
+	s *memoryStore
+}
+
+func (r *memorySessionRepository) Create(ctx context.Context, session *models.Session) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, existing := range r.s.sessions {
+		if existing.TokenHash == session.TokenHash {
+			return ErrDuplicate
+		}
+	}
+
+	session.ID = r.s.id("sessions")
+	stored := *session
+	r.s.sessions[session.ID] = &stored
+	return nil
+}
+
+func (r *memorySessionRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*models.Session, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, session := range r.s.sessions {
+		if session.TokenHash == tokenHash {
+			found := *session
+			return &found, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memorySessionRepository) MarkUsed(ctx context.Context, id int, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	session, ok := r.s.sessions[id]
+	if !ok || session.UsedAt != nil {
+		return ErrNotFound
+	}
+	session.UsedAt = &at
+	return nil
+}
+
+func (r *memorySessionRepository) RevokeFamily(ctx context.Context, familyID string, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, session := range r.s.sessions {
+		if session.FamilyID == familyID && session.RevokedAt == nil {
+			session.RevokedAt = &at
+		}
+	}
+	return nil
+}
+
+func (r *memorySessionRepository) RevokeAllForUser(ctx context.Context, userID int, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, session := range r.s.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			session.RevokedAt = &at
+		}
+	}
+	return nil
+}
+
+func (r *memorySessionRepository) IsFamilyRevoked(ctx context.Context, familyID string) (bool, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	found := false
+	for _, session := range r.s.sessions {
+		if session.FamilyID != familyID {
+			continue
+		}
+		if session.RevokedAt != nil {
+			return true, nil
+		}
+		found = true
+	}
+	return !found, nil
+}
+
+type memoryOrganizationRepository struct {
+	s *memoryStore
+}
+
+func (r *memoryOrganizationRepository) Create(ctx context.Context, name string) (*models.Organization, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	organization := &models.Organization{ID: r.s.id("organizations"), Name: name}
+	r.s.organizations[organization.ID] = organization
+	result := *organization
+	return &result, nil
+}
+
+func (r *memoryOrganizationRepository) GetByID(ctx context.Context, id int) (*models.Organization, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	organization, ok := r.s.organizations[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	result := *organization
+	return &result, nil
+}
+
+func (r *memoryOrganizationRepository) Rename(ctx context.Context, id int, name string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	organization, ok := r.s.organizations[id]
+	if !ok {
+		return ErrNotFound
+	}
+	organization.Name = name
+	return nil
+}
+
+func (r *memoryOrganizationRepository) Delete(ctx context.Context, id int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.organizations[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.s.organizations, id)
+
+	// Участники и приглашения удаляются вместе с организацией (ON DELETE CASCADE)
+	for key, member := range r.s.members {
+		if member.organizationID == id {
+			delete(r.s.members, key)
+		}
+	}
+	for invitationID, invitation := range r.s.invitations {
+		if invitation.OrganizationID == id {
+			delete(r.s.invitations, invitationID)
+		}
+	}
+	return nil
+}
+
+func (r *memoryOrganizationRepository) AddMember(ctx context.Context, organizationID, userID int, role string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if r.findMember(organizationID, userID) != nil {
+		return ErrDuplicate
+	}
+	r.s.members[r.s.id("organization_members")] = &memoryMember{
+		organizationID: organizationID,
+		userID:         userID,
+		role:           role,
+	}
+	return nil
+}
+
+func (r *memoryOrganizationRepository) GetMember(ctx context.Context, organizationID, userID int) (*models.OrganizationMember, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	member := r.findMember(organizationID, userID)
+	if member == nil {
+		return nil, ErrNotFound
+	}
+	result := r.model(member)
+	return &result, nil
+}
+
+func (r *memoryOrganizationRepository) GetDefaultMembership(ctx context.Context, userID int) (*models.OrganizationMember, error) {
+	memberships, _ := r.ListByUser(ctx, userID)
+	if len(memberships) == 0 {
+		return nil, ErrNotFound
+	}
+	return &memberships[0], nil
+}
+
+func (r *memoryOrganizationRepository) ListByUser(ctx context.Context, userID int) ([]models.OrganizationMember, error) {
+	memberships := r.list(func(m *memoryMember) bool { return m.userID == userID })
+	sort.Slice(memberships, func(i, j int) bool {
+		return memberships[i].OrganizationID < memberships[j].OrganizationID
+	})
+	return memberships, nil
+}
+
+func (r *memoryOrganizationRepository) ListMembers(ctx context.Context, organizationID int) ([]models.OrganizationMember, error) {
+	members := r.list(func(m *memoryMember) bool { return m.organizationID == organizationID })
+	sort.Slice(members, func(i, j int) bool { return members[i].UserID < members[j].UserID })
+	return members, nil
+}
+
+func (r *memoryOrganizationRepository) SetMemberRole(ctx context.Context, organizationID, userID int, role string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	member := r.findMember(organizationID, userID)
+	if member == nil {
+		return ErrNotFound
+	}
+	member.role = role
+	return nil
+}
+
+func (r *memoryOrganizationRepository) RemoveMember(ctx context.Context, organizationID, userID int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for key, member := range r.s.members {
+		if member.organizationID == organizationID && member.userID == userID {
+			delete(r.s.members, key)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *memoryOrganizationRepository) CountMembersWithRole(ctx context.Context, organizationID int, role string) (int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	count := 0
+	for _, member := range r.s.members {
+		if member.organizationID == organizationID && member.role == role {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *memoryOrganizationRepository) CreateInvitation(ctx context.Context, invitation *models.Invitation) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, existing := range r.s.invitations {
+		if existing.TokenHash == invitation.TokenHash {
+			return ErrDuplicate
+		}
+	}
+
+	invitation.ID = r.s.id("organization_invitations")
+	stored := *invitation
+	r.s.invitations[invitation.ID] = &stored
+	return nil
+}
+
+func (r *memoryOrganizationRepository) GetInvitationByTokenHash(ctx context.Context, tokenHash string) (*models.Invitation, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, invitation := range r.s.invitations {
+		if invitation.TokenHash == tokenHash {
+			found := *invitation
+			return &found, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryOrganizationRepository) MarkInvitationAccepted(ctx context.Context, id int, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	invitation, ok := r.s.invitations[id]
+	if !ok || invitation.AcceptedAt != nil {
+		return ErrNotFound
+	}
+	invitation.AcceptedAt = &at
+	return nil
+}
+
+func (r *memoryOrganizationRepository) DeleteInvitationsByEmail(ctx context.Context, email string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for id, invitation := range r.s.invitations {
+		if invitation.Email == email {
+			delete(r.s.invitations, id)
+		}
+	}
+	return nil
+}
+
+// findMember ищет участника; вызывается под блокировкой хранилища
+func (r *memoryOrganizationRepository) findMember(organizationID, userID int) *memoryMember {
+	for _, member := range r.s.members {
+		if member.organizationID == organizationID && member.userID == userID {
+			return member
+		}
+	}
+	return nil
+}
+
+// list возвращает участников, подходящих под условие, вместе с данными организации и пользователя
+func (r *memoryOrganizationRepository) list(match func(m *memoryMember) bool) []models.OrganizationMember {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	members := []models.OrganizationMember{}
+	for _, member := range r.s.members {
+		if match(member) {
+			members = append(members, r.model(member))
+		}
+	}
+	return members
+}
+
+// model дополняет участника названием организации и email, как JOIN в SQL
+func (r *memoryOrganizationRepository) model(member *memoryMember) models.OrganizationMember {
+	result := models.OrganizationMember{
+		OrganizationID: member.organizationID,
+		UserID:         member.userID,
+		Role:           member.role,
+	}
+	if organization, ok := r.s.organizations[member.organizationID]; ok {
+		result.OrganizationName = organization.Name
+	}
+	if user, ok := r.s.users[member.userID]; ok {
+		result.Email = user.Email
+	}
+	return result
+}
+
+type memoryTwoFactorRepository struct {
+	s *memoryStore
+}
+
+func (r *memoryTwoFactorRepository) Get(ctx context.Context, subjectType string, subjectID int) (*models.TwoFactor, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	_, twoFactor := r.find(subjectType, subjectID)
+	if twoFactor == nil {
+		return nil, ErrNotFound
+	}
+	result := *twoFactor
+	return &result, nil
+}
+
+func (r *memoryTwoFactorRepository) Create(ctx context.Context, twoFactor *models.TwoFactor) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, existing := r.find(twoFactor.SubjectType, twoFactor.SubjectID); existing != nil {
+		return ErrDuplicate
+	}
+	stored := *twoFactor
+	stored.ConfirmedAt = nil
+	stored.LastUsedStep = 0
+	r.s.twoFactor[r.s.id("two_factor")] = &stored
+	return nil
+}
+
+func (r *memoryTwoFactorRepository) ListSecrets(ctx context.Context) ([]models.TwoFactor, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var settings []models.TwoFactor
+	for _, key := range sortedIDs(r.s.twoFactor) {
+		twoFactor := r.s.twoFactor[key]
+		settings = append(settings, models.TwoFactor{SubjectType: twoFactor.SubjectType, SubjectID: twoFactor.SubjectID, Secret: twoFactor.Secret})
+	}
+	return settings, nil
+}
+
+func (r *memoryTwoFactorRepository) ReplaceSecret(ctx context.Context, subjectType string, subjectID int, oldSecret, newSecret string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	_, twoFactor := r.find(subjectType, subjectID)
+	if twoFactor == nil || twoFactor.Secret != oldSecret {
+		return ErrNotFound
+	}
+	twoFactor.Secret = newSecret
+	return nil
+}
+
+func (r *memoryTwoFactorRepository) Delete(ctx context.Context, subjectType string, subjectID int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.deleteRecoveryCodes(subjectType, subjectID)
+	key, twoFactor := r.find(subjectType, subjectID)
+	if twoFactor == nil {
+		return ErrNotFound
+	}
+	delete(r.s.twoFactor, key)
+	return nil
+}
+
+func (r *memoryTwoFactorRepository) Confirm(ctx context.Context, subjectType string, subjectID int, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	_, twoFactor := r.find(subjectType, subjectID)
+	if twoFactor == nil {
+		return ErrNotFound
+	}
+	twoFactor.ConfirmedAt = &at
+	return nil
+}
+
+func (r *memoryTwoFactorRepository) MarkStepUsed(ctx context.Context, subjectType string, subjectID int, step int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	_, twoFactor := r.find(subjectType, subjectID)
+	if twoFactor == nil || twoFactor.LastUsedStep >= step {
+		return ErrNotFound
+	}
+	twoFactor.LastUsedStep = step
+	return nil
+}
+
+func (r *memoryTwoFactorRepository) ReplaceRecoveryCodes(ctx context.Context, subjectType string, subjectID int, codeHashes []string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.deleteRecoveryCodes(subjectType, subjectID)
+	for _, codeHash := range codeHashes {
+		r.s.recoveryCodes[r.s.id("recovery_codes")] = &memoryRecoveryCode{
+			subjectType: subjectType,
+			subjectID:   subjectID,
+			codeHash:    codeHash,
+		}
+	}
+	return nil
+}
+
+func (r *memoryTwoFactorRepository) UseRecoveryCode(ctx context.Context, subjectType string, subjectID int, codeHash string, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, code := range r.s.recoveryCodes {
+		if code.subjectType == subjectType && code.subjectID == subjectID && code.codeHash == codeHash && !code.used {
+			code.used = true
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *memoryTwoFactorRepository) CountRecoveryCodes(ctx context.Context, subjectType string, subjectID int) (int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	count := 0
+	for _, code := range r.s.recoveryCodes {
+		if code.subjectType == subjectType && code.subjectID == subjectID && !code.used {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// find ищет настройку и ее ключ; вызывается под блокировкой хранилища
+func (r *memoryTwoFactorRepository) find(subjectType string, subjectID int) (int, *models.TwoFactor) {
+	for key, twoFactor := range r.s.twoFactor {
+		if twoFactor.SubjectType == subjectType && twoFactor.SubjectID == subjectID {
+			return key, twoFactor
+		}
+	}
+	return 0, nil
+}
+
+// deleteRecoveryCodes удаляет коды восстановления; вызывается под блокировкой хранилища
+func (r *memoryTwoFactorRepository) deleteRecoveryCodes(subjectType string, subjectID int) {
+	for key, code := range r.s.recoveryCodes {
+		if code.subjectType == subjectType && code.subjectID == subjectID {
+			delete(r.s.recoveryCodes, key)
+		}
+	}
+}
+
+type memoryUserTokenRepository struct {
+	s *memoryStore
+}
+
+func (r *memoryUserTokenRepository) Create(ctx context.Context, token *models.UserToken) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, existing := range r.s.userTokens {
+		if existing.TokenHash == token.TokenHash {
+			return ErrDuplicate
+		}
+	}
+
+	token.ID = r.s.id("user_tokens")
+	stored := *token
+	r.s.userTokens[token.ID] = &stored
+	return nil
+}
+
+func (r *memoryUserTokenRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*models.UserToken, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, token := range r.s.userTokens {
+		if token.TokenHash == tokenHash {
+			found := *token
+			return &found, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryUserTokenRepository) MarkUsed(ctx context.Context, id int, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	token, ok := r.s.userTokens[id]
+	if !ok || token.UsedAt != nil {
+		return ErrNotFound
+	}
+	token.UsedAt = &at
+	return nil
+}
+
+func (r *memoryUserTokenRepository) InvalidateForUser(ctx context.Context, userID int, purpose string, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, token := range r.s.userTokens {
+		if token.UserID == userID && token.Purpose == purpose && token.UsedAt == nil {
+			token.UsedAt = &at
+		}
+	}
+	return nil
+}
+
+type memoryLoginAttemptRepository struct {
+	s *memoryStore
+}
+
+// find возвращает счетчик по ключу; вызывается под блокировкой хранилища
+func (r *memoryLoginAttemptRepository) find(key string) *models.LoginAttempt {
+	for _, attempt := range r.s.loginAttempts {
+		if attempt.Key == key {
+			return attempt
+		}
+	}
+	return nil
+}
+
+func (r *memoryLoginAttemptRepository) Get(ctx context.Context, key string) (*models.LoginAttempt, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	attempt := r.find(key)
+	if attempt == nil {
+		return nil, ErrNotFound
+	}
+	found := *attempt
+	return &found, nil
+}
+
+func (r *memoryLoginAttemptRepository) RegisterFailure(ctx context.Context, key string, at, windowStart time.Time) (*models.LoginAttempt, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	attempt := r.find(key)
+	if attempt == nil {
+		attempt = &models.LoginAttempt{ID: r.s.id("login_attempts"), Key: key}
+		r.s.loginAttempts[attempt.ID] = attempt
+	}
+	if attempt.LastFailureAt.Before(windowStart) {
+		attempt.Failures = 0
+	}
+	attempt.Failures++
+	attempt.LastFailureAt = at
+	found := *attempt
+	return &found, nil
+}
+
+func (r *memoryLoginAttemptRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	attempt := r.find(key)
+	if attempt == nil {
+		return ErrNotFound
+	}
+	attempt.LockedUntil = &until
+	attempt.Failures = 0
+	return nil
+}
+
+func (r *memoryLoginAttemptRepository) Reset(ctx context.Context, key string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	attempt := r.find(key)
+	if attempt == nil {
+		return ErrNotFound
+	}
+	delete(r.s.loginAttempts, attempt.ID)
+	return nil
+}
+
+func (r *memoryLoginAttemptRepository) ListLocked(ctx context.Context, at time.Time) ([]models.LoginAttempt, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	attempts := []models.LoginAttempt{}
+	for _, id := range sortedIDs(r.s.loginAttempts) {
+		attempt := r.s.loginAttempts[id]
+		if attempt.LockedUntil != nil && attempt.LockedUntil.After(at) {
+			attempts = append(attempts, *attempt)
+		}
+	}
+	sort.SliceStable(attempts, func(i, j int) bool {
+		return attempts[i].LockedUntil.Before(*attempts[j].LockedUntil)
+	})
+	return attempts, nil
+}
+
+func (r *memoryLoginAttemptRepository) DeleteStale(ctx context.Context, before time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for id, attempt := range r.s.loginAttempts {
+		if attempt.LastFailureAt.Before(before) && (attempt.LockedUntil == nil || attempt.LockedUntil.Before(before)) {
+			delete(r.s.loginAttempts, id)
+		}
+	}
+	return nil
+}
+
+type memoryRateLimitRepository struct {
+	s *memoryStore
+}
+
+func (r *memoryRateLimitRepository) Take(ctx context.Context, key string, capacity int, interval time.Duration, at time.Time) (bool, float64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	bucket, ok := r.s.rateLimits[key]
+	if !ok {
+		r.s.rateLimits[key] = &memoryRateLimitBucket{tokens: float64(capacity - 1), updatedAt: at}
+		return true, float64(capacity - 1), nil
+	}
+	if at.After(bucket.updatedAt) {
+		bucket.tokens = math.Min(float64(capacity), bucket.tokens+float64(at.Sub(bucket.updatedAt))/float64(interval))
+		bucket.updatedAt = at
+	}
+	if bucket.tokens < 1 {
+		return false, bucket.tokens, nil
+	}
+	bucket.tokens--
+	return true, bucket.tokens, nil
+}
+
+func (r *memoryRateLimitRepository) DeleteStale(ctx context.Context, before time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for key, bucket := range r.s.rateLimits {
+		if bucket.updatedAt.Before(before) {
+			delete(r.s.rateLimits, key)
+		}
+	}
+	return nil
+}
+
+type memoryAPIKeyRepository struct {
+	s *memoryStore
+}
+
+// copyAPIKey возвращает копию ключа, не разделяющую срез разрешений с хранилищем
+func copyAPIKey(key *models.APIKey) *models.APIKey {
+	copied := *key
+	copied.Scopes = append([]string{}, key.Scopes...)
+	return &copied
+}
+
+func (r *memoryAPIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, existing := range r.s.apiKeys {
+		if existing.KeyHash == key.KeyHash {
+			return ErrDuplicate
+		}
+	}
+	key.ID = r.s.id("api_keys")
+	key.CreatedAt = time.Now()
+	r.s.apiKeys[key.ID] = copyAPIKey(key)
+	return nil
+}
+
+func (r *memoryAPIKeyRepository) GetByKeyHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, key := range r.s.apiKeys {
+		if key.KeyHash == keyHash {
+			return copyAPIKey(key), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryAPIKeyRepository) ListByUser(ctx context.Context, userID int) ([]models.APIKey, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	keys := []models.APIKey{}
+	for _, id := range sortedIDs(r.s.apiKeys) {
+		if key := r.s.apiKeys[id]; key.UserID == userID {
+			keys = append(keys, *copyAPIKey(key))
+		}
+	}
+	return keys, nil
+}
+
+func (r *memoryAPIKeyRepository) Revoke(ctx context.Context, userID, id int, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	key, ok := r.s.apiKeys[id]
+	if !ok || key.UserID != userID || key.RevokedAt != nil {
+		return ErrNotFound
+	}
+	key.RevokedAt = &at
+	return nil
+}
+
+func (r *memoryAPIKeyRepository) TouchLastUsed(ctx context.Context, id int, at, notAfter time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if key, ok := r.s.apiKeys[id]; ok && (key.LastUsedAt == nil || key.LastUsedAt.Before(notAfter)) {
+		key.LastUsedAt = &at
+	}
+	return nil
+}
+
+type memoryAuditRepository struct {
+	s *memoryStore
+}
+
+func (r *memoryAuditRepository) Create(ctx context.Context, event *models.AuditEvent) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	event.ID = r.s.id("audit_events")
+	copied := *event
+	r.s.auditEvents[event.ID] = &copied
+	return nil
+}
+
+func (r *memoryAuditRepository) List(ctx context.Context, filter AuditFilter) ([]models.AuditEvent, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	ids := sortedIDs(r.s.auditEvents)
+	events := []models.AuditEvent{}
+	for i := len(ids) - 1; i >= 0; i-- {
+		if filter.Limit > 0 && len(events) == filter.Limit {
+			break
+		}
+		if event := r.s.auditEvents[ids[i]]; filter.matches(event) {
+			events = append(events, *event)
+		}
+	}
+	return events, nil
+}
+
+// matches повторяет условия WHERE из sqlAuditRepository.List
+func (f AuditFilter) matches(event *models.AuditEvent) bool {
+	actorID, impersonatorID := 0, 0
+	if event.ActorID != nil {
+		actorID = *event.ActorID
+	}
+	if event.ImpersonatorID != nil {
+		impersonatorID = *event.ImpersonatorID
+	}
+	switch {
+	case f.ActorType != "" && event.ActorType != f.ActorType,
+		f.ActorID != 0 && actorID != f.ActorID,
+		f.ImpersonatorID != 0 && impersonatorID != f.ImpersonatorID,
+		f.Action != "" && event.Action != f.Action,
+		f.TargetType != "" && event.TargetType != f.TargetType,
+		f.TargetID != "" && event.TargetID != f.TargetID,
+		!f.From.IsZero() && event.CreatedAt.Before(f.From),
+		!f.To.IsZero() && !event.CreatedAt.Before(f.To),
+		f.BeforeID != 0 && event.ID >= f.BeforeID:
+		return false
+	}
+	if f.UserID != 0 {
+		byUser := (event.ActorType == models.ActorUser || event.ActorType == models.ActorAPIKey) && actorID == f.UserID
+		onUser := event.TargetType == "user" && event.TargetID == strconv.Itoa(f.UserID)
+		return byUser || onUser
+	}
+	return true
+}
+
+type memorySyncRunRepository struct {
+	s *memoryStore
+}
+
+func (r *memorySyncRunRepository) Create(ctx context.Context, run *models.SyncRun) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	run.ID = r.s.id("sync_runs")
+	copied := *run
+	r.s.syncRuns[run.ID] = &copied
+	return nil
+}
+
+func (r *memorySyncRunRepository) Summary(ctx context.Context, from time.Time) ([]SyncSummary, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	counts := make(map[SyncSummary]int)
+	for _, run := range r.s.syncRuns {
+		if !run.StartedAt.Before(from) {
+			counts[SyncSummary{StoreType: run.StoreType, Status: run.Status, ErrorCategory: run.ErrorCategory}]++
+		}
+	}
+	var summary []SyncSummary
+	for row, runs := range counts {
+		row.Runs = runs
+		summary = append(summary, row)
+	}
+	sort.Slice(summary, func(i, j int) bool {
+		a, b := summary[i], summary[j]
+		if a.StoreType != b.StoreType {
+			return a.StoreType < b.StoreType
+		}
+		if a.Status != b.Status {
+			return a.Status < b.Status
+		}
+		return a.ErrorCategory < b.ErrorCategory
+	})
+	return summary, nil
+}
+
+type memoryStatsRepository struct {
+	s *memoryStore
+}
+
+func (r *memoryStatsRepository) CreatedPerDay(ctx context.Context, entity string, from time.Time) ([]DailyCount, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var createdAt []*time.Time
+	switch entity {
+	case StatsUsers:
+		for _, user := range r.s.users {
+			createdAt = append(createdAt, user.CreatedAt)
+		}
+	case StatsStores:
+		for _, store := range r.s.stores {
+			createdAt = append(createdAt, store.CreatedAt)
+		}
+	case StatsProducts:
+		for _, product := range r.s.products {
+			createdAt = append(createdAt, product.CreatedAt)
+		}
+	case StatsMappings:
+		for _, mapping := range r.s.mappings {
+			createdAt = append(createdAt, mapping.CreatedAt)
+		}
+	default:
+		return nil, fmt.Errorf("unknown stats entity %q", entity)
+	}
+
+	perDay := make(map[string]int)
+	for _, t := range createdAt {
+		if t != nil && !t.Before(from) {
+			perDay[t.UTC().Format(time.DateOnly)]++
+		}
+	}
+	counts := make([]DailyCount, 0, len(perDay))
+	for day, count := range perDay {
+		counts = append(counts, DailyCount{Day: day, Count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Day < counts[j].Day })
+	return counts, nil
+}
+
+func (r *memoryStatsRepository) CountByStoreType(ctx context.Context) ([]StoreTypeCount, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	byType := make(map[string]*StoreTypeCount)
+	for _, store := range r.s.stores {
+		count, ok := byType[store.Type]
+		if !ok {
+			count = &StoreTypeCount{StoreType: store.Type}
+			byType[store.Type] = count
+		}
+		count.Stores++
+	}
+	for _, product := range r.s.products {
+		if store, ok := r.s.stores[product.StoreID]; ok {
+			byType[store.Type].Products++
+		}
+	}
+
+	var counts []StoreTypeCount
+	for _, count := range byType {
+		counts = append(counts, *count)
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].StoreType < counts[j].StoreType })
+	return counts, nil
+}
+
+func (r *memoryStatsRepository) TopUsersByProducts(ctx context.Context, limit int) ([]UserCatalogue, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	byUser := make(map[int]*UserCatalogue)
+	for _, store := range r.s.stores {
+		user, ok := r.s.users[store.UserID]
+		if !ok {
+			continue
+		}
+		catalogue, ok := byUser[user.ID]
+		if !ok {
+			catalogue = &UserCatalogue{UserID: user.ID, Email: user.Email}
+			byUser[user.ID] = catalogue
+		}
+		catalogue.Stores++
+	}
+	for _, product := range r.s.products {
+		if store, ok := r.s.stores[product.StoreID]; ok {
+			if catalogue, ok := byUser[store.UserID]; ok {
+				catalogue.Products++
+			}
+		}
+	}
+
+	var users []UserCatalogue
+	for _, catalogue := range byUser {
+		if catalogue.Products > 0 {
+			users = append(users, *catalogue)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].Products != users[j].Products {
+			return users[i].Products > users[j].Products
+		}
+		return users[i].UserID < users[j].UserID
+	})
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+func (r *memoryStatsRepository) InactiveStores(ctx context.Context, since time.Time, limit int) ([]InactiveStore, int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	// Последняя успешная загрузка каждого магазина, как MAX(id) в SQL
+	lastSuccess := make(map[int]*models.SyncRun)
+	for _, id := range sortedIDs(r.s.syncRuns) {
+		if run := r.s.syncRuns[id]; run.Status == models.SyncSucceeded {
+			lastSuccess[run.StoreID] = run
+		}
+	}
+
+	stores := []InactiveStore{}
+	total := 0
+	for _, id := range sortedIDs(r.s.stores) {
+		store := r.s.stores[id].Store
+		if store.CreatedAt == nil || !store.CreatedAt.Before(since) {
+			continue
+		}
+		run, ok := lastSuccess[id]
+		if ok && !run.StartedAt.Before(since) {
+			continue
+		}
+		total++
+		if len(stores) < limit {
+			inactive := InactiveStore{Store: store}
+			if ok {
+				startedAt := run.StartedAt
+				inactive.LastSyncedAt = &startedAt
+			}
+			stores = append(stores, inactive)
+		}
+	}
+	return stores, total, nil
+}
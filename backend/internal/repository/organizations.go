@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"kursovaya_backend/internal/database"
+	"kursovaya_backend/internal/models"
+)
+
+// OrganizationRepository описывает хранилище организаций, их участников и приглашений
+type OrganizationRepository interface {
+	Create(ctx context.Context, name string) (*models.Organization, error)
+	GetByID(ctx context.Context, id int) (*models.Organization, error)
+	Rename(ctx context.Context, id int, name string) error
+	// Delete удаляет организацию вместе с участниками и приглашениями.
+	// Магазины и сопоставления организации должны быть удалены заранее.
+	Delete(ctx context.Context, id int) error
+	// AddMember добавляет пользователя в организацию. Возвращает ErrDuplicate,
+	// если пользователь уже состоит в ней.
+	AddMember(ctx context.Context, organizationID, userID int, role string) error
+	GetMember(ctx context.Context, organizationID, userID int) (*models.OrganizationMember, error)
+	// GetDefaultMembership возвращает самое раннее членство пользователя,
+	// обычно это его личная организация
+	GetDefaultMembership(ctx context.Context, userID int) (*models.OrganizationMember, error)
+	// ListByUser возвращает организации, в которых состоит пользователь
+	ListByUser(ctx context.Context, userID int) ([]models.OrganizationMember, error)
+	ListMembers(ctx context.Context, organizationID int) ([]models.OrganizationMember, error)
+	SetMemberRole(ctx context.Context, organizationID, userID int, role string) error
+	RemoveMember(ctx context.Context, organizationID, userID int) error
+	// CountMembersWithRole считает участников организации с указанной ролью
+	CountMembersWithRole(ctx context.Context, organizationID int, role string) (int, error)
+
+	// CreateInvitation сохраняет приглашение и заполняет его ID
+	CreateInvitation(ctx context.Context, invitation *models.Invitation) error
+	GetInvitationByTokenHash(ctx context.Context, tokenHash string) (*models.Invitation, error)
+	// MarkInvitationAccepted помечает приглашение принятым. Возвращает ErrNotFound,
+	// если оно уже было принято, поэтому приглашение одноразовое.
+	MarkInvitationAccepted(ctx context.Context, id int, at time.Time) error
+	// DeleteInvitationsByEmail удаляет все приглашения, выписанные на email
+	DeleteInvitationsByEmail(ctx context.Context, email string) error
+}
+
+type sqlOrganizationRepository struct {
+	db database.DBTX
+}
+
+func (r *sqlOrganizationRepository) Create(ctx context.Context, name string) (*models.Organization, error) {
+	organization := models.Organization{Name: name}
+	err := r.db.QueryRowContext(ctx,
+		"INSERT INTO organizations (name) VALUES ($1) RETURNING id", name,
+	).Scan(&organization.ID)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &organization, nil
+}
+
+func (r *sqlOrganizationRepository) GetByID(ctx context.Context, id int) (*models.Organization, error) {
+	var organization models.Organization
+	err := r.db.QueryRowContext(ctx, "SELECT id, name FROM organizations WHERE id = $1", id).
+		Scan(&organization.ID, &organization.Name)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &organization, nil
+}
+
+func (r *sqlOrganizationRepository) Rename(ctx context.Context, id int, name string) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE organizations SET name = $1 WHERE id = $2", name, id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqlOrganizationRepository) Delete(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM organizations WHERE id = $1", id)
+	if err != nil {
+		return mapError(err)
+	}
+	return checkAffected(result)
+}
+
+func (r *sqlOrganizationRepository) AddMember(ctx context.Context, organizationID, userID int, role string) error {
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO organization_members (organization_id, user_id, role) VALUES ($1, $2, $3)",
+		organizationID, userID, role,
+	)
+	return mapError(err)
+}
+
+// memberColumns - поля участника вместе с названием организации и email пользователя
+const memberColumns = `
+	SELECT m.organization_id, o.name, m.user_id, u.email, m.role
+	FROM organization_members m
+	JOIN organizations o ON o.id = m.organization_id
+	JOIN users u ON u.id = m.user_id
+`
+
+func (r *sqlOrganizationRepository) GetMember(ctx context.Context, organizationID, userID int) (*models.OrganizationMember, error) {
+	return r.queryMember(ctx, memberColumns+"WHERE m.organization_id = $1 AND m.user_id = $2", organizationID, userID)
+}
+
+func (r *sqlOrganizationRepository) GetDefaultMembership(ctx context.Context, userID int) (*models.OrganizationMember, error) {
+	return r.queryMember(ctx, memberColumns+"WHERE m.user_id = $1 ORDER BY m.organization_id LIMIT 1", userID)
+}
+
+func (r *sqlOrganizationRepository) ListByUser(ctx context.Context, userID int) ([]models.OrganizationMember, error) {
+	return r.queryMembers(ctx, memberColumns+"WHERE m.user_id = $1 ORDER BY m.organization_id", userID)
+}
+
+func (r *sqlOrganizationRepository) ListMembers(ctx context.Context, organizationID int) ([]models.OrganizationMember, error) {
+	return r.queryMembers(ctx, memberColumns+"WHERE m.organization_id = $1 ORDER BY m.user_id", organizationID)
+}
+
+func (r *sqlOrganizationRepository) SetMemberRole(ctx context.Context, organizationID, userID int, role string) error {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE organization_members SET role = $1 WHERE organization_id = $2 AND user_id = $3",
+		role, organizationID, userID,
+	)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqlOrganizationRepository) RemoveMember(ctx context.Context, organizationID, userID int) error {
+	result, err := r.db.ExecContext(ctx,
+		"DELETE FROM organization_members WHERE organization_id = $1 AND user_id = $2",
+		organizationID, userID,
+	)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqlOrganizationRepository) CountMembersWithRole(ctx context.Context, organizationID int, role string) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM organization_members WHERE organization_id = $1 AND role = $2",
+		organizationID, role,
+	).Scan(&count)
+	return count, err
+}
+
+func (r *sqlOrganizationRepository) CreateInvitation(ctx context.Context, invitation *models.Invitation) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO organization_invitations (organization_id, email, role, token_hash, invited_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id
+	`,
+		invitation.OrganizationID, invitation.Email, invitation.Role,
+		invitation.TokenHash, invitation.InvitedBy, invitation.ExpiresAt.UTC(),
+	).Scan(&invitation.ID)
+	return mapError(err)
+}
+
+func (r *sqlOrganizationRepository) GetInvitationByTokenHash(ctx context.Context, tokenHash string) (*models.Invitation, error) {
+	var invitation models.Invitation
+	var acceptedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, organization_id, email, role, token_hash, invited_by, expires_at, accepted_at
+		FROM organization_invitations WHERE token_hash = $1
+	`, tokenHash).Scan(
+		&invitation.ID, &invitation.OrganizationID, &invitation.Email, &invitation.Role,
+		&invitation.TokenHash, &invitation.InvitedBy, &invitation.ExpiresAt, &acceptedAt,
+	)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	if acceptedAt.Valid {
+		invitation.AcceptedAt = &acceptedAt.Time
+	}
+	return &invitation, nil
+}
+
+func (r *sqlOrganizationRepository) MarkInvitationAccepted(ctx context.Context, id int, at time.Time) error {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE organization_invitations SET accepted_at = $1 WHERE id = $2 AND accepted_at IS NULL",
+		at.UTC(), id,
+	)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqlOrganizationRepository) DeleteInvitationsByEmail(ctx context.Context, email string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM organization_invitations WHERE email = $1", email)
+	return err
+}
+
+func (r *sqlOrganizationRepository) queryMember(ctx context.Context, query string, args ...interface{}) (*models.OrganizationMember, error) {
+	var member models.OrganizationMember
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(
+		&member.OrganizationID, &member.OrganizationName, &member.UserID, &member.Email, &member.Role,
+	)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &member, nil
+}
+
+func (r *sqlOrganizationRepository) queryMembers(ctx context.Context, query string, args ...interface{}) ([]models.OrganizationMember, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []models.OrganizationMember{}
+	for rows.Next() {
+		var member models.OrganizationMember
+		if err := rows.Scan(&member.OrganizationID, &member.OrganizationName, &member.UserID, &member.Email, &member.Role); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
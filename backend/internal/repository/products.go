@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"kursovaya_backend/internal/database"
+	"kursovaya_backend/internal/models"
+)
+
+// ProductRepository описывает хранилище сохраненных товаров
+type ProductRepository interface {
+	// Create сохраняет товар и заполняет его ID
+	Create(ctx context.Context, product *models.Product) error
+	// ListByOrganization возвращает товары всех магазинов организации
+	ListByOrganization(ctx context.Context, organizationID int) ([]models.Product, error)
+	// ListMappedByOrganization возвращает товары, участвующие в сопоставлениях организации
+	ListMappedByOrganization(ctx context.Context, organizationID int) ([]models.Product, error)
+	// DeleteByStore удаляет все товары магазина
+	DeleteByStore(ctx context.Context, storeID int) error
+	GetByID(ctx context.Context, id int) (*models.Product, error)
+	// List возвращает страницу товаров и общее число подходящих под фильтры
+	List(ctx context.Context, query ListQuery) ([]models.Product, int, error)
+	Delete(ctx context.Context, id int) error
+	Count(ctx context.Context) (int, error)
+}
+
+type sqlProductRepository struct {
+	db database.DBTX
+}
+
+func (r *sqlProductRepository) Create(ctx context.Context, product *models.Product) error {
+	err := r.db.QueryRowContext(ctx,
+		"INSERT INTO products (store_id, external_id, name, price, quantity) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at",
+		product.StoreID, product.ExternalID, product.Name, product.Price, product.Quantity,
+	).Scan(&product.ID, &product.CreatedAt)
+	return mapError(err)
+}
+
+func (r *sqlProductRepository) ListByOrganization(ctx context.Context, organizationID int) ([]models.Product, error) {
+	return r.query(ctx, `
+		SELECT p.id, p.store_id, p.external_id, p.name, p.price, p.quantity, p.created_at
+		FROM products p
+		JOIN stores s ON s.id = p.store_id
+		WHERE s.organization_id = $1
+		ORDER BY p.id
+	`, organizationID)
+}
+
+func (r *sqlProductRepository) ListMappedByOrganization(ctx context.Context, organizationID int) ([]models.Product, error) {
+	return r.query(ctx, `
+		SELECT id, store_id, external_id, name, price, quantity, created_at
+		FROM products
+		WHERE id IN (
+			SELECT product1_id FROM product_mappings WHERE organization_id = $1
+			UNION
+			SELECT product2_id FROM product_mappings WHERE organization_id = $1
+		)
+		ORDER BY id
+	`, organizationID)
+}
+
+func (r *sqlProductRepository) DeleteByStore(ctx context.Context, storeID int) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM products WHERE store_id = $1", storeID)
+	return mapError(err)
+}
+
+func (r *sqlProductRepository) GetByID(ctx context.Context, id int) (*models.Product, error) {
+	var product models.Product
+	err := r.db.QueryRowContext(ctx,
+		"SELECT id, store_id, external_id, name, price, quantity, created_at FROM products WHERE id = $1", id,
+	).Scan(&product.ID, &product.StoreID, &product.ExternalID, &product.Name, &product.Price, &product.Quantity, &product.CreatedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &product, nil
+}
+
+// productSortColumns сопоставляет ProductSortFields колонкам запроса
+var productSortColumns = map[string]string{
+	"id": "p.id", "name": "p.name", "price": "p.price", "quantity": "p.quantity", "created_at": "p.created_at",
+}
+
+func (r *sqlProductRepository) List(ctx context.Context, query ListQuery) ([]models.Product, int, error) {
+	var b listBuilder
+	b.contains("p.name", query.Name)
+	if query.StoreID != 0 {
+		b.add("p.store_id = %s", query.StoreID)
+	}
+	if query.StoreType != "" {
+		b.add("s.store_type = %s", query.StoreType)
+	}
+	if query.UserID != 0 {
+		b.add("s.user_id = %s", query.UserID)
+	}
+	b.created("p.created_at", query)
+	rows, total, err := b.run(ctx, r.db,
+		"p.id, p.store_id, p.external_id, p.name, p.price, p.quantity, p.created_at",
+		"FROM products p JOIN stores s ON s.id = p.store_id", productSortColumns, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	products, err := scanProducts(rows)
+	return products, total, err
+}
+
+func (r *sqlProductRepository) Delete(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM products WHERE id = $1", id)
+	if err != nil {
+		return mapError(err)
+	}
+	return checkAffected(result)
+}
+
+func (r *sqlProductRepository) Count(ctx context.Context) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM products").Scan(&count)
+	return count, err
+}
+
+func (r *sqlProductRepository) query(ctx context.Context, query string, args ...interface{}) ([]models.Product, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanProducts(rows)
+}
+
+// scanProducts читает и закрывает строки товаров
+func scanProducts(rows *sql.Rows) ([]models.Product, error) {
+	defer rows.Close()
+
+	products := []models.Product{}
+	for rows.Next() {
+		var product models.Product
+		if err := rows.Scan(&product.ID, &product.StoreID, &product.ExternalID, &product.Name, &product.Price, &product.Quantity, &product.CreatedAt); err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+	return products, rows.Err()
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"kursovaya_backend/internal/database"
+)
+
+// RateLimitRepository описывает хранилище корзин токенов для ограничения
+// частоты запросов
+type RateLimitRepository interface {
+	// Take атомарно пополняет корзину key на один токен за каждый interval,
+	// прошедший с прошлого обращения, но не больше capacity, и забирает из
+	// нее токен, если он есть. Новая корзина создается полной. Возвращает,
+	// был ли токен выдан, и число токенов, оставшихся в корзине.
+	Take(ctx context.Context, key string, capacity int, interval time.Duration, at time.Time) (bool, float64, error)
+	// DeleteStale удаляет корзины, к которым не обращались с before
+	DeleteStale(ctx context.Context, before time.Time) error
+}
+
+// NewMemoryRateLimits создает отдельное хранилище корзин в памяти. Подходит
+// для одного экземпляра сервера; при нескольких репликах корзины должны
+// храниться в базе, иначе каждая реплика пропускает свой лимит запросов.
+func NewMemoryRateLimits() RateLimitRepository {
+	return &memoryRateLimitRepository{newMemoryStore()}
+}
+
+type sqlRateLimitRepository struct {
+	db database.DBTX
+}
+
+// Выражения пополнения корзины для Take. Время хранится в миллисекундах
+// Unix, чтобы арифметика одинаково работала в PostgreSQL и SQLite. Часы
+// реплик могут расходиться, поэтому время обращения не уменьшается.
+const (
+	rateLimitElapsed = `(CASE WHEN $3 > rate_limits.updated_at_ms THEN $3 - rate_limits.updated_at_ms ELSE 0 END)`
+	rateLimitRefill  = `rate_limits.tokens + ` + rateLimitElapsed + ` * CAST($4 AS DOUBLE PRECISION)`
+	rateLimitTokens  = `(CASE WHEN ` + rateLimitRefill + ` > $2 THEN $2 ELSE ` + rateLimitRefill + ` END)`
+)
+
+func (r *sqlRateLimitRepository) Take(ctx context.Context, key string, capacity int, interval time.Duration, at time.Time) (bool, float64, error) {
+	// Один запрос вместо чтения и записи, чтобы параллельные запросы
+	// с разных реплик не получили один и тот же токен
+	var allowed int
+	var tokens float64
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO rate_limits (bucket_key, tokens, allowed, updated_at_ms) VALUES ($1, $5, 1, $3)
+		ON CONFLICT (bucket_key) DO UPDATE SET
+			tokens = CASE WHEN `+rateLimitTokens+` >= 1 THEN `+rateLimitTokens+` - 1 ELSE `+rateLimitTokens+` END,
+			allowed = CASE WHEN `+rateLimitTokens+` >= 1 THEN 1 ELSE 0 END,
+			updated_at_ms = CASE WHEN $3 > rate_limits.updated_at_ms THEN $3 ELSE rate_limits.updated_at_ms END
+		RETURNING allowed, tokens`,
+		key, float64(capacity), at.UnixMilli(), float64(time.Millisecond)/float64(interval), float64(capacity-1),
+	).Scan(&allowed, &tokens)
+	if err != nil {
+		return false, 0, mapError(err)
+	}
+	return allowed == 1, tokens, nil
+}
+
+func (r *sqlRateLimitRepository) DeleteStale(ctx context.Context, before time.Time) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM rate_limits WHERE updated_at_ms < $1", before.UnixMilli())
+	return err
+}
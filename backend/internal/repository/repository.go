@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"kursovaya_backend/internal/database"
+)
+
+var (
+	// ErrNotFound возвращается, когда запись не найдена
+	ErrNotFound = errors.New("record not found")
+	// ErrDuplicate возвращается при нарушении ограничения уникальности
+	ErrDuplicate = errors.New("record already exists")
+)
+
+// Repositories объединяет все репозитории приложения
+type Repositories struct {
+	Users         UserRepository
+	Admins        AdminRepository
+	Stores        StoreRepository
+	Products      ProductRepository
+	Mappings      MappingRepository
+	Sessions      SessionRepository
+	Organizations OrganizationRepository
+	TwoFactor     TwoFactorRepository
+	UserTokens    UserTokenRepository
+	LoginAttempts LoginAttemptRepository
+	APIKeys       APIKeyRepository
+	Audit         AuditRepository
+	SyncRuns      SyncRunRepository
+	Stats         StatsRepository
+	RateLimits    RateLimitRepository
+
+	// withinTx запускает функцию с репозиториями, привязанными к одной транзакции
+	withinTx func(ctx context.Context, fn func(tx *Repositories) error) error
+}
+
+// WithinTx выполняет fn атомарно: все вызовы репозиториев tx либо применяются
+// вместе, либо откатываются, если fn вернула ошибку. Вложенный вызов
+// выполняется в уже открытой транзакции. При конфликте сериализации fn
+// вызывается заново (см. database.RunInTx), поэтому она должна быть
+// идемпотентной.
+func (r *Repositories) WithinTx(ctx context.Context, fn func(tx *Repositories) error) error {
+	return r.withinTx(ctx, fn)
+}
+
+// NewSQL создает репозитории поверх SQL-базы. Запросы совместимы
+// и с PostgreSQL, и с SQLite, поэтому реализация общая для обоих драйверов;
+// различающиеся выражения выбираются по драйверу db.
+func NewSQL(db *sql.DB) *Repositories {
+	driver := database.DriverOf(db)
+	repos := newSQLRepositories(db, driver)
+	repos.withinTx = func(ctx context.Context, fn func(tx *Repositories) error) error {
+		return database.RunInTx(ctx, db, func(tx *sql.Tx) error {
+			txRepos := newSQLRepositories(tx, driver)
+			txRepos.withinTx = func(ctx context.Context, fn func(tx *Repositories) error) error {
+				return fn(txRepos)
+			}
+			return fn(txRepos)
+		})
+	}
+	return repos
+}
+
+func newSQLRepositories(db database.DBTX, driver string) *Repositories {
+	return &Repositories{
+		Users:         &sqlUserRepository{db: db},
+		Admins:        &sqlAdminRepository{db: db},
+		Stores:        &sqlStoreRepository{db: db},
+		Products:      &sqlProductRepository{db: db},
+		Mappings:      &sqlMappingRepository{db: db},
+		Sessions:      &sqlSessionRepository{db: db},
+		Organizations: &sqlOrganizationRepository{db: db},
+		TwoFactor:     &sqlTwoFactorRepository{db: db},
+		UserTokens:    &sqlUserTokenRepository{db: db},
+		LoginAttempts: &sqlLoginAttemptRepository{db: db},
+		APIKeys:       &sqlAPIKeyRepository{db: db},
+		Audit:         &sqlAuditRepository{db: db},
+		SyncRuns:      &sqlSyncRunRepository{db: db},
+		Stats:         &sqlStatsRepository{db: db, driver: driver},
+		RateLimits:    &sqlRateLimitRepository{db: db},
+	}
+}
+
+// NewMemory создает репозитории, хранящие данные в памяти (используются в тестах)
+func NewMemory() *Repositories {
+	store := newMemoryStore()
+	repos := newMemoryRepositories(store)
+	txRepos := newMemoryRepositories(store)
+	txRepos.withinTx = func(ctx context.Context, fn func(tx *Repositories) error) error {
+		return fn(txRepos)
+	}
+	repos.withinTx = func(ctx context.Context, fn func(tx *Repositories) error) error {
+		return store.withinTx(func() error { return fn(txRepos) })
+	}
+	return repos
+}
+
+func newMemoryRepositories(store *memoryStore) *Repositories {
+	return &Repositories{
+		Users:         &memoryUserRepository{store},
+		Admins:        &memoryAdminRepository{store},
+		Stores:        &memoryStoreRepository{store},
+		Products:      &memoryProductRepository{store},
+		Mappings:      &memoryMappingRepository{store},
+		Sessions:      &memorySessionRepository{store},
+		Organizations: &memoryOrganizationRepository{store},
+		TwoFactor:     &memoryTwoFactorRepository{store},
+		UserTokens:    &memoryUserTokenRepository{store},
+		LoginAttempts: &memoryLoginAttemptRepository{store},
+		APIKeys:       &memoryAPIKeyRepository{store},
+		Audit:         &memoryAuditRepository{store},
+		SyncRuns:      &memorySyncRunRepository{store},
+		Stats:         &memoryStatsRepository{store},
+		RateLimits:    &memoryRateLimitRepository{store},
+	}
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"kursovaya_backend/internal/database"
+	"kursovaya_backend/internal/models"
+)
+
+// SessionRepository описывает хранилище refresh-токенов пользователей
+type SessionRepository interface {
+	// Create сохраняет сессию и заполняет ее ID
+	Create(ctx context.Context, session *models.Session) error
+	GetByTokenHash(ctx context.Context, tokenHash string) (*models.Session, error)
+	// MarkUsed помечает токен обменянным. Возвращает ErrNotFound, если токен
+	// уже был использован, поэтому один токен нельзя обменять дважды.
+	MarkUsed(ctx context.Context, id int, at time.Time) error
+	// RevokeFamily отзывает все токены цепочки ротации
+	RevokeFamily(ctx context.Context, familyID string, at time.Time) error
+	// RevokeAllForUser отзывает все сессии пользователя, например после смены пароля
+	RevokeAllForUser(ctx context.Context, userID int, at time.Time) error
+	// IsFamilyRevoked сообщает, отозвана ли сессия. Неизвестная сессия считается отозванной.
+	IsFamilyRevoked(ctx context.Context, familyID string) (bool, error)
+}
+
+type sqlSessionRepository struct {
+	db database.DBTX
+}
+
+func (r *sqlSessionRepository) Create(ctx context.Context, session *models.Session) error {
+	err := r.db.QueryRowContext(ctx,
+		"INSERT INTO sessions (user_id, family_id, token_hash, expires_at) VALUES ($1, $2, $3, $4) RETURNING id",
+		session.UserID, session.FamilyID, session.TokenHash, session.ExpiresAt.UTC(),
+	).Scan(&session.ID)
+	return mapError(err)
+}
+
+func (r *sqlSessionRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*models.Session, error) {
+	var session models.Session
+	var usedAt, revokedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx,
+		"SELECT id, user_id, family_id, token_hash, expires_at, used_at, revoked_at FROM sessions WHERE token_hash = $1",
+		tokenHash,
+	).Scan(&session.ID, &session.UserID, &session.FamilyID, &session.TokenHash, &session.ExpiresAt, &usedAt, &revokedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	if usedAt.Valid {
+		session.UsedAt = &usedAt.Time
+	}
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Time
+	}
+	return &session, nil
+}
+
+func (r *sqlSessionRepository) MarkUsed(ctx context.Context, id int, at time.Time) error {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE sessions SET used_at = $1 WHERE id = $2 AND used_at IS NULL",
+		at.UTC(), id,
+	)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqlSessionRepository) RevokeFamily(ctx context.Context, familyID string, at time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE sessions SET revoked_at = $1 WHERE family_id = $2 AND revoked_at IS NULL",
+		at.UTC(), familyID,
+	)
+	return err
+}
+
+func (r *sqlSessionRepository) RevokeAllForUser(ctx context.Context, userID int, at time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE sessions SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL",
+		at.UTC(), userID,
+	)
+	return err
+}
+
+func (r *sqlSessionRepository) IsFamilyRevoked(ctx context.Context, familyID string) (bool, error) {
+	var total, revoked int
+	err := r.db.QueryRowContext(ctx,
+		"SELECT COUNT(*), COUNT(revoked_at) FROM sessions WHERE family_id = $1",
+		familyID,
+	).Scan(&total, &revoked)
+	if err != nil {
+		return false, err
+	}
+	return total == 0 || revoked > 0, nil
+}
@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"kursovaya_backend/internal/database"
+	"kursovaya_backend/internal/models"
+)
+
+// Сущности, рост числа которых считает статистика админ-панели
+const (
+	StatsUsers    = "users"
+	StatsStores   = "stores"
+	StatsProducts = "products"
+	StatsMappings = "mappings"
+)
+
+// statsTables сопоставляет сущности статистики таблицам
+var statsTables = map[string]string{
+	StatsUsers:    "users",
+	StatsStores:   "stores",
+	StatsProducts: "products",
+	StatsMappings: "product_mappings",
+}
+
+// StatsRepository выполняет агрегирующие запросы статистики админ-панели
+type StatsRepository interface {
+	// CreatedPerDay возвращает число записей сущности entity (StatsUsers и
+	// т. д.), созданных не раньше from, по дням UTC по возрастанию. Дни без
+	// записей пропускаются.
+	CreatedPerDay(ctx context.Context, entity string, from time.Time) ([]DailyCount, error)
+	// CountByStoreType возвращает число магазинов и их товаров по маркетплейсам
+	CountByStoreType(ctx context.Context) ([]StoreTypeCount, error)
+	// TopUsersByProducts возвращает до limit пользователей с наибольшим числом
+	// товаров в добавленных ими магазинах
+	TopUsersByProducts(ctx context.Context, limit int) ([]UserCatalogue, error)
+	// InactiveStores возвращает до limit магазинов, созданных раньше since и
+	// без успешной загрузки товаров начиная с since, и общее число таких магазинов
+	InactiveStores(ctx context.Context, since time.Time, limit int) ([]InactiveStore, int, error)
+}
+
+// DailyCount - число записей, созданных за день Day (в формате time.DateOnly)
+type DailyCount struct {
+	Day   string
+	Count int
+}
+
+// StoreTypeCount - число магазинов маркетплейса и их товаров
+type StoreTypeCount struct {
+	StoreType string
+	Stores    int
+	Products  int
+}
+
+// UserCatalogue - размер каталога пользователя: магазины, которые он добавил, и их товары
+type UserCatalogue struct {
+	UserID   int
+	Email    string
+	Stores   int
+	Products int
+}
+
+// InactiveStore - магазин без недавних успешных загрузок. LastSyncedAt - время
+// последней успешной загрузки, nil, если ее не было.
+type InactiveStore struct {
+	Store        models.Store
+	LastSyncedAt *time.Time
+}
+
+type sqlStatsRepository struct {
+	db     database.DBTX
+	driver string
+}
+
+// createdDay - день создания записи в формате YYYY-MM-DD. Функции дат у
+// PostgreSQL и SQLite различаются; created_at хранится без часового пояса, в UTC.
+var createdDay = map[string]string{
+	database.DriverPostgres: "to_char(date_trunc('day', created_at), 'YYYY-MM-DD')",
+	database.DriverSQLite:   "date(created_at)",
+}
+
+func (r *sqlStatsRepository) CreatedPerDay(ctx context.Context, entity string, from time.Time) ([]DailyCount, error) {
+	table, ok := statsTables[entity]
+	if !ok {
+		return nil, fmt.Errorf("unknown stats entity %q", entity)
+	}
+	day := createdDay[r.driver]
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT "+day+", COUNT(*) FROM "+table+" WHERE created_at >= $1 GROUP BY "+day+" ORDER BY "+day,
+		from.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []DailyCount
+	for rows.Next() {
+		var count DailyCount
+		if err := rows.Scan(&count.Day, &count.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
+
+func (r *sqlStatsRepository) CountByStoreType(ctx context.Context) ([]StoreTypeCount, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT s.store_type, COUNT(DISTINCT s.id), COUNT(p.id)
+		FROM stores s LEFT JOIN products p ON p.store_id = s.id
+		GROUP BY s.store_type ORDER BY s.store_type`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []StoreTypeCount
+	for rows.Next() {
+		var count StoreTypeCount
+		if err := rows.Scan(&count.StoreType, &count.Stores, &count.Products); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
+
+func (r *sqlStatsRepository) TopUsersByProducts(ctx context.Context, limit int) ([]UserCatalogue, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT u.id, u.email, COUNT(DISTINCT s.id), COUNT(p.id)
+		FROM stores s JOIN users u ON u.id = s.user_id LEFT JOIN products p ON p.store_id = s.id
+		GROUP BY u.id, u.email HAVING COUNT(p.id) > 0
+		ORDER BY COUNT(p.id) DESC, u.id LIMIT $1`,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []UserCatalogue
+	for rows.Next() {
+		var user UserCatalogue
+		if err := rows.Scan(&user.UserID, &user.Email, &user.Stores, &user.Products); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// inactiveStoresFrom присоединяет к магазину его последнюю успешную загрузку.
+// Время выбирается через ID загрузки, а не MAX(started_at): агрегат времени
+// SQLite возвращает строкой.
+const inactiveStoresFrom = `
+	FROM stores s LEFT JOIN sync_runs r ON r.id = (
+		SELECT MAX(l.id) FROM sync_runs l WHERE l.store_id = s.id AND l.status = 'success'
+	)
+	WHERE s.created_at < $1 AND (r.id IS NULL OR r.started_at < $1)`
+
+func (r *sqlStatsRepository) InactiveStores(ctx context.Context, since time.Time, limit int) ([]InactiveStore, int, error) {
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*)"+inactiveStoresFrom, since.UTC()).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT s.id, s.organization_id, s.user_id, s.store_type, s.created_at, r.started_at"+inactiveStoresFrom+" ORDER BY s.id LIMIT $2",
+		since.UTC(), limit,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	stores := []InactiveStore{}
+	for rows.Next() {
+		var store InactiveStore
+		var lastSyncedAt sql.NullTime
+		if err := rows.Scan(&store.Store.ID, &store.Store.OrganizationID, &store.Store.UserID, &store.Store.Type, &store.Store.CreatedAt, &lastSyncedAt); err != nil {
+			return nil, 0, err
+		}
+		if lastSyncedAt.Valid {
+			store.LastSyncedAt = &lastSyncedAt.Time
+		}
+		stores = append(stores, store)
+	}
+	return stores, total, rows.Err()
+}
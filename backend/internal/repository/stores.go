@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+
+	"kursovaya_backend/internal/database"
+	"kursovaya_backend/internal/models"
+)
+
+// StoreRepository описывает хранилище магазинов
+type StoreRepository interface {
+	// Create сохраняет магазин организации с уже зашифрованным токеном
+	Create(ctx context.Context, organizationID, userID int, storeType, encryptedToken string) (*models.Store, error)
+	ListByOrganization(ctx context.Context, organizationID int) ([]*models.Store, error)
+	// GetToken возвращает зашифрованный токен магазина. Права на магазин
+	// проверяет сервис по членству в организации-владельце.
+	GetToken(ctx context.Context, storeID int) (string, error)
+	GetByID(ctx context.Context, id int) (*models.Store, error)
+	// List возвращает страницу магазинов без токенов и общее число подходящих под фильтры
+	List(ctx context.Context, query ListQuery) ([]models.Store, int, error)
+	DeleteByID(ctx context.Context, id int) error
+	Count(ctx context.Context) (int, error)
+
+	// ListEncryptedTokens возвращает до limit зашифрованных токенов магазинов
+	// с ID больше afterID по возрастанию ID, для перешифрования порциями
+	ListEncryptedTokens(ctx context.Context, afterID, limit int) ([]EncryptedValue, error)
+	// ReplaceEncryptedToken заменяет токен, только если он все еще равен
+	// oldToken; иначе возвращает ErrNotFound
+	ReplaceEncryptedToken(ctx context.Context, storeID int, oldToken, newToken string) error
+}
+
+// EncryptedValue - зашифрованное значение строки таблицы
+type EncryptedValue struct {
+	ID    int
+	Value string
+}
+
+type sqlStoreRepository struct {
+	db database.DBTX
+}
+
+func (r *sqlStoreRepository) Create(ctx context.Context, organizationID, userID int, storeType, encryptedToken string) (*models.Store, error) {
+	store := models.Store{OrganizationID: organizationID, UserID: userID, Type: storeType}
+	err := r.db.QueryRowContext(ctx,
+		"INSERT INTO stores (organization_id, user_id, store_type, api_token) VALUES ($1, $2, $3, $4) RETURNING id, created_at",
+		organizationID, userID, storeType, encryptedToken,
+	).Scan(&store.ID, &store.CreatedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &store, nil
+}
+
+func (r *sqlStoreRepository) ListByOrganization(ctx context.Context, organizationID int) ([]*models.Store, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT id, organization_id, user_id, store_type, created_at FROM stores WHERE organization_id = $1 ORDER BY id",
+		organizationID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stores []*models.Store
+	for rows.Next() {
+		var store models.Store
+		if err := rows.Scan(&store.ID, &store.OrganizationID, &store.UserID, &store.Type, &store.CreatedAt); err != nil {
+			return nil, err
+		}
+		stores = append(stores, &store)
+	}
+	return stores, rows.Err()
+}
+
+func (r *sqlStoreRepository) GetToken(ctx context.Context, storeID int) (string, error) {
+	var encryptedToken string
+	err := r.db.QueryRowContext(ctx, "SELECT api_token FROM stores WHERE id = $1", storeID).Scan(&encryptedToken)
+	if err != nil {
+		return "", mapError(err)
+	}
+	return encryptedToken, nil
+}
+
+func (r *sqlStoreRepository) GetByID(ctx context.Context, id int) (*models.Store, error) {
+	var store models.Store
+	err := r.db.QueryRowContext(ctx, "SELECT id, organization_id, user_id, store_type, created_at FROM stores WHERE id = $1", id).
+		Scan(&store.ID, &store.OrganizationID, &store.UserID, &store.Type, &store.CreatedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &store, nil
+}
+
+// storeSortColumns сопоставляет StoreSortFields колонкам таблицы
+var storeSortColumns = map[string]string{"id": "id", "store_type": "store_type", "user_id": "user_id", "created_at": "created_at"}
+
+func (r *sqlStoreRepository) List(ctx context.Context, query ListQuery) ([]models.Store, int, error) {
+	var b listBuilder
+	if query.StoreType != "" {
+		b.add("store_type = %s", query.StoreType)
+	}
+	if query.UserID != 0 {
+		b.add("user_id = %s", query.UserID)
+	}
+	b.created("created_at", query)
+	rows, total, err := b.run(ctx, r.db, "id, organization_id, user_id, store_type, created_at", "FROM stores", storeSortColumns, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	stores := []models.Store{}
+	for rows.Next() {
+		var store models.Store
+		if err := rows.Scan(&store.ID, &store.OrganizationID, &store.UserID, &store.Type, &store.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		stores = append(stores, store)
+	}
+	return stores, total, rows.Err()
+}
+
+func (r *sqlStoreRepository) DeleteByID(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM stores WHERE id = $1", id)
+	if err != nil {
+		return mapError(err)
+	}
+	return checkAffected(result)
+}
+
+func (r *sqlStoreRepository) Count(ctx context.Context) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM stores").Scan(&count)
+	return count, err
+}
+
+func (r *sqlStoreRepository) ListEncryptedTokens(ctx context.Context, afterID, limit int) ([]EncryptedValue, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT id, api_token FROM stores WHERE id > $1 ORDER BY id LIMIT $2",
+		afterID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []EncryptedValue
+	for rows.Next() {
+		var value EncryptedValue
+		if err := rows.Scan(&value.ID, &value.Value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+func (r *sqlStoreRepository) ReplaceEncryptedToken(ctx context.Context, storeID int, oldToken, newToken string) error {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE stores SET api_token = $1 WHERE id = $2 AND api_token = $3",
+		newToken, storeID, oldToken,
+	)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
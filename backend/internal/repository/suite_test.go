@@ -0,0 +1,1183 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"kursovaya_backend/internal/database"
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/rbac"
+)
+
+// Общий набор тестов, который выполняется для каждой реализации репозиториев
+
+func TestMemoryRepositories(t *testing.T) {
+	runRepositorySuite(t, func(t *testing.T) *Repositories {
+		return NewMemory()
+	})
+}
+
+func TestSQLiteRepositories(t *testing.T) {
+	runRepositorySuite(t, func(t *testing.T) *Repositories {
+		db, err := database.OpenSQLite(filepath.Join(t.TempDir(), "test.db"))
+		if err != nil {
+			t.Fatalf("Ошибка открытия SQLite: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		if err := database.Migrate(context.Background(), db, database.DriverSQLite); err != nil {
+			t.Fatalf("Ошибка создания схемы: %v", err)
+		}
+		return NewSQL(db)
+	})
+}
+
+// TestPostgresRepositories выполняется только при заданной переменной TEST_DATABASE_URL
+func TestPostgresRepositories(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL не задан, тесты PostgreSQL пропущены")
+	}
+
+	runRepositorySuite(t, func(t *testing.T) *Repositories {
+		db, err := sql.Open("postgres", dsn)
+		if err != nil {
+			t.Fatalf("Ошибка подключения к PostgreSQL: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		if err := database.Migrate(context.Background(), db, database.DriverPostgres); err != nil {
+			t.Fatalf("Ошибка создания схемы: %v", err)
+		}
+		if _, err := db.Exec("TRUNCATE rate_limits, sync_runs, audit_events, admin_bootstrap_tokens, api_keys, login_attempts, user_tokens, recovery_codes, two_factor, organization_invitations, organization_members, sessions, product_mappings, products, stores, organizations, users, admins RESTART IDENTITY CASCADE"); err != nil {
+			t.Fatalf("Ошибка очистки таблиц: %v", err)
+		}
+		return NewSQL(db)
+	})
+}
+
+func runRepositorySuite(t *testing.T, newRepos func(t *testing.T) *Repositories) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, repos *Repositories)
+	}{
+		{"Users", testUsers},
+		{"UserStatus", testUserStatus},
+		{"Admins", testAdmins},
+		{"Stores", testStores},
+		{"Sessions", testSessions},
+		{"Organizations", testOrganizations},
+		{"TwoFactor", testTwoFactor},
+		{"UserTokens", testUserTokens},
+		{"LoginAttempts", testLoginAttempts},
+		{"RateLimits", testRateLimits},
+		{"APIKeys", testAPIKeys},
+		{"Audit", testAudit},
+		{"ProductsAndMappings", testProductsAndMappings},
+		{"AdminLists", testAdminLists},
+		{"Stats", testStats},
+		{"TxRollback", testTxRollback},
+		{"TxCommitNested", testTxCommitNested},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, newRepos(t))
+		})
+	}
+}
+
+func testUsers(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+
+	user, err := repos.Users.Create(ctx, "user@example.com", "hash")
+	if err != nil {
+		t.Fatalf("Ошибка создания пользователя: %v", err)
+	}
+	if _, err := repos.Users.Create(ctx, "user@example.com", "hash"); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Ожидается ErrDuplicate для повторного email, получено %v", err)
+	}
+
+	found, hash, err := repos.Users.GetCredentials(ctx, "user@example.com")
+	if err != nil || found.ID != user.ID || hash != "hash" {
+		t.Errorf("Ожидаются учетные данные пользователя %d, получено %v, %q, %v", user.ID, found, hash, err)
+	}
+	if exists, _ := repos.Users.ExistsByEmail(ctx, "user@example.com"); !exists {
+		t.Error("Ожидается, что пользователь существует")
+	}
+	if _, err := repos.Users.GetByID(ctx, user.ID+100); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound, получено %v", err)
+	}
+
+	if user.Role != rbac.DefaultUserRole {
+		t.Errorf("Ожидается роль по умолчанию %q, получена %q", rbac.DefaultUserRole, user.Role)
+	}
+	if err := repos.Users.SetRole(ctx, user.ID, rbac.RoleViewer); err != nil {
+		t.Fatalf("Ошибка назначения роли: %v", err)
+	}
+	if found, _ := repos.Users.GetByID(ctx, user.ID); found.Role != rbac.RoleViewer {
+		t.Errorf("Ожидается роль %q, получена %q", rbac.RoleViewer, found.Role)
+	}
+	if err := repos.Users.SetRole(ctx, user.ID+100, rbac.RoleViewer); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound, получено %v", err)
+	}
+
+	if err := repos.Users.Delete(ctx, user.ID); err != nil {
+		t.Fatalf("Ошибка удаления пользователя: %v", err)
+	}
+	if count, _ := repos.Users.Count(ctx); count != 0 {
+		t.Errorf("Ожидается 0 пользователей, найдено %d", count)
+	}
+	if err := repos.Users.Delete(ctx, user.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound при повторном удалении, получено %v", err)
+	}
+}
+
+func testUserStatus(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	pending, _ := repos.Users.Create(ctx, "pending@example.com", "hash")
+	verified, _ := repos.Users.Create(ctx, "verified@example.com", "hash")
+	suspended, _ := repos.Users.Create(ctx, "suspended@example.com", "hash")
+	repos.Users.MarkEmailVerified(ctx, verified.ID, now)
+	repos.Users.MarkEmailVerified(ctx, suspended.ID, now)
+
+	// Неподтвержденный email отображается отдельным статусом
+	if pending.Status != models.UserPendingVerification {
+		t.Errorf("Ожидается статус %q, получен %q", models.UserPendingVerification, pending.Status)
+	}
+	if err := repos.Users.SetStatus(ctx, suspended.ID, models.UserSuspended, "Спам", now); err != nil {
+		t.Fatalf("Ошибка блокировки: %v", err)
+	}
+	found, _ := repos.Users.GetByID(ctx, suspended.ID)
+	if found.Status != models.UserSuspended || found.StatusReason != "Спам" || found.StatusChangedAt == nil {
+		t.Errorf("Ожидается заблокированный пользователь с причиной, получено %+v", found)
+	}
+	if err := repos.Users.SetStatus(ctx, suspended.ID+100, models.UserSuspended, "", now); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound, получено %v", err)
+	}
+
+	for status, want := range map[string]int{
+		models.UserPendingVerification: pending.ID,
+		models.UserActive:              verified.ID,
+		models.UserSuspended:           suspended.ID,
+	} {
+		if list, _, _ := repos.Users.List(ctx, ListQuery{Status: status}); len(list) != 1 || list[0].ID != want {
+			t.Errorf("Статус %s: ожидается пользователь %d, получено %+v", status, want, list)
+		}
+	}
+
+	// После удаления данных email обезличен, пароль не подходит, статус не меняется
+	if err := repos.Users.Erase(ctx, suspended.ID, now); err != nil {
+		t.Fatalf("Ошибка удаления данных: %v", err)
+	}
+	found, _ = repos.Users.GetByID(ctx, suspended.ID)
+	if found.Email != ErasedEmail(suspended.ID) || found.Status != models.UserDeleted || found.StatusReason != "" || found.ErasedAt == nil {
+		t.Errorf("Ожидается обезличенный удаленный пользователь, получено %+v", found)
+	}
+	if _, _, err := repos.Users.GetCredentials(ctx, "suspended@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound для прежнего email, получено %v", err)
+	}
+	if err := repos.Users.SetStatus(ctx, suspended.ID, models.UserActive, "", now); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound при восстановлении удаленных данных, получено %v", err)
+	}
+}
+
+func testAdmins(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+
+	admin, err := repos.Admins.Create(ctx, "admin", "hash", rbac.DefaultAdminRole, false)
+	if err != nil {
+		t.Fatalf("Ошибка создания администратора: %v", err)
+	}
+	if _, err := repos.Admins.Create(ctx, "admin", "hash", rbac.RoleSupport, false); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Ожидается ErrDuplicate для повторного логина, получено %v", err)
+	}
+	if exists, _ := repos.Admins.Exists(ctx, admin.ID); !exists {
+		t.Error("Ожидается, что администратор существует")
+	}
+	if admin.Role != rbac.DefaultAdminRole {
+		t.Errorf("Ожидается роль по умолчанию %q, получена %q", rbac.DefaultAdminRole, admin.Role)
+	}
+	if err := repos.Admins.SetRole(ctx, admin.ID, rbac.RoleSupport); err != nil {
+		t.Fatalf("Ошибка назначения роли: %v", err)
+	}
+	if found, err := repos.Admins.GetByID(ctx, admin.ID); err != nil || found.Role != rbac.RoleSupport {
+		t.Errorf("Ожидается роль %q, получено %+v, %v", rbac.RoleSupport, found, err)
+	}
+	if _, _, err := repos.Admins.GetCredentials(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound, получено %v", err)
+	}
+
+	// Пароль, заданный другим администратором, нужно сменить при входе
+	second, err := repos.Admins.Create(ctx, "second", "temporary", rbac.DefaultAdminRole, true)
+	if err != nil || !second.MustChangePassword || second.CreatedAt.IsZero() {
+		t.Fatalf("Ожидается администратор с обязательной сменой пароля, получено %+v, %v", second, err)
+	}
+	if err := repos.Admins.SetPassword(ctx, second.ID, "changed", false); err != nil {
+		t.Fatalf("Ошибка смены пароля: %v", err)
+	}
+	// Смена пароля отзывает выданные токены
+	if found, hash, _ := repos.Admins.GetCredentials(ctx, "second"); hash != "changed" || found.MustChangePassword || found.TokenVersion != second.TokenVersion+1 {
+		t.Errorf("Ожидается новый пароль без обязательной смены, получено %+v, %q", found, hash)
+	}
+	if hash, err := repos.Admins.GetPasswordHash(ctx, second.ID); err != nil || hash != "changed" {
+		t.Errorf("Ожидается хеш нового пароля, получено %q, %v", hash, err)
+	}
+
+	// Отключенные администраторы не считаются активными
+	if count, _ := repos.Admins.CountActiveWithRole(ctx, rbac.DefaultAdminRole); count != 1 {
+		t.Errorf("Ожидается 1 активный суперадминистратор, получено %d", count)
+	}
+	disabledAt := time.Now()
+	if err := repos.Admins.SetDisabled(ctx, second.ID, &disabledAt); err != nil {
+		t.Fatalf("Ошибка отключения: %v", err)
+	}
+	if found, _ := repos.Admins.GetByID(ctx, second.ID); found.DisabledAt == nil {
+		t.Error("Ожидается отметка об отключении")
+	}
+	if count, _ := repos.Admins.CountActiveWithRole(ctx, rbac.DefaultAdminRole); count != 0 {
+		t.Errorf("Ожидается 0 активных суперадминистраторов, получено %d", count)
+	}
+	if err := repos.Admins.SetDisabled(ctx, second.ID, nil); err != nil {
+		t.Fatalf("Ошибка включения: %v", err)
+	}
+	// Отключение отзывает токены, а повторное включение их не возвращает
+	if found, _ := repos.Admins.GetByID(ctx, second.ID); found.TokenVersion != second.TokenVersion+2 {
+		t.Errorf("Ожидается версия токенов %d, получена %d", second.TokenVersion+2, found.TokenVersion)
+	}
+	if admins, err := repos.Admins.List(ctx); err != nil || len(admins) != 2 || admins[0].ID != admin.ID || admins[1].DisabledAt != nil {
+		t.Errorf("Ожидается 2 администратора по порядку, получено %+v, %v", admins, err)
+	}
+	if err := repos.Admins.Delete(ctx, second.ID); err != nil {
+		t.Fatalf("Ошибка удаления: %v", err)
+	}
+	if err := repos.Admins.Delete(ctx, second.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound при повторном удалении, получено %v", err)
+	}
+
+	// Токен создания первого администратора одноразовый и ограничен по времени
+	now := time.Now()
+	if err := repos.Admins.SaveBootstrapToken(ctx, "expired", now.Add(-time.Minute)); err != nil {
+		t.Fatalf("Ошибка сохранения токена: %v", err)
+	}
+	if err := repos.Admins.ConsumeBootstrapToken(ctx, "expired", now); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound для истекшего токена, получено %v", err)
+	}
+	// Повторное сохранение продлевает срок действия
+	if err := repos.Admins.SaveBootstrapToken(ctx, "expired", now.Add(time.Hour)); err != nil {
+		t.Fatalf("Ошибка продления токена: %v", err)
+	}
+	if err := repos.Admins.ConsumeBootstrapToken(ctx, "expired", now); err != nil {
+		t.Errorf("Ошибка использования токена: %v", err)
+	}
+	if err := repos.Admins.ConsumeBootstrapToken(ctx, "expired", now); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound для использованного токена, получено %v", err)
+	}
+	repos.Admins.SaveBootstrapToken(ctx, "other", now.Add(time.Hour))
+	if err := repos.Admins.DeleteBootstrapTokens(ctx); err != nil {
+		t.Fatalf("Ошибка удаления токенов: %v", err)
+	}
+	if err := repos.Admins.ConsumeBootstrapToken(ctx, "other", now); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound после удаления токенов, получено %v", err)
+	}
+}
+
+func testStores(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	owner, _ := repos.Users.Create(ctx, "owner@example.com", "hash")
+	organization, _ := repos.Organizations.Create(ctx, "Команда")
+	other, _ := repos.Organizations.Create(ctx, "Другая команда")
+
+	store, err := repos.Stores.Create(ctx, organization.ID, owner.ID, "wb", "encrypted")
+	if err != nil {
+		t.Fatalf("Ошибка создания магазина: %v", err)
+	}
+
+	stores, err := repos.Stores.ListByOrganization(ctx, organization.ID)
+	if err != nil || len(stores) != 1 || stores[0].Type != "wb" || stores[0].OrganizationID != organization.ID {
+		t.Errorf("Ожидается один магазин wb организации, получено %v, %v", stores, err)
+	}
+	if stores, _ := repos.Stores.ListByOrganization(ctx, other.ID); len(stores) != 0 {
+		t.Errorf("Ожидается, что у другой организации нет магазинов, получено %v", stores)
+	}
+	if found, err := repos.Stores.GetByID(ctx, store.ID); err != nil || found.OrganizationID != organization.ID || found.UserID != owner.ID {
+		t.Errorf("Ожидается магазин организации %d, получено %+v, %v", organization.ID, found, err)
+	}
+	if token, err := repos.Stores.GetToken(ctx, store.ID); err != nil || token != "encrypted" {
+		t.Errorf("Ожидается токен магазина, получено %q, %v", token, err)
+	}
+
+	// Перешифрование: порции по ID и замена только неизмененного значения
+	second, _ := repos.Stores.Create(ctx, organization.ID, owner.ID, "ozon", "second")
+	tokens, err := repos.Stores.ListEncryptedTokens(ctx, 0, 1)
+	if err != nil || len(tokens) != 1 || tokens[0].ID != store.ID || tokens[0].Value != "encrypted" {
+		t.Errorf("Ожидается первая порция из одного токена, получено %v, %v", tokens, err)
+	}
+	if tokens, _ := repos.Stores.ListEncryptedTokens(ctx, store.ID, 10); len(tokens) != 1 || tokens[0].ID != second.ID {
+		t.Errorf("Ожидается вторая порция с магазином %d, получено %v", second.ID, tokens)
+	}
+	if err := repos.Stores.ReplaceEncryptedToken(ctx, second.ID, "stale", "rotated"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound для измененного токена, получено %v", err)
+	}
+	if err := repos.Stores.ReplaceEncryptedToken(ctx, second.ID, "second", "rotated"); err != nil {
+		t.Errorf("Ошибка замены токена: %v", err)
+	}
+	if token, _ := repos.Stores.GetToken(ctx, second.ID); token != "rotated" {
+		t.Errorf("Ожидается перешифрованный токен, получено %q", token)
+	}
+	if err := repos.Stores.DeleteByID(ctx, store.ID); err != nil {
+		t.Errorf("Ошибка удаления магазина: %v", err)
+	}
+	if _, err := repos.Stores.GetToken(ctx, store.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound для удаленного магазина, получено %v", err)
+	}
+}
+
+func testOrganizations(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	owner, _ := repos.Users.Create(ctx, "owner@example.com", "hash")
+	member, _ := repos.Users.Create(ctx, "member@example.com", "hash")
+	personal, _ := repos.Organizations.Create(ctx, "owner@example.com")
+	team, err := repos.Organizations.Create(ctx, "Команда")
+	if err != nil {
+		t.Fatalf("Ошибка создания организации: %v", err)
+	}
+
+	if err := repos.Organizations.AddMember(ctx, personal.ID, owner.ID, rbac.RoleOwner); err != nil {
+		t.Fatalf("Ошибка добавления участника: %v", err)
+	}
+	if err := repos.Organizations.AddMember(ctx, team.ID, owner.ID, rbac.RoleOwner); err != nil {
+		t.Fatalf("Ошибка добавления участника: %v", err)
+	}
+	if err := repos.Organizations.AddMember(ctx, team.ID, member.ID, rbac.RoleViewer); err != nil {
+		t.Fatalf("Ошибка добавления участника: %v", err)
+	}
+	if err := repos.Organizations.AddMember(ctx, team.ID, member.ID, rbac.RoleEditor); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Ожидается ErrDuplicate для повторного членства, получено %v", err)
+	}
+
+	found, err := repos.Organizations.GetMember(ctx, team.ID, member.ID)
+	if err != nil || found.Role != rbac.RoleViewer || found.Email != "member@example.com" || found.OrganizationName != "Команда" {
+		t.Errorf("Ожидается участник viewer с email и названием организации, получено %+v, %v", found, err)
+	}
+	if _, err := repos.Organizations.GetMember(ctx, personal.ID, member.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound для чужой организации, получено %v", err)
+	}
+	if def, err := repos.Organizations.GetDefaultMembership(ctx, owner.ID); err != nil || def.OrganizationID != personal.ID {
+		t.Errorf("Ожидается личная организация по умолчанию, получено %+v, %v", def, err)
+	}
+	if list, _ := repos.Organizations.ListByUser(ctx, owner.ID); len(list) != 2 || list[0].OrganizationID != personal.ID {
+		t.Errorf("Ожидается две организации владельца, получено %v", list)
+	}
+	if list, _ := repos.Organizations.ListMembers(ctx, team.ID); len(list) != 2 || list[0].UserID != owner.ID {
+		t.Errorf("Ожидается два участника команды, получено %v", list)
+	}
+
+	if err := repos.Organizations.SetMemberRole(ctx, team.ID, member.ID, rbac.RoleOwner); err != nil {
+		t.Fatalf("Ошибка изменения роли: %v", err)
+	}
+	if owners, _ := repos.Organizations.CountMembersWithRole(ctx, team.ID, rbac.RoleOwner); owners != 2 {
+		t.Errorf("Ожидается два владельца, найдено %d", owners)
+	}
+	if err := repos.Organizations.RemoveMember(ctx, team.ID, member.ID); err != nil {
+		t.Fatalf("Ошибка исключения участника: %v", err)
+	}
+	if err := repos.Organizations.RemoveMember(ctx, team.ID, member.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound при повторном исключении, получено %v", err)
+	}
+
+	// Приглашение можно принять только один раз
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	invitation := &models.Invitation{
+		OrganizationID: team.ID, Email: "member@example.com", Role: rbac.RoleEditor,
+		TokenHash: "invite-hash", InvitedBy: owner.ID, ExpiresAt: expiresAt,
+	}
+	if err := repos.Organizations.CreateInvitation(ctx, invitation); err != nil || invitation.ID == 0 {
+		t.Fatalf("Ошибка создания приглашения: %v", err)
+	}
+	stored, err := repos.Organizations.GetInvitationByTokenHash(ctx, "invite-hash")
+	if err != nil || stored.ID != invitation.ID || stored.AcceptedAt != nil || !stored.ExpiresAt.Equal(expiresAt) {
+		t.Fatalf("Ожидается непринятое приглашение %d, получено %+v, %v", invitation.ID, stored, err)
+	}
+	if err := repos.Organizations.MarkInvitationAccepted(ctx, invitation.ID, time.Now()); err != nil {
+		t.Fatalf("Ошибка принятия приглашения: %v", err)
+	}
+	if err := repos.Organizations.MarkInvitationAccepted(ctx, invitation.ID, time.Now()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound при повторном принятии, получено %v", err)
+	}
+
+	// Членства удаляются вместе с пользователем
+	if err := repos.Users.Delete(ctx, owner.ID); err != nil {
+		t.Fatalf("Ошибка удаления пользователя: %v", err)
+	}
+	if list, _ := repos.Organizations.ListMembers(ctx, team.ID); len(list) != 0 {
+		t.Errorf("Ожидается удаление членств пользователя, осталось %v", list)
+	}
+
+	// Переименование и удаление организации вместе с приглашениями
+	if err := repos.Organizations.Rename(ctx, personal.ID, "Организация"); err != nil {
+		t.Fatalf("Ошибка переименования: %v", err)
+	}
+	if found, _ := repos.Organizations.GetByID(ctx, personal.ID); found.Name != "Организация" {
+		t.Errorf("Ожидается новое название, получено %+v", found)
+	}
+	repos.Organizations.CreateInvitation(ctx, &models.Invitation{
+		OrganizationID: personal.ID, Email: "guest@example.com", Role: rbac.RoleViewer,
+		TokenHash: "guest-hash", InvitedBy: member.ID, ExpiresAt: expiresAt,
+	})
+	if err := repos.Organizations.DeleteInvitationsByEmail(ctx, "member@example.com"); err != nil {
+		t.Fatalf("Ошибка удаления приглашений: %v", err)
+	}
+	if _, err := repos.Organizations.GetInvitationByTokenHash(ctx, "invite-hash"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается удаление приглашения по email, получено %v", err)
+	}
+	if err := repos.Organizations.Delete(ctx, personal.ID); err != nil {
+		t.Fatalf("Ошибка удаления организации: %v", err)
+	}
+	if _, err := repos.Organizations.GetInvitationByTokenHash(ctx, "guest-hash"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается удаление приглашений вместе с организацией, получено %v", err)
+	}
+	if err := repos.Organizations.Delete(ctx, personal.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound при повторном удалении, получено %v", err)
+	}
+}
+
+func testSessions(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	user, _ := repos.Users.Create(ctx, "session@example.com", "hash")
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	first := &models.Session{UserID: user.ID, FamilyID: "family", TokenHash: "hash-1", ExpiresAt: expiresAt}
+	if err := repos.Sessions.Create(ctx, first); err != nil || first.ID == 0 {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+	if err := repos.Sessions.Create(ctx, &models.Session{UserID: user.ID, FamilyID: "other", TokenHash: "hash-1", ExpiresAt: expiresAt}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Ожидается ErrDuplicate для повторного хеша токена, получено %v", err)
+	}
+
+	found, err := repos.Sessions.GetByTokenHash(ctx, "hash-1")
+	if err != nil || found.ID != first.ID || found.UsedAt != nil || found.RevokedAt != nil {
+		t.Fatalf("Ожидается неиспользованная сессия %d, получено %+v, %v", first.ID, found, err)
+	}
+	if !found.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("Ожидается срок действия %s, получено %s", expiresAt, found.ExpiresAt)
+	}
+
+	// Токен можно обменять только один раз
+	if err := repos.Sessions.MarkUsed(ctx, first.ID, time.Now()); err != nil {
+		t.Fatalf("Ошибка пометки токена: %v", err)
+	}
+	if err := repos.Sessions.MarkUsed(ctx, first.ID, time.Now()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound при повторном обмене, получено %v", err)
+	}
+	if found, _ := repos.Sessions.GetByTokenHash(ctx, "hash-1"); found.UsedAt == nil {
+		t.Error("Ожидается отметка об использовании токена")
+	}
+
+	second := &models.Session{UserID: user.ID, FamilyID: "family", TokenHash: "hash-2", ExpiresAt: expiresAt}
+	if err := repos.Sessions.Create(ctx, second); err != nil {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+	if revoked, _ := repos.Sessions.IsFamilyRevoked(ctx, "family"); revoked {
+		t.Error("Ожидается, что сессия активна")
+	}
+	if revoked, _ := repos.Sessions.IsFamilyRevoked(ctx, "unknown"); !revoked {
+		t.Error("Ожидается, что неизвестная сессия считается отозванной")
+	}
+
+	if err := repos.Sessions.RevokeFamily(ctx, "family", time.Now()); err != nil {
+		t.Fatalf("Ошибка отзыва сессии: %v", err)
+	}
+	if revoked, _ := repos.Sessions.IsFamilyRevoked(ctx, "family"); !revoked {
+		t.Error("Ожидается, что сессия отозвана")
+	}
+	if found, _ := repos.Sessions.GetByTokenHash(ctx, "hash-2"); found.RevokedAt == nil {
+		t.Error("Ожидается отзыв всех токенов семьи")
+	}
+
+	// Сессии удаляются вместе с пользователем
+	if err := repos.Users.Delete(ctx, user.ID); err != nil {
+		t.Fatalf("Ошибка удаления пользователя с сессиями: %v", err)
+	}
+	if _, err := repos.Sessions.GetByTokenHash(ctx, "hash-2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound после удаления пользователя, получено %v", err)
+	}
+}
+
+func testTwoFactor(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+
+	if _, err := repos.TwoFactor.Get(ctx, "user", 1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Ожидается ErrNotFound для ненастроенной 2FA, получено %v", err)
+	}
+	if err := repos.TwoFactor.Create(ctx, &models.TwoFactor{SubjectType: "user", SubjectID: 1, Secret: "secret"}); err != nil {
+		t.Fatalf("Ошибка создания настройки: %v", err)
+	}
+	if err := repos.TwoFactor.Create(ctx, &models.TwoFactor{SubjectType: "user", SubjectID: 1, Secret: "other"}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Ожидается ErrDuplicate для повторной настройки, получено %v", err)
+	}
+	// Тот же ID администратора - другая учетная запись
+	if err := repos.TwoFactor.Create(ctx, &models.TwoFactor{SubjectType: "admin", SubjectID: 1, Secret: "admin"}); err != nil {
+		t.Fatalf("Ошибка создания настройки администратора: %v", err)
+	}
+
+	found, err := repos.TwoFactor.Get(ctx, "user", 1)
+	if err != nil || found.Secret != "secret" || found.ConfirmedAt != nil || found.LastUsedStep != 0 {
+		t.Fatalf("Ожидается неподтвержденная настройка, получено %+v, %v", found, err)
+	}
+	if err := repos.TwoFactor.Confirm(ctx, "user", 1, time.Now()); err != nil {
+		t.Fatalf("Ошибка подтверждения: %v", err)
+	}
+	if found, _ := repos.TwoFactor.Get(ctx, "user", 1); found.ConfirmedAt == nil {
+		t.Error("Ожидается отметка о подтверждении")
+	}
+
+	// Шаг TOTP принимается только один раз и только по возрастанию
+	if err := repos.TwoFactor.MarkStepUsed(ctx, "user", 1, 100); err != nil {
+		t.Fatalf("Ошибка сохранения шага: %v", err)
+	}
+	for _, step := range []int64{100, 99} {
+		if err := repos.TwoFactor.MarkStepUsed(ctx, "user", 1, step); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Ожидается ErrNotFound для шага %d, получено %v", step, err)
+		}
+	}
+
+	// Коды восстановления одноразовые и заменяются целиком
+	if err := repos.TwoFactor.ReplaceRecoveryCodes(ctx, "user", 1, []string{"a", "b"}); err != nil {
+		t.Fatalf("Ошибка сохранения кодов: %v", err)
+	}
+	if err := repos.TwoFactor.UseRecoveryCode(ctx, "user", 1, "a", time.Now()); err != nil {
+		t.Fatalf("Ошибка использования кода: %v", err)
+	}
+	if err := repos.TwoFactor.UseRecoveryCode(ctx, "user", 1, "a", time.Now()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound для повторного кода, получено %v", err)
+	}
+	if err := repos.TwoFactor.UseRecoveryCode(ctx, "admin", 1, "b", time.Now()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound для кода другой учетной записи, получено %v", err)
+	}
+	if count, _ := repos.TwoFactor.CountRecoveryCodes(ctx, "user", 1); count != 1 {
+		t.Errorf("Ожидается 1 неиспользованный код, получено %d", count)
+	}
+	if err := repos.TwoFactor.ReplaceRecoveryCodes(ctx, "user", 1, []string{"c", "d", "e"}); err != nil {
+		t.Fatalf("Ошибка замены кодов: %v", err)
+	}
+	if err := repos.TwoFactor.UseRecoveryCode(ctx, "user", 1, "b", time.Now()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается, что старые коды недействительны, получено %v", err)
+	}
+
+	secrets, err := repos.TwoFactor.ListSecrets(ctx)
+	if err != nil || len(secrets) != 2 {
+		t.Errorf("Ожидается 2 секрета, получено %v, %v", secrets, err)
+	}
+	if err := repos.TwoFactor.ReplaceSecret(ctx, "user", 1, "stale", "rotated"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound для измененного секрета, получено %v", err)
+	}
+	if err := repos.TwoFactor.ReplaceSecret(ctx, "user", 1, "secret", "rotated"); err != nil {
+		t.Errorf("Ошибка замены секрета: %v", err)
+	}
+	if found, _ := repos.TwoFactor.Get(ctx, "user", 1); found.Secret != "rotated" || found.ConfirmedAt == nil {
+		t.Errorf("Ожидается перешифрованный секрет подтвержденной настройки, получено %+v", found)
+	}
+
+	if err := repos.TwoFactor.Delete(ctx, "user", 1); err != nil {
+		t.Fatalf("Ошибка удаления настройки: %v", err)
+	}
+	if err := repos.TwoFactor.Delete(ctx, "user", 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound при повторном удалении, получено %v", err)
+	}
+	if count, _ := repos.TwoFactor.CountRecoveryCodes(ctx, "user", 1); count != 0 {
+		t.Errorf("Ожидается удаление кодов вместе с настройкой, осталось %d", count)
+	}
+	if _, err := repos.TwoFactor.Get(ctx, "admin", 1); err != nil {
+		t.Errorf("Настройка администратора не должна удаляться, получено %v", err)
+	}
+}
+
+func testUserTokens(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	user, _ := repos.Users.Create(ctx, "tokens@example.com", "hash")
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	// Пароль и подтверждение email
+	if err := repos.Users.SetPassword(ctx, user.ID, "new-hash"); err != nil {
+		t.Fatalf("Ошибка смены пароля: %v", err)
+	}
+	if _, hash, _ := repos.Users.GetCredentials(ctx, user.Email); hash != "new-hash" {
+		t.Errorf("Ожидается новый хеш пароля, получено %q", hash)
+	}
+	if found, _ := repos.Users.GetByID(ctx, user.ID); found.EmailVerifiedAt != nil {
+		t.Error("Ожидается неподтвержденный email нового пользователя")
+	}
+	if err := repos.Users.MarkEmailVerified(ctx, user.ID, time.Now()); err != nil {
+		t.Fatalf("Ошибка подтверждения email: %v", err)
+	}
+	if found, _ := repos.Users.GetByID(ctx, user.ID); found.EmailVerifiedAt == nil {
+		t.Error("Ожидается подтвержденный email")
+	}
+	if err := repos.Users.SetPassword(ctx, 999, "hash"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound для несуществующего пользователя, получено %v", err)
+	}
+
+	first := &models.UserToken{UserID: user.ID, Purpose: models.TokenPurposePasswordReset, TokenHash: "reset-1", ExpiresAt: expiresAt}
+	if err := repos.UserTokens.Create(ctx, first); err != nil || first.ID == 0 {
+		t.Fatalf("Ошибка создания токена: %v", err)
+	}
+	if err := repos.UserTokens.Create(ctx, &models.UserToken{UserID: user.ID, Purpose: models.TokenPurposePasswordReset, TokenHash: "reset-1", ExpiresAt: expiresAt}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Ожидается ErrDuplicate для повторного хеша, получено %v", err)
+	}
+	verification := &models.UserToken{UserID: user.ID, Purpose: models.TokenPurposeEmailVerification, TokenHash: "verify-1", ExpiresAt: expiresAt}
+	repos.UserTokens.Create(ctx, verification)
+
+	found, err := repos.UserTokens.GetByTokenHash(ctx, "reset-1")
+	if err != nil || found.ID != first.ID || found.Purpose != models.TokenPurposePasswordReset || !found.ExpiresAt.Equal(expiresAt) {
+		t.Fatalf("Ожидается токен %d, получено %+v, %v", first.ID, found, err)
+	}
+
+	if err := repos.UserTokens.MarkUsed(ctx, first.ID, time.Now()); err != nil {
+		t.Fatalf("Ошибка пометки токена: %v", err)
+	}
+	if err := repos.UserTokens.MarkUsed(ctx, first.ID, time.Now()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound при повторном использовании, получено %v", err)
+	}
+
+	// Отмена затрагивает только токены указанного назначения
+	second := &models.UserToken{UserID: user.ID, Purpose: models.TokenPurposePasswordReset, TokenHash: "reset-2", ExpiresAt: expiresAt}
+	repos.UserTokens.Create(ctx, second)
+	if err := repos.UserTokens.InvalidateForUser(ctx, user.ID, models.TokenPurposePasswordReset, time.Now()); err != nil {
+		t.Fatalf("Ошибка отмены токенов: %v", err)
+	}
+	if found, _ := repos.UserTokens.GetByTokenHash(ctx, "reset-2"); found.UsedAt == nil {
+		t.Error("Ожидается, что токен сброса пароля отменен")
+	}
+	if found, _ := repos.UserTokens.GetByTokenHash(ctx, "verify-1"); found.UsedAt != nil {
+		t.Error("Токен подтверждения email не должен отменяться")
+	}
+
+	// Все сессии пользователя отзываются разом
+	repos.Sessions.Create(ctx, &models.Session{UserID: user.ID, FamilyID: "a", TokenHash: "session-a", ExpiresAt: expiresAt})
+	repos.Sessions.Create(ctx, &models.Session{UserID: user.ID, FamilyID: "b", TokenHash: "session-b", ExpiresAt: expiresAt})
+	if err := repos.Sessions.RevokeAllForUser(ctx, user.ID, time.Now()); err != nil {
+		t.Fatalf("Ошибка отзыва сессий: %v", err)
+	}
+	for _, family := range []string{"a", "b"} {
+		if revoked, _ := repos.Sessions.IsFamilyRevoked(ctx, family); !revoked {
+			t.Errorf("Ожидается, что сессия %s отозвана", family)
+		}
+	}
+
+	// Токены удаляются вместе с пользователем
+	if err := repos.Users.Delete(ctx, user.ID); err != nil {
+		t.Fatalf("Ошибка удаления пользователя: %v", err)
+	}
+	if _, err := repos.UserTokens.GetByTokenHash(ctx, "verify-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound после удаления пользователя, получено %v", err)
+	}
+}
+
+func testRateLimits(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	interval := 500 * time.Millisecond
+
+	// Новая корзина полна: три запроса проходят, четвертый отклоняется
+	for i := 1; i <= 3; i++ {
+		allowed, tokens, err := repos.RateLimits.Take(ctx, "api:user:1", 3, interval, start)
+		if err != nil || !allowed || tokens != float64(3-i) {
+			t.Fatalf("Запрос %d: ожидается токен и %d в корзине, получено %t, %v, %v", i, 3-i, allowed, tokens, err)
+		}
+	}
+	if allowed, tokens, _ := repos.RateLimits.Take(ctx, "api:user:1", 3, interval, start); allowed || tokens != 0 {
+		t.Errorf("Ожидается отказ из пустой корзины, получено %t, %v", allowed, tokens)
+	}
+
+	// Корзины разных ключей независимы
+	if allowed, _, _ := repos.RateLimits.Take(ctx, "api:user:2", 3, interval, start); !allowed {
+		t.Error("Ожидается отдельная корзина для другого ключа")
+	}
+
+	// За 750 мс добавляется полтора токена: один выдается, половина остается
+	allowed, tokens, err := repos.RateLimits.Take(ctx, "api:user:1", 3, interval, start.Add(750*time.Millisecond))
+	if err != nil || !allowed || tokens != 0.5 {
+		t.Errorf("Ожидается токен и 0.5 в корзине, получено %t, %v, %v", allowed, tokens, err)
+	}
+	// Обращение с более ранним временем (часы другой реплики) не пополняет корзину
+	if allowed, tokens, _ := repos.RateLimits.Take(ctx, "api:user:1", 3, interval, start); allowed || tokens != 0.5 {
+		t.Errorf("Ожидается отказ без пополнения, получено %t, %v", allowed, tokens)
+	}
+	// Корзина не пополняется сверх емкости
+	if _, tokens, _ := repos.RateLimits.Take(ctx, "api:user:1", 3, interval, start.Add(time.Hour)); tokens != 2 {
+		t.Errorf("Ожидается полная корзина минус токен, получено %v", tokens)
+	}
+
+	// Удаляются только корзины без обращений с before
+	if err := repos.RateLimits.DeleteStale(ctx, start.Add(time.Minute)); err != nil {
+		t.Fatalf("Ошибка удаления корзин: %v", err)
+	}
+	if _, tokens, _ := repos.RateLimits.Take(ctx, "api:user:2", 3, interval, start.Add(time.Hour)); tokens != 2 {
+		t.Errorf("Удаленная корзина должна создаваться заново полной, получено %v", tokens)
+	}
+	if _, tokens, _ := repos.RateLimits.Take(ctx, "api:user:1", 3, interval, start.Add(time.Hour)); tokens != 1 {
+		t.Errorf("Недавно использованная корзина не должна удаляться, получено %v", tokens)
+	}
+}
+
+func testLoginAttempts(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	window := 15 * time.Minute
+
+	if _, err := repos.LoginAttempts.Get(ctx, "user:a@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound для нового ключа, получено %v", err)
+	}
+
+	// Неудачи в пределах окна суммируются
+	for i := 1; i <= 3; i++ {
+		at := start.Add(time.Duration(i) * time.Minute)
+		attempt, err := repos.LoginAttempts.RegisterFailure(ctx, "user:a@example.com", at, at.Add(-window))
+		if err != nil {
+			t.Fatalf("Ошибка учета неудачи: %v", err)
+		}
+		if attempt.Failures != i || !attempt.LastFailureAt.Equal(at) {
+			t.Errorf("Ожидается %d неудач в %s, получено %+v", i, at, attempt)
+		}
+	}
+
+	// После окна счет начинается заново
+	late := start.Add(time.Hour)
+	attempt, err := repos.LoginAttempts.RegisterFailure(ctx, "user:a@example.com", late, late.Add(-window))
+	if err != nil || attempt.Failures != 1 {
+		t.Errorf("Ожидается сброс счетчика после окна, получено %+v, %v", attempt, err)
+	}
+
+	// Блокировка обнуляет счетчик и видна в списке, пока действует
+	until := late.Add(window)
+	if err := repos.LoginAttempts.Lock(ctx, "user:a@example.com", until); err != nil {
+		t.Fatalf("Ошибка блокировки: %v", err)
+	}
+	if err := repos.LoginAttempts.Lock(ctx, "user:missing@example.com", until); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound для неизвестного ключа, получено %v", err)
+	}
+	attempt, _ = repos.LoginAttempts.Get(ctx, "user:a@example.com")
+	if attempt.Failures != 0 || attempt.LockedUntil == nil || !attempt.LockedUntil.Equal(until) {
+		t.Errorf("Ожидается блокировка до %s, получено %+v", until, attempt)
+	}
+	repos.LoginAttempts.RegisterFailure(ctx, "ip:10.0.0.1", late, late.Add(-window))
+	locked, err := repos.LoginAttempts.ListLocked(ctx, late)
+	if err != nil || len(locked) != 1 || locked[0].Key != "user:a@example.com" {
+		t.Errorf("Ожидается одна блокировка, получено %+v, %v", locked, err)
+	}
+	if locked, _ := repos.LoginAttempts.ListLocked(ctx, until.Add(time.Second)); len(locked) != 0 {
+		t.Errorf("Истекшая блокировка не должна попадать в список, получено %+v", locked)
+	}
+
+	// Очистка не трогает действующие блокировки
+	if err := repos.LoginAttempts.DeleteStale(ctx, late.Add(time.Minute)); err != nil {
+		t.Fatalf("Ошибка очистки: %v", err)
+	}
+	if _, err := repos.LoginAttempts.Get(ctx, "ip:10.0.0.1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается, что устаревший счетчик удален, получено %v", err)
+	}
+	if _, err := repos.LoginAttempts.Get(ctx, "user:a@example.com"); err != nil {
+		t.Errorf("Действующая блокировка не должна удаляться: %v", err)
+	}
+
+	if err := repos.LoginAttempts.Reset(ctx, "user:a@example.com"); err != nil {
+		t.Fatalf("Ошибка сброса: %v", err)
+	}
+	if err := repos.LoginAttempts.Reset(ctx, "user:a@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound при повторном сбросе, получено %v", err)
+	}
+}
+
+func testAPIKeys(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	owner, _ := repos.Users.Create(ctx, "keys@example.com", "hash")
+	other, _ := repos.Users.Create(ctx, "other-keys@example.com", "hash")
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	key := &models.APIKey{UserID: owner.ID, Name: "CI", Prefix: "mpt_abcdefgh", KeyHash: "key-hash", Scopes: []string{"stores:read", "products:read"}, ExpiresAt: &expiresAt}
+	if err := repos.APIKeys.Create(ctx, key); err != nil || key.ID == 0 || key.CreatedAt.IsZero() {
+		t.Fatalf("Ошибка создания ключа: %v, %+v", err, key)
+	}
+	if err := repos.APIKeys.Create(ctx, &models.APIKey{UserID: other.ID, Name: "dup", Prefix: "mpt_", KeyHash: "key-hash", Scopes: []string{"stores:read"}}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Ожидается ErrDuplicate для повторного хеша, получено %v", err)
+	}
+
+	found, err := repos.APIKeys.GetByKeyHash(ctx, "key-hash")
+	if err != nil || found.UserID != owner.ID || found.Name != "CI" || len(found.Scopes) != 2 || found.Scopes[1] != "products:read" ||
+		found.ExpiresAt == nil || !found.ExpiresAt.Equal(expiresAt) || found.LastUsedAt != nil {
+		t.Fatalf("Неожиданный ключ: %+v, %v", found, err)
+	}
+
+	// Время использования обновляется не чаще заданного интервала
+	first := time.Now().UTC().Truncate(time.Second)
+	repos.APIKeys.TouchLastUsed(ctx, key.ID, first, first.Add(-time.Minute))
+	repos.APIKeys.TouchLastUsed(ctx, key.ID, first.Add(time.Second), first.Add(-time.Minute))
+	if found, _ := repos.APIKeys.GetByKeyHash(ctx, "key-hash"); found.LastUsedAt == nil || !found.LastUsedAt.Equal(first) {
+		t.Errorf("Ожидается время использования %s, получено %v", first, found.LastUsedAt)
+	}
+
+	if keys, _ := repos.APIKeys.ListByUser(ctx, other.ID); len(keys) != 0 {
+		t.Errorf("Ожидается пустой список чужих ключей, получено %+v", keys)
+	}
+
+	// Отозвать можно только свой действующий ключ
+	if err := repos.APIKeys.Revoke(ctx, other.ID, key.ID, time.Now()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound при отзыве чужого ключа, получено %v", err)
+	}
+	if err := repos.APIKeys.Revoke(ctx, owner.ID, key.ID, time.Now()); err != nil {
+		t.Fatalf("Ошибка отзыва ключа: %v", err)
+	}
+	if err := repos.APIKeys.Revoke(ctx, owner.ID, key.ID, time.Now()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound при повторном отзыве, получено %v", err)
+	}
+	keys, err := repos.APIKeys.ListByUser(ctx, owner.ID)
+	if err != nil || len(keys) != 1 || keys[0].RevokedAt == nil {
+		t.Errorf("Ожидается один отозванный ключ, получено %+v, %v", keys, err)
+	}
+
+	// Ключи удаляются вместе с пользователем
+	repos.Users.Delete(ctx, owner.ID)
+	if _, err := repos.APIKeys.GetByKeyHash(ctx, "key-hash"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидается ErrNotFound после удаления пользователя, получено %v", err)
+	}
+}
+
+func testAudit(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	userID, adminID, keyID := 7, 1, 3
+	start := time.Now().UTC().Truncate(time.Second)
+
+	events := []*models.AuditEvent{
+		{ActorType: models.ActorUser, ActorID: &userID, ImpersonatorID: &adminID, Action: "store_created", TargetType: "store", TargetID: "10",
+			After: []byte(`{"id":10,"type":"wb"}`), IP: "10.0.0.1", UserAgent: "curl/8.0", CreatedAt: start},
+		{ActorType: models.ActorAPIKey, ActorID: &userID, APIKeyID: &keyID, Action: "mapping_created", TargetType: "mapping", TargetID: "5", CreatedAt: start.Add(time.Minute)},
+		{ActorType: models.ActorAdmin, ActorID: &adminID, Action: "user_role_changed", TargetType: "user", TargetID: "7",
+			Before: []byte(`{"role":"owner"}`), After: []byte(`{"role":"viewer"}`), CreatedAt: start.Add(2 * time.Minute)},
+		{ActorType: models.ActorSystem, Action: "keys_rotated", CreatedAt: start.Add(3 * time.Minute)},
+	}
+	for _, event := range events {
+		if err := repos.Audit.Create(ctx, event); err != nil || event.ID == 0 {
+			t.Fatalf("Ошибка записи события: %v", err)
+		}
+	}
+
+	all, err := repos.Audit.List(ctx, AuditFilter{})
+	if err != nil || len(all) != 4 || all[0].ID != events[3].ID || all[0].ActorID != nil {
+		t.Fatalf("Ожидаются 4 события от новых к старым, получено %+v, %v", all, err)
+	}
+	stored := all[3]
+	if stored.Action != "store_created" || *stored.ActorID != userID || stored.APIKeyID != nil || string(stored.After) != `{"id":10,"type":"wb"}` ||
+		stored.Before != nil || stored.IP != "10.0.0.1" || stored.UserAgent != "curl/8.0" || !stored.CreatedAt.Equal(start) ||
+		stored.ImpersonatorID == nil || *stored.ImpersonatorID != adminID || all[2].ImpersonatorID != nil {
+		t.Errorf("Неожиданное событие: %+v", stored)
+	}
+
+	tests := []struct {
+		name   string
+		filter AuditFilter
+		want   []int
+	}{
+		{"инициатор", AuditFilter{ActorType: models.ActorAdmin, ActorID: adminID}, []int{events[2].ID}},
+		{"действие", AuditFilter{Action: "mapping_created"}, []int{events[1].ID}},
+		{"цель", AuditFilter{TargetType: "store", TargetID: "10"}, []int{events[0].ID}},
+		{"вход от имени пользователя", AuditFilter{ImpersonatorID: adminID}, []int{events[0].ID}},
+		{"пользователь", AuditFilter{UserID: userID}, []int{events[2].ID, events[1].ID, events[0].ID}},
+		{"период", AuditFilter{From: start.Add(time.Minute), To: start.Add(3 * time.Minute)}, []int{events[2].ID, events[1].ID}},
+		{"страница", AuditFilter{BeforeID: events[3].ID, Limit: 2}, []int{events[2].ID, events[1].ID}},
+	}
+	for _, tt := range tests {
+		found, err := repos.Audit.List(ctx, tt.filter)
+		var ids []int
+		for _, event := range found {
+			ids = append(ids, event.ID)
+		}
+		if err != nil || len(ids) != len(tt.want) {
+			t.Errorf("%s: ожидаются события %v, получено %v, %v", tt.name, tt.want, ids, err)
+			continue
+		}
+		for i := range ids {
+			if ids[i] != tt.want[i] {
+				t.Errorf("%s: ожидаются события %v, получено %v", tt.name, tt.want, ids)
+				break
+			}
+		}
+	}
+}
+
+func testStats(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	owner, _ := repos.Users.Create(ctx, "owner@example.com", "hash")
+	other, _ := repos.Users.Create(ctx, "other@example.com", "hash")
+	organization, _ := repos.Organizations.Create(ctx, "Команда")
+	wb, _ := repos.Stores.Create(ctx, organization.ID, owner.ID, "wb", "encrypted")
+	ozon, _ := repos.Stores.Create(ctx, organization.ID, owner.ID, "ozon", "encrypted")
+	otherWB, _ := repos.Stores.Create(ctx, organization.ID, other.ID, "wb", "encrypted")
+	for _, storeID := range []int{wb.ID, wb.ID, otherWB.ID} {
+		repos.Products.Create(ctx, &models.Product{StoreID: storeID, ExternalID: "ext", Name: "Товар", Price: 100})
+	}
+
+	// Число созданных записей по дням: все товары созданы сегодня (UTC)
+	dayAgo := time.Now().Add(-24 * time.Hour)
+	today := time.Now().UTC().Format(time.DateOnly)
+	if perDay, err := repos.Stats.CreatedPerDay(ctx, StatsProducts, dayAgo); err != nil || len(perDay) != 1 || perDay[0] != (DailyCount{Day: today, Count: 3}) {
+		t.Errorf("Ожидается 3 товара за %s, получено %+v, %v", today, perDay, err)
+	}
+	if perDay, _ := repos.Stats.CreatedPerDay(ctx, StatsUsers, time.Now().Add(time.Hour)); len(perDay) != 0 {
+		t.Errorf("Ожидается пустой результат для будущего периода, получено %v", perDay)
+	}
+	if _, err := repos.Stats.CreatedPerDay(ctx, "stores; DROP TABLE users", dayAgo); err == nil {
+		t.Error("Ожидается ошибка для неизвестной сущности")
+	}
+
+	counts, err := repos.Stats.CountByStoreType(ctx)
+	if err != nil || len(counts) != 2 || counts[0] != (StoreTypeCount{StoreType: "ozon", Stores: 1}) ||
+		counts[1] != (StoreTypeCount{StoreType: "wb", Stores: 2, Products: 3}) {
+		t.Errorf("Неожиданная разбивка по маркетплейсам: %+v, %v", counts, err)
+	}
+
+	top, err := repos.Stats.TopUsersByProducts(ctx, 10)
+	if err != nil || len(top) != 2 || top[0] != (UserCatalogue{UserID: owner.ID, Email: "owner@example.com", Stores: 2, Products: 2}) ||
+		top[1] != (UserCatalogue{UserID: other.ID, Email: "other@example.com", Stores: 1, Products: 1}) {
+		t.Errorf("Неожиданный рейтинг пользователей: %+v, %v", top, err)
+	}
+	if top, _ := repos.Stats.TopUsersByProducts(ctx, 1); len(top) != 1 || top[0].UserID != owner.ID {
+		t.Errorf("Ожидается один пользователь, получено %+v", top)
+	}
+
+	// Граница неактивности позже создания магазинов; ozon загружен после нее
+	since := time.Now().UTC().Add(time.Minute).Truncate(time.Second)
+	runs := []*models.SyncRun{
+		{StoreID: wb.ID, StoreType: "wb", Status: models.SyncSucceeded, Products: 2, StartedAt: since.Add(-240 * time.Hour)},
+		{StoreID: wb.ID, StoreType: "wb", Status: models.SyncFailed, ErrorCategory: "auth", StartedAt: since.Add(-24 * time.Hour)},
+		{StoreID: ozon.ID, StoreType: "ozon", Status: models.SyncSucceeded, DurationMs: 120, StartedAt: since.Add(time.Second)},
+	}
+	for _, run := range runs {
+		if err := repos.SyncRuns.Create(ctx, run); err != nil || run.ID == 0 {
+			t.Fatalf("Ошибка записи загрузки: %v", err)
+		}
+	}
+
+	summary, err := repos.SyncRuns.Summary(ctx, since.Add(-48*time.Hour))
+	if err != nil || len(summary) != 2 ||
+		summary[0] != (SyncSummary{StoreType: "ozon", Status: models.SyncSucceeded, Runs: 1}) ||
+		summary[1] != (SyncSummary{StoreType: "wb", Status: models.SyncFailed, ErrorCategory: "auth", Runs: 1}) {
+		t.Errorf("Неожиданная сводка загрузок: %+v, %v", summary, err)
+	}
+
+	inactive, total, err := repos.Stats.InactiveStores(ctx, since, 10)
+	if err != nil || total != 2 || len(inactive) != 2 || inactive[0].Store.ID != wb.ID || inactive[1].Store.ID != otherWB.ID {
+		t.Fatalf("Ожидаются 2 неактивных магазина, получено %+v, %d, %v", inactive, total, err)
+	}
+	if last := inactive[0].LastSyncedAt; last == nil || !last.Equal(runs[0].StartedAt) || inactive[1].LastSyncedAt != nil {
+		t.Errorf("Неожиданное время последней загрузки: %v, %v", last, inactive[1].LastSyncedAt)
+	}
+	if inactive, total, _ := repos.Stats.InactiveStores(ctx, since, 1); total != 2 || len(inactive) != 1 {
+		t.Errorf("Ожидается 1 магазин из 2, получено %+v, %d", inactive, total)
+	}
+}
+
+func testProductsAndMappings(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	user, _ := repos.Users.Create(ctx, "owner@example.com", "hash")
+	organization, _ := repos.Organizations.Create(ctx, "Команда")
+	wb, _ := repos.Stores.Create(ctx, organization.ID, user.ID, "wb", "encrypted")
+	ozon, _ := repos.Stores.Create(ctx, organization.ID, user.ID, "ozon", "encrypted")
+
+	var products []*models.Product
+	for _, storeID := range []int{wb.ID, wb.ID, ozon.ID} {
+		product := &models.Product{StoreID: storeID, ExternalID: "ext", Name: "Товар", Price: 100, Quantity: 1}
+		if err := repos.Products.Create(ctx, product); err != nil {
+			t.Fatalf("Ошибка создания товара: %v", err)
+		}
+		products = append(products, product)
+	}
+
+	other, _ := repos.Users.Create(ctx, "other@example.com", "hash")
+	otherOrganization, _ := repos.Organizations.Create(ctx, "Другая команда")
+	otherStore, _ := repos.Stores.Create(ctx, otherOrganization.ID, other.ID, "wb", "encrypted")
+	if err := repos.Products.Create(ctx, &models.Product{StoreID: otherStore.ID, ExternalID: "ext", Name: "Чужой"}); err != nil {
+		t.Fatalf("Ошибка создания товара: %v", err)
+	}
+	if list, _ := repos.Products.ListByOrganization(ctx, organization.ID); len(list) != 3 {
+		t.Errorf("Ожидается 3 товара организации, найдено %d", len(list))
+	}
+
+	first, err := repos.Mappings.Create(ctx, organization.ID, products[0].ID, products[2].ID, user.ID)
+	if err != nil {
+		t.Fatalf("Ошибка создания сопоставления: %v", err)
+	}
+	second, _ := repos.Mappings.Create(ctx, organization.ID, products[1].ID, products[2].ID, user.ID)
+	if _, err := repos.Mappings.Create(ctx, organization.ID, products[0].ID, products[2].ID, user.ID); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Ожидается ErrDuplicate для повторного сопоставления, получено %v", err)
+	}
+	if exists, _ := repos.Mappings.ExistsBetween(ctx, products[2].ID, products[0].ID); !exists {
+		t.Error("Ожидается, что сопоставление найдено в обратном порядке")
+	}
+
+	mappings, _ := repos.Mappings.ListByOrganization(ctx, organization.ID)
+	if len(mappings) != 2 || mappings[0].ID != second.ID || mappings[1].ID != first.ID {
+		t.Errorf("Ожидаются сопоставления от новых к старым, получено %v", mappings)
+	}
+	if mappings[0].OrganizationID != organization.ID {
+		t.Errorf("Ожидается сопоставление организации %d, получено %+v", organization.ID, mappings[0])
+	}
+	if mapped, _ := repos.Products.ListMappedByOrganization(ctx, organization.ID); len(mapped) != 3 {
+		t.Errorf("Ожидается 3 товара в сопоставлениях, найдено %d", len(mapped))
+	}
+
+	if err := repos.Mappings.DeleteByStore(ctx, wb.ID); err != nil {
+		t.Fatalf("Ошибка удаления сопоставлений магазина: %v", err)
+	}
+	if err := repos.Products.DeleteByStore(ctx, wb.ID); err != nil {
+		t.Fatalf("Ошибка удаления товаров магазина: %v", err)
+	}
+	if count, _ := repos.Mappings.Count(ctx); count != 0 {
+		t.Errorf("Ожидается удаление всех сопоставлений, осталось %d", count)
+	}
+	if count, _ := repos.Products.Count(ctx); count != 2 {
+		t.Errorf("Ожидается два оставшихся товара, найдено %d", count)
+	}
+}
+
+// Тест отката изменений, сделанных внутри транзакции
+func testTxRollback(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	failure := errors.New("откат")
+
+	err := repos.WithinTx(ctx, func(tx *Repositories) error {
+		if _, err := tx.Users.Create(ctx, "tx@example.com", "hash"); err != nil {
+			return err
+		}
+		return failure
+	})
+
+	if !errors.Is(err, failure) {
+		t.Errorf("Ожидается ошибка из транзакции, получена %v", err)
+	}
+	if count, _ := repos.Users.Count(ctx); count != 0 {
+		t.Errorf("Ожидается откат вставки, найдено пользователей: %d", count)
+	}
+}
+
+// Тест фиксации изменений и вложенной транзакции
+func testTxCommitNested(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+
+	err := repos.WithinTx(ctx, func(tx *Repositories) error {
+		return tx.WithinTx(ctx, func(inner *Repositories) error {
+			_, err := inner.Users.Create(ctx, "tx@example.com", "hash")
+			return err
+		})
+	})
+
+	if err != nil {
+		t.Fatalf("Ожидается успешная транзакция, получена ошибка: %v", err)
+	}
+	if count, _ := repos.Users.Count(ctx); count != 1 {
+		t.Errorf("Ожидается один пользователь, найдено %d", count)
+	}
+}
+
+func testAdminLists(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	organization, _ := repos.Organizations.Create(ctx, "Команда")
+	var users []*models.User
+	for _, email := range []string{"bob@example.com", "alice@example.com", "carol@test.org"} {
+		user, err := repos.Users.Create(ctx, email, "hash")
+		if err != nil {
+			t.Fatalf("Ошибка создания пользователя: %v", err)
+		}
+		users = append(users, user)
+	}
+	wb, _ := repos.Stores.Create(ctx, organization.ID, users[0].ID, "wb", "encrypted")
+	ozon, _ := repos.Stores.Create(ctx, organization.ID, users[1].ID, "ozon", "encrypted")
+	for i, name := range []string{"Кружка 100%", "Футболка", "кружка синяя", "Ручка"} {
+		storeID := wb.ID
+		if i%2 == 1 {
+			storeID = ozon.ID
+		}
+		if err := repos.Products.Create(ctx, &models.Product{StoreID: storeID, ExternalID: "ext", Name: name, Price: 100 * (4 - i)}); err != nil {
+			t.Fatalf("Ошибка создания товара: %v", err)
+		}
+	}
+
+	// Фильтр по части email без учета регистра, общее число без учета страницы
+	list, total, err := repos.Users.List(ctx, ListQuery{Email: "EXAMPLE", Sort: "email", Limit: 1})
+	if err != nil || total != 2 || len(list) != 1 || list[0].Email != "alice@example.com" || list[0].CreatedAt == nil {
+		t.Fatalf("Ожидается alice из 2 пользователей, получено %+v, %d, %v", list, total, err)
+	}
+	if list, _, _ := repos.Users.List(ctx, ListQuery{Email: "example", Sort: "email", Limit: 1, Offset: 1}); len(list) != 1 || list[0].ID != users[0].ID {
+		t.Errorf("Ожидается Bob на второй странице, получено %+v", list)
+	}
+	if list, total, _ := repos.Users.List(ctx, ListQuery{Email: "_"}); len(list) != 0 || total != 0 {
+		t.Errorf("Символы шаблона LIKE должны искаться буквально, получено %+v", list)
+	}
+
+	// Период создания
+	hour := time.Now().UTC().Add(-time.Hour)
+	if _, total, _ := repos.Users.List(ctx, ListQuery{CreatedFrom: hour, CreatedTo: hour.Add(2 * time.Hour)}); total != 3 {
+		t.Errorf("Ожидаются 3 пользователя за период, получено %d", total)
+	}
+	if _, total, _ := repos.Users.List(ctx, ListQuery{CreatedTo: hour}); total != 0 {
+		t.Errorf("Ожидается пустой список до периода, получено %d", total)
+	}
+
+	// Курсор по убыванию ID
+	list, _, _ = repos.Users.List(ctx, ListQuery{Desc: true, AfterID: users[2].ID, Limit: 10})
+	if len(list) != 2 || list[0].ID != users[1].ID || list[1].ID != users[0].ID {
+		t.Errorf("Ожидаются пользователи до курсора по убыванию, получено %+v", list)
+	}
+	if list, total, _ := repos.Users.List(ctx, ListQuery{AfterID: users[0].ID, Limit: 10, SkipTotal: true}); len(list) != 2 || total != 0 {
+		t.Errorf("Ожидаются 2 пользователя без подсчета общего числа, получено %d из %d", len(list), total)
+	}
+
+	stores, total, _ := repos.Stores.List(ctx, ListQuery{StoreType: "ozon"})
+	if total != 1 || stores[0].ID != ozon.ID {
+		t.Errorf("Ожидается магазин ozon, получено %+v", stores)
+	}
+	if stores, _, _ := repos.Stores.List(ctx, ListQuery{UserID: users[0].ID}); len(stores) != 1 || stores[0].ID != wb.ID {
+		t.Errorf("Ожидается магазин пользователя, получено %+v", stores)
+	}
+
+	// Товары фильтруются по названию и по типу магазина, сортируются по цене
+	products, total, _ := repos.Products.List(ctx, ListQuery{Name: "кружка", Sort: "price"})
+	if total != 2 || products[0].Name != "кружка синяя" || products[1].Name != "Кружка 100%" {
+		t.Errorf("Ожидаются кружки по возрастанию цены, получено %+v", products)
+	}
+	if products, _, _ := repos.Products.List(ctx, ListQuery{Name: "100%"}); len(products) != 1 {
+		t.Errorf("Ожидается один товар с %% в названии, получено %+v", products)
+	}
+	if _, total, _ := repos.Products.List(ctx, ListQuery{StoreType: "ozon", UserID: users[1].ID}); total != 2 {
+		t.Errorf("Ожидаются 2 товара магазина ozon, получено %d", total)
+	}
+	if products, _, _ := repos.Products.List(ctx, ListQuery{Sort: "price", Desc: true, Limit: 1}); products[0].Name != "Кружка 100%" {
+		t.Errorf("Ожидается самый дорогой товар, получено %+v", products)
+	}
+
+	// Каждое поле сортировки поддерживается
+	for _, field := range UserSortFields {
+		if _, total, err := repos.Users.List(ctx, ListQuery{Sort: field, Desc: true}); err != nil || total != 3 {
+			t.Errorf("Ошибка сортировки пользователей по %s: %d, %v", field, total, err)
+		}
+	}
+	for _, field := range StoreSortFields {
+		if _, total, err := repos.Stores.List(ctx, ListQuery{Sort: field}); err != nil || total != 2 {
+			t.Errorf("Ошибка сортировки магазинов по %s: %d, %v", field, total, err)
+		}
+	}
+	for _, field := range ProductSortFields {
+		if _, total, err := repos.Products.List(ctx, ListQuery{Sort: field}); err != nil || total != 4 {
+			t.Errorf("Ошибка сортировки товаров по %s: %d, %v", field, total, err)
+		}
+	}
+	for _, field := range MappingSortFields {
+		if _, total, err := repos.Mappings.List(ctx, ListQuery{Sort: field, UserID: users[0].ID}); err != nil || total != 0 {
+			t.Errorf("Ошибка сортировки сопоставлений по %s: %d, %v", field, total, err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"kursovaya_backend/internal/database"
+	"kursovaya_backend/internal/models"
+)
+
+// SyncRunRepository хранит результаты загрузки товаров магазинов
+type SyncRunRepository interface {
+	// Create сохраняет результат загрузки и заполняет его ID
+	Create(ctx context.Context, run *models.SyncRun) error
+	// Summary группирует загрузки, начатые не раньше from, по маркетплейсу,
+	// результату и категории ошибки
+	Summary(ctx context.Context, from time.Time) ([]SyncSummary, error)
+}
+
+// SyncSummary - число загрузок маркетплейса с одним результатом и категорией ошибки
+type SyncSummary struct {
+	StoreType     string
+	Status        string
+	ErrorCategory string
+	Runs          int
+}
+
+type sqlSyncRunRepository struct {
+	db database.DBTX
+}
+
+func (r *sqlSyncRunRepository) Create(ctx context.Context, run *models.SyncRun) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO sync_runs (store_id, store_type, status, error_category, products, duration_ms, started_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		run.StoreID, run.StoreType, run.Status, run.ErrorCategory, run.Products, run.DurationMs, run.StartedAt.UTC(),
+	).Scan(&run.ID)
+	return mapError(err)
+}
+
+func (r *sqlSyncRunRepository) Summary(ctx context.Context, from time.Time) ([]SyncSummary, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT store_type, status, error_category, COUNT(*) FROM sync_runs WHERE started_at >= $1
+		GROUP BY store_type, status, error_category ORDER BY store_type, status, error_category`,
+		from.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summary []SyncSummary
+	for rows.Next() {
+		var row SyncSummary
+		if err := rows.Scan(&row.StoreType, &row.Status, &row.ErrorCategory, &row.Runs); err != nil {
+			return nil, err
+		}
+		summary = append(summary, row)
+	}
+	return summary, rows.Err()
+}
@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"kursovaya_backend/internal/database"
+	"kursovaya_backend/internal/models"
+)
+
+// TwoFactorRepository описывает хранилище настроек TOTP и кодов восстановления.
+// Запись определяется парой subjectType ("user" или "admin") и subjectID.
+type TwoFactorRepository interface {
+	Get(ctx context.Context, subjectType string, subjectID int) (*models.TwoFactor, error)
+	// Create сохраняет новую настройку. Возвращает ErrDuplicate, если она уже есть.
+	Create(ctx context.Context, twoFactor *models.TwoFactor) error
+	// Delete удаляет настройку вместе с кодами восстановления
+	Delete(ctx context.Context, subjectType string, subjectID int) error
+	Confirm(ctx context.Context, subjectType string, subjectID int, at time.Time) error
+	// MarkStepUsed запоминает принятый шаг TOTP. Возвращает ErrNotFound, если
+	// этот или более поздний шаг уже был принят, поэтому код нельзя повторить.
+	MarkStepUsed(ctx context.Context, subjectType string, subjectID int, step int64) error
+
+	// ReplaceRecoveryCodes заменяет все коды восстановления новыми хешами
+	ReplaceRecoveryCodes(ctx context.Context, subjectType string, subjectID int, codeHashes []string) error
+	// UseRecoveryCode помечает код использованным. Возвращает ErrNotFound, если
+	// кода нет или он уже был использован.
+	UseRecoveryCode(ctx context.Context, subjectType string, subjectID int, codeHash string, at time.Time) error
+	CountRecoveryCodes(ctx context.Context, subjectType string, subjectID int) (int, error)
+
+	// ListSecrets возвращает все настройки с зашифрованными секретами для перешифрования
+	ListSecrets(ctx context.Context) ([]models.TwoFactor, error)
+	// ReplaceSecret заменяет секрет, только если он все еще равен oldSecret;
+	// иначе возвращает ErrNotFound
+	ReplaceSecret(ctx context.Context, subjectType string, subjectID int, oldSecret, newSecret string) error
+}
+
+type sqlTwoFactorRepository struct {
+	db database.DBTX
+}
+
+func (r *sqlTwoFactorRepository) Get(ctx context.Context, subjectType string, subjectID int) (*models.TwoFactor, error) {
+	twoFactor := models.TwoFactor{SubjectType: subjectType, SubjectID: subjectID}
+	var confirmedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx,
+		"SELECT secret, confirmed_at, last_used_step FROM two_factor WHERE subject_type = $1 AND subject_id = $2",
+		subjectType, subjectID,
+	).Scan(&twoFactor.Secret, &confirmedAt, &twoFactor.LastUsedStep)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	if confirmedAt.Valid {
+		twoFactor.ConfirmedAt = &confirmedAt.Time
+	}
+	return &twoFactor, nil
+}
+
+func (r *sqlTwoFactorRepository) Create(ctx context.Context, twoFactor *models.TwoFactor) error {
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO two_factor (subject_type, subject_id, secret) VALUES ($1, $2, $3)",
+		twoFactor.SubjectType, twoFactor.SubjectID, twoFactor.Secret,
+	)
+	return mapError(err)
+}
+
+func (r *sqlTwoFactorRepository) Delete(ctx context.Context, subjectType string, subjectID int) error {
+	if _, err := r.db.ExecContext(ctx,
+		"DELETE FROM recovery_codes WHERE subject_type = $1 AND subject_id = $2",
+		subjectType, subjectID,
+	); err != nil {
+		return err
+	}
+
+	result, err := r.db.ExecContext(ctx,
+		"DELETE FROM two_factor WHERE subject_type = $1 AND subject_id = $2",
+		subjectType, subjectID,
+	)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqlTwoFactorRepository) Confirm(ctx context.Context, subjectType string, subjectID int, at time.Time) error {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE two_factor SET confirmed_at = $1 WHERE subject_type = $2 AND subject_id = $3",
+		at.UTC(), subjectType, subjectID,
+	)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqlTwoFactorRepository) MarkStepUsed(ctx context.Context, subjectType string, subjectID int, step int64) error {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE two_factor SET last_used_step = $1 WHERE subject_type = $2 AND subject_id = $3 AND last_used_step < $1",
+		step, subjectType, subjectID,
+	)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqlTwoFactorRepository) ReplaceRecoveryCodes(ctx context.Context, subjectType string, subjectID int, codeHashes []string) error {
+	if _, err := r.db.ExecContext(ctx,
+		"DELETE FROM recovery_codes WHERE subject_type = $1 AND subject_id = $2",
+		subjectType, subjectID,
+	); err != nil {
+		return err
+	}
+
+	for _, codeHash := range codeHashes {
+		if _, err := r.db.ExecContext(ctx,
+			"INSERT INTO recovery_codes (subject_type, subject_id, code_hash) VALUES ($1, $2, $3)",
+			subjectType, subjectID, codeHash,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *sqlTwoFactorRepository) UseRecoveryCode(ctx context.Context, subjectType string, subjectID int, codeHash string, at time.Time) error {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE recovery_codes SET used_at = $1
+		WHERE subject_type = $2 AND subject_id = $3 AND code_hash = $4 AND used_at IS NULL`,
+		at.UTC(), subjectType, subjectID, codeHash,
+	)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqlTwoFactorRepository) CountRecoveryCodes(ctx context.Context, subjectType string, subjectID int) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM recovery_codes WHERE subject_type = $1 AND subject_id = $2 AND used_at IS NULL",
+		subjectType, subjectID,
+	).Scan(&count)
+	return count, err
+}
+
+func (r *sqlTwoFactorRepository) ListSecrets(ctx context.Context) ([]models.TwoFactor, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT subject_type, subject_id, secret FROM two_factor ORDER BY subject_type, subject_id",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var settings []models.TwoFactor
+	for rows.Next() {
+		var twoFactor models.TwoFactor
+		if err := rows.Scan(&twoFactor.SubjectType, &twoFactor.SubjectID, &twoFactor.Secret); err != nil {
+			return nil, err
+		}
+		settings = append(settings, twoFactor)
+	}
+	return settings, rows.Err()
+}
+
+func (r *sqlTwoFactorRepository) ReplaceSecret(ctx context.Context, subjectType string, subjectID int, oldSecret, newSecret string) error {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE two_factor SET secret = $1 WHERE subject_type = $2 AND subject_id = $3 AND secret = $4",
+		newSecret, subjectType, subjectID, oldSecret,
+	)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
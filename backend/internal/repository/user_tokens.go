@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"kursovaya_backend/internal/database"
+	"kursovaya_backend/internal/models"
+)
+
+// UserTokenRepository описывает хранилище одноразовых токенов сброса пароля
+// и подтверждения email
+type UserTokenRepository interface {
+	// Create сохраняет токен и заполняет его ID
+	Create(ctx context.Context, token *models.UserToken) error
+	GetByTokenHash(ctx context.Context, tokenHash string) (*models.UserToken, error)
+	// MarkUsed помечает токен использованным. Возвращает ErrNotFound, если
+	// он уже был использован, поэтому токен нельзя применить дважды.
+	MarkUsed(ctx context.Context, id int, at time.Time) error
+	// InvalidateForUser помечает использованными все действующие токены
+	// пользователя с указанным назначением
+	InvalidateForUser(ctx context.Context, userID int, purpose string, at time.Time) error
+}
+
+type sqlUserTokenRepository struct {
+	db database.DBTX
+}
+
+func (r *sqlUserTokenRepository) Create(ctx context.Context, token *models.UserToken) error {
+	err := r.db.QueryRowContext(ctx,
+		"INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at) VALUES ($1, $2, $3, $4) RETURNING id",
+		token.UserID, token.Purpose, token.TokenHash, token.ExpiresAt.UTC(),
+	).Scan(&token.ID)
+	return mapError(err)
+}
+
+func (r *sqlUserTokenRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*models.UserToken, error) {
+	var token models.UserToken
+	var usedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx,
+		"SELECT id, user_id, purpose, token_hash, expires_at, used_at FROM user_tokens WHERE token_hash = $1",
+		tokenHash,
+	).Scan(&token.ID, &token.UserID, &token.Purpose, &token.TokenHash, &token.ExpiresAt, &usedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	if usedAt.Valid {
+		token.UsedAt = &usedAt.Time
+	}
+	return &token, nil
+}
+
+func (r *sqlUserTokenRepository) MarkUsed(ctx context.Context, id int, at time.Time) error {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE user_tokens SET used_at = $1 WHERE id = $2 AND used_at IS NULL",
+		at.UTC(), id,
+	)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqlUserTokenRepository) InvalidateForUser(ctx context.Context, userID int, purpose string, at time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE user_tokens SET used_at = $1 WHERE user_id = $2 AND purpose = $3 AND used_at IS NULL",
+		at.UTC(), userID, purpose,
+	)
+	return err
+}
@@ -0,0 +1,203 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"kursovaya_backend/internal/database"
+	"kursovaya_backend/internal/models"
+)
+
+// UserRepository описывает хранилище пользователей
+type UserRepository interface {
+	// Create создает пользователя с уже захешированным паролем
+	Create(ctx context.Context, email, passwordHash string) (*models.User, error)
+	GetByID(ctx context.Context, id int) (*models.User, error)
+	// GetCredentials возвращает пользователя и хеш его пароля по email
+	GetCredentials(ctx context.Context, email string) (*models.User, string, error)
+	ExistsByEmail(ctx context.Context, email string) (bool, error)
+	// List возвращает страницу пользователей и общее число подходящих под фильтры
+	List(ctx context.Context, query ListQuery) ([]models.User, int, error)
+	// SetRole меняет роль пользователя
+	SetRole(ctx context.Context, id int, role string) error
+	// SetPassword заменяет хеш пароля пользователя
+	SetPassword(ctx context.Context, id int, passwordHash string) error
+	// MarkEmailVerified отмечает email пользователя подтвержденным
+	MarkEmailVerified(ctx context.Context, id int, at time.Time) error
+	// SetStatus меняет статус (models.UserActive, UserSuspended или UserDeleted) и его причину
+	SetStatus(ctx context.Context, id int, status, reason string, at time.Time) error
+	// Erase заменяет email обезличенным адресом, удаляет хеш пароля и помечает
+	// пользователя удаленным. Строка остается, чтобы не нарушить внешние ключи.
+	Erase(ctx context.Context, id int, at time.Time) error
+	Delete(ctx context.Context, id int) error
+	Count(ctx context.Context) (int, error)
+}
+
+type sqlUserRepository struct {
+	db database.DBTX
+}
+
+const userColumns = "id, email, role, email_verified_at, status, status_reason, status_changed_at, erased_at, created_at"
+
+// scanUser разбирает колонки userColumns и, после них, колонки extra
+func scanUser(row rowScanner, extra ...any) (*models.User, error) {
+	var user models.User
+	var verifiedAt sql.NullTime
+	dest := append([]any{&user.ID, &user.Email, &user.Role, &verifiedAt, &user.Status, &user.StatusReason,
+		&user.StatusChangedAt, &user.ErasedAt, &user.CreatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	setVerifiedAt(&user, verifiedAt)
+	return &user, nil
+}
+
+func (r *sqlUserRepository) Create(ctx context.Context, email, passwordHash string) (*models.User, error) {
+	user, err := scanUser(r.db.QueryRowContext(ctx,
+		"INSERT INTO users (email, password) VALUES ($1, $2) RETURNING "+userColumns,
+		email, passwordHash,
+	))
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	return user, nil
+}
+
+func (r *sqlUserRepository) GetByID(ctx context.Context, id int) (*models.User, error) {
+	user, err := scanUser(r.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", id))
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return user, nil
+}
+
+func (r *sqlUserRepository) GetCredentials(ctx context.Context, email string) (*models.User, string, error) {
+	var hashedPassword string
+	user, err := scanUser(r.db.QueryRowContext(ctx, "SELECT "+userColumns+", password FROM users WHERE email = $1", email), &hashedPassword)
+	if err != nil {
+		return nil, "", mapError(err)
+	}
+	return user, hashedPassword, nil
+}
+
+func (r *sqlUserRepository) ExistsByEmail(ctx context.Context, email string) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE email = $1", email).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// userSortColumns сопоставляет UserSortFields колонкам таблицы
+var userSortColumns = map[string]string{"id": "id", "email": "email", "role": "role", "created_at": "created_at"}
+
+func (r *sqlUserRepository) List(ctx context.Context, query ListQuery) ([]models.User, int, error) {
+	var b listBuilder
+	b.contains("email", query.Email)
+	switch query.Status {
+	case "":
+	case models.UserPendingVerification:
+		b.add("status = %s AND email_verified_at IS NULL", models.UserActive)
+	case models.UserActive:
+		b.add("status = %s AND email_verified_at IS NOT NULL", models.UserActive)
+	default:
+		b.add("status = %s", query.Status)
+	}
+	b.created("created_at", query)
+	rows, total, err := b.run(ctx, r.db, userColumns, "FROM users", userSortColumns, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, *user)
+	}
+	return users, total, rows.Err()
+}
+
+func (r *sqlUserRepository) SetRole(ctx context.Context, id int, role string) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE users SET role = $1 WHERE id = $2", role, id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqlUserRepository) SetPassword(ctx context.Context, id int, passwordHash string) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE users SET password = $1 WHERE id = $2", passwordHash, id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqlUserRepository) MarkEmailVerified(ctx context.Context, id int, at time.Time) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE users SET email_verified_at = $1 WHERE id = $2", at.UTC(), id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqlUserRepository) SetStatus(ctx context.Context, id int, status, reason string, at time.Time) error {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE users SET status = $1, status_reason = $2, status_changed_at = $3 WHERE id = $4 AND erased_at IS NULL",
+		status, reason, at.UTC(), id,
+	)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqlUserRepository) Erase(ctx context.Context, id int, at time.Time) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE users
+		SET email = $1, password = '', email_verified_at = NULL, status = $2, status_reason = '', status_changed_at = $3, erased_at = $3
+		WHERE id = $4`,
+		ErasedEmail(id), models.UserDeleted, at.UTC(), id,
+	)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+// ErasedEmail - обезличенный адрес пользователя после удаления персональных данных
+func ErasedEmail(id int) string {
+	return fmt.Sprintf("erased-%d@erased.invalid", id)
+}
+
+func (r *sqlUserRepository) Delete(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM users WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqlUserRepository) Count(ctx context.Context) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&count)
+	return count, err
+}
+
+// setVerifiedAt переносит время подтверждения email из nullable-колонки.
+// Активный пользователь без подтвержденного email ожидает подтверждения.
+func setVerifiedAt(user *models.User, verifiedAt sql.NullTime) {
+	if verifiedAt.Valid {
+		user.EmailVerifiedAt = &verifiedAt.Time
+	} else if user.Status == models.UserActive {
+		user.Status = models.UserPendingVerification
+	}
+}
@@ -0,0 +1,111 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"kursovaya_backend/internal/config"
+	"kursovaya_backend/internal/database"
+	"kursovaya_backend/internal/handlers"
+	"kursovaya_backend/internal/mailer"
+	"kursovaya_backend/internal/metrics"
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/rbac"
+	"kursovaya_backend/internal/repository"
+	"kursovaya_backend/internal/secrets"
+	"kursovaya_backend/pkg/utils"
+)
+
+// Тест удаления магазина администратором: товары и сопоставления удаляются
+// вместе с магазином, а токен - из хранилища секретов
+func TestAdminDeleteStore(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.OpenSQLite(filepath.Join(t.TempDir(), "admin.db"))
+	if err != nil {
+		t.Fatalf("Ошибка открытия SQLite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := database.Migrate(ctx, db, database.DriverSQLite); err != nil {
+		t.Fatalf("Ошибка миграции схемы: %v", err)
+	}
+	repos := repository.NewSQL(db)
+	cfg := &config.Config{
+		EncryptionKey:   "admin-routes-test-encryption-key",
+		AccessTokenTTL:  time.Hour,
+		RefreshTokenTTL: 24 * time.Hour,
+	}
+	keyring, err := cfg.Keyring()
+	if err != nil {
+		t.Fatalf("Ошибка создания ключей: %v", err)
+	}
+	utils.SetEncryptionKeyring(keyring)
+	secretStore := secrets.NewFileStore(t.TempDir())
+
+	user, _ := repos.Users.Create(ctx, "owner@example.com", "hash")
+	organization, _ := repos.Organizations.Create(ctx, "Магазины")
+	repos.Organizations.AddMember(ctx, organization.ID, user.ID, rbac.RoleOwner)
+	tokenRef, err := secretStore.Put(ctx, "marketplace-token")
+	if err != nil {
+		t.Fatalf("Ошибка сохранения токена: %v", err)
+	}
+	store, err := repos.Stores.Create(ctx, organization.ID, user.ID, "wb", tokenRef)
+	if err != nil {
+		t.Fatalf("Ошибка создания магазина: %v", err)
+	}
+	var products []int
+	for i := 0; i < 2; i++ {
+		product := &models.Product{StoreID: store.ID, ExternalID: fmt.Sprintf("ext-%d", i), Name: "Товар"}
+		if err := repos.Products.Create(ctx, product); err != nil {
+			t.Fatalf("Ошибка создания товара: %v", err)
+		}
+		products = append(products, product.ID)
+	}
+	if _, err := repos.Mappings.Create(ctx, organization.ID, products[0], products[1], user.ID); err != nil {
+		t.Fatalf("Ошибка создания сопоставления: %v", err)
+	}
+
+	admin, _ := repos.Admins.Create(ctx, "admin", "hash", rbac.DefaultAdminRole, false)
+	utils.SetJWTKey("admin-routes-test-secret-key-0123456789")
+	token, err := utils.GenerateAdminToken(admin.ID, admin.Role, admin.TokenVersion)
+	if err != nil {
+		t.Fatalf("Ошибка генерации токена: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(handlers.GlobalErrorHandler())
+	SetupRoutes(r, cfg, repos, mailer.NewLogMailer(), secretStore, metrics.New())
+
+	req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/api/v1/admin/stores/%d", store.ID), nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Ожидается 200, получено %d: %s", w.Code, w.Body.String())
+	}
+
+	if _, err := repos.Stores.GetByID(ctx, store.ID); err != repository.ErrNotFound {
+		t.Errorf("Ожидается удаление магазина, получено %v", err)
+	}
+	if count, _ := repos.Products.Count(ctx); count != 0 {
+		t.Errorf("Ожидается удаление товаров, осталось %d", count)
+	}
+	if count, _ := repos.Mappings.Count(ctx); count != 0 {
+		t.Errorf("Ожидается удаление сопоставлений, осталось %d", count)
+	}
+	if _, err := secretStore.Get(ctx, tokenRef); err != secrets.ErrNotFound {
+		t.Errorf("Ожидается удаление токена из хранилища, получено %v", err)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Повторное удаление: ожидается 404, получено %d", w.Code)
+	}
+}
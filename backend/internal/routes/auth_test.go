@@ -0,0 +1,83 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"kursovaya_backend/internal/config"
+	"kursovaya_backend/internal/handlers"
+	"kursovaya_backend/internal/mailer"
+	"kursovaya_backend/internal/metrics"
+	"kursovaya_backend/internal/repository"
+	"kursovaya_backend/internal/secrets"
+	"kursovaya_backend/pkg/utils"
+)
+
+// Тест счетчика неудачных входов по IP: подделанный X-Forwarded-For не дает
+// нового счетчика, а от доверенного прокси адрес клиента берется из заголовка
+func TestLoginIPLockoutIgnoresForgedForwardedFor(t *testing.T) {
+	utils.SetJWTKey("auth-routes-test-secret-key-0123456789")
+	const maxFailures = 3
+
+	tests := []struct {
+		name           string
+		trustedProxies string
+		wantLocked     bool
+	}{
+		{"no trusted proxies", "", true},
+		{"request from trusted proxy", "192.0.2.1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				EncryptionKey:        "auth-routes-test-encryption-key!",
+				AccessTokenTTL:       time.Hour,
+				RefreshTokenTTL:      24 * time.Hour,
+				LoginMaxFailures:     5,
+				LoginIPMaxFailures:   maxFailures,
+				LoginFailureWindow:   time.Hour,
+				LoginLockoutDuration: time.Hour,
+				LoginDelayBase:       time.Second,
+				LoginDelayMax:        time.Second,
+				TrustedProxies:       tt.trustedProxies,
+			}
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			// Как при запуске сервера
+			if err := r.SetTrustedProxies(cfg.TrustedProxyList()); err != nil {
+				t.Fatalf("Ошибка настройки доверенных прокси: %v", err)
+			}
+			r.Use(handlers.GlobalErrorHandler())
+			SetupRoutes(r, cfg, repository.NewMemory(), mailer.NewLogMailer(), secrets.NewDatabaseStore(), metrics.New())
+
+			// Каждая попытка - с другой учетной записью и другим X-Forwarded-For
+			var codes []int
+			for i := 0; i <= maxFailures; i++ {
+				body := fmt.Sprintf(`{"email": "user%d@example.com", "password": "wrong-password"}`, i)
+				req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader(body))
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set("X-Forwarded-For", fmt.Sprintf("203.0.113.%d", i+1))
+				req.RemoteAddr = "192.0.2.1:1234"
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				codes = append(codes, w.Code)
+			}
+
+			for i, code := range codes[:maxFailures] {
+				if code != http.StatusUnauthorized {
+					t.Errorf("Попытка %d: ожидается 401, получено %d", i+1, code)
+				}
+			}
+			locked := codes[maxFailures] == http.StatusTooManyRequests
+			if locked != tt.wantLocked {
+				t.Errorf("Попытка после %d неудач: получено %d, ожидается блокировка: %v", maxFailures, codes[maxFailures], tt.wantLocked)
+			}
+		})
+	}
+}
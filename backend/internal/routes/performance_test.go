@@ -0,0 +1,278 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"kursovaya_backend/internal/config"
+	"kursovaya_backend/internal/database"
+	"kursovaya_backend/internal/handlers"
+	"kursovaya_backend/internal/mailer"
+	"kursovaya_backend/internal/metrics"
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/rbac"
+	"kursovaya_backend/internal/repository"
+	"kursovaya_backend/internal/secrets"
+	"kursovaya_backend/internal/service"
+	"kursovaya_backend/pkg/utils"
+)
+
+// Размер каталога для проверки производительности: 100 пользователей,
+// у каждого магазины wb и ozon по 500 товаров - всего 100 000 товаров
+const (
+	perfUsers            = 100
+	perfProductsPerStore = 500
+	perfMappingsPerUser  = 200
+
+	// perfTargetP95 - допустимая задержка списков товаров и сопоставлений
+	perfTargetP95 = 100 * time.Millisecond
+	perfRequests  = 50
+)
+
+// perfFixture - роутер с заполненной базой и токен одного из пользователей
+type perfFixture struct {
+	router *gin.Engine
+	token  string
+	db     *sql.DB
+	// dir - временный каталог базы SQLite, пустой для PostgreSQL
+	dir string
+}
+
+var (
+	perfOnce   sync.Once
+	perfShared *perfFixture
+	perfErr    error
+)
+
+// TestMain закрывает общую базу проверки производительности после всех тестов
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if perfShared != nil {
+		perfShared.close()
+	}
+	os.Exit(code)
+}
+
+// sharedPerfFixture заполняет каталог один раз за запуск: бенчмарк
+// вызывается несколько раз с растущим b.N, а запросы только читают данные,
+// поэтому все проверки пользуются одной базой
+func sharedPerfFixture(tb testing.TB) *perfFixture {
+	tb.Helper()
+	perfOnce.Do(func() {
+		start := time.Now()
+		perfShared, perfErr = newPerfFixture(context.Background())
+		if perfErr == nil {
+			tb.Logf("Каталог из %d товаров заполнен за %s", perfUsers*2*perfProductsPerStore, time.Since(start))
+		}
+	})
+	if perfErr != nil {
+		tb.Fatalf("Ошибка подготовки каталога: %v", perfErr)
+	}
+	return perfShared
+}
+
+// newPerfFixture создает базу (SQLite во временном файле или PostgreSQL из
+// TEST_DATABASE_URL), заполняет ее каталогом и поднимает настоящие маршруты
+func newPerfFixture(ctx context.Context) (*perfFixture, error) {
+	f, driver, err := openPerfDatabase()
+	if err != nil {
+		return nil, err
+	}
+	if err := f.prepare(ctx, driver); err != nil {
+		f.close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *perfFixture) prepare(ctx context.Context, driver string) error {
+	if err := database.Migrate(ctx, f.db, driver); err != nil {
+		return fmt.Errorf("миграция схемы: %w", err)
+	}
+	// Общая тестовая база PostgreSQL очищается от данных предыдущих запусков
+	if driver == database.DriverPostgres {
+		if _, err := f.db.Exec("TRUNCATE rate_limits, sync_runs, audit_events, admin_bootstrap_tokens, api_keys, login_attempts, user_tokens, recovery_codes, two_factor, organization_invitations, organization_members, sessions, product_mappings, products, stores, organizations, users, admins RESTART IDENTITY CASCADE"); err != nil {
+			return fmt.Errorf("очистка таблиц: %w", err)
+		}
+	}
+	repos := repository.NewSQL(f.db)
+
+	userID, err := seedCatalogue(ctx, repos)
+	if err != nil {
+		return fmt.Errorf("заполнение каталога: %w", err)
+	}
+
+	cfg := &config.Config{
+		EncryptionKey:   "performance-test-encryption-key!",
+		AccessTokenTTL:  time.Hour,
+		RefreshTokenTTL: 24 * time.Hour,
+	}
+	utils.SetJWTKey("performance-test-secret-key-0123456789")
+	user, err := repos.Users.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("получение пользователя: %w", err)
+	}
+	tokens, err := service.NewSessionService(repos, cfg).StartSession(ctx, user)
+	if err != nil {
+		return fmt.Errorf("открытие сессии: %w", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(handlers.GlobalErrorHandler())
+	SetupRoutes(r, cfg, repos, mailer.NewLogMailer(), secrets.NewDatabaseStore(), metrics.New())
+
+	f.router = r
+	f.token = tokens.AccessToken
+	return nil
+}
+
+func openPerfDatabase() (*perfFixture, string, error) {
+	if dsn := os.Getenv("TEST_DATABASE_URL"); dsn != "" {
+		db, err := sql.Open("postgres", dsn)
+		if err != nil {
+			return nil, "", fmt.Errorf("подключение к PostgreSQL: %w", err)
+		}
+		return &perfFixture{db: db}, database.DriverPostgres, nil
+	}
+
+	dir, err := os.MkdirTemp("", "perf")
+	if err != nil {
+		return nil, "", err
+	}
+	db, err := database.OpenSQLite(filepath.Join(dir, "perf.db"))
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, "", fmt.Errorf("открытие SQLite: %w", err)
+	}
+	return &perfFixture{db: db, dir: dir}, database.DriverSQLite, nil
+}
+
+func (f *perfFixture) close() {
+	f.db.Close()
+	if f.dir != "" {
+		os.RemoveAll(f.dir)
+	}
+}
+
+// seedCatalogue заполняет базу в одной транзакции и возвращает ID первого пользователя
+func seedCatalogue(ctx context.Context, repos *repository.Repositories) (int, error) {
+	var firstUserID int
+	err := repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		for u := 0; u < perfUsers; u++ {
+			user, err := tx.Users.Create(ctx, fmt.Sprintf("user%d@example.com", u), "hash")
+			if err != nil {
+				return err
+			}
+			if u == 0 {
+				firstUserID = user.ID
+			}
+			organization, err := tx.Organizations.Create(ctx, user.Email)
+			if err != nil {
+				return err
+			}
+			if err := tx.Organizations.AddMember(ctx, organization.ID, user.ID, rbac.RoleOwner); err != nil {
+				return err
+			}
+
+			var storeProducts [2][]int
+			for s, storeType := range []string{"wb", "ozon"} {
+				store, err := tx.Stores.Create(ctx, organization.ID, user.ID, storeType, "encrypted")
+				if err != nil {
+					return err
+				}
+				for p := 0; p < perfProductsPerStore; p++ {
+					product := &models.Product{
+						StoreID:    store.ID,
+						ExternalID: fmt.Sprintf("%s-%d-%d", storeType, u, p),
+						Name:       fmt.Sprintf("Товар %d", p),
+						Price:      100 + p,
+						Quantity:   p % 10,
+					}
+					if err := tx.Products.Create(ctx, product); err != nil {
+						return err
+					}
+					storeProducts[s] = append(storeProducts[s], product.ID)
+				}
+			}
+
+			for m := 0; m < perfMappingsPerUser; m++ {
+				if _, err := tx.Mappings.Create(ctx, organization.ID, storeProducts[0][m], storeProducts[1][m], user.ID); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return firstUserID, err
+}
+
+// get выполняет авторизованный запрос и проверяет код ответа
+func (f *perfFixture) get(tb testing.TB, path string) {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Authorization", "Bearer "+f.token)
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		tb.Fatalf("%s: ожидается 200, получено %d: %s", path, w.Code, w.Body.String())
+	}
+}
+
+// TestListLatency проверяет, что списки укладываются в целевую задержку на
+// большом каталоге. Результат зависит от машины, поэтому проверка
+// запускается только с PERF_TESTS=1; по умолчанию производительность
+// измеряют бенчмарки ниже.
+func TestListLatency(t *testing.T) {
+	if os.Getenv("PERF_TESTS") != "1" {
+		t.Skip("Проверка производительности запускается с PERF_TESTS=1")
+	}
+
+	f := sharedPerfFixture(t)
+
+	for _, path := range []string{"/api/v1/products/saved", "/api/v1/mappings"} {
+		t.Run(path, func(t *testing.T) {
+			// Прогрев: первые запросы заполняют кеши базы
+			f.get(t, path)
+
+			durations := make([]time.Duration, perfRequests)
+			for i := range durations {
+				start := time.Now()
+				f.get(t, path)
+				durations[i] = time.Since(start)
+			}
+
+			sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+			p95 := durations[len(durations)*95/100]
+			t.Logf("p50=%s p95=%s", durations[len(durations)/2], p95)
+			if p95 > perfTargetP95 {
+				t.Errorf("p95 %s превышает целевые %s", p95, perfTargetP95)
+			}
+		})
+	}
+}
+
+func BenchmarkSavedProducts(b *testing.B) {
+	f := sharedPerfFixture(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f.get(b, "/api/v1/products/saved")
+	}
+}
+
+func BenchmarkMappings(b *testing.B) {
+	f := sharedPerfFixture(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f.get(b, "/api/v1/mappings")
+	}
+}
@@ -7,10 +7,21 @@ import (
 	"github.com/gin-contrib/cors"
 	"kursovaya_backend/internal/config"
 	"kursovaya_backend/internal/handlers"
+	"kursovaya_backend/internal/mailer"
+	"kursovaya_backend/internal/metrics"
 	"kursovaya_backend/internal/middleware"
+	"kursovaya_backend/internal/ratelimit"
+	"kursovaya_backend/internal/rbac"
+	"kursovaya_backend/internal/repository"
+	"kursovaya_backend/internal/secrets"
+	"kursovaya_backend/internal/service"
+	"kursovaya_backend/pkg/utils"
 )
 
-func SetupRoutes(r *gin.Engine, cfg *config.Config) {
+func SetupRoutes(r *gin.Engine, cfg *config.Config, repos *repository.Repositories, mail mailer.Mailer, secretStore secrets.SecretStore, m *metrics.Metrics) {
+	// Метрики HTTP-запросов
+	r.Use(middleware.Metrics(m))
+
 	// Настройка CORS
 	corsConfig := cors.DefaultConfig()
 	// Ограничиваем доступ только с доверенных источников
@@ -27,17 +38,65 @@ func SetupRoutes(r *gin.Engine, cfg *config.Config) {
 		corsConfig.AllowOrigins = []string{"http://localhost:3000", "http://localhost:8080", "http://127.0.0.1:3000", "http://127.0.0.1:8080"}
 	}
 	corsConfig.AllowCredentials = true
-	corsConfig.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization"}
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization", middleware.OrganizationHeader, middleware.APIKeyHeader, utils.RequestIDHeader}
 	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"}
+	corsConfig.ExposeHeaders = []string{
+		middleware.RateLimitLimitHeader, middleware.RateLimitRemainingHeader, middleware.RateLimitResetHeader,
+		middleware.RateLimitPolicyHeader, "Retry-After", utils.RequestIDHeader,
+	}
 	r.Use(cors.New(corsConfig))
+	// IP-адрес и User-Agent клиента для журнала аудита
+	r.Use(middleware.AuditContext())
+
+	// Создаем сервисы
+	storeService := service.NewStoreService(repos, secretStore)
+	productService := service.NewProductService(storeService, repos.Products, repos.SyncRuns, m)
+	mappingService := service.NewMappingService(repos)
+
+	twoFactorService := service.NewTwoFactorService(repos, cfg)
+	accountService := service.NewAccountService(repos, cfg, mail)
+	apiKeyService := service.NewAPIKeyService(repos)
+
+	// Счетчики попыток входа: в памяти для одного экземпляра, в базе - общие для реплик
+	loginAttempts := repository.NewMemoryLoginAttempts()
+	if cfg.LoginAttemptStore == "database" {
+		loginAttempts = repos.LoginAttempts
+	}
+	lockoutService := service.NewLockoutService(loginAttempts, repos.Audit, cfg)
+
+	// Ограничение частоты запросов: корзины в памяти для одного экземпляра, в базе - общие для реплик
+	rateLimitBuckets := repository.NewMemoryRateLimits()
+	if cfg.RateLimitStore == "database" {
+		rateLimitBuckets = repos.RateLimits
+	}
+	rateLimits := middleware.RateLimits{
+		APIKey: ratelimit.Limit{Name: "api", PerMinute: cfg.RateLimitAPIKey, Burst: cfg.RateLimitAPIKeyBurst},
+		User:   ratelimit.Limit{Name: "api", PerMinute: cfg.RateLimitUser, Burst: cfg.RateLimitUserBurst},
+		IP:     ratelimit.Limit{Name: "api", PerMinute: cfg.RateLimitIP, Burst: cfg.RateLimitIPBurst},
+	}
+	// Загрузка товаров обращается к API маркетплейсов токенами пользователя, поэтому ограничена отдельно
+	productsLimit := ratelimit.Limit{Name: "products", PerMinute: cfg.RateLimitProducts, Burst: cfg.RateLimitProductsBurst}
+	limiter := ratelimit.New(rateLimitBuckets, rateLimits.APIKey, rateLimits.User, rateLimits.IP, productsLimit)
+	limitRequests := middleware.RateLimit(limiter, rateLimits)
+	limitProducts := middleware.RateLimit(limiter, middleware.RateLimits{APIKey: productsLimit, User: productsLimit})
 
 	// Создаем хендлеры
-	authHandler := &handlers.AuthHandler{}
-	productHandler := handlers.NewProductHandler()
-	mappingHandler := handlers.NewMappingHandler()
-	adminHandler := &handlers.AdminHandler{}
-	storeHandler := &handlers.StoreHandler{}
-	adminManagementHandler := &handlers.AdminManagementHandler{}
+	authHandler := handlers.NewAuthHandler(service.NewAuthService(repos), service.NewSessionService(repos, cfg), twoFactorService, accountService, lockoutService)
+	accountHandler := handlers.NewAccountHandler(accountService)
+	organizationHandler := handlers.NewOrganizationHandler(service.NewOrganizationService(repos))
+	productHandler := handlers.NewProductHandler(productService)
+	mappingHandler := handlers.NewMappingHandler(mappingService)
+	adminService := service.NewAdminService(repos, cfg)
+	adminHandler := handlers.NewAdminHandler(adminService, twoFactorService, lockoutService)
+	adminAccountHandler := handlers.NewAdminAccountHandler(adminService)
+	userTwoFactorHandler := handlers.NewTwoFactorHandler(twoFactorService, utils.SubjectUser)
+	adminTwoFactorHandler := handlers.NewTwoFactorHandler(twoFactorService, utils.SubjectAdmin)
+	storeHandler := handlers.NewStoreHandler(storeService)
+	adminManagementHandler := handlers.NewAdminManagementHandler(repos, service.NewAdminListService(repos), service.NewUserLifecycleService(repos, storeService), service.NewStatsService(repos, cfg), storeService, twoFactorService)
+	lockoutHandler := handlers.NewLockoutHandler(lockoutService)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	auditHandler := handlers.NewAuditHandler(service.NewAuditService(repos))
+	impersonationHandler := handlers.NewImpersonationHandler(service.NewImpersonationService(repos, cfg))
 
 	// Эндпоинт для проверки состояния (health check) - без версии
 	r.GET("/health", func(c *gin.Context) {
@@ -47,103 +106,167 @@ func SetupRoutes(r *gin.Engine, cfg *config.Config) {
 		})
 	})
 
-	// Публичные маршруты v1
-	publicV1 := r.Group("/api/v1")
-	{
-		publicV1.POST("/auth/register", authHandler.Register)
-		publicV1.POST("/auth/login", authHandler.Login)
-		publicV1.POST("/admin/login", adminHandler.Login) // Добавляем маршрут для аутентификации администратора
+	// Метрики Prometheus на основном порту доступны только с токеном; при
+	// METRICS_ADDR они отдаются на отдельном адресе
+	if cfg.MetricsAddr == "" && cfg.MetricsToken != "" {
+		r.GET("/metrics", gin.WrapH(m.Handler(cfg.MetricsToken)))
 	}
 
-	// Защищенные маршруты v1 (требуют JWT токен)
-	protectedV1 := r.Group("/api/v1")
-	protectedV1.Use(middleware.AuthMiddleware())
-	{
-		protectedV1.GET("/stores", storeHandler.GetStores)
-		protectedV1.POST("/stores", storeHandler.AddStore)
-		protectedV1.DELETE("/stores/:id", storeHandler.DeleteStore)
-		protectedV1.GET("/products", productHandler.GetProducts)
-		protectedV1.GET("/products/saved", productHandler.GetSavedProducts)
-		protectedV1.GET("/mappings", mappingHandler.GetMappings)
-		protectedV1.POST("/mappings", mappingHandler.CreateMapping)
-		protectedV1.DELETE("/mappings/:id", mappingHandler.DeleteMapping)
-	}
+	// Маршруты v1 и пути без версии для обратной совместимости (временно)
+	for _, prefix := range []string{"/api/v1", "/api"} {
+		// Публичные маршруты
+		public := r.Group(prefix)
+		public.Use(limitRequests)
+		{
+			public.POST("/auth/register", authHandler.Register)
+			public.POST("/auth/login", authHandler.Login)
+			public.POST("/auth/refresh", authHandler.Refresh)
+			public.POST("/auth/logout", authHandler.Logout)
+			public.POST("/auth/mfa/verify", authHandler.VerifyMFA)
+			public.POST("/auth/password/forgot", accountHandler.ForgotPassword)
+			public.POST("/auth/password/reset", accountHandler.ResetPassword)
+			public.POST("/auth/email/verify", accountHandler.VerifyEmail)
+			public.POST("/admin/login", adminHandler.Login) // Добавляем маршрут для аутентификации администратора
+			public.POST("/admin/login/mfa", adminHandler.VerifyMFA)
+			public.POST("/admin/bootstrap", adminAccountHandler.Bootstrap)
+		}
 
-	// Админ-маршруты v1 (требуют аутентификации администратора)
-	adminV1 := r.Group("/api/v1/admin")
-	adminV1.Use(middleware.AdminAuthMiddleware())
-	{
-		// Статистика
-		adminV1.GET("/stats", adminManagementHandler.GetStats)
-
-		// Управление пользователями
-		adminV1.GET("/users", adminManagementHandler.GetUsers)
-		adminV1.GET("/users/:id", adminManagementHandler.GetUser)
-		adminV1.DELETE("/users/:id", adminManagementHandler.DeleteUser)
-
-		// Управление магазинами
-		adminV1.GET("/stores", adminManagementHandler.GetStores)
-		adminV1.GET("/stores/:id", adminManagementHandler.GetStore)
-		adminV1.DELETE("/stores/:id", adminManagementHandler.DeleteStore)
-
-		// Управление товарами
-		adminV1.GET("/products", adminManagementHandler.GetProducts)
-		adminV1.GET("/products/:id", adminManagementHandler.GetProduct)
-		adminV1.DELETE("/products/:id", adminManagementHandler.DeleteProduct)
-
-		// Управление сопоставлениями
-		adminV1.GET("/mappings", adminManagementHandler.GetMappings)
-		adminV1.GET("/mappings/:id", adminManagementHandler.GetMapping)
-		adminV1.DELETE("/mappings/:id", adminManagementHandler.DeleteMapping)
-	}
+		// Защищенные маршруты управления учетной записью (требуют JWT токен, API-ключи не принимаются)
+		protected := r.Group(prefix)
+		protected.Use(
+			middleware.AuthMiddleware(repos.Sessions, repos.Users, apiKeyService),
+			limitRequests,
+			middleware.RequireSession(),
+			middleware.ImpersonationGuard(false),
+		)
+		{
+			protected.POST("/auth/email/resend", accountHandler.ResendVerification)
 
-	// Пути без версии для обратной совместимости (временно)
-	public := r.Group("/api")
-	{
-		public.POST("/auth/register", authHandler.Register)
-		public.POST("/auth/login", authHandler.Login)
-		public.POST("/admin/login", adminHandler.Login) // Добавляем маршрут для аутентификации администратора
-	}
+			// Журнал действий пользователя и действий над его учетной записью
+			protected.GET("/me/activity", auditHandler.GetMyActivity)
 
-	// Защищенные маршруты (требуют JWT токен) - для обратной совместимости (временно)
-	protected := r.Group("/api")
-	protected.Use(middleware.AuthMiddleware())
-	{
-		protected.GET("/stores", storeHandler.GetStores)
-		protected.POST("/stores", storeHandler.AddStore)
-		protected.DELETE("/stores/:id", storeHandler.DeleteStore)
-		protected.GET("/products", productHandler.GetProducts)
-		protected.GET("/products/saved", productHandler.GetSavedProducts)
-		protected.GET("/mappings", mappingHandler.GetMappings)
-		protected.POST("/mappings", mappingHandler.CreateMapping)
-		protected.DELETE("/mappings/:id", mappingHandler.DeleteMapping)
-	}
+			// Персональные API-ключи
+			protected.GET("/api-keys", apiKeyHandler.GetAPIKeys)
+			protected.POST("/api-keys", apiKeyHandler.CreateAPIKey)
+			protected.DELETE("/api-keys/:id", apiKeyHandler.RevokeAPIKey)
+
+			// Двухфакторная аутентификация пользователя
+			protected.GET("/auth/2fa", userTwoFactorHandler.GetStatus)
+			protected.POST("/auth/2fa/setup", userTwoFactorHandler.Setup)
+			protected.POST("/auth/2fa/confirm", userTwoFactorHandler.Confirm)
+			protected.POST("/auth/2fa/disable", userTwoFactorHandler.Disable)
+			protected.POST("/auth/2fa/recovery-codes", userTwoFactorHandler.RegenerateRecoveryCodes)
+
+			// Организации: права участника проверяет сервис
+			protected.GET("/organizations", organizationHandler.GetOrganizations)
+			protected.POST("/organizations", organizationHandler.CreateOrganization)
+			protected.GET("/organizations/:id/members", organizationHandler.GetMembers)
+			protected.POST("/organizations/:id/invitations", organizationHandler.InviteMember)
+			protected.PUT("/organizations/:id/members/:userId", organizationHandler.SetMemberRole)
+			protected.DELETE("/organizations/:id/members/:userId", organizationHandler.RemoveMember)
+			protected.POST("/invitations/accept", organizationHandler.AcceptInvitation)
+		}
 
-	// Админ-маршруты (требуют аутентификации администратора) - для обратной совместимости (временно)
-	admin := r.Group("/api/admin")
-	admin.Use(middleware.AdminAuthMiddleware())
-	{
-		// Статистика
-		admin.GET("/stats", adminManagementHandler.GetStats)
-
-		// Управление пользователями
-		admin.GET("/users", adminManagementHandler.GetUsers)
-		admin.GET("/users/:id", adminManagementHandler.GetUser)
-		admin.DELETE("/users/:id", adminManagementHandler.DeleteUser)
-
-		// Управление магазинами
-		admin.GET("/stores", adminManagementHandler.GetStores)
-		admin.GET("/stores/:id", adminManagementHandler.GetStore)
-		admin.DELETE("/stores/:id", adminManagementHandler.DeleteStore)
-
-		// Управление товарами
-		admin.GET("/products", adminManagementHandler.GetProducts)
-		admin.GET("/products/:id", adminManagementHandler.GetProduct)
-		admin.DELETE("/products/:id", adminManagementHandler.DeleteProduct)
-
-		// Управление сопоставлениями
-		admin.GET("/mappings", adminManagementHandler.GetMappings)
-		admin.GET("/mappings/:id", adminManagementHandler.GetMapping)
-		admin.DELETE("/mappings/:id", adminManagementHandler.DeleteMapping)
+		// Завершение входа администратора от имени пользователя доступно и при входе только для чтения
+		impersonation := r.Group(prefix)
+		impersonation.Use(middleware.AuthMiddleware(repos.Sessions, repos.Users, nil), limitRequests)
+		{
+			impersonation.POST("/impersonation/end", impersonationHandler.EndImpersonation)
+		}
+
+		// Данные активной организации (заголовок X-Organization-ID или личная организация).
+		// Доступны и по API-ключу в пределах его разрешений.
+		workspace := r.Group(prefix)
+		workspace.Use(
+			middleware.AuthMiddleware(repos.Sessions, repos.Users, apiKeyService),
+			limitRequests,
+			middleware.RequireVerifiedEmail(cfg.RequireEmailVerification),
+			middleware.ImpersonationGuard(true),
+			middleware.OrganizationMiddleware(repos.Organizations),
+		)
+		{
+			workspace.GET("/stores", middleware.RequirePermission(rbac.StoresRead), storeHandler.GetStores)
+			workspace.POST("/stores", middleware.RequirePermission(rbac.StoresWrite), storeHandler.AddStore)
+			workspace.DELETE("/stores/:id", middleware.RequirePermission(rbac.StoresWrite), storeHandler.DeleteStore)
+			workspace.GET("/products", middleware.RequirePermission(rbac.ProductsRead), limitProducts, productHandler.GetProducts)
+			workspace.GET("/products/saved", middleware.RequirePermission(rbac.ProductsRead), productHandler.GetSavedProducts)
+			workspace.GET("/mappings", middleware.RequirePermission(rbac.MappingsRead), mappingHandler.GetMappings)
+			workspace.POST("/mappings", middleware.RequirePermission(rbac.MappingsWrite), mappingHandler.CreateMapping)
+			workspace.DELETE("/mappings/:id", middleware.RequirePermission(rbac.MappingsWrite), mappingHandler.DeleteMapping)
+		}
+
+		// Админ-маршруты (требуют аутентификации администратора и разрешения его роли)
+		adminAuth := r.Group(prefix + "/admin")
+		adminAuth.Use(middleware.AdminAuthMiddleware(repos.Admins))
+		{
+			// Смена временного пароля и настройка 2FA доступны до остальных маршрутов
+			adminAuth.POST("/password", adminAccountHandler.ChangePassword)
+
+			// Двухфакторная аутентификация администратора доступна и до ее подключения
+			adminAuth.GET("/2fa", adminTwoFactorHandler.GetStatus)
+			adminAuth.POST("/2fa/setup", adminTwoFactorHandler.Setup)
+			adminAuth.POST("/2fa/confirm", adminTwoFactorHandler.Confirm)
+			adminAuth.POST("/2fa/disable", adminTwoFactorHandler.Disable)
+			adminAuth.POST("/2fa/recovery-codes", adminTwoFactorHandler.RegenerateRecoveryCodes)
+		}
+
+		// Остальные админ-маршруты требуют смененного временного пароля
+		// и подключенной 2FA, если она обязательна
+		admin := adminAuth.Group("")
+		admin.Use(middleware.RequireAdminPasswordChanged(), middleware.RequireAdminTwoFactor(repos.TwoFactor, cfg.AdminRequire2FA))
+		{
+			// Статистика
+			admin.GET("/stats", middleware.RequirePermission(rbac.AdminStatsRead), adminManagementHandler.GetStats)
+
+			// Управление пользователями
+			admin.GET("/users", middleware.RequirePermission(rbac.AdminUsersRead), adminManagementHandler.GetUsers)
+			admin.GET("/users/export", middleware.RequirePermission(rbac.AdminUsersRead), adminManagementHandler.ExportUsers)
+			admin.GET("/users/:id", middleware.RequirePermission(rbac.AdminUsersRead), adminManagementHandler.GetUser)
+			admin.POST("/users/:id/suspend", middleware.RequirePermission(rbac.AdminUsersWrite), adminManagementHandler.SuspendUser)
+			admin.POST("/users/:id/reactivate", middleware.RequirePermission(rbac.AdminUsersWrite), adminManagementHandler.ReactivateUser)
+			admin.DELETE("/users/:id", middleware.RequirePermission(rbac.AdminUsersDelete), adminManagementHandler.DeleteUser)
+			admin.POST("/users/:id/erase", middleware.RequirePermission(rbac.AdminUsersDelete), adminManagementHandler.EraseUser)
+			admin.POST("/users/:id/impersonate", middleware.RequirePermission(rbac.AdminUsersImpersonate), impersonationHandler.Impersonate)
+			admin.DELETE("/users/:id/2fa", middleware.RequirePermission(rbac.AdminUsersWrite), adminManagementHandler.ResetUserTwoFactor)
+
+			// Учетные записи администраторов
+			admin.GET("/admins", middleware.RequirePermission(rbac.AdminAdminsRead), adminAccountHandler.GetAdmins)
+			admin.POST("/admins", middleware.RequirePermission(rbac.AdminAdminsWrite), adminAccountHandler.CreateAdmin)
+			admin.GET("/admins/:id", middleware.RequirePermission(rbac.AdminAdminsRead), adminAccountHandler.GetAdmin)
+			admin.PUT("/admins/:id/password", middleware.RequirePermission(rbac.AdminAdminsWrite), adminAccountHandler.ResetPassword)
+			admin.POST("/admins/:id/disable", middleware.RequirePermission(rbac.AdminAdminsWrite), adminAccountHandler.Disable)
+			admin.POST("/admins/:id/enable", middleware.RequirePermission(rbac.AdminAdminsWrite), adminAccountHandler.Enable)
+			admin.DELETE("/admins/:id", middleware.RequirePermission(rbac.AdminAdminsWrite), adminAccountHandler.DeleteAdmin)
+
+			// Журнал аудита
+			admin.GET("/audit", middleware.RequirePermission(rbac.AdminAuditRead), auditHandler.GetAuditEvents)
+
+			// Блокировки входа после неудачных попыток
+			admin.GET("/lockouts", middleware.RequirePermission(rbac.AdminUsersRead), lockoutHandler.GetLockouts)
+			admin.POST("/lockouts/unlock", middleware.RequirePermission(rbac.AdminUsersWrite), lockoutHandler.Unlock)
+
+			// Управление магазинами
+			admin.GET("/stores", middleware.RequirePermission(rbac.AdminStoresRead), adminManagementHandler.GetStores)
+			admin.GET("/stores/export", middleware.RequirePermission(rbac.AdminStoresRead), adminManagementHandler.ExportStores)
+			admin.GET("/stores/:id", middleware.RequirePermission(rbac.AdminStoresRead), adminManagementHandler.GetStore)
+			admin.DELETE("/stores/:id", middleware.RequirePermission(rbac.AdminStoresDelete), adminManagementHandler.DeleteStore)
+
+			// Управление товарами
+			admin.GET("/products", middleware.RequirePermission(rbac.AdminProductsRead), adminManagementHandler.GetProducts)
+			admin.GET("/products/export", middleware.RequirePermission(rbac.AdminProductsRead), adminManagementHandler.ExportProducts)
+			admin.GET("/products/:id", middleware.RequirePermission(rbac.AdminProductsRead), adminManagementHandler.GetProduct)
+			admin.DELETE("/products/:id", middleware.RequirePermission(rbac.AdminProductsDelete), adminManagementHandler.DeleteProduct)
+
+			// Управление сопоставлениями
+			admin.GET("/mappings", middleware.RequirePermission(rbac.AdminMappingsRead), adminManagementHandler.GetMappings)
+			admin.GET("/mappings/export", middleware.RequirePermission(rbac.AdminMappingsRead), adminManagementHandler.ExportMappings)
+			admin.GET("/mappings/:id", middleware.RequirePermission(rbac.AdminMappingsRead), adminManagementHandler.GetMapping)
+			admin.DELETE("/mappings/:id", middleware.RequirePermission(rbac.AdminMappingsDelete), adminManagementHandler.DeleteMapping)
+
+			// Роли и разрешения
+			admin.GET("/roles", middleware.RequirePermission(rbac.AdminRolesRead), adminManagementHandler.GetRoles)
+			admin.PUT("/users/:id/role", middleware.RequirePermission(rbac.AdminRolesWrite), adminManagementHandler.SetUserRole)
+			admin.PUT("/admins/:id/role", middleware.RequirePermission(rbac.AdminRolesWrite), adminAccountHandler.SetRole)
+		}
 	}
-}
\ No newline at end of file
+}
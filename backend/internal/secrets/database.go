@@ -0,0 +1,28 @@
+package secrets
+
+import (
+	"context"
+
+	"kursovaya_backend/pkg/utils"
+)
+
+// DatabaseStore шифрует значения ключами ENCRYPTION_KEYS: ссылка и есть
+// шифротекст, который сохраняется в таблице stores
+type DatabaseStore struct{}
+
+func NewDatabaseStore() *DatabaseStore {
+	return &DatabaseStore{}
+}
+
+func (s *DatabaseStore) Put(ctx context.Context, value string) (string, error) {
+	return utils.EncryptString(value)
+}
+
+func (s *DatabaseStore) Get(ctx context.Context, ref string) (string, error) {
+	return utils.DecryptString(ref)
+}
+
+// Delete ничего не делает: шифротекст удаляется вместе со строкой магазина
+func (s *DatabaseStore) Delete(ctx context.Context, ref string) error {
+	return nil
+}
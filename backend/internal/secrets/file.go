@@ -0,0 +1,106 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"kursovaya_backend/pkg/utils"
+)
+
+// fileNamePattern допускает только имена файлов без каталогов. Имена с точки
+// пропускаются: Kubernetes хранит в смонтированном каталоге служебные ..data.
+var fileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*$`)
+
+// FileStore хранит каждое значение в отдельном файле каталога - так же, как
+// Kubernetes монтирует секреты. Ссылка имеет вид "file:<имя файла>".
+// Значения, сохраненные через Put, шифруются ключами ENCRYPTION_KEYS, как в
+// DatabaseStore, поэтому на диске токены не лежат в открытом виде; для этого
+// каталог должен быть доступен на запись. Значения, подготовленные заранее
+// (смонтированные секреты), читаются как есть и из каталога только для чтения.
+type FileStore struct {
+	dir string
+}
+
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{dir: dir}
+}
+
+func (s *FileStore) Put(ctx context.Context, value string) (string, error) {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return "", err
+	}
+	name, err := newName()
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := utils.EncryptString(value)
+	if err != nil {
+		return "", err
+	}
+
+	// Пишем во временный файл и переименовываем, чтобы читатели не увидели
+	// частично записанное значение
+	tmp, err := os.CreateTemp(s.dir, ".tmp-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(ciphertext); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.dir, name)); err != nil {
+		return "", err
+	}
+	return fileScheme + name, nil
+}
+
+func (s *FileStore) Get(ctx context.Context, ref string) (string, error) {
+	path, err := s.path(ref)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	// Файлы, созданные из kubectl create secret --from-file, часто
+	// заканчиваются переводом строки
+	value := strings.TrimRight(string(data), "\r\n")
+	// Зашифрованы только значения, сохраненные через Put
+	if utils.IsCiphertext(value) {
+		return utils.DecryptString(value)
+	}
+	return value, nil
+}
+
+func (s *FileStore) Delete(ctx context.Context, ref string) error {
+	path, err := s.path(ref)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// path возвращает путь к файлу по ссылке, не выходя за пределы каталога
+func (s *FileStore) path(ref string) (string, error) {
+	name, ok := strings.CutPrefix(ref, fileScheme)
+	if !ok || !fileNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid file secret reference %q", ref)
+	}
+	return filepath.Join(s.dir, name), nil
+}
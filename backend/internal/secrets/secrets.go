@@ -0,0 +1,127 @@
+// Package secrets хранит токены маркетплейсов: зашифрованными в базе данных,
+// в HashiCorp Vault или в файлах каталога, смонтированного из секретов Kubernetes
+package secrets
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"kursovaya_backend/internal/config"
+)
+
+// SecretStore хранит секретные значения. В таблицу stores записывается
+// только ссылка, которую возвращает Put.
+type SecretStore interface {
+	// Put сохраняет значение и возвращает ссылку на него
+	Put(ctx context.Context, value string) (string, error)
+	// Get возвращает значение по ссылке
+	Get(ctx context.Context, ref string) (string, error)
+	// Delete удаляет значение; удаление отсутствующего значения не ошибка
+	Delete(ctx context.Context, ref string) error
+}
+
+// Хранилища секретов, выбираются переменной окружения SECRET_STORE
+const (
+	DriverDatabase = "database"
+	DriverVault    = "vault"
+	DriverFile     = "file"
+)
+
+// Префиксы ссылок на значения во внешних хранилищах. Ссылка без префикса -
+// шифротекст, сохраненный прямо в базе данных.
+const (
+	vaultScheme = "vault:"
+	fileScheme  = "file:"
+)
+
+// ErrNotFound возвращается, если значения по ссылке нет
+var ErrNotFound = errors.New("secret not found")
+
+// New создает хранилище по настройкам. Новые значения сохраняются в
+// хранилище SECRET_STORE, а прочитать можно значение из любого настроенного
+// хранилища: после смены SECRET_STORE старые магазины продолжают работать.
+func New(cfg *config.Config) (SecretStore, error) {
+	r := &router{
+		database: NewDatabaseStore(),
+		file:     NewFileStore(cfg.SecretsDir),
+	}
+	if cfg.VaultAddr != "" {
+		r.vault = NewVaultStore(cfg.VaultAddr, cfg.VaultToken, cfg.VaultNamespace, cfg.VaultKVMount, cfg.VaultPathPrefix)
+	}
+
+	switch cfg.SecretStore {
+	case DriverDatabase, "":
+		r.primary = r.database
+	case DriverFile:
+		r.primary = r.file
+	case DriverVault:
+		if r.vault == nil || cfg.VaultToken == "" {
+			return nil, fmt.Errorf("VAULT_ADDR and VAULT_TOKEN are required for SECRET_STORE=vault")
+		}
+		r.primary = r.vault
+	default:
+		return nil, fmt.Errorf("unknown SECRET_STORE %q, use database, vault or file", cfg.SecretStore)
+	}
+	return r, nil
+}
+
+// External сообщает, что ссылка указывает на значение во внешнем хранилище,
+// а не на шифротекст в базе данных
+func External(ref string) bool {
+	return strings.HasPrefix(ref, vaultScheme) || strings.HasPrefix(ref, fileScheme)
+}
+
+// router сохраняет значения в основное хранилище и читает из того,
+// на которое указывает префикс ссылки
+type router struct {
+	primary  SecretStore
+	database SecretStore
+	vault    SecretStore
+	file     SecretStore
+}
+
+func (r *router) Put(ctx context.Context, value string) (string, error) {
+	return r.primary.Put(ctx, value)
+}
+
+func (r *router) Get(ctx context.Context, ref string) (string, error) {
+	store, err := r.route(ref)
+	if err != nil {
+		return "", err
+	}
+	return store.Get(ctx, ref)
+}
+
+func (r *router) Delete(ctx context.Context, ref string) error {
+	store, err := r.route(ref)
+	if err != nil {
+		return err
+	}
+	return store.Delete(ctx, ref)
+}
+
+func (r *router) route(ref string) (SecretStore, error) {
+	switch {
+	case strings.HasPrefix(ref, vaultScheme):
+		if r.vault == nil {
+			return nil, fmt.Errorf("secret is stored in Vault but VAULT_ADDR is not configured")
+		}
+		return r.vault, nil
+	case strings.HasPrefix(ref, fileScheme):
+		return r.file, nil
+	}
+	return r.database, nil
+}
+
+// newName возвращает случайное имя для нового значения
+func newName() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "store-" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,206 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"kursovaya_backend/internal/config"
+	"kursovaya_backend/pkg/utils"
+)
+
+// fakeVault - заглушка API KV v2 с одной точкой монтирования "secret"
+type fakeVault struct {
+	mu      sync.Mutex
+	secrets map[string]string
+}
+
+func newFakeVault(t *testing.T) *httptest.Server {
+	vault := &fakeVault{secrets: make(map[string]string)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		vault.mu.Lock()
+		defer vault.mu.Unlock()
+
+		if path, ok := strings.CutPrefix(r.URL.Path, "/v1/secret/data/"); ok {
+			switch r.Method {
+			case http.MethodPost:
+				var body struct {
+					Data map[string]string `json:"data"`
+				}
+				json.NewDecoder(r.Body).Decode(&body)
+				vault.secrets[path] = body.Data["value"]
+				w.Write([]byte(`{"data":{"version":1}}`))
+				return
+			case http.MethodGet:
+				value, ok := vault.secrets[path]
+				if !ok {
+					w.WriteHeader(http.StatusNotFound)
+					w.Write([]byte(`{"errors":[]}`))
+					return
+				}
+				json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"data": map[string]string{"value": value}}})
+				return
+			}
+		}
+		if path, ok := strings.CutPrefix(r.URL.Path, "/v1/secret/metadata/"); ok && r.Method == http.MethodDelete {
+			if _, ok := vault.secrets[path]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			delete(vault.secrets, path)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// checkStore проверяет полный цикл значения: сохранение, чтение, удаление
+func checkStore(t *testing.T, store SecretStore, scheme string) {
+	t.Helper()
+	ctx := context.Background()
+
+	ref, err := store.Put(ctx, "wb-token-123")
+	if err != nil {
+		t.Fatalf("Ошибка сохранения: %v", err)
+	}
+	if !strings.HasPrefix(ref, scheme) || strings.Contains(ref, "wb-token-123") {
+		t.Errorf("Ожидается ссылка с префиксом %q без значения, получено %q", scheme, ref)
+	}
+	if other, _ := store.Put(ctx, "wb-token-123"); other == ref {
+		t.Error("Каждое значение должно получать свою ссылку")
+	}
+	if value, err := store.Get(ctx, ref); err != nil || value != "wb-token-123" {
+		t.Errorf("Ожидается сохраненное значение, получено %q, %v", value, err)
+	}
+
+	if err := store.Delete(ctx, ref); err != nil {
+		t.Fatalf("Ошибка удаления: %v", err)
+	}
+	if err := store.Delete(ctx, ref); err != nil {
+		t.Errorf("Повторное удаление не должно быть ошибкой, получено %v", err)
+	}
+	if _, err := store.Get(ctx, ref); err != ErrNotFound {
+		t.Errorf("Ожидается ErrNotFound для удаленного значения, получено %v", err)
+	}
+}
+
+func TestVaultStore(t *testing.T) {
+	server := newFakeVault(t)
+	checkStore(t, NewVaultStore(server.URL+"/", "root", "", "secret", "/marketplace-tracker/stores/"), "vault:marketplace-tracker/stores/store-")
+
+	store := NewVaultStore(server.URL, "wrong", "", "secret", "stores")
+	if _, err := store.Put(context.Background(), "value"); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("Ожидается ошибка доступа Vault, получено %v", err)
+	}
+	for _, ref := range []string{"vault:", "vault:/abs", "vault:a/../b", "file:name"} {
+		if _, err := store.Get(context.Background(), ref); err == nil || err == ErrNotFound {
+			t.Errorf("Ожидается ошибка некорректной ссылки %q, получено %v", ref, err)
+		}
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	keyring, err := utils.NewKeyring("test", map[string]string{"test": "test-encryption-key"}, "")
+	if err != nil {
+		t.Fatalf("Ошибка создания ключей: %v", err)
+	}
+	utils.SetEncryptionKeyring(keyring)
+	dir := t.TempDir()
+	store := NewFileStore(filepath.Join(dir, "secrets"))
+	checkStore(t, store, "file:store-")
+
+	// Сохраненное значение зашифровано на диске
+	ref, err := store.Put(context.Background(), "plain-token")
+	if err != nil {
+		t.Fatalf("Ошибка сохранения: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "secrets", strings.TrimPrefix(ref, "file:")))
+	if strings.Contains(string(data), "plain-token") || !strings.HasPrefix(string(data), "v1:test:") {
+		t.Errorf("Ожидается шифротекст в файле, получено %q", data)
+	}
+
+	// Значение, смонтированное из секрета Kubernetes, читается без перевода строки
+	os.WriteFile(filepath.Join(dir, "secrets", "wb-main"), []byte("mounted-token\n"), 0o600)
+	if value, err := store.Get(context.Background(), "file:wb-main"); err != nil || value != "mounted-token" {
+		t.Errorf("Ожидается смонтированное значение, получено %q, %v", value, err)
+	}
+	os.WriteFile(filepath.Join(dir, "outside"), []byte("secret"), 0o600)
+	for _, ref := range []string{"file:../outside", "file:..data", "file:a/b", "file:"} {
+		if _, err := store.Get(context.Background(), ref); err == nil || err == ErrNotFound {
+			t.Errorf("Ожидается ошибка некорректной ссылки %q, получено %v", ref, err)
+		}
+	}
+}
+
+// Тест выбора хранилища: новые значения - в SECRET_STORE, чтение - по префиксу ссылки
+func TestNewRoutesByReference(t *testing.T) {
+	keyring, err := utils.NewKeyring("test", map[string]string{"test": "test-encryption-key"}, "")
+	if err != nil {
+		t.Fatalf("Ошибка создания ключей: %v", err)
+	}
+	utils.SetEncryptionKeyring(keyring)
+	ctx := context.Background()
+	server := newFakeVault(t)
+	cfg := &config.Config{
+		SecretStore:     DriverDatabase,
+		SecretsDir:      t.TempDir(),
+		VaultAddr:       server.URL,
+		VaultToken:      "root",
+		VaultKVMount:    "secret",
+		VaultPathPrefix: "stores",
+	}
+
+	database, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Ошибка создания хранилища: %v", err)
+	}
+	databaseRef, _ := database.Put(ctx, "db-token")
+	if External(databaseRef) || !strings.HasPrefix(databaseRef, "v1:test:") {
+		t.Errorf("Ожидается шифротекст в базе данных, получено %q", databaseRef)
+	}
+
+	cfg.SecretStore = DriverVault
+	vault, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Ошибка создания хранилища: %v", err)
+	}
+	vaultRef, _ := vault.Put(ctx, "vault-token")
+	if !External(vaultRef) || !strings.HasPrefix(vaultRef, "vault:stores/") {
+		t.Errorf("Ожидается ссылка на Vault, получено %q", vaultRef)
+	}
+
+	// После смены хранилища старые значения по-прежнему читаются
+	for ref, want := range map[string]string{databaseRef: "db-token", vaultRef: "vault-token"} {
+		if value, err := vault.Get(ctx, ref); err != nil || value != want {
+			t.Errorf("Ссылка %q: ожидается %q, получено %q, %v", ref, want, value, err)
+		}
+	}
+
+	cfg.VaultAddr = ""
+	if _, err := New(cfg); err == nil {
+		t.Error("Ожидается ошибка для SECRET_STORE=vault без VAULT_ADDR")
+	}
+	cfg.SecretStore = DriverDatabase
+	withoutVault, _ := New(cfg)
+	if _, err := withoutVault.Get(ctx, vaultRef); err == nil {
+		t.Error("Ожидается ошибка чтения из ненастроенного Vault")
+	}
+	cfg.SecretStore = "s3"
+	if _, err := New(cfg); err == nil {
+		t.Error("Ожидается ошибка для неизвестного SECRET_STORE")
+	}
+}
@@ -0,0 +1,151 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// VaultStore хранит значения в HashiCorp Vault, в движке KV версии 2.
+// Ссылка имеет вид "vault:<путь>", путь отсчитывается от точки монтирования.
+type VaultStore struct {
+	addr      string
+	token     string
+	namespace string
+	mount     string
+	prefix    string
+	client    *http.Client
+}
+
+// NewVaultStore создает хранилище Vault. mount - точка монтирования KV
+// (например, "secret"), prefix - каталог внутри нее для новых значений.
+func NewVaultStore(addr, token, namespace, mount, prefix string) *VaultStore {
+	return &VaultStore{
+		addr:      strings.TrimRight(addr, "/"),
+		token:     token,
+		namespace: namespace,
+		mount:     strings.Trim(mount, "/"),
+		prefix:    strings.Trim(prefix, "/"),
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// vaultSecret - тело секрета KV v2
+type vaultSecret struct {
+	Data struct {
+		Data map[string]string `json:"data"`
+	} `json:"data"`
+}
+
+func (s *VaultStore) Put(ctx context.Context, value string) (string, error) {
+	name, err := newName()
+	if err != nil {
+		return "", err
+	}
+	path := name
+	if s.prefix != "" {
+		path = s.prefix + "/" + name
+	}
+
+	body := map[string]interface{}{"data": map[string]string{"value": value}}
+	if err := s.do(ctx, http.MethodPost, "data/"+path, body, nil); err != nil {
+		return "", err
+	}
+	return vaultScheme + path, nil
+}
+
+func (s *VaultStore) Get(ctx context.Context, ref string) (string, error) {
+	path, err := vaultPath(ref)
+	if err != nil {
+		return "", err
+	}
+
+	var secret vaultSecret
+	if err := s.do(ctx, http.MethodGet, "data/"+path, nil, &secret); err != nil {
+		return "", err
+	}
+	value, ok := secret.Data.Data["value"]
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no \"value\" field", path)
+	}
+	return value, nil
+}
+
+// Delete удаляет секрет вместе со всеми версиями
+func (s *VaultStore) Delete(ctx context.Context, ref string) error {
+	path, err := vaultPath(ref)
+	if err != nil {
+		return err
+	}
+	err = s.do(ctx, http.MethodDelete, "metadata/"+path, nil, nil)
+	if err == ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// do выполняет запрос к API KV v2 и разбирает ответ в out
+func (s *VaultStore) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.addr+"/v1/"+s.mount+"/"+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", s.token)
+	if s.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", s.namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var vaultErr struct {
+			Errors []string `json:"errors"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&vaultErr)
+		return fmt.Errorf("vault %s %s: status %d: %s", method, path, resp.StatusCode, strings.Join(vaultErr.Errors, "; "))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// vaultPath возвращает путь секрета по ссылке
+func vaultPath(ref string) (string, error) {
+	path, ok := strings.CutPrefix(ref, vaultScheme)
+	if !ok || path == "" || strings.HasPrefix(path, "/") {
+		return "", fmt.Errorf("invalid vault secret reference %q", ref)
+	}
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return "", fmt.Errorf("invalid vault secret reference %q", ref)
+		}
+	}
+	return path, nil
+}
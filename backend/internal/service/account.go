@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"kursovaya_backend/internal/config"
+	"kursovaya_backend/internal/errors"
+	"kursovaya_backend/internal/mailer"
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/repository"
+	"kursovaya_backend/pkg/utils"
+)
+
+const (
+	// passwordResetTTL - срок действия ссылки для сброса пароля
+	passwordResetTTL = time.Hour
+	// emailVerificationTTL - срок действия ссылки для подтверждения email
+	emailVerificationTTL = 24 * time.Hour
+)
+
+// AccountService отвечает за сброс пароля и подтверждение email по ссылкам из писем
+type AccountService struct {
+	repos  *repository.Repositories
+	cfg    *config.Config
+	mailer mailer.Mailer
+	now    func() time.Time
+	// background запускает отправку письма о сбросе пароля в фоне
+	background func(fn func())
+}
+
+// NewAccountService создает новый сервис учетных записей
+func NewAccountService(repos *repository.Repositories, cfg *config.Config, m mailer.Mailer) *AccountService {
+	return &AccountService{
+		repos:  repos,
+		cfg:    cfg,
+		mailer: m,
+		now:    time.Now,
+		background: func(fn func()) {
+			go fn()
+		},
+	}
+}
+
+// RequestPasswordReset отправляет письмо со ссылкой для сброса пароля. Для
+// неизвестного email ошибка не возвращается, чтобы по ответу нельзя было
+// узнать, зарегистрирован ли адрес. По той же причине письмо отправляется в
+// фоне: иначе ответ для известного адреса приходил бы заметно позже, на
+// время обращения к SMTP-серверу.
+func (s *AccountService) RequestPasswordReset(ctx context.Context, email, language string) error {
+	user, _, err := s.repos.Users.GetCredentials(ctx, email)
+	if err == repository.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return errors.InternalServerError("Ошибка получения пользователя", err.Error())
+	}
+
+	// Письмо отправляется и после завершения запроса; контекст сохраняет
+	// request_id для лога
+	ctx = context.WithoutCancel(ctx)
+	s.background(func() {
+		if err := s.send(ctx, user, models.TokenPurposePasswordReset, language); err != nil {
+			// Ответ не зависит от того, удалось ли отправить письмо
+			slog.ErrorContext(ctx, "Ошибка отправки письма для сброса пароля", "user_id", user.ID, "error", err)
+		}
+	})
+	return nil
+}
+
+// ResetPassword задает новый пароль по токену из письма и завершает все
+// сессии пользователя
+func (s *AccountService) ResetPassword(ctx context.Context, token, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return errors.InternalServerError("Ошибка хеширования пароля", err.Error())
+	}
+
+	err = s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		userToken, err := s.consume(ctx, tx, token, models.TokenPurposePasswordReset)
+		if err != nil {
+			return err
+		}
+		if err := tx.Users.SetPassword(ctx, userToken.UserID, string(hashedPassword)); err != nil {
+			return err
+		}
+		// Письмо со ссылкой пришло на email, значит адрес принадлежит пользователю
+		if err := tx.Users.MarkEmailVerified(ctx, userToken.UserID, s.now()); err != nil {
+			return err
+		}
+		return tx.Sessions.RevokeAllForUser(ctx, userToken.UserID, s.now())
+	})
+	if err != nil {
+		return appError(err, "Ошибка сброса пароля")
+	}
+	return nil
+}
+
+// SendVerification отправляет письмо для подтверждения email пользователя
+func (s *AccountService) SendVerification(ctx context.Context, userID int, language string) error {
+	user, err := s.repos.Users.GetByID(ctx, userID)
+	if err == repository.ErrNotFound {
+		return errors.NotFound("Пользователь не найден", "User does not exist")
+	}
+	if err != nil {
+		return errors.InternalServerError("Ошибка получения пользователя", err.Error())
+	}
+	if user.EmailVerifiedAt != nil {
+		return errors.BadRequest("Email уже подтвержден", "Email is already verified")
+	}
+
+	if err := s.send(ctx, user, models.TokenPurposeEmailVerification, language); err != nil {
+		return errors.InternalServerError("Ошибка отправки письма", err.Error())
+	}
+	return nil
+}
+
+// VerifyEmail подтверждает email по токену из письма
+func (s *AccountService) VerifyEmail(ctx context.Context, token string) error {
+	err := s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		userToken, err := s.consume(ctx, tx, token, models.TokenPurposeEmailVerification)
+		if err != nil {
+			return err
+		}
+		return tx.Users.MarkEmailVerified(ctx, userToken.UserID, s.now())
+	})
+	if err != nil {
+		return appError(err, "Ошибка подтверждения email")
+	}
+	return nil
+}
+
+// send выпускает новый токен, отменяя прежние токены того же назначения,
+// и отправляет письмо со ссылкой
+func (s *AccountService) send(ctx context.Context, user *models.User, purpose, language string) error {
+	token, err := utils.GenerateSignedToken(purpose)
+	if err != nil {
+		return err
+	}
+
+	ttl, page, templateName := emailVerificationTTL, "/verify-email", mailer.TemplateEmailVerification
+	if purpose == models.TokenPurposePasswordReset {
+		ttl, page, templateName = passwordResetTTL, "/reset-password", mailer.TemplatePasswordReset
+	}
+
+	now := s.now()
+	userToken := &models.UserToken{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		ExpiresAt: now.Add(ttl),
+	}
+	err = s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		if err := tx.UserTokens.InvalidateForUser(ctx, user.ID, purpose, now); err != nil {
+			return err
+		}
+		return tx.UserTokens.Create(ctx, userToken)
+	})
+	if err != nil {
+		return err
+	}
+
+	msg, err := mailer.Render(templateName, language, user.Email, mailer.TemplateData{
+		Email:     user.Email,
+		Link:      s.cfg.AppURL + page + "?token=" + url.QueryEscape(token),
+		ExpiresAt: userToken.ExpiresAt,
+	})
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, msg)
+}
+
+// consume проверяет токен из письма и помечает его использованным
+func (s *AccountService) consume(ctx context.Context, tx *repository.Repositories, token, purpose string) (*models.UserToken, error) {
+	invalid := errors.BadRequest("Недействительная ссылка", "Token is invalid")
+	if !utils.VerifySignedToken(token, purpose) {
+		return nil, invalid
+	}
+
+	userToken, err := tx.UserTokens.GetByTokenHash(ctx, hashToken(token))
+	if err == repository.ErrNotFound {
+		return nil, invalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if userToken.Purpose != purpose {
+		return nil, invalid
+	}
+	if userToken.UsedAt != nil {
+		return nil, errors.BadRequest("Ссылка уже использована", "Token has already been used")
+	}
+	if s.now().After(userToken.ExpiresAt) {
+		return nil, errors.BadRequest("Срок действия ссылки истек", "Token has expired")
+	}
+
+	err = tx.UserTokens.MarkUsed(ctx, userToken.ID, s.now())
+	if err == repository.ErrNotFound {
+		return nil, errors.BadRequest("Ссылка уже использована", "Token has already been used")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return userToken, nil
+}
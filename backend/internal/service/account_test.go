@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"kursovaya_backend/internal/config"
+	"kursovaya_backend/internal/mailer"
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/repository"
+	"kursovaya_backend/pkg/utils"
+)
+
+// recordingMailer запоминает отправленные письма
+type recordingMailer struct {
+	sent []mailer.Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// token извлекает токен из ссылки в последнем письме
+func (m *recordingMailer) token(t *testing.T) string {
+	t.Helper()
+	if len(m.sent) == 0 {
+		t.Fatal("Ожидается отправленное письмо")
+	}
+	body := m.sent[len(m.sent)-1].Body
+	start := strings.Index(body, "token=")
+	if start < 0 {
+		t.Fatalf("В письме нет ссылки с токеном:\n%s", body)
+	}
+	token, err := url.QueryUnescape(strings.Fields(body[start+len("token="):])[0])
+	if err != nil {
+		t.Fatalf("Некорректный токен в ссылке: %v", err)
+	}
+	return token
+}
+
+// newTestAccountService создает сервис с управляемыми часами и одного пользователя
+func newTestAccountService(t *testing.T) (*AccountService, *repository.Repositories, *recordingMailer, *time.Time) {
+	t.Helper()
+	utils.SetJWTKey("test-secret-key-with-at-least-32-characters")
+
+	repos := repository.NewMemory()
+	if _, err := repos.Users.Create(context.Background(), "account@example.com", "hash"); err != nil {
+		t.Fatalf("Ошибка создания пользователя: %v", err)
+	}
+
+	mail := &recordingMailer{}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	service := NewAccountService(repos, &config.Config{AppURL: "http://app.test"}, mail)
+	service.now = func() time.Time { return now }
+	// Письма отправляются сразу, чтобы тесты видели их после вызова
+	service.background = func(fn func()) { fn() }
+	return service, repos, mail, &now
+}
+
+func TestPasswordReset(t *testing.T) {
+	service, repos, mail, _ := newTestAccountService(t)
+	ctx := context.Background()
+	repos.Sessions.Create(ctx, &models.Session{UserID: 1, FamilyID: "family", TokenHash: "hash", ExpiresAt: time.Now().Add(time.Hour)})
+
+	// Для неизвестного адреса ответ тот же, но письмо не отправляется
+	if err := service.RequestPasswordReset(ctx, "unknown@example.com", "ru"); err != nil || len(mail.sent) != 0 {
+		t.Fatalf("Ожидается молчаливый успех для неизвестного email, получено %v, писем %d", err, len(mail.sent))
+	}
+
+	if err := service.RequestPasswordReset(ctx, "account@example.com", "en"); err != nil {
+		t.Fatalf("Ошибка запроса сброса пароля: %v", err)
+	}
+	if msg := mail.sent[0]; msg.To != "account@example.com" || !strings.Contains(msg.Subject, "password reset") ||
+		!strings.Contains(msg.Body, "http://app.test/reset-password?token=") {
+		t.Fatalf("Неожиданное письмо: %+v", msg)
+	}
+	token := mail.token(t)
+
+	if err := service.ResetPassword(ctx, token, "new-password"); err != nil {
+		t.Fatalf("Ошибка сброса пароля: %v", err)
+	}
+	_, hash, _ := repos.Users.GetCredentials(ctx, "account@example.com")
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte("new-password")) != nil {
+		t.Error("Ожидается, что пароль изменен")
+	}
+	if revoked, _ := repos.Sessions.IsFamilyRevoked(ctx, "family"); !revoked {
+		t.Error("Ожидается, что сессии пользователя завершены")
+	}
+
+	// Токен одноразовый
+	expectCode(t, service.ResetPassword(ctx, token, "another-password"), 400)
+}
+
+// blockingMailer отправляет письмо, только когда release закрыт
+type blockingMailer struct {
+	release chan struct{}
+	sent    chan mailer.Message
+}
+
+func (m *blockingMailer) Send(ctx context.Context, msg mailer.Message) error {
+	<-m.release
+	m.sent <- msg
+	return nil
+}
+
+// Тест фоновой отправки: ответ для известного адреса не ждет SMTP-сервер
+func TestPasswordResetDoesNotWaitForMail(t *testing.T) {
+	repos := repository.NewMemory()
+	repos.Users.Create(context.Background(), "account@example.com", "hash")
+	mail := &blockingMailer{release: make(chan struct{}), sent: make(chan mailer.Message, 1)}
+	service := NewAccountService(repos, &config.Config{AppURL: "http://app.test"}, mail)
+
+	if err := service.RequestPasswordReset(context.Background(), "account@example.com", "ru"); err != nil {
+		t.Fatalf("Ошибка запроса сброса пароля: %v", err)
+	}
+	close(mail.release)
+	select {
+	case msg := <-mail.sent:
+		if msg.To != "account@example.com" {
+			t.Errorf("Неожиданный получатель %q", msg.To)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Письмо не отправлено")
+	}
+}
+
+func TestPasswordResetTokenRules(t *testing.T) {
+	service, _, mail, now := newTestAccountService(t)
+	ctx := context.Background()
+
+	service.RequestPasswordReset(ctx, "account@example.com", "ru")
+	first := mail.token(t)
+	service.RequestPasswordReset(ctx, "account@example.com", "ru")
+	second := mail.token(t)
+
+	// Новый запрос отменяет прежнюю ссылку
+	expectCode(t, service.ResetPassword(ctx, first, "new-password"), 400)
+	// Поддельная подпись и чужое назначение отклоняются
+	expectCode(t, service.ResetPassword(ctx, second+"x", "new-password"), 400)
+	expectCode(t, service.VerifyEmail(ctx, second), 400)
+
+	*now = now.Add(passwordResetTTL + time.Minute)
+	expectCode(t, service.ResetPassword(ctx, second, "new-password"), 400)
+}
+
+func TestEmailVerification(t *testing.T) {
+	service, repos, mail, _ := newTestAccountService(t)
+	ctx := context.Background()
+
+	if err := service.SendVerification(ctx, 1, "ru"); err != nil {
+		t.Fatalf("Ошибка отправки письма: %v", err)
+	}
+	if msg := mail.sent[0]; !strings.Contains(msg.Subject, "Подтвердите email") || !strings.Contains(msg.Body, "http://app.test/verify-email?token=") {
+		t.Fatalf("Неожиданное письмо: %+v", msg)
+	}
+
+	if err := service.VerifyEmail(ctx, mail.token(t)); err != nil {
+		t.Fatalf("Ошибка подтверждения email: %v", err)
+	}
+	if user, _ := repos.Users.GetByID(ctx, 1); user.EmailVerifiedAt == nil {
+		t.Error("Ожидается, что email подтвержден")
+	}
+	expectCode(t, service.VerifyEmail(ctx, mail.token(t)), 400)
+	expectCode(t, service.SendVerification(ctx, 1, "ru"), 400)
+}
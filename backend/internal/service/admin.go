@@ -1,87 +1,419 @@
 package service
 
 import (
-	"errors"
-	"log"
-	"math/rand"
-	"os"
+	"context"
+	stderrors "errors"
+	"log/slog"
+	"regexp"
 	"time"
 	"golang.org/x/crypto/bcrypt"
-	"kursovaya_backend/internal/database"
+	"kursovaya_backend/internal/audit"
+	"kursovaya_backend/internal/config"
+	"kursovaya_backend/internal/errors"
 	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/rbac"
+	"kursovaya_backend/internal/repository"
+	"kursovaya_backend/pkg/utils"
 )
 
-func AuthenticateAdmin(username, password string) (*models.Admin, error) {
-	var admin models.Admin
-	var hashedPassword string
+const (
+	// defaultAdminUsername - логин администратора, создаваемого с ADMIN_DEFAULT_PASSWORD
+	defaultAdminUsername = "admin"
+	// minAdminPasswordLength - минимальная длина пароля администратора
+	minAdminPasswordLength = 8
+)
+
+// adminUsernamePattern ограничивает логины администраторов
+var adminUsernamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{3,64}$`)
+
+// AdminService для аутентификации администраторов и управления их учетными записями
+type AdminService struct {
+	repos  *repository.Repositories
+	admins repository.AdminRepository
+	cfg    *config.Config
+	now    func() time.Time
+}
+
+// NewAdminService создает новый сервис администраторов
+func NewAdminService(repos *repository.Repositories, cfg *config.Config) *AdminService {
+	return &AdminService{
+		repos:  repos,
+		admins: repos.Admins,
+		cfg:    cfg,
+		now:    time.Now,
+	}
+}
 
-	err := database.DB.QueryRow("SELECT id, username, password FROM admins WHERE username = $1", username).
-		Scan(&admin.ID, &admin.Username, &hashedPassword)
+func (s *AdminService) AuthenticateAdmin(ctx context.Context, username, password string) (*models.Admin, error) {
+	admin, hashedPassword, err := s.admins.GetCredentials(ctx, username)
 	if err != nil {
-		return nil, errors.New("admin not found")
+		return nil, stderrors.New("admin not found")
 	}
 
 	// Сравниваем пароль
 	err = bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 	if err != nil {
-		return nil, errors.New("invalid password")
+		return nil, stderrors.New("invalid password")
+	}
+
+	// Отключенный администратор не входит даже с верным паролем
+	if admin.DisabledAt != nil {
+		return nil, stderrors.New("admin account is disabled")
+	}
+
+	return admin, nil
+}
+
+// GetAdmin возвращает администратора по ID, например после второго шага входа
+func (s *AdminService) GetAdmin(ctx context.Context, id int) (*models.Admin, error) {
+	admin, err := s.admins.GetByID(ctx, id)
+	if err == repository.ErrNotFound {
+		return nil, errors.NotFound("Admin not found", "Admin does not exist")
+	}
+	if err != nil {
+		return nil, errors.InternalServerError("Error querying admin", err.Error())
+	}
+	return admin, nil
+}
+
+// ListAdmins возвращает всех администраторов
+func (s *AdminService) ListAdmins(ctx context.Context) ([]models.Admin, error) {
+	admins, err := s.admins.List(ctx)
+	if err != nil {
+		return nil, errors.InternalServerError("Ошибка получения администраторов", err.Error())
+	}
+	return admins, nil
+}
+
+// CreateAdmin создает администратора с временным паролем, который нужно
+// сменить при первом входе
+func (s *AdminService) CreateAdmin(ctx context.Context, username, password, role string) (*models.Admin, error) {
+	if !adminUsernamePattern.MatchString(username) {
+		return nil, errors.BadRequest("Некорректный логин администратора", "Username must be 3-64 letters, digits, '.', '_' or '-'")
+	}
+	if !rbac.IsAdminRole(role) {
+		return nil, errors.BadRequest("Неизвестная роль администратора", "Unknown admin role "+role)
+	}
+	hashedPassword, err := hashAdminPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	var admin *models.Admin
+	err = s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		var err error
+		admin, err = tx.Admins.Create(ctx, username, hashedPassword, role, true)
+		if err == repository.ErrDuplicate {
+			return errors.BadRequest("Администратор с таким логином уже существует", "Username is already taken")
+		}
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx.Audit, audit.Entry{Action: "admin_created", TargetType: "admin", TargetID: admin.ID, After: admin})
+	})
+	if err != nil {
+		return nil, appError(err, "Ошибка создания администратора")
+	}
+	return admin, nil
+}
+
+// ChangePassword меняет собственный пароль администратора и снимает
+// требование смены пароля
+func (s *AdminService) ChangePassword(ctx context.Context, adminID int, currentPassword, newPassword string) error {
+	hashedPassword, err := s.admins.GetPasswordHash(ctx, adminID)
+	if err == repository.ErrNotFound {
+		return errors.NotFound("Администратор не найден", "Admin does not exist")
+	}
+	if err != nil {
+		return errors.InternalServerError("Ошибка получения администратора", err.Error())
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(currentPassword)) != nil {
+		return errors.Unauthorized("Неверный текущий пароль", "Current password does not match")
+	}
+	if currentPassword == newPassword {
+		return errors.BadRequest("Новый пароль должен отличаться от текущего", "New password equals the current one")
+	}
+
+	newHash, err := hashAdminPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	err = s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		if err := tx.Admins.SetPassword(ctx, adminID, newHash, false); err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx.Audit, audit.Entry{Action: "admin_password_changed", TargetType: "admin", TargetID: adminID})
+	})
+	if err != nil {
+		return errors.InternalServerError("Ошибка смены пароля", err.Error())
+	}
+	return nil
+}
+
+// ResetPassword задает другому администратору временный пароль, который
+// нужно сменить при следующем входе
+func (s *AdminService) ResetPassword(ctx context.Context, actorID, id int, password string) error {
+	if actorID == id {
+		return errors.Forbidden("Для смены своего пароля укажите текущий пароль", "Use the password change endpoint for your own account")
+	}
+	hashedPassword, err := hashAdminPassword(password)
+	if err != nil {
+		return err
+	}
+	err = s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		if err := tx.Admins.SetPassword(ctx, id, hashedPassword, true); err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx.Audit, audit.Entry{Action: "admin_password_reset", TargetType: "admin", TargetID: id})
+	})
+	if err == repository.ErrNotFound {
+		return errors.NotFound("Администратор не найден", "Admin does not exist")
+	}
+	if err != nil {
+		return errors.InternalServerError("Ошибка смены пароля", err.Error())
 	}
+	return nil
+}
 
-	return &admin, nil
+// SetRole меняет роль другого администратора. Последнего активного
+// суперадминистратора понизить нельзя.
+func (s *AdminService) SetRole(ctx context.Context, actorID, id int, role string) error {
+	if !rbac.IsAdminRole(role) {
+		return errors.BadRequest("Неизвестная роль администратора", "Unknown admin role "+role)
+	}
+	// Свою роль менять нельзя, чтобы не лишить себя доступа
+	if actorID == id {
+		return errors.Forbidden("Нельзя изменить свою роль", "Cannot change your own role")
+	}
+
+	err := s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		admin, err := getAdmin(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if role != rbac.RoleSuperadmin {
+			if err := ensureAnotherSuperadmin(ctx, tx, admin); err != nil {
+				return err
+			}
+		}
+		if err := tx.Admins.SetRole(ctx, id, role); err != nil {
+			return err
+		}
+		changed := *admin
+		changed.Role = role
+		return audit.Record(ctx, tx.Audit, audit.Entry{Action: "admin_role_changed", TargetType: "admin", TargetID: id, Before: admin, After: changed})
+	})
+	if err != nil {
+		return appError(err, "Ошибка назначения роли")
+	}
+	return nil
 }
 
-// generateSecurePassword генерирует безопасный случайный пароль
-func generateSecurePassword(length int) string {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!@#$%^&*"
-	// Устанавливаем сид для генератора случайных чисел
-	rand.Seed(time.Now().UnixNano())
+// SetDisabled отключает или снова включает другого администратора.
+// Отключенный администратор не может войти, а выданные ему токены перестают
+// приниматься сразу. Последнего активного суперадминистратора отключить нельзя.
+func (s *AdminService) SetDisabled(ctx context.Context, actorID, id int, disabled bool) error {
+	if actorID == id {
+		return errors.Forbidden("Нельзя отключить свою учетную запись", "Cannot disable your own account")
+	}
 
-	b := make([]byte, length)
-	for i := range b {
-		b[i] = charset[rand.Intn(len(charset))]
+	err := s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		admin, err := getAdmin(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		changed := *admin
+		changed.DisabledAt = nil
+		action := "admin_enabled"
+		if disabled {
+			if err := ensureAnotherSuperadmin(ctx, tx, admin); err != nil {
+				return err
+			}
+			now := s.now()
+			changed.DisabledAt = &now
+			action = "admin_disabled"
+		}
+		if err := tx.Admins.SetDisabled(ctx, id, changed.DisabledAt); err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx.Audit, audit.Entry{Action: action, TargetType: "admin", TargetID: id, Before: admin, After: changed})
+	})
+	if err != nil {
+		return appError(err, "Ошибка изменения учетной записи администратора")
 	}
-	return string(b)
+	return nil
 }
 
-// InitializeAdmin создает администратора по умолчанию при первом запуске
-// Логин: admin, пароль задается через переменную окружения ADMIN_DEFAULT_PASSWORD или генерируется автоматически
-func InitializeAdmin() error {
-	var count int
-	err := database.DB.QueryRow("SELECT COUNT(*) FROM admins").Scan(&count)
+// DeleteAdmin удаляет другого администратора вместе с его настройками 2FA.
+// Последнего активного суперадминистратора удалить нельзя.
+func (s *AdminService) DeleteAdmin(ctx context.Context, actorID, id int) error {
+	if actorID == id {
+		return errors.Forbidden("Нельзя удалить свою учетную запись", "Cannot delete your own account")
+	}
+
+	err := s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		admin, err := getAdmin(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if err := ensureAnotherSuperadmin(ctx, tx, admin); err != nil {
+			return err
+		}
+		if err := tx.TwoFactor.Delete(ctx, utils.SubjectAdmin, id); err != nil && err != repository.ErrNotFound {
+			return err
+		}
+		if err := tx.Admins.Delete(ctx, id); err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx.Audit, audit.Entry{Action: "admin_deleted", TargetType: "admin", TargetID: id, Before: admin})
+	})
+	if err != nil {
+		return appError(err, "Ошибка удаления администратора")
+	}
+	return nil
+}
+
+// InitializeAdmin готовит создание первого администратора при первом запуске.
+// С ADMIN_DEFAULT_PASSWORD сразу создается "admin", которому при входе нужно
+// сменить пароль. Иначе пароль не генерируется и не попадает в журнал:
+// сохраняется одноразовый токен, по которому первый администратор создается
+// через POST /api/v1/admin/bootstrap. Токен берется из ADMIN_BOOTSTRAP_TOKEN
+// или генерируется и выводится в журнал.
+func (s *AdminService) InitializeAdmin(ctx context.Context) error {
+	count, err := s.admins.Count(ctx)
 	if err != nil {
-		log.Printf("Ошибка при проверке наличия администратора: %v", err)
+		slog.ErrorContext(ctx, "Ошибка при проверке наличия администратора", "error", err)
 		return err
 	}
 
 	if count > 0 {
 		// Администратор уже существует
-		log.Println("Администратор уже существует в базе данных")
+		slog.InfoContext(ctx, "Администратор уже существует в базе данных")
 		return nil
 	}
 
-	log.Println("Создание нового администратора...")
+	if s.cfg.AdminDefaultPassword != "" {
+		slog.InfoContext(ctx, "Создание нового администратора")
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(s.cfg.AdminDefaultPassword), bcrypt.DefaultCost)
+		if err != nil {
+			slog.ErrorContext(ctx, "Ошибка при хешировании пароля администратора", "error", err)
+			return err
+		}
+		err = s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+			admin, err := tx.Admins.Create(ctx, defaultAdminUsername, string(hashedPassword), rbac.DefaultAdminRole, true)
+			if err != nil {
+				return err
+			}
+			return audit.Record(ctx, tx.Audit, audit.Entry{Action: "admin_created", TargetType: "admin", TargetID: admin.ID, After: admin})
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Ошибка при создании администратора", "error", err)
+			return err
+		}
+		slog.InfoContext(ctx, "Администратор создан, пароль из ADMIN_DEFAULT_PASSWORD нужно сменить при первом входе", "username", defaultAdminUsername)
+		return nil
+	}
 
-	// Хешируем пароль
-	password, exists := os.LookupEnv("ADMIN_DEFAULT_PASSWORD")
-	if !exists {
-		// Используем безопасный случайный пароль по умолчанию, если переменная не установлена
-		password = generateSecurePassword(12)
-		log.Printf("Использован сгенерированный пароль для администратора (переменная ADMIN_DEFAULT_PASSWORD не установлена)")
+	token := s.cfg.AdminBootstrapToken
+	if token == "" {
+		if token, err = randomToken(32); err != nil {
+			return err
+		}
 	}
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		log.Printf("Ошибка при хешировании пароля администратора: %v", err)
+	expiresAt := s.now().Add(s.cfg.AdminBootstrapTokenTTL)
+	if err := s.admins.SaveBootstrapToken(ctx, hashToken(token), expiresAt); err != nil {
+		slog.ErrorContext(ctx, "Ошибка при сохранении токена создания администратора", "error", err)
 		return err
 	}
 
-	// Создаем администратора
-	_, err = database.DB.Exec("INSERT INTO admins (username, password) VALUES ($1, $2)", "admin", string(hashedPassword))
+	if s.cfg.AdminBootstrapToken != "" {
+		slog.InfoContext(ctx, "Администраторов нет. Создайте первого через POST /api/v1/admin/bootstrap с токеном из ADMIN_BOOTSTRAP_TOKEN",
+			"expires_at", expiresAt.UTC())
+	} else {
+		slog.InfoContext(ctx, "Администраторов нет. Создайте первого через POST /api/v1/admin/bootstrap с одноразовым токеном",
+			"bootstrap_token", token, "expires_at", expiresAt.UTC())
+	}
+	return nil
+}
+
+// Bootstrap создает первого суперадминистратора по одноразовому токену.
+// Токен принимается, только пока администраторов нет, и сгорает при использовании.
+func (s *AdminService) Bootstrap(ctx context.Context, token, username, password string) (*models.Admin, error) {
+	if !adminUsernamePattern.MatchString(username) {
+		return nil, errors.BadRequest("Некорректный логин администратора", "Username must be 3-64 letters, digits, '.', '_' or '-'")
+	}
+	hashedPassword, err := hashAdminPassword(password)
 	if err != nil {
-		log.Printf("Ошибка при создании администратора: %v", err)
-		return err
+		return nil, err
 	}
 
-	log.Println("Администратор успешно создан")
+	var admin *models.Admin
+	err = s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		err := tx.Admins.ConsumeBootstrapToken(ctx, hashToken(token), s.now())
+		if err == repository.ErrNotFound {
+			return errors.Unauthorized("Недействительный или истекший токен", "Bootstrap token is invalid, expired or already used")
+		}
+		if err != nil {
+			return err
+		}
 
+		count, err := tx.Admins.Count(ctx)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.Forbidden("Администратор уже создан", "Bootstrap is only allowed while there are no admins")
+		}
+
+		admin, err = tx.Admins.Create(ctx, username, hashedPassword, rbac.DefaultAdminRole, false)
+		if err != nil {
+			return err
+		}
+		// Остальные токены, например выданные другими экземплярами сервера, больше не нужны
+		if err := tx.Admins.DeleteBootstrapTokens(ctx); err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx.Audit, audit.Entry{Action: "admin_bootstrapped", TargetType: "admin", TargetID: admin.ID, After: admin})
+	})
+	if err != nil {
+		return nil, appError(err, "Ошибка создания администратора")
+	}
+	return admin, nil
+}
+
+// hashAdminPassword проверяет длину пароля и возвращает его bcrypt-хеш
+func hashAdminPassword(password string) (string, error) {
+	if len(password) < minAdminPasswordLength {
+		return "", errors.BadRequest("Пароль слишком короткий", "Password must be at least 8 characters long")
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", errors.InternalServerError("Ошибка хеширования пароля", err.Error())
+	}
+	return string(hashedPassword), nil
+}
+
+// getAdmin возвращает администратора в транзакции или ошибку 404
+func getAdmin(ctx context.Context, tx *repository.Repositories, id int) (*models.Admin, error) {
+	admin, err := tx.Admins.GetByID(ctx, id)
+	if err == repository.ErrNotFound {
+		return nil, errors.NotFound("Администратор не найден", "Admin does not exist")
+	}
+	return admin, err
+}
+
+// ensureAnotherSuperadmin запрещает изменение, после которого не останется
+// ни одного активного суперадминистратора
+func ensureAnotherSuperadmin(ctx context.Context, tx *repository.Repositories, admin *models.Admin) error {
+	if admin.Role != rbac.RoleSuperadmin || admin.DisabledAt != nil {
+		return nil
+	}
+	count, err := tx.Admins.CountActiveWithRole(ctx, rbac.RoleSuperadmin)
+	if err != nil {
+		return err
+	}
+	if count <= 1 {
+		return errors.Forbidden("Нельзя удалить, отключить или понизить последнего активного суперадминистратора", "At least one active superadmin is required")
+	}
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"kursovaya_backend/internal/audit"
+	"kursovaya_backend/internal/errors"
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/repository"
+)
+
+const (
+	// defaultListPageSize и maxListPageSize ограничивают размер страницы административных списков
+	defaultListPageSize = 50
+	maxListPageSize     = 500
+
+	// exportBatchSize - число записей, читаемых из базы за один запрос при выгрузке
+	exportBatchSize = 1000
+)
+
+// ListPage - страница административного списка. Total - число записей,
+// подходящих под фильтры, без учета страницы. NextCursor задается при
+// сортировке по id, если есть следующая страница.
+type ListPage[T any] struct {
+	Items      []T `json:"items"`
+	Total      int `json:"total"`
+	Limit      int `json:"limit"`
+	Offset     int `json:"offset"`
+	NextCursor int `json:"next_cursor,omitempty"`
+}
+
+// AdminListService выдает списки пользователей, магазинов, товаров и
+// сопоставлений для админ-панели по общим правилам фильтрации и страниц
+type AdminListService struct {
+	repos *repository.Repositories
+}
+
+// NewAdminListService создает новый сервис административных списков
+func NewAdminListService(repos *repository.Repositories) *AdminListService {
+	return &AdminListService{
+		repos: repos,
+	}
+}
+
+// Users возвращает страницу пользователей
+func (s *AdminListService) Users(ctx context.Context, query repository.ListQuery) (*ListPage[models.User], error) {
+	return listPage(ctx, query, repository.UserSortFields, s.repos.Users.List, func(user models.User) int { return user.ID })
+}
+
+// Stores возвращает страницу магазинов без токенов
+func (s *AdminListService) Stores(ctx context.Context, query repository.ListQuery) (*ListPage[models.Store], error) {
+	return listPage(ctx, query, repository.StoreSortFields, s.repos.Stores.List, func(store models.Store) int { return store.ID })
+}
+
+// Products возвращает страницу товаров
+func (s *AdminListService) Products(ctx context.Context, query repository.ListQuery) (*ListPage[models.Product], error) {
+	return listPage(ctx, query, repository.ProductSortFields, s.repos.Products.List, func(product models.Product) int { return product.ID })
+}
+
+// Mappings возвращает страницу сопоставлений
+func (s *AdminListService) Mappings(ctx context.Context, query repository.ListQuery) (*ListPage[models.ProductMapping], error) {
+	return listPage(ctx, query, repository.MappingSortFields, s.repos.Mappings.List, func(mapping models.ProductMapping) int { return mapping.ID })
+}
+
+// listPage проверяет сортировку, ограничивает размер страницы и выбирает ее
+func listPage[T any](
+	ctx context.Context,
+	query repository.ListQuery,
+	sortFields []string,
+	list func(context.Context, repository.ListQuery) ([]T, int, error),
+	id func(T) int,
+) (*ListPage[T], error) {
+	if query.Sort != "" && !slices.Contains(sortFields, query.Sort) {
+		return nil, errors.BadRequest("Недопустимое поле сортировки",
+			fmt.Sprintf("sort must be one of: %s", strings.Join(sortFields, ", ")))
+	}
+	if query.AfterID != 0 && ((query.Sort != "" && query.Sort != "id") || query.Offset != 0) {
+		return nil, errors.BadRequest("Курсор используется только при сортировке по id без смещения",
+			"cursor requires sort=id and no offset")
+	}
+	if query.Limit <= 0 {
+		query.Limit = defaultListPageSize
+	}
+	if query.Limit > maxListPageSize {
+		query.Limit = maxListPageSize
+	}
+
+	// Лишняя запись показывает, есть ли следующая страница
+	limit := query.Limit
+	query.Limit = limit + 1
+	items, total, err := list(ctx, query)
+	if err != nil {
+		return nil, errors.InternalServerError("Ошибка получения списка", err.Error())
+	}
+
+	page := &ListPage[T]{Items: items, Total: total, Limit: limit, Offset: query.Offset}
+	if len(items) > limit {
+		page.Items = items[:limit]
+		if query.Sort == "" || query.Sort == "id" {
+			page.NextCursor = id(items[limit-1])
+		}
+	}
+	return page, nil
+}
+
+// ExportUsers передает write всех пользователей, подходящих под фильтры query, порциями
+func (s *AdminListService) ExportUsers(ctx context.Context, query repository.ListQuery, write func([]models.User) error) error {
+	return exportList(ctx, s.repos.Audit, "users", query, s.repos.Users.List, func(user models.User) int { return user.ID }, write)
+}
+
+// ExportStores передает write все подходящие магазины без токенов порциями
+func (s *AdminListService) ExportStores(ctx context.Context, query repository.ListQuery, write func([]models.Store) error) error {
+	return exportList(ctx, s.repos.Audit, "stores", query, s.repos.Stores.List, func(store models.Store) int { return store.ID }, write)
+}
+
+// ExportProducts передает write все подходящие товары порциями
+func (s *AdminListService) ExportProducts(ctx context.Context, query repository.ListQuery, write func([]models.Product) error) error {
+	return exportList(ctx, s.repos.Audit, "products", query, s.repos.Products.List, func(product models.Product) int { return product.ID }, write)
+}
+
+// ExportMappings передает write все подходящие сопоставления порциями
+func (s *AdminListService) ExportMappings(ctx context.Context, query repository.ListQuery, write func([]models.ProductMapping) error) error {
+	return exportList(ctx, s.repos.Audit, "mappings", query, s.repos.Mappings.List, func(mapping models.ProductMapping) int { return mapping.ID }, write)
+}
+
+// exportList выбирает все записи, подходящие под фильтры query, порциями по
+// exportBatchSize по возрастанию id, передавая курсор от порции к порции, и
+// передает их write. В памяти одновременно находится не больше одной порции,
+// а соединение с базой не удерживается, пока клиент читает ответ. Сортировка
+// и страница из query не учитываются. Завершенная выгрузка записывается в
+// журнал аудита.
+func exportList[T any](
+	ctx context.Context,
+	events repository.AuditRepository,
+	name string,
+	query repository.ListQuery,
+	list func(context.Context, repository.ListQuery) ([]T, int, error),
+	id func(T) int,
+	write func([]T) error,
+) error {
+	query.Sort, query.Desc, query.Offset, query.AfterID = "", false, 0, 0
+	query.Limit = exportBatchSize
+	query.SkipTotal = true
+
+	rows := 0
+	for {
+		items, _, err := list(ctx, query)
+		if err != nil {
+			return errors.InternalServerError("Ошибка выгрузки списка", err.Error())
+		}
+		if len(items) > 0 {
+			if err := write(items); err != nil {
+				return err
+			}
+			rows += len(items)
+		}
+		if len(items) < exportBatchSize {
+			break
+		}
+		query.AfterID = id(items[len(items)-1])
+	}
+
+	return audit.Record(ctx, events, audit.Entry{Action: "list_exported", TargetType: name,
+		After: map[string]any{"rows": rows, "filters": exportFilters(query)}})
+}
+
+// exportFilters - заданные фильтры выгрузки для журнала аудита
+func exportFilters(query repository.ListQuery) map[string]any {
+	filters := map[string]any{}
+	for name, value := range map[string]string{"email": query.Email, "status": query.Status, "name": query.Name, "store_type": query.StoreType} {
+		if value != "" {
+			filters[name] = value
+		}
+	}
+	for name, value := range map[string]int{"user_id": query.UserID, "store_id": query.StoreID} {
+		if value != 0 {
+			filters[name] = value
+		}
+	}
+	for name, value := range map[string]time.Time{"created_from": query.CreatedFrom, "created_to": query.CreatedTo} {
+		if !value.IsZero() {
+			filters[name] = value.UTC()
+		}
+	}
+	return filters
+}
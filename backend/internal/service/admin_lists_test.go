@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/repository"
+)
+
+// Тест постраничной выборки административных списков
+func TestAdminListPages(t *testing.T) {
+	ctx := context.Background()
+	repos := repository.NewMemory()
+	_, _, products := seedMappingFixtures(t, repos)
+	for i := 0; i < 3; i++ {
+		repos.Products.Create(ctx, &models.Product{StoreID: 1, ExternalID: "extra", Name: "Дополнительный"})
+	}
+	lists := NewAdminListService(repos)
+
+	// Курсор проходит весь список без повторов
+	seen := map[int]bool{}
+	query := repository.ListQuery{Limit: 2}
+	for {
+		page, err := lists.Products(ctx, query)
+		if err != nil {
+			t.Fatalf("Ошибка получения страницы: %v", err)
+		}
+		if page.Total != len(products)+3 || page.Limit != 2 {
+			t.Fatalf("Неожиданная страница %+v", page)
+		}
+		for _, product := range page.Items {
+			if seen[product.ID] {
+				t.Fatalf("Товар %d повторяется", product.ID)
+			}
+			seen[product.ID] = true
+		}
+		if page.NextCursor == 0 {
+			break
+		}
+		query.AfterID = page.NextCursor
+	}
+	if len(seen) != len(products)+3 {
+		t.Errorf("Ожидается %d товаров, получено %d", len(products)+3, len(seen))
+	}
+
+	// Без лимита используется страница по умолчанию, размер страницы ограничен
+	page, _ := lists.Products(ctx, repository.ListQuery{})
+	if page.Limit != defaultListPageSize || page.NextCursor != 0 {
+		t.Errorf("Ожидается страница по умолчанию без курсора, получено %+v", page)
+	}
+	if page, _ := lists.Users(ctx, repository.ListQuery{Limit: 100000}); page.Limit != maxListPageSize {
+		t.Errorf("Ожидается ограничение размера страницы, получено %d", page.Limit)
+	}
+
+	// При другой сортировке курсор не выдается и не принимается
+	if page, _ := lists.Products(ctx, repository.ListQuery{Sort: "name", Limit: 1}); page.NextCursor != 0 {
+		t.Errorf("Курсор выдается только при сортировке по id, получено %d", page.NextCursor)
+	}
+	_, err := lists.Products(ctx, repository.ListQuery{Sort: "name", AfterID: 1})
+	expectCode(t, err, http.StatusBadRequest)
+	_, err = lists.Stores(ctx, repository.ListQuery{Sort: "api_token"})
+	expectCode(t, err, http.StatusBadRequest)
+}
+
+// Тест выгрузки административного списка порциями
+func TestAdminListExport(t *testing.T) {
+	ctx := context.Background()
+	repos := repository.NewMemory()
+	_, _, products := seedMappingFixtures(t, repos)
+	extra := exportBatchSize + 1
+	for i := 0; i < extra; i++ {
+		repos.Products.Create(ctx, &models.Product{StoreID: 1, ExternalID: "extra", Name: "Дополнительный"})
+	}
+	lists := NewAdminListService(repos)
+
+	// Сортировка и страница игнорируются: выгружаются все записи по возрастанию id
+	var batches, rows, lastID int
+	query := repository.ListQuery{Sort: "name", Desc: true, Limit: 2, Offset: 5}
+	err := lists.ExportProducts(ctx, query, func(items []models.Product) error {
+		batches++
+		for _, product := range items {
+			if product.ID <= lastID {
+				t.Fatalf("Товар %d выгружен не по возрастанию id", product.ID)
+			}
+			lastID = product.ID
+		}
+		rows += len(items)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Ошибка выгрузки: %v", err)
+	}
+	if rows != len(products)+extra || batches != 2 {
+		t.Errorf("Ожидается %d товаров в 2 порциях, получено %d в %d", len(products)+extra, rows, batches)
+	}
+
+	// Фильтры списка применяются к выгрузке
+	rows = 0
+	err = lists.ExportProducts(ctx, repository.ListQuery{StoreID: 2}, func(items []models.Product) error {
+		for _, product := range items {
+			if product.StoreID != 2 {
+				t.Errorf("Товар %d не подходит под фильтр", product.ID)
+			}
+		}
+		rows += len(items)
+		return nil
+	})
+	if err != nil || rows != 2 {
+		t.Errorf("Ожидается 2 товара магазина 2, получено %d, %v", rows, err)
+	}
+
+	events, _ := repos.Audit.List(ctx, repository.AuditFilter{Action: "list_exported"})
+	if len(events) != 2 || events[0].TargetType != "products" ||
+		string(events[0].After) != `{"filters":{"store_id":2},"rows":2}` {
+		t.Errorf("Неожиданные события выгрузки: %+v", events)
+	}
+
+	// Ошибка записи прерывает выгрузку и не записывается в журнал как выгрузка
+	failed := fmt.Errorf("клиент отключился")
+	err = lists.ExportUsers(ctx, repository.ListQuery{}, func([]models.User) error { return failed })
+	if err != failed {
+		t.Errorf("Ожидается ошибка записи, получено %v", err)
+	}
+	if events, _ := repos.Audit.List(ctx, repository.AuditFilter{Action: "list_exported"}); len(events) != 2 {
+		t.Errorf("Прерванная выгрузка не должна попадать в журнал, получено %d событий", len(events))
+	}
+}
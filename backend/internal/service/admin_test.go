@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"kursovaya_backend/internal/config"
+	"kursovaya_backend/internal/rbac"
+	"kursovaya_backend/internal/repository"
+)
+
+func newTestAdminService(cfg *config.Config) (*AdminService, *repository.Repositories, *time.Time) {
+	repos := repository.NewMemory()
+	cfg.AdminBootstrapTokenTTL = time.Hour
+	service := NewAdminService(repos, cfg)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+	return service, repos, &now
+}
+
+// createTestAdmin создает администратора с паролем "password"
+func createTestAdmin(t *testing.T, repos *repository.Repositories, username, role string) int {
+	t.Helper()
+	hash, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	admin, err := repos.Admins.Create(context.Background(), username, string(hash), role, false)
+	if err != nil {
+		t.Fatalf("Ошибка создания администратора: %v", err)
+	}
+	return admin.ID
+}
+
+// Тест первого запуска без ADMIN_DEFAULT_PASSWORD: администратор создается
+// только по одноразовому токену, пока администраторов нет
+func TestAdminBootstrap(t *testing.T) {
+	ctx := context.Background()
+	adminService, repos, now := newTestAdminService(&config.Config{AdminBootstrapToken: "bootstrap-token-from-environment-0123"})
+
+	if err := adminService.InitializeAdmin(ctx); err != nil {
+		t.Fatalf("Ошибка инициализации: %v", err)
+	}
+	if count, _ := repos.Admins.Count(ctx); count != 0 {
+		t.Fatalf("Без ADMIN_DEFAULT_PASSWORD администратор не должен создаваться, создано %d", count)
+	}
+
+	_, err := adminService.Bootstrap(ctx, "wrong-token", "root", "long-password")
+	expectCode(t, err, http.StatusUnauthorized)
+
+	admin, err := adminService.Bootstrap(ctx, "bootstrap-token-from-environment-0123", "root", "long-password")
+	if err != nil {
+		t.Fatalf("Ошибка создания первого администратора: %v", err)
+	}
+	if admin.Role != rbac.RoleSuperadmin || admin.MustChangePassword {
+		t.Errorf("Ожидается суперадминистратор со своим паролем, получено %+v", admin)
+	}
+	if _, err := adminService.AuthenticateAdmin(ctx, "root", "long-password"); err != nil {
+		t.Errorf("Ожидается вход с выбранным паролем, получено %v", err)
+	}
+
+	// Токен одноразовый, а после создания администратора не действует и новый
+	_, err = adminService.Bootstrap(ctx, "bootstrap-token-from-environment-0123", "second", "long-password")
+	expectCode(t, err, http.StatusUnauthorized)
+	repos.Admins.SaveBootstrapToken(ctx, hashToken("late-token"), now.Add(time.Hour))
+	_, err = adminService.Bootstrap(ctx, "late-token", "second", "long-password")
+	expectCode(t, err, http.StatusForbidden)
+}
+
+// Тест истечения токена создания первого администратора
+func TestAdminBootstrapTokenExpires(t *testing.T) {
+	ctx := context.Background()
+	adminService, _, now := newTestAdminService(&config.Config{AdminBootstrapToken: "bootstrap-token-from-environment-0123"})
+	if err := adminService.InitializeAdmin(ctx); err != nil {
+		t.Fatalf("Ошибка инициализации: %v", err)
+	}
+
+	*now = now.Add(2 * time.Hour)
+	_, err := adminService.Bootstrap(ctx, "bootstrap-token-from-environment-0123", "root", "long-password")
+	expectCode(t, err, http.StatusUnauthorized)
+}
+
+// Тест ADMIN_DEFAULT_PASSWORD: пароль из окружения нужно сменить при входе
+func TestInitializeAdminWithDefaultPassword(t *testing.T) {
+	ctx := context.Background()
+	adminService, _, _ := newTestAdminService(&config.Config{AdminDefaultPassword: "default-password"})
+
+	if err := adminService.InitializeAdmin(ctx); err != nil {
+		t.Fatalf("Ошибка инициализации: %v", err)
+	}
+	admin, err := adminService.AuthenticateAdmin(ctx, "admin", "default-password")
+	if err != nil || !admin.MustChangePassword {
+		t.Fatalf("Ожидается администратор с обязательной сменой пароля, получено %+v, %v", admin, err)
+	}
+
+	expectCode(t, adminService.ChangePassword(ctx, admin.ID, "wrong-password", "new-password"), http.StatusUnauthorized)
+	expectCode(t, adminService.ChangePassword(ctx, admin.ID, "default-password", "default-password"), http.StatusBadRequest)
+	if err := adminService.ChangePassword(ctx, admin.ID, "default-password", "new-password"); err != nil {
+		t.Fatalf("Ошибка смены пароля: %v", err)
+	}
+	if admin, err := adminService.AuthenticateAdmin(ctx, "admin", "new-password"); err != nil || admin.MustChangePassword {
+		t.Errorf("Ожидается вход с новым паролем без требования смены, получено %+v, %v", admin, err)
+	}
+
+	// Повторный запуск ничего не меняет
+	if err := adminService.InitializeAdmin(ctx); err != nil {
+		t.Fatalf("Ошибка повторной инициализации: %v", err)
+	}
+}
+
+// Тест управления администраторами: временный пароль, отключение, удаление
+func TestManageAdmins(t *testing.T) {
+	ctx := context.Background()
+	adminService, repos, _ := newTestAdminService(&config.Config{})
+	rootID := createTestAdmin(t, repos, "root", rbac.RoleSuperadmin)
+
+	created, err := adminService.CreateAdmin(ctx, "support", "temporary", rbac.RoleSupport)
+	if err != nil {
+		t.Fatalf("Ошибка создания администратора: %v", err)
+	}
+	if !created.MustChangePassword {
+		t.Error("Ожидается обязательная смена временного пароля")
+	}
+	_, err = adminService.CreateAdmin(ctx, "support", "temporary", rbac.RoleSupport)
+	expectCode(t, err, http.StatusBadRequest)
+	_, err = adminService.CreateAdmin(ctx, "x", "temporary", rbac.RoleSupport)
+	expectCode(t, err, http.StatusBadRequest)
+	_, err = adminService.CreateAdmin(ctx, "other", "temporary", rbac.RoleOwner)
+	expectCode(t, err, http.StatusBadRequest)
+
+	if err := adminService.SetDisabled(ctx, rootID, created.ID, true); err != nil {
+		t.Fatalf("Ошибка отключения: %v", err)
+	}
+	if _, err := adminService.AuthenticateAdmin(ctx, "support", "temporary"); err == nil {
+		t.Error("Отключенный администратор не должен входить")
+	}
+	if err := adminService.SetDisabled(ctx, rootID, created.ID, false); err != nil {
+		t.Fatalf("Ошибка включения: %v", err)
+	}
+
+	if err := adminService.ResetPassword(ctx, rootID, created.ID, "another-temporary"); err != nil {
+		t.Fatalf("Ошибка сброса пароля: %v", err)
+	}
+	if admin, err := adminService.AuthenticateAdmin(ctx, "support", "another-temporary"); err != nil || !admin.MustChangePassword {
+		t.Errorf("Ожидается вход с временным паролем и требованием смены, получено %+v, %v", admin, err)
+	}
+	expectCode(t, adminService.ResetPassword(ctx, rootID, rootID, "another-temporary"), http.StatusForbidden)
+
+	if err := adminService.DeleteAdmin(ctx, rootID, created.ID); err != nil {
+		t.Fatalf("Ошибка удаления: %v", err)
+	}
+	expectCode(t, adminService.DeleteAdmin(ctx, rootID, created.ID), http.StatusNotFound)
+}
+
+// Тест защиты последнего активного суперадминистратора
+func TestLastSuperadminProtection(t *testing.T) {
+	ctx := context.Background()
+	adminService, repos, _ := newTestAdminService(&config.Config{})
+	rootID := createTestAdmin(t, repos, "root", rbac.RoleSuperadmin)
+	otherID := createTestAdmin(t, repos, "other", rbac.RoleSuperadmin)
+
+	// Себя нельзя удалить, отключить или понизить
+	expectCode(t, adminService.DeleteAdmin(ctx, rootID, rootID), http.StatusForbidden)
+	expectCode(t, adminService.SetDisabled(ctx, rootID, rootID, true), http.StatusForbidden)
+	expectCode(t, adminService.SetRole(ctx, rootID, rootID, rbac.RoleSupport), http.StatusForbidden)
+
+	// Пока есть второй суперадминистратор, первого можно отключить
+	if err := adminService.SetDisabled(ctx, otherID, rootID, true); err != nil {
+		t.Fatalf("Ошибка отключения: %v", err)
+	}
+	// Отключенный суперадминистратор не считается: other теперь последний активный
+	expectCode(t, adminService.SetRole(ctx, rootID, otherID, rbac.RoleSupport), http.StatusForbidden)
+	if err := adminService.SetDisabled(ctx, otherID, rootID, false); err != nil {
+		t.Fatalf("Ошибка включения: %v", err)
+	}
+
+	// После понижения other последним остается root
+	if err := adminService.SetRole(ctx, rootID, otherID, rbac.RoleSupport); err != nil {
+		t.Fatalf("Ошибка понижения второго суперадминистратора: %v", err)
+	}
+	expectCode(t, adminService.SetRole(ctx, otherID, rootID, rbac.RoleSupport), http.StatusForbidden)
+	expectCode(t, adminService.SetDisabled(ctx, otherID, rootID, true), http.StatusForbidden)
+	expectCode(t, adminService.DeleteAdmin(ctx, otherID, rootID), http.StatusForbidden)
+
+	if admin, _ := repos.Admins.GetByID(ctx, rootID); admin.Role != rbac.RoleSuperadmin || admin.DisabledAt != nil {
+		t.Errorf("Последний суперадминистратор не должен измениться, получено %+v", admin)
+	}
+	// Администратора поддержки по-прежнему можно удалить
+	if err := adminService.DeleteAdmin(ctx, rootID, otherID); err != nil {
+		t.Errorf("Ошибка удаления администратора поддержки: %v", err)
+	}
+}
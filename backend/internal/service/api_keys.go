@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"kursovaya_backend/internal/audit"
+	"kursovaya_backend/internal/errors"
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/rbac"
+	"kursovaya_backend/internal/repository"
+)
+
+const (
+	// APIKeyPrefix начинает каждый API-ключ, чтобы его было легко узнать
+	// (например, сканерам секретов в репозиториях)
+	APIKeyPrefix = "mpt_"
+	// apiKeyDisplayLength - сколько первых символов ключа хранится открыто для списка
+	apiKeyDisplayLength = 12
+	// maxActiveAPIKeys - сколько действующих ключей может быть у пользователя
+	maxActiveAPIKeys = 20
+	// apiKeyTouchInterval - не чаще какого интервала обновляется время последнего использования
+	apiKeyTouchInterval = time.Minute
+)
+
+// APIKeyService выпускает, отзывает и проверяет персональные API-ключи
+type APIKeyService struct {
+	repos *repository.Repositories
+	now   func() time.Time
+}
+
+// NewAPIKeyService создает новый сервис API-ключей
+func NewAPIKeyService(repos *repository.Repositories) *APIKeyService {
+	return &APIKeyService{
+		repos: repos,
+		now:   time.Now,
+	}
+}
+
+// Create выпускает ключ с разрешениями scopes, действующий ttl. Разрешения
+// должны входить в роль пользователя. Возвращает сохраненный ключ и открытое
+// значение, которое больше нигде не хранится.
+func (s *APIKeyService) Create(ctx context.Context, userID int, name string, scopes []string, ttl time.Duration) (*models.APIKey, string, error) {
+	user, err := s.repos.Users.GetByID(ctx, userID)
+	if err == repository.ErrNotFound {
+		return nil, "", errors.NotFound("Пользователь не найден", "User does not exist")
+	}
+	if err != nil {
+		return nil, "", errors.InternalServerError("Ошибка получения пользователя", err.Error())
+	}
+
+	normalized := []string{}
+	seen := make(map[string]bool)
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if !rbac.HasPermission(user.Role, rbac.Permission(scope)) {
+			return nil, "", errors.BadRequest("Недопустимое разрешение ключа", "Scope "+scope+" is not granted by role "+user.Role)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			normalized = append(normalized, scope)
+		}
+	}
+	if len(normalized) == 0 {
+		return nil, "", errors.BadRequest("Укажите разрешения ключа", "At least one scope is required")
+	}
+
+	keys, err := s.repos.APIKeys.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, "", errors.InternalServerError("Ошибка получения API-ключей", err.Error())
+	}
+	active := 0
+	for i := range keys {
+		if s.usable(&keys[i]) == nil {
+			active++
+		}
+	}
+	if active >= maxActiveAPIKeys {
+		return nil, "", errors.BadRequest("Слишком много API-ключей", "Revoke unused keys before creating new ones")
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, "", errors.InternalServerError("Ошибка создания API-ключа", err.Error())
+	}
+	plain := APIKeyPrefix + secret
+	expiresAt := s.now().Add(ttl)
+	key := &models.APIKey{
+		UserID:    userID,
+		Name:      strings.TrimSpace(name),
+		Prefix:    plain[:apiKeyDisplayLength],
+		KeyHash:   hashToken(plain),
+		Scopes:    normalized,
+		ExpiresAt: &expiresAt,
+	}
+	err = s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		if err := tx.APIKeys.Create(ctx, key); err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx.Audit, audit.Entry{Action: "api_key_created", TargetType: "api_key", TargetID: key.ID, After: key})
+	})
+	if err != nil {
+		return nil, "", errors.InternalServerError("Ошибка сохранения API-ключа", err.Error())
+	}
+	return key, plain, nil
+}
+
+// List возвращает ключи пользователя без открытых значений
+func (s *APIKeyService) List(ctx context.Context, userID int) ([]models.APIKey, error) {
+	keys, err := s.repos.APIKeys.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, errors.InternalServerError("Ошибка получения API-ключей", err.Error())
+	}
+	return keys, nil
+}
+
+// Revoke отзывает ключ пользователя; отозванный ключ перестает приниматься сразу
+func (s *APIKeyService) Revoke(ctx context.Context, userID, id int) error {
+	err := s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		if err := tx.APIKeys.Revoke(ctx, userID, id, s.now()); err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx.Audit, audit.Entry{Action: "api_key_revoked", TargetType: "api_key", TargetID: id})
+	})
+	if err == repository.ErrNotFound {
+		return errors.NotFound("API-ключ не найден", "API key does not exist or is already revoked")
+	}
+	if err != nil {
+		return errors.InternalServerError("Ошибка отзыва API-ключа", err.Error())
+	}
+	return nil
+}
+
+// Authenticate проверяет открытый ключ из запроса и возвращает ключ и его владельца
+func (s *APIKeyService) Authenticate(ctx context.Context, plain string) (*models.APIKey, *models.User, error) {
+	invalid := errors.Unauthorized("Недействительный API-ключ", "Invalid API key")
+	if !strings.HasPrefix(plain, APIKeyPrefix) {
+		return nil, nil, invalid
+	}
+
+	key, err := s.repos.APIKeys.GetByKeyHash(ctx, hashToken(plain))
+	if err == repository.ErrNotFound {
+		return nil, nil, invalid
+	}
+	if err != nil {
+		return nil, nil, errors.InternalServerError("Ошибка проверки API-ключа", err.Error())
+	}
+	if err := s.usable(key); err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.repos.Users.GetByID(ctx, key.UserID)
+	if err == repository.ErrNotFound {
+		return nil, nil, invalid
+	}
+	if err != nil {
+		return nil, nil, errors.InternalServerError("Ошибка получения пользователя", err.Error())
+	}
+
+	now := s.now()
+	if err := s.repos.APIKeys.TouchLastUsed(ctx, key.ID, now, now.Add(-apiKeyTouchInterval)); err != nil {
+		slog.ErrorContext(ctx, "Ошибка обновления времени использования API-ключа", "api_key_id", key.ID, "error", err)
+	}
+	return key, user, nil
+}
+
+// usable возвращает ошибку 401, если ключ отозван или истек
+func (s *APIKeyService) usable(key *models.APIKey) error {
+	if key.RevokedAt != nil {
+		return errors.Unauthorized("API-ключ отозван", "API key has been revoked")
+	}
+	if key.ExpiresAt != nil && !s.now().Before(*key.ExpiresAt) {
+		return errors.Unauthorized("Срок действия API-ключа истек", "API key has expired")
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"kursovaya_backend/internal/rbac"
+	"kursovaya_backend/internal/repository"
+)
+
+// newTestAPIKeyService создает сервис с управляемыми часами и пользователем-владельцем
+func newTestAPIKeyService(t *testing.T) (*APIKeyService, *repository.Repositories, *time.Time) {
+	t.Helper()
+	repos := repository.NewMemory()
+	if _, err := repos.Users.Create(context.Background(), "keys@example.com", "hash"); err != nil {
+		t.Fatalf("Ошибка создания пользователя: %v", err)
+	}
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	service := NewAPIKeyService(repos)
+	service.now = func() time.Time { return now }
+	return service, repos, &now
+}
+
+func TestAPIKeyCreateAndAuthenticate(t *testing.T) {
+	service, repos, now := newTestAPIKeyService(t)
+	ctx := context.Background()
+
+	key, plain, err := service.Create(ctx, 1, " CI ", []string{"stores:read", "stores:read", "products:read"}, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("Ошибка создания ключа: %v", err)
+	}
+	if !strings.HasPrefix(plain, APIKeyPrefix) || !strings.HasPrefix(plain, key.Prefix) || key.Name != "CI" || len(key.Scopes) != 2 {
+		t.Fatalf("Неожиданный ключ %q: %+v", plain, key)
+	}
+	if key.KeyHash == plain {
+		t.Error("Ключ должен храниться в виде хеша")
+	}
+
+	authenticated, user, err := service.Authenticate(ctx, plain)
+	if err != nil || authenticated.ID != key.ID || user.Email != "keys@example.com" {
+		t.Fatalf("Ожидается вход владельца ключа, получено %+v, %+v, %v", authenticated, user, err)
+	}
+	if stored, _ := repos.APIKeys.GetByKeyHash(ctx, key.KeyHash); stored.LastUsedAt == nil || !stored.LastUsedAt.Equal(*now) {
+		t.Errorf("Ожидается время последнего использования %s, получено %v", *now, stored.LastUsedAt)
+	}
+
+	_, _, err = service.Authenticate(ctx, plain+"x")
+	expectCode(t, err, 401)
+	_, _, err = service.Authenticate(ctx, "not-a-key")
+	expectCode(t, err, 401)
+
+	// Истекший ключ не принимается
+	*now = now.Add(25 * time.Hour)
+	_, _, err = service.Authenticate(ctx, plain)
+	expectCode(t, err, 401)
+}
+
+func TestAPIKeyScopesLimitedByRole(t *testing.T) {
+	service, repos, _ := newTestAPIKeyService(t)
+	ctx := context.Background()
+	repos.Users.SetRole(ctx, 1, rbac.RoleViewer)
+
+	tests := []struct {
+		name   string
+		scopes []string
+		want   int
+	}{
+		{"Разрешение роли", []string{"stores:read"}, 0},
+		{"Разрешение сверх роли", []string{"stores:write"}, 400},
+		{"Разрешение администратора", []string{"admin:users:read"}, 400},
+		{"Неизвестное разрешение", []string{"everything"}, 400},
+		{"Без разрешений", []string{}, 400},
+	}
+
+	for _, tt := range tests {
+		_, _, err := service.Create(ctx, 1, "key", tt.scopes, time.Hour)
+		if tt.want == 0 {
+			if err != nil {
+				t.Errorf("%s: ожидается успех, получено %v", tt.name, err)
+			}
+			continue
+		}
+		expectCode(t, err, tt.want)
+	}
+}
+
+func TestAPIKeyRevoke(t *testing.T) {
+	service, repos, _ := newTestAPIKeyService(t)
+	ctx := context.Background()
+	repos.Users.Create(ctx, "other@example.com", "hash")
+
+	key, plain, _ := service.Create(ctx, 1, "CI", []string{"stores:read"}, time.Hour)
+
+	// Чужой ключ отозвать нельзя
+	expectCode(t, service.Revoke(ctx, 2, key.ID), 404)
+
+	if err := service.Revoke(ctx, 1, key.ID); err != nil {
+		t.Fatalf("Ошибка отзыва ключа: %v", err)
+	}
+	_, _, err := service.Authenticate(ctx, plain)
+	expectCode(t, err, 401)
+	expectCode(t, service.Revoke(ctx, 1, key.ID), 404)
+
+	keys, _ := service.List(ctx, 1)
+	if len(keys) != 1 || keys[0].RevokedAt == nil {
+		t.Errorf("Ожидается отозванный ключ в списке, получено %+v", keys)
+	}
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+
+	"kursovaya_backend/internal/errors"
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/repository"
+)
+
+const (
+	// defaultAuditPageSize и maxAuditPageSize ограничивают размер страницы журнала
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 200
+)
+
+// AuditPage - страница журнала аудита от новых событий к старым. NextCursor
+// передается в следующем запросе; если он не задан, событий больше нет.
+type AuditPage struct {
+	Events     []models.AuditEvent `json:"events"`
+	NextCursor int                 `json:"next_cursor,omitempty"`
+}
+
+// AuditService читает журнал аудита. События записывает audit.Record в
+// сервисах, выполняющих действия.
+type AuditService struct {
+	events repository.AuditRepository
+}
+
+// NewAuditService создает новый сервис журнала аудита
+func NewAuditService(repos *repository.Repositories) *AuditService {
+	return &AuditService{
+		events: repos.Audit,
+	}
+}
+
+// List возвращает страницу событий, подходящих под фильтр
+func (s *AuditService) List(ctx context.Context, filter repository.AuditFilter) (*AuditPage, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultAuditPageSize
+	}
+	if limit > maxAuditPageSize {
+		limit = maxAuditPageSize
+	}
+
+	// Лишнее событие показывает, есть ли следующая страница
+	filter.Limit = limit + 1
+	events, err := s.events.List(ctx, filter)
+	if err != nil {
+		return nil, errors.InternalServerError("Ошибка получения журнала аудита", err.Error())
+	}
+
+	page := &AuditPage{Events: events}
+	if len(events) > limit {
+		page.Events = events[:limit]
+		page.NextCursor = events[limit-1].ID
+	}
+	return page, nil
+}
+
+// UserActivity возвращает действия пользователя и действия над его учетной
+// записью. В чужих действиях, например администратора, скрываются инициатор,
+// его IP-адрес и User-Agent; действия администратора от имени пользователя
+// помечаются Impersonated.
+func (s *AuditService) UserActivity(ctx context.Context, userID int, filter repository.AuditFilter) (*AuditPage, error) {
+	filter.UserID = userID
+	filter.ActorType, filter.ActorID, filter.ImpersonatorID = "", 0, 0
+	filter.TargetType, filter.TargetID = "", ""
+
+	page, err := s.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	for i := range page.Events {
+		event := &page.Events[i]
+		if event.ImpersonatorID != nil {
+			event.Impersonated = true
+			event.ImpersonatorID = nil
+		}
+		ownAction := (event.ActorType == models.ActorUser || event.ActorType == models.ActorAPIKey) &&
+			event.ActorID != nil && *event.ActorID == userID && !event.Impersonated
+		if !ownAction {
+			event.ActorID = nil
+			event.IP = ""
+			event.UserAgent = ""
+		}
+	}
+	return page, nil
+}
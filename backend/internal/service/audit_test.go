@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"kursovaya_backend/internal/audit"
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/repository"
+	"kursovaya_backend/internal/secrets"
+	"kursovaya_backend/pkg/utils"
+)
+
+// Тест записи действий пользователя и администратора в журнал аудита
+func TestAuditTrail(t *testing.T) {
+	useTestKeyring(t)
+	repos := repository.NewMemory()
+	userID, otherUserID, products := seedMappingFixtures(t, repos)
+	client := audit.Client{IP: "10.0.0.1", UserAgent: "test-agent"}
+	userCtx := audit.WithActor(audit.WithClient(context.Background(), client), audit.Actor{Type: models.ActorUser, ID: userID})
+
+	// Действия пользователя: сопоставление, магазин и его удаление
+	mapping, err := NewMappingService(repos).CreateMapping(userCtx, products[0], products[1], userID)
+	if err != nil {
+		t.Fatalf("Ошибка создания сопоставления: %v", err)
+	}
+	storeService := NewStoreService(repos, secrets.NewDatabaseStore())
+	store, err := storeService.AddStore(userCtx, mapping.OrganizationID, userID, "ozon", "ozon-token-123")
+	if err != nil {
+		t.Fatalf("Ошибка добавления магазина: %v", err)
+	}
+	if err := storeService.DeleteStore(userCtx, store.ID, userID); err != nil {
+		t.Fatalf("Ошибка удаления магазина: %v", err)
+	}
+
+	// Неудачное действие не оставляет записи
+	if err := storeService.DeleteStore(userCtx, store.ID, otherUserID); err == nil {
+		t.Fatal("Ожидается ошибка удаления несуществующего магазина")
+	}
+
+	// Действие администратора над учетной записью пользователя
+	repos.TwoFactor.Create(context.Background(), &models.TwoFactor{SubjectType: utils.SubjectUser, SubjectID: userID, Secret: "secret"})
+	adminCtx := audit.WithActor(audit.WithClient(context.Background(), audit.Client{IP: "192.168.0.1"}), audit.Actor{Type: models.ActorAdmin, ID: 1})
+	if err := NewTwoFactorService(repos, nil).Reset(adminCtx, utils.SubjectUser, userID); err != nil {
+		t.Fatalf("Ошибка сброса 2FA: %v", err)
+	}
+
+	auditService := NewAuditService(repos)
+	page, err := auditService.List(context.Background(), repository.AuditFilter{})
+	if err != nil {
+		t.Fatalf("Ошибка чтения журнала: %v", err)
+	}
+	actions := []string{"two_factor_reset", "store_deleted", "store_created", "mapping_created"}
+	if len(page.Events) != len(actions) {
+		t.Fatalf("Ожидаются события %v, получено %+v", actions, page.Events)
+	}
+	for i, action := range actions {
+		if page.Events[i].Action != action {
+			t.Errorf("Событие %d: ожидается %s, получено %s", i, action, page.Events[i].Action)
+		}
+	}
+
+	deleted := page.Events[1]
+	if deleted.ActorType != models.ActorUser || *deleted.ActorID != userID || deleted.IP != client.IP || deleted.UserAgent != client.UserAgent ||
+		deleted.TargetType != "store" || deleted.TargetID != strconv.Itoa(store.ID) || len(deleted.Before) == 0 || deleted.After != nil {
+		t.Errorf("Неожиданное событие удаления магазина: %+v", deleted)
+	}
+
+	// Пользователь видит свои действия и сброс 2FA, но не IP администратора
+	activity, err := auditService.UserActivity(context.Background(), userID, repository.AuditFilter{})
+	if err != nil || len(activity.Events) != 4 {
+		t.Fatalf("Ожидаются 4 события пользователя, получено %+v, %v", activity, err)
+	}
+	reset := activity.Events[0]
+	if reset.ActorType != models.ActorAdmin || reset.ActorID != nil || reset.IP != "" {
+		t.Errorf("Ожидается скрытый инициатор-администратор, получено %+v", reset)
+	}
+	if own := activity.Events[1]; own.IP != client.IP {
+		t.Errorf("Ожидается IP-адрес в собственном действии, получено %+v", own)
+	}
+	if other, _ := auditService.UserActivity(context.Background(), otherUserID, repository.AuditFilter{}); len(other.Events) != 0 {
+		t.Errorf("Ожидается пустой журнал другого пользователя, получено %+v", other.Events)
+	}
+
+	// Постраничная выборка
+	first, _ := auditService.List(context.Background(), repository.AuditFilter{Limit: 3})
+	if len(first.Events) != 3 || first.NextCursor != first.Events[2].ID {
+		t.Fatalf("Ожидаются 3 события и курсор, получено %+v", first)
+	}
+	second, _ := auditService.List(context.Background(), repository.AuditFilter{Limit: 3, BeforeID: first.NextCursor})
+	if len(second.Events) != 1 || second.Events[0].Action != "mapping_created" || second.NextCursor != 0 {
+		t.Errorf("Ожидается последняя страница из одного события, получено %+v", second)
+	}
+}
@@ -1,35 +1,35 @@
 package service
 
 import (
-	"database/sql"
-	"kursovaya_backend/internal/database"
+	"context"
+
 	"kursovaya_backend/internal/errors"
 	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/repository"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// Глобальная переменная DB для обратной совместимости
-// Инициализируется с nil, будет присвоено значение позже
-var DB DBInterface
-
-func RegisterUser(email, password string) (*models.User, error) {
-	realDB := &RealDB{DB: database.DB}
-	return RegisterUserWithDB(realDB, email, password)
+// AuthService для регистрации и аутентификации пользователей
+type AuthService struct {
+	repos *repository.Repositories
+	users repository.UserRepository
 }
 
-func AuthenticateUser(email, password string) (*models.User, error) {
-	realDB := &RealDB{DB: database.DB}
-	return AuthenticateUserWithDB(realDB, email, password)
+// NewAuthService создает новый сервис аутентификации пользователей
+func NewAuthService(repos *repository.Repositories) *AuthService {
+	return &AuthService{repos: repos, users: repos.Users}
 }
 
-func RegisterUserWithDB(db DBInterface, email, password string) (*models.User, error) {
+// RegisterUser создает пользователя вместе с его личной организацией,
+// в которой он становится владельцем
+
+func (s *AuthService) RegisterUser(ctx context.Context, email, password string) (*models.User, error) {
 	// Проверяем, существует ли пользователь
-	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM users WHERE email = $1", email).Scan(&count)
+	exists, err := s.users.ExistsByEmail(ctx, email)
 	if err != nil {
 		return nil, errors.InternalServerError("Error checking user existence", err.Error())
 	}
-	if count > 0 {
+	if exists {
 		return nil, errors.BadRequest("User with this email already exists", "Email is already registered")
 	}
 
@@ -39,27 +39,30 @@ func RegisterUserWithDB(db DBInterface, email, password string) (*models.User, e
 		return nil, errors.InternalServerError("Error hashing password", err.Error())
 	}
 
-	// Создаем пользователя
-	var userID int
-	err = db.QueryRow("INSERT INTO users (email, password) VALUES ($1, $2) RETURNING id", email, string(hashedPassword)).Scan(&userID)
+	// Создаем пользователя и его личную организацию
+	var user *models.User
+	err = s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		user, err = tx.Users.Create(ctx, email, string(hashedPassword))
+		if err != nil {
+			return err
+		}
+		_, err = createOrganization(ctx, tx, user.ID, email)
+		return err
+	})
+	if err == repository.ErrDuplicate {
+		return nil, errors.BadRequest("User with this email already exists", "Email is already registered")
+	}
 	if err != nil {
 		return nil, errors.InternalServerError("Error creating user", err.Error())
 	}
 
-	return &models.User{
-		ID:    userID,
-		Email: email,
-	}, nil
+	return user, nil
 }
 
-func AuthenticateUserWithDB(db DBInterface, email, password string) (*models.User, error) {
-	var user models.User
-	var hashedPassword string
-
-	err := db.QueryRow("SELECT id, email, password FROM users WHERE email = $1", email).
-		Scan(&user.ID, &user.Email, &hashedPassword)
+func (s *AuthService) AuthenticateUser(ctx context.Context, email, password string) (*models.User, error) {
+	user, hashedPassword, err := s.users.GetCredentials(ctx, email)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if err == repository.ErrNotFound {
 			return nil, errors.NotFound("User not found", "User with this email does not exist")
 		}
 		return nil, errors.InternalServerError("Error querying user", err.Error())
@@ -71,5 +74,32 @@ func AuthenticateUserWithDB(db DBInterface, email, password string) (*models.Use
 		return nil, errors.Unauthorized("Invalid password", "Password does not match")
 	}
 
-	return &user, nil
-}
\ No newline at end of file
+	// Удаленная учетная запись ведет себя как несуществующая, а заблокированной
+	// сообщается причина блокировки
+	switch user.Status {
+	case models.UserDeleted:
+		return nil, errors.NotFound("User not found", "User with this email does not exist")
+	case models.UserSuspended:
+		return nil, AccountSuspended(user.StatusReason)
+	}
+
+	return user, nil
+}
+
+// AccountSuspended - ошибка 403 для заблокированного пользователя; в
+// подробностях передается причина блокировки
+func AccountSuspended(reason string) *errors.AppError {
+	return errors.Forbidden("Учетная запись заблокирована", reason)
+}
+
+// GetUser возвращает пользователя по ID, например после второго шага входа
+func (s *AuthService) GetUser(ctx context.Context, id int) (*models.User, error) {
+	user, err := s.users.GetByID(ctx, id)
+	if err == repository.ErrNotFound {
+		return nil, errors.NotFound("User not found", "User does not exist")
+	}
+	if err != nil {
+		return nil, errors.InternalServerError("Error querying user", err.Error())
+	}
+	return user, nil
+}
@@ -1,65 +1,40 @@
 package service
 
 import (
-	"database/sql"
+	"context"
 	"testing"
 	"golang.org/x/crypto/bcrypt"
+	"kursovaya_backend/internal/errors"
+	"kursovaya_backend/internal/repository"
 )
 
-// Mock database for testing
-type MockDB struct {
-	users map[string]string // email -> hashed password
-}
-
-func (m *MockDB) QueryRow(query string, args ...interface{}) *sql.Row {
-	// Имитация sql.Row для тестирования
-	// В реальном приложении для тестов можно использовать sqlmock
-	return nil
-}
-
-func (m *MockDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
-	// Возвращаем фиктивный результат в зависимости от запроса
-	if query == "SELECT COUNT(*) FROM users WHERE email = $1" {
-		email := args[0].(string)
-		_, exists := m.users[email]
-		_ = exists // Используем переменную, чтобы избежать ошибки "declared but not used"
-		// Создаем фиктивный результат с количеством
-		// (реализация здесь упрощена для демонстрации)
+// newTestAuthService создает сервис аутентификации поверх репозиториев в памяти
+func newTestAuthService(t *testing.T, users map[string]string) *AuthService {
+	t.Helper()
+
+	repos := repository.NewMemory()
+	for email, password := range users {
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+		if err != nil {
+			t.Fatalf("Ошибка хеширования пароля: %v", err)
+		}
+		if _, err := repos.Users.Create(context.Background(), email, string(hashedPassword)); err != nil {
+			t.Fatalf("Ошибка подготовки пользователя: %v", err)
+		}
 	}
-	return nil, nil
-}
-
-func (m *MockDB) Exec(query string, args ...interface{}) (sql.Result, error) {
-	if query == "INSERT INTO users (email, password) VALUES ($1, $2) RETURNING id" {
-		email := args[0].(string)
-		hashedPassword := args[1].(string)
-		m.users[email] = hashedPassword
-		return &MockResult{id: len(m.users)}, nil
-	}
-	return nil, nil
-}
-
-type MockResult struct {
-	id int
-}
-
-func (m *MockResult) LastInsertId() (int64, error) {
-	return int64(m.id), nil
-}
 
-func (m *MockResult) RowsAffected() (int64, error) {
-	return 1, nil
+	return NewAuthService(repos)
 }
 
 // Тест регистрации нового пользователя
-func TestRegisterUserWithDB(t *testing.T) {
+func TestRegisterUser(t *testing.T) {
 	// Подготовка
-	mockDB := &MockDB{users: make(map[string]string)}
+	authService := newTestAuthService(t, nil)
 	email := "test@example.com"
 	password := "password123"
 
 	// Выполнение
-	user, err := RegisterUserWithDB(mockDB, email, password)
+	user, err := authService.RegisterUser(context.Background(), email, password)
 
 	// Проверка
 	if err != nil {
@@ -76,49 +51,42 @@ func TestRegisterUserWithDB(t *testing.T) {
 		t.Errorf("Ожидается email %s, получен %s", email, user.Email)
 	}
 
-	// Проверим, что пользователь был добавлен в mockDB
-	if _, exists := mockDB.users[email]; !exists {
-		t.Error("Ожидается, что пользователь был добавлен в базу данных")
+	// Проверим, что пользователь был добавлен в хранилище
+	if _, err := authService.AuthenticateUser(context.Background(), email, password); err != nil {
+		t.Errorf("Ожидается, что пользователь был добавлен в базу данных: %v", err)
 	}
 }
 
 // Тест регистрации пользователя с уже существующим email
 func TestRegisterUserDuplicate(t *testing.T) {
 	// Подготовка
-	password := "password123"
-	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-
-	mockDB := &MockDB{users: map[string]string{
-		"existing@example.com": string(hashedPassword),
-	}}
 	email := "existing@example.com"
+	authService := newTestAuthService(t, map[string]string{email: "password123"})
 	newPassword := "newpassword123"
 
 	// Выполнение
-	_, err := RegisterUserWithDB(mockDB, email, newPassword)
+	_, err := authService.RegisterUser(context.Background(), email, newPassword)
 
 	// Проверка
 	if err == nil {
-		t.Error("Ожидается ошибка при регистрации с существующим email, получено nil")
-	} else {
-		// Проверим, что это именно ошибка о существующем пользователе
-		// todo: проверить тип ошибки
+		t.Fatal("Ожидается ошибка при регистрации с существующим email, получено nil")
+	}
+
+	appErr, ok := err.(*errors.AppError)
+	if !ok || appErr.Code != 400 {
+		t.Errorf("Ожидается ошибка 400 о существующем пользователе, получена %v", err)
 	}
 }
 
 // Тест аутентификации пользователя
-func TestAuthenticateUserWithDB(t *testing.T) {
+func TestAuthenticateUser(t *testing.T) {
 	// Подготовка
 	email := "test@example.com"
 	password := "password123"
-	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-
-	mockDB := &MockDB{users: map[string]string{
-		email: string(hashedPassword),
-	}}
+	authService := newTestAuthService(t, map[string]string{email: password})
 
 	// Выполнение
-	user, err := AuthenticateUserWithDB(mockDB, email, password)
+	user, err := authService.AuthenticateUser(context.Background(), email, password)
 
 	// Проверка
 	if err != nil {
@@ -140,16 +108,11 @@ func TestAuthenticateUserWithDB(t *testing.T) {
 func TestAuthenticateUserInvalidPassword(t *testing.T) {
 	// Подготовка
 	email := "test@example.com"
-	password := "password123"
-	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	authService := newTestAuthService(t, map[string]string{email: "password123"})
 	invalidPassword := "wrongpassword"
 
-	mockDB := &MockDB{users: map[string]string{
-		email: string(hashedPassword),
-	}}
-
 	// Выполнение
-	_, err := AuthenticateUserWithDB(mockDB, email, invalidPassword)
+	_, err := authService.AuthenticateUser(context.Background(), email, invalidPassword)
 
 	// Проверка
 	if err == nil {
@@ -160,16 +123,13 @@ func TestAuthenticateUserInvalidPassword(t *testing.T) {
 // Тест аутентификации несуществующего пользователя
 func TestAuthenticateNonExistentUser(t *testing.T) {
 	// Подготовка
-	email := "nonexistent@example.com"
-	password := "password123"
-
-	mockDB := &MockDB{users: make(map[string]string)}
+	authService := newTestAuthService(t, nil)
 
 	// Выполнение
-	_, err := AuthenticateUserWithDB(mockDB, email, password)
+	_, err := authService.AuthenticateUser(context.Background(), "nonexistent@example.com", "password123")
 
 	// Проверка
 	if err == nil {
 		t.Error("Ожидается ошибка при аутентификации несуществующего пользователя, получено nil")
 	}
-}
\ No newline at end of file
+}
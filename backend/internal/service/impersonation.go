@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"kursovaya_backend/internal/audit"
+	"kursovaya_backend/internal/config"
+	"kursovaya_backend/internal/errors"
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/rbac"
+	"kursovaya_backend/internal/repository"
+	"kursovaya_backend/pkg/utils"
+)
+
+// impersonationSessionPrefix отличает сессии входа от имени пользователя от обычных
+const impersonationSessionPrefix = "imp-"
+
+// Impersonation - токен администратора для входа от имени пользователя
+type Impersonation struct {
+	AccessToken string    `json:"token"`
+	ExpiresIn   int       `json:"expires_in"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	ReadOnly    bool      `json:"read_only"`
+	SessionID   string    `json:"session_id"`
+	UserID      int       `json:"user_id"`
+}
+
+// ImpersonationService выдает администраторам короткоживущие токены для
+// входа от имени пользователя. Токен привязан к отдельной сессии, поэтому
+// вход можно завершить досрочно, а блокировка пользователя завершает и его.
+type ImpersonationService struct {
+	repos *repository.Repositories
+	ttl   time.Duration
+	now   func() time.Time
+}
+
+// NewImpersonationService создает новый сервис входа от имени пользователя
+func NewImpersonationService(repos *repository.Repositories, cfg *config.Config) *ImpersonationService {
+	return &ImpersonationService{
+		repos: repos,
+		ttl:   cfg.ImpersonationTTL,
+		now:   time.Now,
+	}
+}
+
+// Start выдает администратору adminID токен пользователя userID. По умолчанию
+// токен только для чтения; изменяющие запросы (write) разрешаются
+// администратору с правом admin:users:write.
+func (s *ImpersonationService) Start(ctx context.Context, adminID, userID int, reason string, write bool) (*Impersonation, error) {
+	admin, err := s.repos.Admins.GetByID(ctx, adminID)
+	if err != nil {
+		return nil, errors.InternalServerError("Ошибка получения администратора", err.Error())
+	}
+	if write && !rbac.HasPermission(admin.Role, rbac.AdminUsersWrite) {
+		return nil, errors.Forbidden("Недостаточно прав для изменений от имени пользователя", "Write impersonation requires admin:users:write")
+	}
+
+	user, err := s.repos.Users.GetByID(ctx, userID)
+	if err == repository.ErrNotFound {
+		return nil, errors.NotFound("Пользователь не найден", "User not found")
+	}
+	if err != nil {
+		return nil, errors.InternalServerError("Ошибка получения пользователя", err.Error())
+	}
+	if user.Status == models.UserSuspended || user.Status == models.UserDeleted {
+		return nil, errors.BadRequest("Нельзя войти от имени заблокированного или удаленного пользователя",
+			"User status is "+user.Status)
+	}
+
+	familyID, err := randomToken(16)
+	if err != nil {
+		return nil, errors.InternalServerError("Ошибка создания сессии", err.Error())
+	}
+	familyID = impersonationSessionPrefix + familyID
+	// Refresh-токен не выдается: в сессии хранится хеш случайного значения
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, errors.InternalServerError("Ошибка создания сессии", err.Error())
+	}
+
+	now := s.now()
+	impersonation := &Impersonation{
+		ExpiresIn: int(s.ttl.Seconds()),
+		ExpiresAt: now.Add(s.ttl).UTC(),
+		ReadOnly:  !write,
+		SessionID: familyID,
+		UserID:    user.ID,
+	}
+	act := utils.Impersonator{AdminID: admin.ID, Username: admin.Username, ReadOnly: !write}
+	if impersonation.AccessToken, err = utils.GenerateImpersonationToken(user.ID, user.Role, familyID, act, s.ttl); err != nil {
+		return nil, errors.InternalServerError("Ошибка генерации токена", err.Error())
+	}
+
+	err = s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		session := &models.Session{UserID: user.ID, FamilyID: familyID, TokenHash: hashToken(secret), ExpiresAt: impersonation.ExpiresAt}
+		if err := tx.Sessions.Create(ctx, session); err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx.Audit, audit.Entry{Action: "impersonation_started", TargetType: "user", TargetID: user.ID,
+			After: map[string]any{
+				"session_id": familyID,
+				"read_only":  !write,
+				"reason":     strings.TrimSpace(reason),
+				"expires_at": impersonation.ExpiresAt,
+			}})
+	})
+	if err != nil {
+		return nil, errors.InternalServerError("Ошибка создания сессии", err.Error())
+	}
+
+	return impersonation, nil
+}
+
+// End завершает вход от имени пользователя: токен сессии sessionID перестает
+// приниматься. Вызывается с токеном входа от имени пользователя.
+func (s *ImpersonationService) End(ctx context.Context, userID int, sessionID string) error {
+	if !strings.HasPrefix(sessionID, impersonationSessionPrefix) {
+		return errors.BadRequest("Сессия не является входом от имени пользователя", "Not an impersonation session")
+	}
+
+	err := s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		if err := tx.Sessions.RevokeFamily(ctx, sessionID, s.now()); err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx.Audit, audit.Entry{Action: "impersonation_ended", TargetType: "user", TargetID: userID,
+			Before: map[string]any{"session_id": sessionID}})
+	})
+	if err != nil {
+		return errors.InternalServerError("Ошибка завершения сессии", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"kursovaya_backend/internal/audit"
+	"kursovaya_backend/internal/config"
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/rbac"
+	"kursovaya_backend/internal/repository"
+	"kursovaya_backend/pkg/utils"
+)
+
+// Тест входа администратора от имени пользователя
+func TestImpersonation(t *testing.T) {
+	utils.SetJWTKey("test-secret-key-with-at-least-32-characters")
+	ctx := context.Background()
+	repos := repository.NewMemory()
+	user, _ := repos.Users.Create(ctx, "user@example.com", "hash")
+	supportID := createTestAdmin(t, repos, "support", rbac.RoleSupport)
+	rootID := createTestAdmin(t, repos, "root", rbac.RoleSuperadmin)
+	impersonations := NewImpersonationService(repos, &config.Config{ImpersonationTTL: 10 * time.Minute})
+	adminCtx := audit.WithActor(ctx, audit.Actor{Type: models.ActorAdmin, ID: supportID})
+
+	// По умолчанию токен только для чтения и называет администратора в claim act
+	impersonation, err := impersonations.Start(adminCtx, supportID, user.ID, "Обращение в поддержку", false)
+	if err != nil {
+		t.Fatalf("Ошибка входа от имени пользователя: %v", err)
+	}
+	claims, err := utils.ParseUserToken(impersonation.AccessToken)
+	if err != nil || claims.UserID != user.ID || claims.SessionID != impersonation.SessionID {
+		t.Fatalf("Ожидается токен пользователя %d, получено %+v, %v", user.ID, claims, err)
+	}
+	if claims.Act == nil || claims.Act.AdminID != supportID || claims.Act.Subject != "admin:"+strconv.Itoa(supportID) || !claims.Act.ReadOnly {
+		t.Errorf("Ожидается claim act администратора только для чтения, получено %+v", claims.Act)
+	}
+	if impersonation.ExpiresIn != 600 || !impersonation.ReadOnly {
+		t.Errorf("Неожиданный ответ %+v", impersonation)
+	}
+	if revoked, _ := repos.Sessions.IsFamilyRevoked(ctx, impersonation.SessionID); revoked {
+		t.Error("Ожидается действующая сессия входа от имени пользователя")
+	}
+
+	// Изменения от имени пользователя требуют admin:users:write
+	_, err = impersonations.Start(adminCtx, supportID, user.ID, "", true)
+	expectCode(t, err, http.StatusForbidden)
+	if writable, err := impersonations.Start(adminCtx, rootID, user.ID, "", true); err != nil || writable.ReadOnly {
+		t.Errorf("Ожидается токен с правом изменений, получено %+v, %v", writable, err)
+	}
+	_, err = impersonations.Start(adminCtx, supportID, user.ID+100, "", false)
+	expectCode(t, err, http.StatusNotFound)
+
+	// Завершение отзывает сессию; действие помечено администратором
+	impersonatedCtx := audit.WithActor(ctx, audit.Actor{Type: models.ActorUser, ID: user.ID, ImpersonatorID: supportID})
+	expectCode(t, impersonations.End(impersonatedCtx, user.ID, "ordinary-session"), http.StatusBadRequest)
+	if err := impersonations.End(impersonatedCtx, user.ID, impersonation.SessionID); err != nil {
+		t.Fatalf("Ошибка завершения: %v", err)
+	}
+	if revoked, _ := repos.Sessions.IsFamilyRevoked(ctx, impersonation.SessionID); !revoked {
+		t.Error("Ожидается отзыв сессии после завершения")
+	}
+
+	auditService := NewAuditService(repos)
+	page, _ := auditService.List(ctx, repository.AuditFilter{ImpersonatorID: supportID})
+	if len(page.Events) != 1 || page.Events[0].Action != "impersonation_ended" || *page.Events[0].ActorID != user.ID {
+		t.Errorf("Ожидается событие завершения от имени пользователя, получено %+v", page.Events)
+	}
+	if page, _ := auditService.List(ctx, repository.AuditFilter{Action: "impersonation_started"}); len(page.Events) != 2 {
+		t.Errorf("Ожидаются 2 события начала, получено %+v", page.Events)
+	}
+
+	// В истории пользователя действие помечено, но администратор и его IP скрыты
+	activity, _ := auditService.UserActivity(ctx, user.ID, repository.AuditFilter{Action: "impersonation_ended"})
+	if len(activity.Events) != 1 || !activity.Events[0].Impersonated || activity.Events[0].ImpersonatorID != nil {
+		t.Errorf("Ожидается помеченное действие без ID администратора, получено %+v", activity.Events)
+	}
+
+	// Блокированного пользователя нельзя открыть
+	repos.Users.SetStatus(ctx, user.ID, models.UserSuspended, "Спам", time.Now())
+	_, err = impersonations.Start(adminCtx, supportID, user.ID, "", false)
+	expectCode(t, err, http.StatusBadRequest)
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"kursovaya_backend/internal/audit"
+	"kursovaya_backend/internal/repository"
+	"kursovaya_backend/internal/secrets"
+	"kursovaya_backend/pkg/utils"
+)
+
+// defaultRotationBatchSize - сколько магазинов перешифровывается за один запрос к базе
+const defaultRotationBatchSize = 100
+
+// RotationResult - итог перешифрования
+type RotationResult struct {
+	Rotated int `json:"rotated"`
+	Skipped int `json:"skipped"`
+	Failed  int `json:"failed"`
+}
+
+// KeyRotationService перешифровывает сохраненные секреты основным ключом:
+// токены магазинов, хранящиеся в базе данных, и секреты двухфакторной
+// аутентификации. Значения, уже зашифрованные основным ключом, пропускаются,
+// поэтому повторный запуск после сбоя продолжает работу с того же места.
+type KeyRotationService struct {
+	repos   *repository.Repositories
+	keyring *utils.Keyring
+}
+
+// NewKeyRotationService создает новый сервис перешифрования
+func NewKeyRotationService(repos *repository.Repositories, keyring *utils.Keyring) *KeyRotationService {
+	return &KeyRotationService{
+		repos:   repos,
+		keyring: keyring,
+	}
+}
+
+// Rotate перешифровывает все секреты. Токены магазинов читаются порциями по
+// batchSize. Значение заменяется, только если его не изменили за время
+// перешифрования; ошибки по отдельным значениям не прерывают работу и
+// учитываются в Failed.
+func (s *KeyRotationService) Rotate(ctx context.Context, batchSize int) (RotationResult, error) {
+	if batchSize <= 0 {
+		batchSize = defaultRotationBatchSize
+	}
+
+	var result RotationResult
+	afterID := 0
+	for {
+		tokens, err := s.repos.Stores.ListEncryptedTokens(ctx, afterID, batchSize)
+		if err != nil {
+			return result, err
+		}
+		for _, token := range tokens {
+			afterID = token.ID
+			// Токены во внешних хранилищах здесь не перешифровываются: Vault
+			// шифрует их сам, а файлы читаются, пока их ключ есть в ENCRYPTION_KEYS
+			if secrets.External(token.Value) {
+				result.Skipped++
+				continue
+			}
+			err := s.rotate(token.Value, &result, func(rotated string) error {
+				return s.repos.Stores.ReplaceEncryptedToken(ctx, token.ID, token.Value, rotated)
+			})
+			if err != nil {
+				slog.ErrorContext(ctx, "Ошибка перешифрования токена магазина", "store_id", token.ID, "error", err)
+			}
+		}
+		if len(tokens) < batchSize {
+			break
+		}
+	}
+
+	twoFactorSecrets, err := s.repos.TwoFactor.ListSecrets(ctx)
+	if err != nil {
+		return result, err
+	}
+	for _, twoFactor := range twoFactorSecrets {
+		err := s.rotate(twoFactor.Secret, &result, func(rotated string) error {
+			return s.repos.TwoFactor.ReplaceSecret(ctx, twoFactor.SubjectType, twoFactor.SubjectID, twoFactor.Secret, rotated)
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Ошибка перешифрования секрета 2FA", "subject_type", twoFactor.SubjectType, "subject_id", twoFactor.SubjectID, "error", err)
+		}
+	}
+
+	err = audit.Record(ctx, s.repos.Audit, audit.Entry{Action: "keys_rotated", TargetType: "encryption_key", TargetID: s.keyring.PrimaryKeyID(), After: result})
+	return result, err
+}
+
+// rotate перешифровывает одно значение и сохраняет его через replace
+func (s *KeyRotationService) rotate(ciphertext string, result *RotationResult, replace func(string) error) error {
+	if !s.keyring.NeedsRotation(ciphertext) {
+		result.Skipped++
+		return nil
+	}
+
+	plaintext, err := s.keyring.Decrypt(ciphertext)
+	if err == nil {
+		var rotated string
+		rotated, err = s.keyring.Encrypt(plaintext)
+		if err == nil {
+			err = replace(rotated)
+		}
+	}
+	if err == repository.ErrNotFound {
+		// Значение изменили или удалили параллельно - новое уже зашифровано основным ключом
+		result.Skipped++
+		return nil
+	}
+	if err != nil {
+		result.Failed++
+		return err
+	}
+	result.Rotated++
+	return nil
+}
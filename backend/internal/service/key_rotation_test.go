@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/repository"
+	"kursovaya_backend/pkg/utils"
+)
+
+// Тест перешифрования токенов магазинов и секретов 2FA новым основным ключом
+func TestKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	repos := repository.NewMemory()
+	user, _ := repos.Users.Create(ctx, "rotate@example.com", "hash")
+	organization, _ := repos.Organizations.Create(ctx, "Команда")
+
+	old, err := utils.NewKeyring("old", map[string]string{"old": "old-secret"}, "")
+	if err != nil {
+		t.Fatalf("Ошибка создания ключей: %v", err)
+	}
+	var storeIDs []int
+	for _, token := range []string{"token-1", "token-2", "token-3"} {
+		encrypted, _ := old.Encrypt(token)
+		store, err := repos.Stores.Create(ctx, organization.ID, user.ID, "wb", encrypted)
+		if err != nil {
+			t.Fatalf("Ошибка создания магазина: %v", err)
+		}
+		storeIDs = append(storeIDs, store.ID)
+	}
+	secret, _ := old.Encrypt("totp-secret")
+	repos.TwoFactor.Create(ctx, &models.TwoFactor{SubjectType: "user", SubjectID: user.ID, Secret: secret})
+	// Поврежденное значение не должно останавливать перешифрование остальных
+	broken, _ := repos.Stores.Create(ctx, organization.ID, user.ID, "ozon", "v1:old:broken")
+	// Токен во внешнем хранилище пропускается
+	repos.Stores.Create(ctx, organization.ID, user.ID, "ozon", "vault:marketplace-tracker/stores/store-1")
+
+	keyring, err := utils.NewKeyring("new", map[string]string{"old": "old-secret", "new": "new-secret"}, "")
+	if err != nil {
+		t.Fatalf("Ошибка создания ключей: %v", err)
+	}
+	rotation := NewKeyRotationService(repos, keyring)
+
+	result, err := rotation.Rotate(ctx, 2)
+	if err != nil {
+		t.Fatalf("Ошибка перешифрования: %v", err)
+	}
+	if result != (RotationResult{Rotated: 4, Skipped: 1, Failed: 1}) {
+		t.Errorf("Ожидается 4 перешифрованных и 1 ошибка, получено %+v", result)
+	}
+	for i, id := range storeIDs {
+		token, _ := repos.Stores.GetToken(ctx, id)
+		if !strings.HasPrefix(token, "v1:new:") {
+			t.Errorf("Ожидается токен нового ключа, получено %q", token)
+		}
+		if plaintext, err := keyring.Decrypt(token); err != nil || plaintext != []string{"token-1", "token-2", "token-3"}[i] {
+			t.Errorf("Ожидается исходный токен, получено %q, %v", plaintext, err)
+		}
+	}
+	if token, _ := repos.Stores.GetToken(ctx, broken.ID); token != "v1:old:broken" {
+		t.Errorf("Поврежденный токен не должен изменяться, получено %q", token)
+	}
+	twoFactor, _ := repos.TwoFactor.Get(ctx, "user", user.ID)
+	if plaintext, err := keyring.Decrypt(twoFactor.Secret); err != nil || plaintext != "totp-secret" || keyring.NeedsRotation(twoFactor.Secret) {
+		t.Errorf("Ожидается секрет 2FA нового ключа, получено %q, %v", plaintext, err)
+	}
+
+	// Повторный запуск ничего не перешифровывает
+	result, _ = rotation.Rotate(ctx, 2)
+	if result != (RotationResult{Rotated: 0, Skipped: 5, Failed: 1}) {
+		t.Errorf("Ожидается 5 пропущенных значений при повторном запуске, получено %+v", result)
+	}
+}
@@ -0,0 +1,244 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"kursovaya_backend/internal/audit"
+	"kursovaya_backend/internal/config"
+	"kursovaya_backend/internal/errors"
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/repository"
+)
+
+// LockoutService защищает вход от перебора паролей. Неудачные попытки
+// считаются отдельно для учетной записи и для IP-адреса: после каждой неудачи
+// учетная запись ждет паузу, растущую вдвое, а после LoginMaxFailures неудач
+// вход блокируется на LoginLockoutDuration. IP-адрес блокируется после
+// LoginIPMaxFailures неудач по любым учетным записям, без пауз, чтобы не
+// мешать пользователям за общим NAT.
+type LockoutService struct {
+	attempts repository.LoginAttemptRepository
+	events   repository.AuditRepository
+	cfg      *config.Config
+	now      func() time.Time
+}
+
+// NewLockoutService создает новый сервис защиты от перебора паролей.
+// Блокировки и их снятие записываются в журнал аудита events.
+func NewLockoutService(attempts repository.LoginAttemptRepository, events repository.AuditRepository, cfg *config.Config) *LockoutService {
+	return &LockoutService{
+		attempts: attempts,
+		events:   events,
+		cfg:      cfg,
+		now:      time.Now,
+	}
+}
+
+// maxChallengeFailures - сколько неверных кодов принимается по одному токену
+// второго шага входа; затем нужно снова ввести пароль
+const maxChallengeFailures = 5
+
+// AccountKey возвращает ключ счетчика учетной записи: subjectType - тип
+// (utils.SubjectUser или utils.SubjectAdmin), account - email или логин
+func AccountKey(subjectType, account string) string {
+	return subjectType + ":" + strings.ToLower(strings.TrimSpace(account))
+}
+
+// IPKey возвращает ключ счетчика IP-адреса
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// ChallengeKey возвращает ключ счетчика неверных кодов токена второго шага входа
+func ChallengeKey(challengeID string) string {
+	return "mfa:" + challengeID
+}
+
+// Check проверяет, можно ли сейчас пытаться войти. Вызывается до проверки
+// пароля, иначе заблокированный пароль все равно можно было бы подобрать.
+func (s *LockoutService) Check(ctx context.Context, subjectType, account, ip string) error {
+	now := s.now()
+
+	attempt, err := s.get(ctx, AccountKey(subjectType, account))
+	if err != nil {
+		return err
+	}
+	if attempt != nil {
+		if err := lockedError(attempt, now); err != nil {
+			return err
+		}
+		// Пауза действует только для неудач в пределах окна
+		if attempt.Failures > 0 && !attempt.LastFailureAt.Before(now.Add(-s.cfg.LoginFailureWindow)) {
+			next := attempt.LastFailureAt.Add(s.delay(attempt.Failures))
+			if now.Before(next) {
+				return errors.TooManyRequests("Слишком частые попытки входа, повторите позже", "Login attempts are throttled", next.Sub(now))
+			}
+		}
+	}
+
+	if ip == "" {
+		return nil
+	}
+	attempt, err = s.get(ctx, IPKey(ip))
+	if err != nil || attempt == nil {
+		return err
+	}
+	return lockedError(attempt, now)
+}
+
+// Failure учитывает неудачную попытку входа и блокирует учетную запись или
+// IP-адрес, если неудач стало слишком много
+func (s *LockoutService) Failure(ctx context.Context, subjectType, account, ip string) error {
+	now := s.now()
+	windowStart := now.Add(-s.cfg.LoginFailureWindow)
+
+	type limit struct {
+		key         string
+		maxFailures int
+	}
+	limits := []limit{{AccountKey(subjectType, account), s.cfg.LoginMaxFailures}}
+	if ip != "" {
+		limits = append(limits, limit{IPKey(ip), s.cfg.LoginIPMaxFailures})
+	}
+
+	for _, counter := range limits {
+		attempt, err := s.attempts.RegisterFailure(ctx, counter.key, now, windowStart)
+		if err != nil {
+			return errors.InternalServerError("Ошибка учета попытки входа", err.Error())
+		}
+		// Новый счетчик - удобный момент убрать устаревшие, чтобы перебор
+		// случайных адресов не раздувал хранилище
+		if attempt.Failures == 1 {
+			if err := s.attempts.DeleteStale(ctx, windowStart); err != nil {
+				slog.ErrorContext(ctx, "Ошибка очистки счетчиков попыток входа", "error", err)
+			}
+		}
+		if counter.maxFailures <= 0 || attempt.Failures < counter.maxFailures {
+			continue
+		}
+
+		until := now.Add(s.cfg.LoginLockoutDuration)
+		if err := s.attempts.Lock(ctx, counter.key, until); err != nil {
+			return errors.InternalServerError("Ошибка блокировки входа", err.Error())
+		}
+		locked := *attempt
+		locked.LockedUntil = &until
+		err = audit.Record(ctx, s.events, audit.Entry{Action: "login_locked", TargetType: "login", TargetID: counter.key, After: locked})
+		if err != nil {
+			return errors.InternalServerError("Ошибка записи блокировки в журнал аудита", err.Error())
+		}
+	}
+	return nil
+}
+
+// Success сбрасывает счетчик учетной записи после успешного входа. Счетчик
+// IP-адреса не сбрасывается: иначе, входя в свою учетную запись, можно было
+// бы бесконечно перебирать пароли чужих.
+func (s *LockoutService) Success(ctx context.Context, subjectType, account string) error {
+	err := s.attempts.Reset(ctx, AccountKey(subjectType, account))
+	if err != nil && err != repository.ErrNotFound {
+		return errors.InternalServerError("Ошибка сброса попыток входа", err.Error())
+	}
+	return nil
+}
+
+// CheckChallenge проверяет, можно ли ввести код двухфакторной аутентификации
+// по токену второго шага challengeID. Действуют те же блокировки учетной
+// записи и IP-адреса, что и при вводе пароля, а токен, по которому ввели
+// maxChallengeFailures неверных кодов, больше не принимается.
+func (s *LockoutService) CheckChallenge(ctx context.Context, subjectType, account, ip, challengeID string) error {
+	if err := s.Check(ctx, subjectType, account, ip); err != nil {
+		return err
+	}
+	attempt, err := s.get(ctx, ChallengeKey(challengeID))
+	if err != nil || attempt == nil {
+		return err
+	}
+	if attempt.Failures >= maxChallengeFailures {
+		return errors.Unauthorized("Слишком много неверных кодов, войдите заново", "Too many failed codes for this MFA challenge")
+	}
+	return nil
+}
+
+// ChallengeFailure учитывает неверный код как неудачную попытку входа
+// учетной записи и IP-адреса и как неудачу токена второго шага challengeID
+func (s *LockoutService) ChallengeFailure(ctx context.Context, subjectType, account, ip, challengeID string) error {
+	if err := s.Failure(ctx, subjectType, account, ip); err != nil {
+		return err
+	}
+	// Неудачи токена считаются за все время его жизни, без окна
+	if _, err := s.attempts.RegisterFailure(ctx, ChallengeKey(challengeID), s.now(), time.Time{}); err != nil {
+		return errors.InternalServerError("Ошибка учета попытки входа", err.Error())
+	}
+	return nil
+}
+
+// ListLocked возвращает действующие блокировки
+func (s *LockoutService) ListLocked(ctx context.Context) ([]models.LoginAttempt, error) {
+	attempts, err := s.attempts.ListLocked(ctx, s.now())
+	if err != nil {
+		return nil, errors.InternalServerError("Ошибка получения блокировок", err.Error())
+	}
+	return attempts, nil
+}
+
+// Unlock снимает блокировку и сбрасывает счетчик по ключу
+func (s *LockoutService) Unlock(ctx context.Context, key string) error {
+	attempt, err := s.get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if attempt != nil {
+		err = s.attempts.Reset(ctx, key)
+	}
+	if attempt == nil || err == repository.ErrNotFound {
+		return errors.NotFound("Блокировка не найдена", "No login attempts recorded for this key")
+	}
+	if err != nil {
+		return errors.InternalServerError("Ошибка снятия блокировки", err.Error())
+	}
+	if err := audit.Record(ctx, s.events, audit.Entry{Action: "login_unlocked", TargetType: "login", TargetID: key, Before: attempt}); err != nil {
+		return errors.InternalServerError("Ошибка записи в журнал аудита", err.Error())
+	}
+	return nil
+}
+
+// get возвращает счетчик или nil, если неудач не было
+func (s *LockoutService) get(ctx context.Context, key string) (*models.LoginAttempt, error) {
+	attempt, err := s.attempts.Get(ctx, key)
+	if err == repository.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.InternalServerError("Ошибка проверки попыток входа", err.Error())
+	}
+	return attempt, nil
+}
+
+// delay возвращает паузу после failures неудач подряд: LoginDelayBase,
+// затем вдвое больше с каждой неудачей, но не больше LoginDelayMax
+func (s *LockoutService) delay(failures int) time.Duration {
+	delay := s.cfg.LoginDelayBase
+	for i := 1; i < failures && delay < s.cfg.LoginDelayMax; i++ {
+		delay *= 2
+	}
+	if delay > s.cfg.LoginDelayMax {
+		delay = s.cfg.LoginDelayMax
+	}
+	return delay
+}
+
+// lockedError возвращает ошибку 429, если блокировка еще действует
+func lockedError(attempt *models.LoginAttempt, now time.Time) error {
+	if attempt.LockedUntil == nil || !now.Before(*attempt.LockedUntil) {
+		return nil
+	}
+	return errors.TooManyRequests(
+		"Слишком много неудачных попыток входа, вход временно заблокирован",
+		"Login is locked until "+attempt.LockedUntil.UTC().Format(time.RFC3339),
+		attempt.LockedUntil.Sub(now),
+	)
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kursovaya_backend/internal/config"
+	"kursovaya_backend/internal/errors"
+	"kursovaya_backend/internal/repository"
+	"kursovaya_backend/pkg/utils"
+)
+
+// newTestLockoutService создает сервис с управляемыми часами
+func newTestLockoutService() (*LockoutService, *time.Time) {
+	cfg := &config.Config{
+		LoginMaxFailures:     3,
+		LoginIPMaxFailures:   5,
+		LoginFailureWindow:   15 * time.Minute,
+		LoginLockoutDuration: 10 * time.Minute,
+		LoginDelayBase:       time.Second,
+		LoginDelayMax:        3 * time.Second,
+	}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	service := NewLockoutService(repository.NewMemoryLoginAttempts(), repository.NewMemory().Audit, cfg)
+	service.now = func() time.Time { return now }
+	return service, &now
+}
+
+// expectRetryAfter проверяет ошибку 429 и значение Retry-After в секундах
+func expectRetryAfter(t *testing.T, err error, seconds int) {
+	t.Helper()
+	appErr, ok := err.(*errors.AppError)
+	if !ok || appErr.Code != 429 || appErr.RetryAfter != seconds {
+		t.Errorf("Ожидается 429 с Retry-After %d, получено %v", seconds, err)
+	}
+}
+
+func TestLockoutProgressiveDelay(t *testing.T) {
+	service, now := newTestLockoutService()
+	ctx := context.Background()
+
+	service.Failure(ctx, utils.SubjectUser, "user@example.com", "")
+	expectRetryAfter(t, service.Check(ctx, utils.SubjectUser, "user@example.com", ""), 1)
+
+	// Пауза удваивается, но не превышает LoginDelayMax
+	*now = now.Add(time.Second)
+	if err := service.Check(ctx, utils.SubjectUser, "user@example.com", ""); err != nil {
+		t.Fatalf("Ожидается, что после паузы вход разрешен, получено %v", err)
+	}
+	service.Failure(ctx, utils.SubjectUser, "user@example.com", "")
+	expectRetryAfter(t, service.Check(ctx, utils.SubjectUser, "user@example.com", ""), 2)
+
+	// Успешный вход сбрасывает счетчик
+	*now = now.Add(2 * time.Second)
+	if err := service.Success(ctx, utils.SubjectUser, "User@Example.com"); err != nil {
+		t.Fatalf("Ошибка сброса счетчика: %v", err)
+	}
+	service.Failure(ctx, utils.SubjectUser, "user@example.com", "")
+	expectRetryAfter(t, service.Check(ctx, utils.SubjectUser, "user@example.com", ""), 1)
+
+	// Другие учетные записи не затрагиваются
+	if err := service.Check(ctx, utils.SubjectAdmin, "user@example.com", ""); err != nil {
+		t.Errorf("Ожидается, что счетчики администраторов отдельны, получено %v", err)
+	}
+}
+
+func TestLockoutAfterMaxFailures(t *testing.T) {
+	service, now := newTestLockoutService()
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		*now = now.Add(time.Minute)
+		if err := service.Failure(ctx, utils.SubjectAdmin, "admin", ""); err != nil {
+			t.Fatalf("Ошибка учета неудачи: %v", err)
+		}
+	}
+	expectRetryAfter(t, service.Check(ctx, utils.SubjectAdmin, "admin", ""), 600)
+
+	locked, _ := service.ListLocked(ctx)
+	if len(locked) != 1 || locked[0].Key != "admin:admin" {
+		t.Fatalf("Ожидается блокировка admin:admin, получено %+v", locked)
+	}
+
+	// После истечения блокировки вход снова разрешен без паузы
+	*now = now.Add(10 * time.Minute)
+	if err := service.Check(ctx, utils.SubjectAdmin, "admin", ""); err != nil {
+		t.Errorf("Ожидается, что блокировка истекла, получено %v", err)
+	}
+}
+
+func TestLockoutByIP(t *testing.T) {
+	service, now := newTestLockoutService()
+	ctx := context.Background()
+
+	// Перебор разных учетных записей с одного адреса
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com"} {
+		service.Failure(ctx, utils.SubjectUser, email, "10.0.0.1")
+	}
+	*now = now.Add(time.Minute)
+	expectRetryAfter(t, service.Check(ctx, utils.SubjectUser, "new@example.com", "10.0.0.1"), 540)
+	if err := service.Check(ctx, utils.SubjectUser, "new@example.com", "10.0.0.2"); err != nil {
+		t.Errorf("Ожидается, что другой адрес не заблокирован, получено %v", err)
+	}
+
+	// Администратор снимает блокировку, повторно снять нечего
+	if err := service.Unlock(ctx, IPKey("10.0.0.1")); err != nil {
+		t.Fatalf("Ошибка снятия блокировки: %v", err)
+	}
+	if err := service.Check(ctx, utils.SubjectUser, "new@example.com", "10.0.0.1"); err != nil {
+		t.Errorf("Ожидается, что блокировка снята, получено %v", err)
+	}
+	expectCode(t, service.Unlock(ctx, IPKey("10.0.0.1")), 404)
+}
@@ -1,172 +1,176 @@
 package service
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
-	"kursovaya_backend/internal/database"
+	"kursovaya_backend/internal/audit"
 	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/rbac"
+	"kursovaya_backend/internal/repository"
 )
 
 // MappingService для работы с сопоставлениями товаров
 type MappingService struct {
+	repos *repository.Repositories
 }
 
 // NewMappingService создает новый сервис для работы с сопоставлениями
-func NewMappingService() *MappingService {
-	return &MappingService{}
+func NewMappingService(repos *repository.Repositories) *MappingService {
+	return &MappingService{
+		repos: repos,
+	}
 }
 
-// CreateMapping создает новое сопоставление между товарами
-func (ms *MappingService) CreateMapping(product1ID, product2ID, userID int) (*models.ProductMapping, error) {
-	// Проверяем, что пользователь может создать сопоставление для этих товаров
-	if err := ms.validateUserCanMapProducts(product1ID, product2ID, userID); err != nil {
-		return nil, err
-	}
+// CreateMapping создает новое сопоставление между товарами. Сопоставление
+// принадлежит организации, в которой находятся магазины обоих товаров.
+// Проверка прав, проверка дубликата и вставка выполняются в одной транзакции,
+// поэтому параллельные запросы не могут создать одинаковое сопоставление.
+func (ms *MappingService) CreateMapping(ctx context.Context, product1ID, product2ID, userID int) (*models.ProductMapping, error) {
+	var mapping *models.ProductMapping
+	err := ms.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		// Проверяем, что пользователь может создать сопоставление для этих товаров
+		organizationID, err := validateUserCanMapProducts(ctx, tx, product1ID, product2ID, userID)
+		if err != nil {
+			return err
+		}
 
-	// Проверяем, не существует ли уже такое сопоставление
-	var count int
-	err := database.DB.QueryRow(
-		"SELECT COUNT(*) FROM product_mappings WHERE (product1_id = $1 AND product2_id = $2) OR (product1_id = $2 AND product2_id = $1)",
-		product1ID, product2ID, product2ID, product1ID,
-	).Scan(&count)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка проверки существования сопоставления: %v", err)
-	}
-	if count > 0 {
-		return nil, fmt.Errorf("сопоставление между этими товарами уже существует")
-	}
+		// Проверяем, не существует ли уже такое сопоставление
+		exists, err := tx.Mappings.ExistsBetween(ctx, product1ID, product2ID)
+		if err != nil {
+			return fmt.Errorf("ошибка проверки существования сопоставления: %w", err)
+		}
+		if exists {
+			return fmt.Errorf("сопоставление между этими товарами уже существует")
+		}
 
-	// Создаем сопоставление
-	var mappingID int
-	err = database.DB.QueryRow(
-		"INSERT INTO product_mappings (product1_id, product2_id, user_id) VALUES ($1, $2, $3) RETURNING id",
-		product1ID, product2ID, userID,
-	).Scan(&mappingID)
+		// Создаем сопоставление
+		mapping, err = tx.Mappings.Create(ctx, organizationID, product1ID, product2ID, userID)
+		if err == repository.ErrDuplicate {
+			return fmt.Errorf("сопоставление между этими товарами уже существует")
+		}
+		if err != nil {
+			return fmt.Errorf("ошибка создания сопоставления: %w", err)
+		}
+		return audit.Record(ctx, tx.Audit, audit.Entry{Action: "mapping_created", TargetType: "mapping", TargetID: mapping.ID, After: mapping})
+	})
 	if err != nil {
-		return nil, fmt.Errorf("ошибка создания сопоставления: %v", err)
+		return nil, err
 	}
 
-	return &models.ProductMapping{
-		ID:         mappingID,
-		Product1ID: product1ID,
-		Product2ID: product2ID,
-		UserID:     userID,
-	}, nil
+	return mapping, nil
 }
 
-// GetMappingsByUser возвращает все сопоставления пользователя
-func (ms *MappingService) GetMappingsByUser(userID int) ([]*models.ProductMapping, error) {
-	query := `
-		SELECT id, product1_id, product2_id, user_id
-		FROM product_mappings
-		WHERE user_id = $1
-		ORDER BY created_at DESC
-	`
-
-	rows, err := database.DB.Query(query, userID)
+// GetMappingsByOrganization возвращает все сопоставления организации
+func (ms *MappingService) GetMappingsByOrganization(ctx context.Context, organizationID int) ([]*models.ProductMapping, error) {
+	mappings, err := ms.repos.Mappings.ListByOrganization(ctx, organizationID)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка запроса сопоставлений: %v", err)
 	}
-	defer rows.Close()
-
-	var mappings []*models.ProductMapping
-	for rows.Next() {
-		var mapping models.ProductMapping
-		err := rows.Scan(&mapping.ID, &mapping.Product1ID, &mapping.Product2ID, &mapping.UserID)
-		if err != nil {
-			return nil, fmt.Errorf("ошибка сканирования сопоставления: %v", err)
-		}
-		mappings = append(mappings, &mapping)
-	}
 
 	return mappings, nil
 }
 
-// DeleteMapping удаляет сопоставление
-func (ms *MappingService) DeleteMapping(mappingID, userID int) error {
-	result, err := database.DB.Exec(
-		"DELETE FROM product_mappings WHERE id = $1 AND user_id = $2",
-		mappingID, userID,
-	)
+// GetMappedProducts возвращает товары из сопоставлений организации, индексированные по ID.
+// Все товары загружаются одним запросом вместо двух запросов на каждое сопоставление.
+func (ms *MappingService) GetMappedProducts(ctx context.Context, organizationID int) (map[int]models.Product, error) {
+	products, err := ms.repos.Products.ListMappedByOrganization(ctx, organizationID)
 	if err != nil {
-		return fmt.Errorf("ошибка удаления сопоставления: %v", err)
+		return nil, fmt.Errorf("ошибка запроса товаров сопоставлений: %v", err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("ошибка получения количества измененных строк: %v", err)
+	byID := make(map[int]models.Product, len(products))
+	for _, product := range products {
+		byID[product.ID] = product
 	}
+	return byID, nil
+}
 
-	if rowsAffected == 0 {
+// DeleteMapping удаляет сопоставление. Удалить его может участник
+// организации-владельца с правом mappings:write.
+func (ms *MappingService) DeleteMapping(ctx context.Context, mappingID, userID int) error {
+	err := ms.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		mapping, err := tx.Mappings.GetByID(ctx, mappingID)
+		if err != nil {
+			return err
+		}
+		if _, err := requireMember(ctx, tx.Organizations, mapping.OrganizationID, userID, rbac.MappingsWrite); err != nil {
+			if err == errNoAccess {
+				return repository.ErrNotFound
+			}
+			return err
+		}
+		if err := tx.Mappings.DeleteByID(ctx, mappingID); err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx.Audit, audit.Entry{Action: "mapping_deleted", TargetType: "mapping", TargetID: mappingID, Before: mapping})
+	})
+	if err == repository.ErrNotFound {
 		return fmt.Errorf("сопоставление не найдено или не принадлежит пользователю")
 	}
+	if err != nil {
+		return fmt.Errorf("ошибка удаления сопоставления: %v", err)
+	}
 
 	return nil
 }
 
-// validateUserCanMapProducts проверяет, что пользователь может сопоставить два товара
-func (ms *MappingService) validateUserCanMapProducts(product1ID, product2ID, userID int) error {
+// validateUserCanMapProducts проверяет, что пользователь может сопоставить два товара,
+// и возвращает организацию, которой будет принадлежать сопоставление
+func validateUserCanMapProducts(ctx context.Context, repos *repository.Repositories, product1ID, product2ID, userID int) (int, error) {
 	// Валидация входных данных
 	if product1ID <= 0 || product2ID <= 0 || userID <= 0 {
-		return fmt.Errorf("некорректные ID товаров или пользователя")
+		return 0, fmt.Errorf("некорректные ID товаров или пользователя")
 	}
 
 	if product1ID == product2ID {
-		return fmt.Errorf("нельзя сопоставить товар с самим собой")
+		return 0, fmt.Errorf("нельзя сопоставить товар с самим собой")
 	}
 
 	// Получаем информацию о товарах
-	var store1ID, store2ID int
-	err := database.DB.QueryRow(
-		"SELECT store_id FROM products WHERE id = $1",
-		product1ID,
-	).Scan(&store1ID)
+	product1, err := repos.Products.GetByID(ctx, product1ID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return fmt.Errorf("первый товар с ID %d не найден", product1ID)
+		if err == repository.ErrNotFound {
+			return 0, fmt.Errorf("первый товар с ID %d не найден", product1ID)
 		}
-		return fmt.Errorf("ошибка получения информации о первом товаре: %v", err)
+		return 0, fmt.Errorf("ошибка получения информации о первом товаре: %w", err)
 	}
 
-	err = database.DB.QueryRow(
-		"SELECT store_id FROM products WHERE id = $1",
-		product2ID,
-	).Scan(&store2ID)
+	product2, err := repos.Products.GetByID(ctx, product2ID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return fmt.Errorf("второй товар с ID %d не найден", product2ID)
+		if err == repository.ErrNotFound {
+			return 0, fmt.Errorf("второй товар с ID %d не найден", product2ID)
 		}
-		return fmt.Errorf("ошибка получения информации о втором товаре: %v", err)
+		return 0, fmt.Errorf("ошибка получения информации о втором товаре: %w", err)
 	}
 
 	// Получаем информацию о магазинах
-	var user1ID, user2ID int
-	err = database.DB.QueryRow(
-		"SELECT user_id FROM stores WHERE id = $1",
-		store1ID,
-	).Scan(&user1ID)
+	store1, err := repos.Stores.GetByID(ctx, product1.StoreID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return fmt.Errorf("магазин первого товара не найден")
+		if err == repository.ErrNotFound {
+			return 0, fmt.Errorf("магазин первого товара не найден")
 		}
-		return fmt.Errorf("ошибка получения информации о первом магазине: %v", err)
+		return 0, fmt.Errorf("ошибка получения информации о первом магазине: %w", err)
 	}
 
-	err = database.DB.QueryRow(
-		"SELECT user_id FROM stores WHERE id = $1",
-		store2ID,
-	).Scan(&user2ID)
+	store2, err := repos.Stores.GetByID(ctx, product2.StoreID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return fmt.Errorf("магазин второго товара не найден")
+		if err == repository.ErrNotFound {
+			return 0, fmt.Errorf("магазин второго товара не найден")
 		}
-		return fmt.Errorf("ошибка получения информации о втором магазине: %v", err)
+		return 0, fmt.Errorf("ошибка получения информации о втором магазине: %w", err)
 	}
 
-	// Проверяем, что оба товара принадлежат пользователю
-	if user1ID != userID || user2ID != userID {
-		return fmt.Errorf("пользователь не может сопоставить товары, не принадлежащие ему")
+	// Оба товара должны принадлежать одной организации, а пользователь - состоять
+	// в ней с правом на изменение сопоставлений
+	if store1.OrganizationID != store2.OrganizationID {
+		return 0, fmt.Errorf("нельзя сопоставить товары из разных организаций")
+	}
+	if _, err := requireMember(ctx, repos.Organizations, store1.OrganizationID, userID, rbac.MappingsWrite); err != nil {
+		if err == errNoAccess {
+			return 0, fmt.Errorf("пользователь не может сопоставить товары, не принадлежащие ему")
+		}
+		return 0, fmt.Errorf("ошибка проверки членства в организации: %w", err)
 	}
 
-	return nil
-}
\ No newline at end of file
+	return store1.OrganizationID, nil
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/repository"
+)
+
+// seedMappingFixtures создает двух пользователей, у каждого личная организация
+// с магазином и двумя товарами
+func seedMappingFixtures(t *testing.T, repos *repository.Repositories) (userID, otherUserID int, products []int) {
+	t.Helper()
+	ctx := context.Background()
+
+	for i, email := range []string{"owner@example.com", "other@example.com"} {
+		user, err := repos.Users.Create(ctx, email, "hash")
+		if err != nil {
+			t.Fatalf("Ошибка создания пользователя: %v", err)
+		}
+		organization, err := createOrganization(ctx, repos, user.ID, email)
+		if err != nil {
+			t.Fatalf("Ошибка создания организации: %v", err)
+		}
+		store, err := repos.Stores.Create(ctx, organization.ID, user.ID, "wb", "token")
+		if err != nil {
+			t.Fatalf("Ошибка создания магазина: %v", err)
+		}
+		for j := 0; j < 2; j++ {
+			product := &models.Product{StoreID: store.ID, ExternalID: "ext", Name: "Товар"}
+			if err := repos.Products.Create(ctx, product); err != nil {
+				t.Fatalf("Ошибка создания товара: %v", err)
+			}
+			products = append(products, product.ID)
+		}
+		if i == 0 {
+			userID = user.ID
+		} else {
+			otherUserID = user.ID
+		}
+	}
+
+	return userID, otherUserID, products
+}
+
+// Тест создания и повторного создания сопоставления
+func TestCreateMappingDuplicate(t *testing.T) {
+	// Подготовка
+	repos := repository.NewMemory()
+	userID, _, products := seedMappingFixtures(t, repos)
+	mappingService := NewMappingService(repos)
+
+	// Выполнение
+	mapping, err := mappingService.CreateMapping(context.Background(), products[0], products[1], userID)
+	if err != nil {
+		t.Fatalf("Ожидается успешное создание сопоставления, получена ошибка: %v", err)
+	}
+	_, err = mappingService.CreateMapping(context.Background(), products[1], products[0], userID)
+
+	// Проверка
+	if mapping.UserID != userID {
+		t.Errorf("Ожидается владелец %d, получен %d", userID, mapping.UserID)
+	}
+	if err == nil {
+		t.Error("Ожидается ошибка при повторном сопоставлении тех же товаров, получено nil")
+	}
+}
+
+// Тест запрета сопоставления чужих товаров
+func TestCreateMappingForeignProducts(t *testing.T) {
+	// Подготовка
+	repos := repository.NewMemory()
+	_, otherUserID, products := seedMappingFixtures(t, repos)
+	mappingService := NewMappingService(repos)
+
+	// Выполнение
+	_, err := mappingService.CreateMapping(context.Background(), products[0], products[1], otherUserID)
+
+	// Проверка
+	if err == nil {
+		t.Error("Ожидается ошибка при сопоставлении чужих товаров, получено nil")
+	}
+}
+
+// Тест удаления сопоставления другим пользователем
+func TestDeleteMappingOwnership(t *testing.T) {
+	// Подготовка
+	repos := repository.NewMemory()
+	userID, otherUserID, products := seedMappingFixtures(t, repos)
+	mappingService := NewMappingService(repos)
+	mapping, err := mappingService.CreateMapping(context.Background(), products[0], products[1], userID)
+	if err != nil {
+		t.Fatalf("Ошибка создания сопоставления: %v", err)
+	}
+
+	// Выполнение и проверка
+	if err := mappingService.DeleteMapping(context.Background(), mapping.ID, otherUserID); err == nil {
+		t.Error("Ожидается ошибка при удалении чужого сопоставления, получено nil")
+	}
+	if err := mappingService.DeleteMapping(context.Background(), mapping.ID, userID); err != nil {
+		t.Errorf("Ожидается успешное удаление, получена ошибка: %v", err)
+	}
+}
+
+// Тест параллельного создания одного и того же сопоставления
+func TestCreateMappingConcurrent(t *testing.T) {
+	// Подготовка
+	repos := repository.NewMemory()
+	userID, _, products := seedMappingFixtures(t, repos)
+	mappingService := NewMappingService(repos)
+
+	// Выполнение
+	const workers = 10
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		go func(i int) {
+			p1, p2 := products[0], products[1]
+			if i%2 == 1 {
+				p1, p2 = p2, p1
+			}
+			_, err := mappingService.CreateMapping(context.Background(), p1, p2, userID)
+			errs <- err
+		}(i)
+	}
+
+	created := 0
+	for i := 0; i < workers; i++ {
+		if err := <-errs; err == nil {
+			created++
+		}
+	}
+
+	// Проверка
+	if created != 1 {
+		t.Errorf("Ожидается ровно одно созданное сопоставление, создано %d", created)
+	}
+	count, _ := repos.Mappings.Count(context.Background())
+	if count != 1 {
+		t.Errorf("Ожидается одна запись в хранилище, найдено %d", count)
+	}
+}
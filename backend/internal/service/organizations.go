@@ -0,0 +1,304 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"strings"
+	"time"
+
+	"kursovaya_backend/internal/errors"
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/rbac"
+	"kursovaya_backend/internal/repository"
+)
+
+// invitationTTL - срок действия приглашения в организацию
+const invitationTTL = 7 * 24 * time.Hour
+
+// errNoAccess возвращается, когда пользователь не состоит в организации
+// или его роль в ней не дает нужного разрешения
+var errNoAccess = stderrors.New("нет доступа к организации")
+
+// OrganizationService управляет организациями, их участниками и приглашениями
+type OrganizationService struct {
+	repos *repository.Repositories
+	now   func() time.Time
+}
+
+// NewOrganizationService создает новый сервис организаций
+func NewOrganizationService(repos *repository.Repositories) *OrganizationService {
+	return &OrganizationService{
+		repos: repos,
+		now:   time.Now,
+	}
+}
+
+// CreateOrganization создает организацию, в которой пользователь становится владельцем
+func (s *OrganizationService) CreateOrganization(ctx context.Context, userID int, name string) (*models.Organization, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.BadRequest("Название организации не может быть пустым", "Organization name cannot be empty")
+	}
+
+	var organization *models.Organization
+	err := s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		var err error
+		organization, err = createOrganization(ctx, tx, userID, name)
+		return err
+	})
+	if err != nil {
+		return nil, errors.InternalServerError("Ошибка создания организации", err.Error())
+	}
+	return organization, nil
+}
+
+// ListOrganizations возвращает организации пользователя вместе с его ролью в каждой
+func (s *OrganizationService) ListOrganizations(ctx context.Context, userID int) ([]models.OrganizationMember, error) {
+	memberships, err := s.repos.Organizations.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, errors.InternalServerError("Ошибка получения организаций", err.Error())
+	}
+	return memberships, nil
+}
+
+// ResolveMembership возвращает членство пользователя в организации. Если
+// organizationID равен нулю, возвращается организация по умолчанию.
+func (s *OrganizationService) ResolveMembership(ctx context.Context, userID, organizationID int) (*models.OrganizationMember, error) {
+	var member *models.OrganizationMember
+	var err error
+	if organizationID == 0 {
+		member, err = s.repos.Organizations.GetDefaultMembership(ctx, userID)
+	} else {
+		member, err = s.repos.Organizations.GetMember(ctx, organizationID, userID)
+	}
+	if err == repository.ErrNotFound {
+		return nil, errors.Forbidden("Пользователь не состоит в организации", "User is not a member of the organization")
+	}
+	if err != nil {
+		return nil, errors.InternalServerError("Ошибка получения организации", err.Error())
+	}
+	return member, nil
+}
+
+// ListMembers возвращает участников организации; доступно любому ее участнику
+func (s *OrganizationService) ListMembers(ctx context.Context, organizationID, actorID int) ([]models.OrganizationMember, error) {
+	if _, err := s.authorize(ctx, s.repos, organizationID, actorID, ""); err != nil {
+		return nil, err
+	}
+
+	members, err := s.repos.Organizations.ListMembers(ctx, organizationID)
+	if err != nil {
+		return nil, errors.InternalServerError("Ошибка получения участников", err.Error())
+	}
+	return members, nil
+}
+
+// InviteMember создает приглашение для email с указанной ролью. Возвращается
+// открытый токен приглашения: в базе хранится только его хеш.
+func (s *OrganizationService) InviteMember(ctx context.Context, organizationID, actorID int, email, role string) (*models.Invitation, string, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return nil, "", errors.BadRequest("Email не может быть пустым", "Email cannot be empty")
+	}
+	if !rbac.IsUserRole(role) {
+		return nil, "", errors.BadRequest("Неизвестная роль", "Unknown role: "+role)
+	}
+	if _, err := s.authorize(ctx, s.repos, organizationID, actorID, rbac.MembersWrite); err != nil {
+		return nil, "", err
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		return nil, "", errors.InternalServerError("Ошибка генерации приглашения", err.Error())
+	}
+
+	invitation := &models.Invitation{
+		OrganizationID: organizationID,
+		Email:          email,
+		Role:           role,
+		TokenHash:      hashToken(token),
+		InvitedBy:      actorID,
+		ExpiresAt:      s.now().Add(invitationTTL),
+	}
+	if err := s.repos.Organizations.CreateInvitation(ctx, invitation); err != nil {
+		return nil, "", errors.InternalServerError("Ошибка сохранения приглашения", err.Error())
+	}
+	return invitation, token, nil
+}
+
+// AcceptInvitation добавляет пользователя в организацию по токену приглашения.
+// Приглашение одноразовое и действует только для email, на который выписано.
+func (s *OrganizationService) AcceptInvitation(ctx context.Context, userID int, token string) (*models.OrganizationMember, error) {
+	var member *models.OrganizationMember
+	err := s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		invitation, err := tx.Organizations.GetInvitationByTokenHash(ctx, hashToken(token))
+		if err == repository.ErrNotFound {
+			return errors.NotFound("Приглашение не найдено", "Invitation not found")
+		}
+		if err != nil {
+			return err
+		}
+
+		now := s.now()
+		if invitation.AcceptedAt != nil {
+			return errors.BadRequest("Приглашение уже использовано", "Invitation has already been accepted")
+		}
+		if now.After(invitation.ExpiresAt) {
+			return errors.BadRequest("Срок действия приглашения истек", "Invitation has expired")
+		}
+
+		user, err := tx.Users.GetByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(user.Email, invitation.Email) {
+			return errors.Forbidden("Приглашение выписано на другой email", "Invitation email does not match the user")
+		}
+
+		err = tx.Organizations.AddMember(ctx, invitation.OrganizationID, userID, invitation.Role)
+		if err == repository.ErrDuplicate {
+			return errors.BadRequest("Пользователь уже состоит в организации", "User is already a member of the organization")
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.Organizations.MarkInvitationAccepted(ctx, invitation.ID, now); err != nil {
+			return err
+		}
+
+		member, err = tx.Organizations.GetMember(ctx, invitation.OrganizationID, userID)
+		return err
+	})
+	if err != nil {
+		return nil, appError(err, "Ошибка принятия приглашения")
+	}
+	return member, nil
+}
+
+// SetMemberRole меняет роль участника. Последнего владельца понизить нельзя,
+// иначе организацией станет некому управлять.
+func (s *OrganizationService) SetMemberRole(ctx context.Context, organizationID, actorID, memberID int, role string) error {
+	if !rbac.IsUserRole(role) {
+		return errors.BadRequest("Неизвестная роль", "Unknown role: "+role)
+	}
+
+	err := s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		if _, err := s.authorize(ctx, tx, organizationID, actorID, rbac.MembersWrite); err != nil {
+			return err
+		}
+
+		member, err := tx.Organizations.GetMember(ctx, organizationID, memberID)
+		if err == repository.ErrNotFound {
+			return errors.NotFound("Участник не найден", "Member not found")
+		}
+		if err != nil {
+			return err
+		}
+		if member.Role == rbac.RoleOwner && role != rbac.RoleOwner {
+			if err := ensureAnotherOwner(ctx, tx, organizationID); err != nil {
+				return err
+			}
+		}
+
+		return tx.Organizations.SetMemberRole(ctx, organizationID, memberID, role)
+	})
+	if err != nil {
+		return appError(err, "Ошибка изменения роли участника")
+	}
+	return nil
+}
+
+// RemoveMember исключает участника из организации. Владелец может исключить
+// любого участника, а любой участник может выйти сам.
+func (s *OrganizationService) RemoveMember(ctx context.Context, organizationID, actorID, memberID int) error {
+	err := s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		permission := rbac.MembersWrite
+		if actorID == memberID {
+			permission = ""
+		}
+		if _, err := s.authorize(ctx, tx, organizationID, actorID, permission); err != nil {
+			return err
+		}
+
+		member, err := tx.Organizations.GetMember(ctx, organizationID, memberID)
+		if err == repository.ErrNotFound {
+			return errors.NotFound("Участник не найден", "Member not found")
+		}
+		if err != nil {
+			return err
+		}
+		if member.Role == rbac.RoleOwner {
+			if err := ensureAnotherOwner(ctx, tx, organizationID); err != nil {
+				return err
+			}
+		}
+
+		return tx.Organizations.RemoveMember(ctx, organizationID, memberID)
+	})
+	if err != nil {
+		return appError(err, "Ошибка исключения участника")
+	}
+	return nil
+}
+
+// authorize проверяет доступ пользователя к организации и возвращает ошибку 403
+func (s *OrganizationService) authorize(ctx context.Context, repos *repository.Repositories, organizationID, userID int, permission rbac.Permission) (*models.OrganizationMember, error) {
+	member, err := requireMember(ctx, repos.Organizations, organizationID, userID, permission)
+	if err == errNoAccess {
+		return nil, errors.Forbidden("Недостаточно прав в организации", "User is not a member of the organization or lacks permission")
+	}
+	if err != nil {
+		return nil, errors.InternalServerError("Ошибка проверки членства", err.Error())
+	}
+	return member, nil
+}
+
+// requireMember возвращает членство пользователя в организации. Если задано
+// разрешение, роль участника должна его давать. Ошибка errNoAccess означает
+// отсутствие доступа, каждый сервис сообщает о ней в своих терминах.
+func requireMember(ctx context.Context, organizations repository.OrganizationRepository, organizationID, userID int, permission rbac.Permission) (*models.OrganizationMember, error) {
+	member, err := organizations.GetMember(ctx, organizationID, userID)
+	if err == repository.ErrNotFound {
+		return nil, errNoAccess
+	}
+	if err != nil {
+		return nil, err
+	}
+	if permission != "" && !rbac.HasPermission(member.Role, permission) {
+		return nil, errNoAccess
+	}
+	return member, nil
+}
+
+// createOrganization создает организацию с владельцем userID в транзакции tx
+func createOrganization(ctx context.Context, tx *repository.Repositories, userID int, name string) (*models.Organization, error) {
+	organization, err := tx.Organizations.Create(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Organizations.AddMember(ctx, organization.ID, userID, rbac.RoleOwner); err != nil {
+		return nil, err
+	}
+	return organization, nil
+}
+
+// ensureAnotherOwner запрещает изменение, после которого в организации не останется владельца
+func ensureAnotherOwner(ctx context.Context, tx *repository.Repositories, organizationID int) error {
+	owners, err := tx.Organizations.CountMembersWithRole(ctx, organizationID, rbac.RoleOwner)
+	if err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return errors.Forbidden("Нельзя исключить или понизить последнего владельца организации", "Organization must have at least one owner")
+	}
+	return nil
+}
+
+// appError возвращает AppError из транзакции как есть, а прочие ошибки оборачивает в 500
+func appError(err error, message string) error {
+	var appErr *errors.AppError
+	if stderrors.As(err, &appErr) {
+		return appErr
+	}
+	return errors.InternalServerError(message, err.Error())
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kursovaya_backend/internal/errors"
+	"kursovaya_backend/internal/rbac"
+	"kursovaya_backend/internal/repository"
+	"kursovaya_backend/internal/secrets"
+)
+
+// expectCode проверяет, что сервис вернул AppError с указанным кодом
+func expectCode(t *testing.T, err error, code int) {
+	t.Helper()
+	appErr, ok := err.(*errors.AppError)
+	if !ok || appErr.Code != code {
+		t.Errorf("Ожидается ошибка %d, получено %v", code, err)
+	}
+}
+
+// inviteAndAccept приглашает пользователя в личную организацию владельца с ролью role
+func inviteAndAccept(t *testing.T, repos *repository.Repositories, ownerID, userID int, email, role string) int {
+	t.Helper()
+	ctx := context.Background()
+	organizations := NewOrganizationService(repos)
+
+	membership, err := repos.Organizations.GetDefaultMembership(ctx, ownerID)
+	if err != nil {
+		t.Fatalf("Ошибка получения организации владельца: %v", err)
+	}
+	_, token, err := organizations.InviteMember(ctx, membership.OrganizationID, ownerID, email, role)
+	if err != nil {
+		t.Fatalf("Ошибка создания приглашения: %v", err)
+	}
+	if _, err := organizations.AcceptInvitation(ctx, userID, token); err != nil {
+		t.Fatalf("Ошибка принятия приглашения: %v", err)
+	}
+	return membership.OrganizationID
+}
+
+// Тест регистрации: пользователь получает личную организацию, где он владелец
+func TestRegisterCreatesPersonalOrganization(t *testing.T) {
+	repos := repository.NewMemory()
+	user, err := NewAuthService(repos).RegisterUser(context.Background(), "new@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Ошибка регистрации: %v", err)
+	}
+
+	membership, err := repos.Organizations.GetDefaultMembership(context.Background(), user.ID)
+	if err != nil || membership.Role != rbac.RoleOwner || membership.OrganizationName != "new@example.com" {
+		t.Errorf("Ожидается личная организация с ролью owner, получено %+v, %v", membership, err)
+	}
+}
+
+// Тест совместной работы: приглашенный редактор видит токены магазинов
+// и сопоставляет товары организации, но не удаляет ее магазины
+func TestInvitedEditorSharesStoresAndMappings(t *testing.T) {
+	// Подготовка
+	repos := repository.NewMemory()
+	ownerID, editorID, products := seedMappingFixtures(t, repos)
+	inviteAndAccept(t, repos, ownerID, editorID, "other@example.com", rbac.RoleEditor)
+	storeService := NewStoreService(repos, secrets.NewDatabaseStore())
+	mappingService := NewMappingService(repos)
+	product, _ := repos.Products.GetByID(context.Background(), products[0])
+
+	// Выполнение и проверка
+	mapping, err := mappingService.CreateMapping(context.Background(), products[0], products[1], editorID)
+	if err != nil {
+		t.Fatalf("Ожидается, что редактор создаст сопоставление, получена ошибка: %v", err)
+	}
+	if mapping.UserID != editorID || mapping.OrganizationID == 0 {
+		t.Errorf("Ожидается сопоставление организации от имени редактора, получено %+v", mapping)
+	}
+	if err := storeService.DeleteStore(context.Background(), product.StoreID, editorID); err == nil {
+		t.Error("Ожидается, что редактор не может удалить магазин организации")
+	}
+	if err := mappingService.DeleteMapping(context.Background(), mapping.ID, ownerID); err != nil {
+		t.Errorf("Ожидается, что владелец удалит сопоставление редактора, получена ошибка: %v", err)
+	}
+}
+
+// Тест запрета сопоставления товаров из разных организаций
+func TestCreateMappingAcrossOrganizations(t *testing.T) {
+	repos := repository.NewMemory()
+	ownerID, otherID, products := seedMappingFixtures(t, repos)
+	inviteAndAccept(t, repos, ownerID, otherID, "other@example.com", rbac.RoleOwner)
+
+	// Пользователь состоит в обеих организациях, но товары из разных организаций не сопоставляются
+	if _, err := NewMappingService(repos).CreateMapping(context.Background(), products[0], products[2], otherID); err == nil {
+		t.Error("Ожидается ошибка при сопоставлении товаров из разных организаций")
+	}
+}
+
+// Тест приглашения: только для указанного email, одноразовое и с ограниченным сроком
+func TestAcceptInvitationRules(t *testing.T) {
+	repos := repository.NewMemory()
+	ctx := context.Background()
+	ownerID, otherID, _ := seedMappingFixtures(t, repos)
+	organizations := NewOrganizationService(repos)
+	membership, _ := repos.Organizations.GetDefaultMembership(ctx, ownerID)
+
+	if _, _, err := organizations.InviteMember(ctx, membership.OrganizationID, otherID, "x@example.com", rbac.RoleViewer); err == nil {
+		t.Error("Ожидается, что приглашать может только участник с правом members:write")
+	}
+
+	_, token, err := organizations.InviteMember(ctx, membership.OrganizationID, ownerID, "someone@example.com", rbac.RoleViewer)
+	if err != nil {
+		t.Fatalf("Ошибка создания приглашения: %v", err)
+	}
+	_, err = organizations.AcceptInvitation(ctx, otherID, token)
+	expectCode(t, err, 403)
+
+	_, token, _ = organizations.InviteMember(ctx, membership.OrganizationID, ownerID, "other@example.com", rbac.RoleViewer)
+	organizations.now = func() time.Time { return time.Now().Add(invitationTTL + time.Hour) }
+	_, err = organizations.AcceptInvitation(ctx, otherID, token)
+	expectCode(t, err, 400)
+
+	organizations.now = time.Now
+	if _, err := organizations.AcceptInvitation(ctx, otherID, token); err != nil {
+		t.Fatalf("Ошибка принятия приглашения: %v", err)
+	}
+	_, err = organizations.AcceptInvitation(ctx, otherID, token)
+	expectCode(t, err, 400)
+}
+
+// Тест защиты последнего владельца организации
+func TestLastOwnerCannotLeave(t *testing.T) {
+	repos := repository.NewMemory()
+	ctx := context.Background()
+	ownerID, otherID, _ := seedMappingFixtures(t, repos)
+	organizationID := inviteAndAccept(t, repos, ownerID, otherID, "other@example.com", rbac.RoleViewer)
+	organizations := NewOrganizationService(repos)
+
+	expectCode(t, organizations.RemoveMember(ctx, organizationID, ownerID, ownerID), 403)
+	expectCode(t, organizations.SetMemberRole(ctx, organizationID, ownerID, ownerID, rbac.RoleEditor), 403)
+	expectCode(t, organizations.RemoveMember(ctx, organizationID, otherID, ownerID), 403)
+
+	// После назначения второго владельца первый может выйти
+	if err := organizations.SetMemberRole(ctx, organizationID, ownerID, otherID, rbac.RoleOwner); err != nil {
+		t.Fatalf("Ошибка назначения владельца: %v", err)
+	}
+	if err := organizations.RemoveMember(ctx, organizationID, ownerID, ownerID); err != nil {
+		t.Errorf("Ожидается, что владелец выйдет при наличии другого владельца, получена ошибка: %v", err)
+	}
+}
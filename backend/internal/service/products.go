@@ -1,31 +1,49 @@
 package service
 
 import (
+	"context"
 	"fmt"
-	"strings"
-	"kursovaya_backend/internal/database"
+	"kursovaya_backend/internal/metrics"
 	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/repository"
 	"kursovaya_backend/pkg/api"
+	"log/slog"
+	"time"
 )
 
+// syncErrorToken - категория загрузки, для которой не удалось получить токен магазина
+const syncErrorToken = "token"
+
 // ProductService для работы с товарами
 type ProductService struct {
+	stores   *StoreService
+	products repository.ProductRepository
+	syncRuns repository.SyncRunRepository
+	metrics  *metrics.Metrics
 }
 
-// NewProductService создает новый сервис для работы с товарами
-func NewProductService() *ProductService {
-	return &ProductService{}
+// NewProductService создает новый сервис для работы с товарами. Результат
+// каждой загрузки товаров магазина сохраняется в syncRuns для статистики
+// и учитывается в метриках m (m может быть nil).
+func NewProductService(stores *StoreService, products repository.ProductRepository, syncRuns repository.SyncRunRepository, m *metrics.Metrics) *ProductService {
+	return &ProductService{
+		stores:   stores,
+		products: products,
+		syncRuns: syncRuns,
+		metrics:  m,
+	}
 }
 
-// GetProductsByUser возвращает все товары пользователя из всех его магазинов
-func (ps *ProductService) GetProductsByUser(userID int) ([]api.Product, error) {
-	// Получаем магазины пользователя
-	stores, err := GetStoresByUser(userID)
+// GetProductsByOrganization возвращает товары из всех магазинов организации.
+// Токены магазинов выдаются от имени пользователя userID.
+func (ps *ProductService) GetProductsByOrganization(ctx context.Context, organizationID, userID int) ([]api.Product, error) {
+	// Получаем магазины организации, кроме приостановленных
+	stores, err := ps.stores.GetSyncableStores(ctx, organizationID)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка получения магазинов: %v", err)
 	}
 
-	// Если у пользователя нет магазинов - возвращаем пустой массив
+	// Если у организации нет магазинов - возвращаем пустой массив
 	if len(stores) == 0 {
 		return []api.Product{}, nil
 	}
@@ -33,10 +51,13 @@ func (ps *ProductService) GetProductsByUser(userID int) ([]api.Product, error) {
 	var allProducts []api.Product
 
 	for _, store := range stores {
+		startedAt := time.Now()
+
 		// Получаем токен магазина
-		token, err := GetStoreToken(store.ID, userID)
+		token, err := ps.stores.GetStoreToken(ctx, store.ID, userID)
 		if err != nil {
 			// Пропускаем магазин с ошибкой токена
+			ps.recordSync(ctx, store, startedAt, 0, syncErrorToken)
 			continue
 		}
 
@@ -56,12 +77,14 @@ func (ps *ProductService) GetProductsByUser(userID int) ([]api.Product, error) {
 		}
 
 		// Получаем товары из маркетплейса
-		products, err := client.GetProducts()
+		products, err := client.GetProducts(ctx)
 		if err != nil {
 			// Логируем ошибку, но не прерываем выполнение
-			fmt.Printf("Ошибка получения товаров из магазина %s (ID: %d): %v\n", store.Type, store.ID, err)
+			slog.WarnContext(ctx, "Ошибка получения товаров из магазина", "store_id", store.ID, "store_type", store.Type, "error", err)
+			ps.recordSync(ctx, store, startedAt, 0, api.ErrorCategory(err))
 			continue
 		}
+		ps.recordSync(ctx, store, startedAt, len(products), "")
 
 		// Добавляем товары к общему списку
 		allProducts = append(allProducts, products...)
@@ -70,66 +93,45 @@ func (ps *ProductService) GetProductsByUser(userID int) ([]api.Product, error) {
 	return allProducts, nil
 }
 
-// SaveProduct сохраняет товар в базу данных
-func (ps *ProductService) SaveProduct(product api.Product, storeID int) error {
-	_, err := database.DB.Exec(
-		"INSERT INTO products (store_id, external_id, name, price, quantity) VALUES ($1, $2, $3, $4, $5)",
-		storeID, product.ID, product.Name, product.Price, product.Quantity,
-	)
-	return err
-}
-
-// GetSavedProducts возвращает сохраненные товары пользователя из базы данных
-func (ps *ProductService) GetSavedProducts(userID int) ([]models.Product, error) {
-	// Получаем магазины пользователя
-	stores, err := GetStoresByUser(userID)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка получения магазинов: %v", err)
+// recordSync сохраняет результат загрузки товаров магазина; пустая категория
+// ошибки означает успешную загрузку. Ошибка записи только логируется, чтобы
+// не мешать выдаче товаров.
+func (ps *ProductService) recordSync(ctx context.Context, store *models.Store, startedAt time.Time, products int, errorCategory string) {
+	run := &models.SyncRun{
+		StoreID:       store.ID,
+		StoreType:     store.Type,
+		Status:        models.SyncSucceeded,
+		ErrorCategory: errorCategory,
+		Products:      products,
+		DurationMs:    time.Since(startedAt).Milliseconds(),
+		StartedAt:     startedAt,
 	}
-
-	// Формируем список ID магазинов
-	var storeIDs []int
-	for _, store := range stores {
-		storeIDs = append(storeIDs, store.ID)
+	if errorCategory != "" {
+		run.Status = models.SyncFailed
 	}
-
-	if len(storeIDs) == 0 {
-		return []models.Product{}, nil
+	ps.metrics.ObserveSync(store.Type, run.Status, errorCategory, time.Since(startedAt))
+	if err := ps.syncRuns.Create(ctx, run); err != nil {
+		slog.ErrorContext(ctx, "Ошибка сохранения результата загрузки магазина", "store_id", store.ID, "error", err)
 	}
+}
 
-	// Формируем плейсхолдеры для IN
-	args := make([]interface{}, len(storeIDs))
-	for i, id := range storeIDs {
-		args[i] = id
-	}
-
-	// Создаем строку с плейсхолдерами для PostgreSQL
-	var placeholders []string
-	for i := range storeIDs {
-		placeholders = append(placeholders, fmt.Sprintf("$%d", i+1))
-	}
-	placeholderStr := strings.Join(placeholders, ", ")
-
-	query := fmt.Sprintf(
-		"SELECT id, store_id, external_id, name, price, quantity FROM products WHERE store_id IN (%s)",
-		placeholderStr,
-	)
+// SaveProduct сохраняет товар в базу данных
+func (ps *ProductService) SaveProduct(ctx context.Context, product api.Product, storeID int) error {
+	return ps.products.Create(ctx, &models.Product{
+		StoreID:    storeID,
+		ExternalID: product.ID,
+		Name:       product.Name,
+		Price:      product.Price,
+		Quantity:   product.Quantity,
+	})
+}
 
-	rows, err := database.DB.Query(query, args...)
+// GetSavedProducts возвращает сохраненные товары организации из базы данных
+func (ps *ProductService) GetSavedProducts(ctx context.Context, organizationID int) ([]models.Product, error) {
+	products, err := ps.products.ListByOrganization(ctx, organizationID)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка запроса к базе: %v", err)
 	}
-	defer rows.Close()
-
-	var products []models.Product
-	for rows.Next() {
-		var product models.Product
-		err := rows.Scan(&product.ID, &product.StoreID, &product.ExternalID, &product.Name, &product.Price, &product.Quantity)
-		if err != nil {
-			return nil, fmt.Errorf("ошибка сканирования результата: %v", err)
-		}
-		products = append(products, product)
-	}
 
 	return products, nil
-}
\ No newline at end of file
+}
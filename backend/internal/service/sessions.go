@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	stderrors "errors"
+	"log/slog"
+	"time"
+
+	"kursovaya_backend/internal/config"
+	"kursovaya_backend/internal/errors"
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/repository"
+	"kursovaya_backend/pkg/utils"
+)
+
+// TokenPair - access-токен и refresh-токен, выдаваемые при входе и обновлении сессии
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	// ExpiresIn - время жизни access-токена в секундах
+	ExpiresIn int
+}
+
+// SessionService управляет сессиями пользователей: выдает пары токенов,
+// ротирует refresh-токены и отзывает сессии
+type SessionService struct {
+	repos *repository.Repositories
+	cfg   *config.Config
+	now   func() time.Time
+}
+
+// NewSessionService создает новый сервис сессий
+func NewSessionService(repos *repository.Repositories, cfg *config.Config) *SessionService {
+	return &SessionService{
+		repos: repos,
+		cfg:   cfg,
+		now:   time.Now,
+	}
+}
+
+// StartSession открывает новую сессию пользователя после входа или регистрации
+func (s *SessionService) StartSession(ctx context.Context, user *models.User) (*TokenPair, error) {
+	familyID, err := randomToken(16)
+	if err != nil {
+		return nil, errors.InternalServerError("Ошибка создания сессии", err.Error())
+	}
+	return s.issue(ctx, s.repos.Sessions, user, familyID)
+}
+
+// Refresh обменивает refresh-токен на новую пару токенов. Каждый refresh-токен
+// одноразовый: повторное предъявление уже обменянного токена означает, что он
+// украден, поэтому вся сессия отзывается.
+func (s *SessionService) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	var pair *TokenPair
+	var reused bool
+
+	err := s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		// Транзакция может быть перезапущена, флаг прошлой попытки не должен сохраниться
+		reused = false
+		session, err := tx.Sessions.GetByTokenHash(ctx, hashToken(refreshToken))
+		if err == repository.ErrNotFound {
+			return errors.Unauthorized("Недействительный refresh-токен", "Refresh token not found")
+		}
+		if err != nil {
+			return errors.InternalServerError("Ошибка получения сессии", err.Error())
+		}
+
+		if session.RevokedAt != nil {
+			return errors.Unauthorized("Сессия завершена", "Session has been revoked")
+		}
+
+		now := s.now()
+		if session.UsedAt != nil {
+			// Отзыв должен сохраниться, поэтому транзакция завершается без ошибки
+			reused = true
+			return tx.Sessions.RevokeFamily(ctx, session.FamilyID, now)
+		}
+		if now.After(session.ExpiresAt) {
+			return errors.Unauthorized("Срок действия сессии истек", "Refresh token has expired")
+		}
+
+		if err := tx.Sessions.MarkUsed(ctx, session.ID, now); err != nil {
+			return errors.InternalServerError("Ошибка обновления сессии", err.Error())
+		}
+
+		user, err := tx.Users.GetByID(ctx, session.UserID)
+		if err != nil {
+			return errors.Unauthorized("Пользователь сессии не найден", err.Error())
+		}
+
+		pair, err = s.issue(ctx, tx.Sessions, user, session.FamilyID)
+		return err
+	})
+	if err != nil {
+		var appErr *errors.AppError
+		if stderrors.As(err, &appErr) {
+			return nil, appErr
+		}
+		return nil, errors.InternalServerError("Ошибка обновления сессии", err.Error())
+	}
+
+	if reused {
+		slog.WarnContext(ctx, "Refresh token reuse detected, session family revoked", "security", true)
+		return nil, errors.Unauthorized("Сессия завершена", "Refresh token reuse detected, session has been revoked")
+	}
+
+	return pair, nil
+}
+
+// Logout отзывает сессию, к которой относится refresh-токен
+func (s *SessionService) Logout(ctx context.Context, refreshToken string) error {
+	session, err := s.repos.Sessions.GetByTokenHash(ctx, hashToken(refreshToken))
+	if err == repository.ErrNotFound {
+		return errors.Unauthorized("Недействительный refresh-токен", "Refresh token not found")
+	}
+	if err != nil {
+		return errors.InternalServerError("Ошибка получения сессии", err.Error())
+	}
+
+	if err := s.repos.Sessions.RevokeFamily(ctx, session.FamilyID, s.now()); err != nil {
+		return errors.InternalServerError("Ошибка завершения сессии", err.Error())
+	}
+	return nil
+}
+
+// issue сохраняет новый refresh-токен в семье familyID и выдает к нему access-токен
+func (s *SessionService) issue(ctx context.Context, sessions repository.SessionRepository, user *models.User, familyID string) (*TokenPair, error) {
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return nil, errors.InternalServerError("Ошибка генерации refresh-токена", err.Error())
+	}
+
+	session := &models.Session{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: s.now().Add(s.cfg.RefreshTokenTTL),
+	}
+	if err := sessions.Create(ctx, session); err != nil {
+		return nil, errors.InternalServerError("Ошибка сохранения сессии", err.Error())
+	}
+
+	accessToken, err := utils.GenerateAccessToken(user.ID, user.Email, user.Role, familyID, s.cfg.AccessTokenTTL)
+	if err != nil {
+		return nil, errors.InternalServerError("Ошибка генерации токена", err.Error())
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(s.cfg.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// randomToken возвращает случайную строку из size байт в base64url
+func randomToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken возвращает SHA-256 токена. В базе хранится только хеш, поэтому
+// утечка таблицы sessions не дает доступа к сессиям.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
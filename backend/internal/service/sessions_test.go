@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+	"kursovaya_backend/internal/config"
+	"kursovaya_backend/internal/errors"
+	"kursovaya_backend/internal/repository"
+	"kursovaya_backend/pkg/utils"
+)
+
+// newTestSessionService создает сервис сессий и одного пользователя в памяти
+func newTestSessionService(t *testing.T) (*SessionService, *repository.Repositories, *TokenPair) {
+	t.Helper()
+	utils.SetJWTKey("test-secret-key-with-at-least-32-characters")
+
+	repos := repository.NewMemory()
+	user, err := repos.Users.Create(context.Background(), "session@example.com", "hash")
+	if err != nil {
+		t.Fatalf("Ошибка создания пользователя: %v", err)
+	}
+
+	sessions := NewSessionService(repos, &config.Config{
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: time.Hour,
+	})
+	tokens, err := sessions.StartSession(context.Background(), user)
+	if err != nil {
+		t.Fatalf("Ошибка открытия сессии: %v", err)
+	}
+	return sessions, repos, tokens
+}
+
+// expectUnauthorized проверяет, что сервис вернул ошибку 401
+func expectUnauthorized(t *testing.T, err error) {
+	t.Helper()
+	appErr, ok := err.(*errors.AppError)
+	if !ok || appErr.Code != 401 {
+		t.Errorf("Ожидается ошибка 401, получено %v", err)
+	}
+}
+
+// sessionOf возвращает ID сессии, записанный в access-токене
+func sessionOf(t *testing.T, accessToken string) string {
+	t.Helper()
+	claims, err := utils.ParseUserToken(accessToken)
+	if err != nil {
+		t.Fatalf("Ошибка разбора access-токена: %v", err)
+	}
+	return claims.SessionID
+}
+
+// Тест ротации: новый refresh-токен выдается в той же сессии
+func TestRefreshRotatesToken(t *testing.T) {
+	sessions, repos, tokens := newTestSessionService(t)
+	ctx := context.Background()
+
+	refreshed, err := sessions.Refresh(ctx, tokens.RefreshToken)
+	if err != nil {
+		t.Fatalf("Ожидается успешное обновление, получена ошибка: %v", err)
+	}
+	if refreshed.RefreshToken == tokens.RefreshToken {
+		t.Error("Ожидается новый refresh-токен после ротации")
+	}
+	if refreshed.ExpiresIn != 900 {
+		t.Errorf("Ожидается срок жизни access-токена 900 секунд, получено %d", refreshed.ExpiresIn)
+	}
+	if sessionOf(t, refreshed.AccessToken) != sessionOf(t, tokens.AccessToken) {
+		t.Error("Ожидается, что новый access-токен относится к той же сессии")
+	}
+	if revoked, _ := repos.Sessions.IsFamilyRevoked(ctx, sessionOf(t, refreshed.AccessToken)); revoked {
+		t.Error("Ожидается, что сессия активна после ротации")
+	}
+}
+
+// Тест повторного использования: предъявление обменянного токена отзывает всю сессию
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	sessions, repos, tokens := newTestSessionService(t)
+	ctx := context.Background()
+
+	refreshed, err := sessions.Refresh(ctx, tokens.RefreshToken)
+	if err != nil {
+		t.Fatalf("Ожидается успешное обновление, получена ошибка: %v", err)
+	}
+
+	// Старый токен предъявлен повторно
+	_, err = sessions.Refresh(ctx, tokens.RefreshToken)
+	expectUnauthorized(t, err)
+
+	if revoked, _ := repos.Sessions.IsFamilyRevoked(ctx, sessionOf(t, tokens.AccessToken)); !revoked {
+		t.Error("Ожидается отзыв сессии после повторного использования токена")
+	}
+
+	// Токен, выданный при последней ротации, тоже больше не действует
+	_, err = sessions.Refresh(ctx, refreshed.RefreshToken)
+	expectUnauthorized(t, err)
+}
+
+// Тест истекшего refresh-токена
+func TestRefreshExpired(t *testing.T) {
+	sessions, _, tokens := newTestSessionService(t)
+	sessions.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+
+	_, err := sessions.Refresh(context.Background(), tokens.RefreshToken)
+	expectUnauthorized(t, err)
+}
+
+// Тест неизвестного refresh-токена
+func TestRefreshUnknownToken(t *testing.T) {
+	sessions, _, _ := newTestSessionService(t)
+
+	_, err := sessions.Refresh(context.Background(), "unknown-token")
+	expectUnauthorized(t, err)
+}
+
+// Тест выхода: сессия отзывается, refresh-токен больше не принимается
+func TestLogoutRevokesSession(t *testing.T) {
+	sessions, repos, tokens := newTestSessionService(t)
+	ctx := context.Background()
+
+	if err := sessions.Logout(ctx, tokens.RefreshToken); err != nil {
+		t.Fatalf("Ожидается успешный выход, получена ошибка: %v", err)
+	}
+	if revoked, _ := repos.Sessions.IsFamilyRevoked(ctx, sessionOf(t, tokens.AccessToken)); !revoked {
+		t.Error("Ожидается отзыв сессии после выхода")
+	}
+
+	_, err := sessions.Refresh(ctx, tokens.RefreshToken)
+	expectUnauthorized(t, err)
+}
@@ -0,0 +1,282 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"kursovaya_backend/internal/config"
+	"kursovaya_backend/internal/errors"
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/repository"
+)
+
+const (
+	// defaultStatsDays - период рядов роста и статистики загрузок по умолчанию
+	defaultStatsDays = 30
+	// defaultInactiveDays - через сколько дней без успешной загрузки магазин считается неактивным
+	defaultInactiveDays = 7
+	// topUsersLimit и inactiveStoresLimit ограничивают списки в статистике
+	topUsersLimit       = 10
+	inactiveStoresLimit = 50
+)
+
+// StatsQuery - параметры статистики. Days - период рядов роста и загрузок в
+// днях, включая текущий; InactiveDays - сколько дней без успешной загрузки
+// делают магазин неактивным. Нулевые значения заменяются значениями по умолчанию.
+type StatsQuery struct {
+	Days         int
+	InactiveDays int
+}
+
+// AdminStats - статистика админ-панели. Users, Stores, Products и Mappings -
+// общее число записей, остальные поля рассчитываются за период Days.
+type AdminStats struct {
+	Users    int `json:"users"`
+	Stores   int `json:"stores"`
+	Products int `json:"products"`
+	Mappings int `json:"mappings"`
+
+	Days           int                `json:"days"`
+	Growth         []DailyGrowth      `json:"growth"`
+	Marketplaces   []MarketplaceStats `json:"marketplaces"`
+	TopUsers       []TopUser          `json:"top_users"`
+	InactiveStores InactiveStores     `json:"inactive_stores"`
+	GeneratedAt    time.Time          `json:"generated_at"`
+}
+
+// DailyGrowth - число записей, созданных за день (UTC)
+type DailyGrowth struct {
+	Date     string `json:"date"`
+	Users    int    `json:"users"`
+	Stores   int    `json:"stores"`
+	Products int    `json:"products"`
+	Mappings int    `json:"mappings"`
+}
+
+// MarketplaceStats - магазины и товары маркетплейса и результаты загрузок за период
+type MarketplaceStats struct {
+	StoreType string    `json:"store_type"`
+	Stores    int       `json:"stores"`
+	Products  int       `json:"products"`
+	Sync      SyncStats `json:"sync"`
+}
+
+// SyncStats - результаты загрузок товаров. SuccessRate - доля успешных
+// загрузок от 0 до 1, Errors - число неудачных загрузок по категориям ошибок.
+type SyncStats struct {
+	Runs        int            `json:"runs"`
+	Succeeded   int            `json:"succeeded"`
+	Failed      int            `json:"failed"`
+	SuccessRate float64        `json:"success_rate"`
+	Errors      map[string]int `json:"errors"`
+}
+
+// TopUser - пользователь с одним из самых больших каталогов
+type TopUser struct {
+	UserID   int    `json:"user_id"`
+	Email    string `json:"email"`
+	Stores   int    `json:"stores"`
+	Products int    `json:"products"`
+}
+
+// InactiveStores - магазины без успешной загрузки начиная с Since. Items
+// содержит первые из них по ID, Total - их общее число.
+type InactiveStores struct {
+	Since time.Time       `json:"since"`
+	Total int             `json:"total"`
+	Items []InactiveStore `json:"items"`
+}
+
+// InactiveStore - неактивный магазин без токена и время его последней успешной загрузки
+type InactiveStore struct {
+	models.Store
+	LastSyncedAt *time.Time `json:"last_synced_at"`
+}
+
+// StatsService рассчитывает статистику админ-панели и кэширует ее на
+// StatsCacheTTL, чтобы панель не выполняла агрегирующие запросы при каждом
+// открытии
+type StatsService struct {
+	repos *repository.Repositories
+	ttl   time.Duration
+	now   func() time.Time
+
+	// mu защищает кэш; пересчет выполняется под ним, поэтому параллельные
+	// запросы ждут одного пересчета вместо того, чтобы повторять его
+	mu    sync.Mutex
+	cache map[StatsQuery]cachedStats
+}
+
+type cachedStats struct {
+	stats     *AdminStats
+	expiresAt time.Time
+}
+
+// NewStatsService создает новый сервис статистики
+func NewStatsService(repos *repository.Repositories, cfg *config.Config) *StatsService {
+	return &StatsService{
+		repos: repos,
+		ttl:   cfg.StatsCacheTTL,
+		now:   time.Now,
+		cache: make(map[StatsQuery]cachedStats),
+	}
+}
+
+// Get возвращает статистику из кэша или рассчитывает ее заново
+func (s *StatsService) Get(ctx context.Context, query StatsQuery) (*AdminStats, error) {
+	if query.Days <= 0 {
+		query.Days = defaultStatsDays
+	}
+	if query.InactiveDays <= 0 {
+		query.InactiveDays = defaultInactiveDays
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if cached, ok := s.cache[query]; ok && now.Before(cached.expiresAt) {
+		return cached.stats, nil
+	}
+
+	stats, err := s.compute(ctx, query, now)
+	if err != nil {
+		return nil, errors.InternalServerError("Ошибка получения статистики", err.Error())
+	}
+
+	// Устаревшие записи удаляются, чтобы кэш не рос с числом разных параметров
+	for key, cached := range s.cache {
+		if !now.Before(cached.expiresAt) {
+			delete(s.cache, key)
+		}
+	}
+	s.cache[query] = cachedStats{stats: stats, expiresAt: now.Add(s.ttl)}
+	return stats, nil
+}
+
+// compute выполняет запросы статистики на момент now
+func (s *StatsService) compute(ctx context.Context, query StatsQuery, now time.Time) (*AdminStats, error) {
+	stats := &AdminStats{Days: query.Days, GeneratedAt: now.UTC()}
+	var err error
+	if stats.Users, err = s.repos.Users.Count(ctx); err != nil {
+		return nil, err
+	}
+	if stats.Stores, err = s.repos.Stores.Count(ctx); err != nil {
+		return nil, err
+	}
+	if stats.Products, err = s.repos.Products.Count(ctx); err != nil {
+		return nil, err
+	}
+	if stats.Mappings, err = s.repos.Mappings.Count(ctx); err != nil {
+		return nil, err
+	}
+
+	// Период начинается в полночь UTC, чтобы каждый день был полным
+	today := now.UTC().Truncate(24 * time.Hour)
+	from := today.AddDate(0, 0, -(query.Days - 1))
+	if stats.Growth, err = s.growth(ctx, from, query.Days); err != nil {
+		return nil, err
+	}
+	if stats.Marketplaces, err = s.marketplaces(ctx, from); err != nil {
+		return nil, err
+	}
+
+	top, err := s.repos.Stats.TopUsersByProducts(ctx, topUsersLimit)
+	if err != nil {
+		return nil, err
+	}
+	stats.TopUsers = make([]TopUser, 0, len(top))
+	for _, user := range top {
+		stats.TopUsers = append(stats.TopUsers, TopUser(user))
+	}
+
+	since := now.UTC().AddDate(0, 0, -query.InactiveDays)
+	inactive, total, err := s.repos.Stats.InactiveStores(ctx, since, inactiveStoresLimit)
+	if err != nil {
+		return nil, err
+	}
+	stats.InactiveStores = InactiveStores{Since: since, Total: total, Items: make([]InactiveStore, 0, len(inactive))}
+	for _, store := range inactive {
+		stats.InactiveStores.Items = append(stats.InactiveStores.Items, InactiveStore{Store: store.Store, LastSyncedAt: store.LastSyncedAt})
+	}
+	return stats, nil
+}
+
+// growth возвращает число записей, созданных за каждый из days дней начиная
+// с from; дни без новых записей заполняются нулями
+func (s *StatsService) growth(ctx context.Context, from time.Time, days int) ([]DailyGrowth, error) {
+	growth := make([]DailyGrowth, days)
+	index := make(map[string]int, days)
+	for i := range growth {
+		growth[i].Date = from.AddDate(0, 0, i).Format(time.DateOnly)
+		index[growth[i].Date] = i
+	}
+
+	counters := map[string]func(*DailyGrowth) *int{
+		repository.StatsUsers:    func(day *DailyGrowth) *int { return &day.Users },
+		repository.StatsStores:   func(day *DailyGrowth) *int { return &day.Stores },
+		repository.StatsProducts: func(day *DailyGrowth) *int { return &day.Products },
+		repository.StatsMappings: func(day *DailyGrowth) *int { return &day.Mappings },
+	}
+	for entity, counter := range counters {
+		perDay, err := s.repos.Stats.CreatedPerDay(ctx, entity, from)
+		if err != nil {
+			return nil, err
+		}
+		for _, count := range perDay {
+			if day, ok := index[count.Day]; ok {
+				*counter(&growth[day]) += count.Count
+			}
+		}
+	}
+	return growth, nil
+}
+
+// marketplaces объединяет число магазинов и товаров по маркетплейсам с
+// результатами загрузок начиная с from, включая загрузки удаленных магазинов
+func (s *StatsService) marketplaces(ctx context.Context, from time.Time) ([]MarketplaceStats, error) {
+	counts, err := s.repos.Stats.CountByStoreType(ctx)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := s.repos.SyncRuns.Summary(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+
+	byType := make(map[string]*MarketplaceStats)
+	marketplace := func(storeType string) *MarketplaceStats {
+		stats, ok := byType[storeType]
+		if !ok {
+			stats = &MarketplaceStats{StoreType: storeType, Sync: SyncStats{Errors: map[string]int{}}}
+			byType[storeType] = stats
+		}
+		return stats
+	}
+	for _, count := range counts {
+		stats := marketplace(count.StoreType)
+		stats.Stores, stats.Products = count.Stores, count.Products
+	}
+	for _, row := range summary {
+		runs := &marketplace(row.StoreType).Sync
+		runs.Runs += row.Runs
+		if row.Status == models.SyncSucceeded {
+			runs.Succeeded += row.Runs
+		} else {
+			runs.Failed += row.Runs
+			runs.Errors[row.ErrorCategory] += row.Runs
+		}
+	}
+
+	marketplaces := make([]MarketplaceStats, 0, len(byType))
+	for _, stats := range byType {
+		if stats.Sync.Runs > 0 {
+			stats.Sync.SuccessRate = float64(stats.Sync.Succeeded) / float64(stats.Sync.Runs)
+		}
+		marketplaces = append(marketplaces, *stats)
+	}
+	sort.Slice(marketplaces, func(i, j int) bool { return marketplaces[i].StoreType < marketplaces[j].StoreType })
+	return marketplaces, nil
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kursovaya_backend/internal/config"
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/repository"
+	"kursovaya_backend/internal/secrets"
+)
+
+// Тест статистики админ-панели: ряды роста, загрузки по маркетплейсам,
+// неактивные магазины и кэширование
+func TestAdminStats(t *testing.T) {
+	ctx := context.Background()
+	repos := repository.NewMemory()
+	userID, otherUserID, _ := seedMappingFixtures(t, repos)
+	otherStores, _ := repos.Stores.ListByOrganization(ctx, mustDefaultOrganization(t, repos, otherUserID))
+
+	// Токен магазина чужой организации не выдается: загрузка записывается как неудачная
+	products := NewProductService(NewStoreService(repos, secrets.NewDatabaseStore()), repos.Products, repos.SyncRuns, nil)
+	if _, err := products.GetProductsByOrganization(ctx, mustDefaultOrganization(t, repos, userID), otherUserID); err != nil {
+		t.Fatalf("Ошибка загрузки товаров: %v", err)
+	}
+	now := time.Now()
+	repos.SyncRuns.Create(ctx, &models.SyncRun{StoreID: otherStores[0].ID, StoreType: "wb", Status: models.SyncSucceeded, Products: 2, StartedAt: now})
+
+	stats := NewStatsService(repos, &config.Config{StatsCacheTTL: time.Minute})
+	stats.now = func() time.Time { return now }
+	result, err := stats.Get(ctx, StatsQuery{})
+	if err != nil {
+		t.Fatalf("Ошибка получения статистики: %v", err)
+	}
+	if result.Users != 2 || result.Stores != 2 || result.Products != 4 || result.Days != defaultStatsDays || len(result.Growth) != defaultStatsDays {
+		t.Fatalf("Неожиданные итоги: %+v", result)
+	}
+	today := result.Growth[len(result.Growth)-1]
+	if today != (DailyGrowth{Date: now.UTC().Format(time.DateOnly), Users: 2, Stores: 2, Products: 4}) || result.Growth[0].Users != 0 {
+		t.Errorf("Неожиданный рост за сегодня: %+v", today)
+	}
+
+	if len(result.Marketplaces) != 1 {
+		t.Fatalf("Ожидается один маркетплейс, получено %+v", result.Marketplaces)
+	}
+	wb := result.Marketplaces[0]
+	if wb.StoreType != "wb" || wb.Stores != 2 || wb.Products != 4 || wb.Sync.Runs != 2 || wb.Sync.Failed != 1 ||
+		wb.Sync.SuccessRate != 0.5 || wb.Sync.Errors[syncErrorToken] != 1 {
+		t.Errorf("Неожиданная статистика маркетплейса: %+v", wb)
+	}
+	if len(result.TopUsers) != 2 || result.TopUsers[0] != (TopUser{UserID: userID, Email: "owner@example.com", Stores: 1, Products: 2}) {
+		t.Errorf("Неожиданный рейтинг пользователей: %+v", result.TopUsers)
+	}
+	if result.InactiveStores.Total != 0 {
+		t.Errorf("Новые магазины не должны считаться неактивными: %+v", result.InactiveStores)
+	}
+
+	// До истечения срока кэша статистика не пересчитывается
+	repos.Users.Create(ctx, "new@example.com", "hash")
+	if cached, _ := stats.Get(ctx, StatsQuery{}); cached.Users != 2 {
+		t.Errorf("Ожидается статистика из кэша, получено %d пользователей", cached.Users)
+	}
+
+	// Через 10 дней оба магазина неактивны: один не загружался ни разу
+	stats.now = func() time.Time { return now.Add(10 * 24 * time.Hour) }
+	result, err = stats.Get(ctx, StatsQuery{})
+	if err != nil || result.Users != 3 {
+		t.Fatalf("Ожидается пересчет после истечения кэша, получено %+v, %v", result, err)
+	}
+	inactive := result.InactiveStores
+	if inactive.Total != 2 || len(inactive.Items) != 2 || inactive.Items[0].LastSyncedAt != nil ||
+		inactive.Items[1].LastSyncedAt == nil || !inactive.Items[1].LastSyncedAt.Equal(now) {
+		t.Errorf("Неожиданные неактивные магазины: %+v", inactive)
+	}
+	if wb := result.Marketplaces[0]; wb.Sync.Runs != 2 {
+		t.Errorf("Загрузки 10-дневной давности входят в 30-дневный период, получено %+v", wb.Sync)
+	}
+	if result, _ := stats.Get(ctx, StatsQuery{Days: 7}); len(result.Growth) != 7 || result.Marketplaces[0].Sync.Runs != 0 {
+		t.Errorf("Ожидается 7-дневный период без загрузок, получено %+v", result)
+	}
+}
+
+// mustDefaultOrganization возвращает ID личной организации пользователя
+func mustDefaultOrganization(t *testing.T, repos *repository.Repositories, userID int) int {
+	t.Helper()
+	membership, err := repos.Organizations.GetDefaultMembership(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("Ошибка получения организации: %v", err)
+	}
+	return membership.OrganizationID
+}
@@ -1,22 +1,37 @@
 package service
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"strings"
-	"kursovaya_backend/internal/config"
-	"kursovaya_backend/internal/database"
+	"kursovaya_backend/internal/audit"
 	"kursovaya_backend/internal/errors"
 	"kursovaya_backend/internal/models"
-	"kursovaya_backend/pkg/utils"
+	"kursovaya_backend/internal/rbac"
+	"kursovaya_backend/internal/repository"
+	"kursovaya_backend/internal/secrets"
 )
 
-var cfg *config.Config
+// StoreService для работы с магазинами организаций. Токены маркетплейсов
+// хранятся в SecretStore, в таблице stores - только ссылка на них.
+type StoreService struct {
+	repos   *repository.Repositories
+	stores  repository.StoreRepository
+	secrets secrets.SecretStore
+}
 
-func InitStoreService(config *config.Config) {
-	cfg = config
+// NewStoreService создает новый сервис для работы с магазинами
+func NewStoreService(repos *repository.Repositories, secretStore secrets.SecretStore) *StoreService {
+	return &StoreService{
+		repos:   repos,
+		stores:  repos.Stores,
+		secrets: secretStore,
+	}
 }
 
-func AddStore(userID int, storeType, apiToken string) (*models.Store, error) {
+// AddStore добавляет магазин в организацию. Пользователь должен иметь в ней право stores:write.
+func (s *StoreService) AddStore(ctx context.Context, organizationID, userID int, storeType, apiToken string) (*models.Store, error) {
 	// Валидация входных данных
 	if userID <= 0 {
 		return nil, errors.BadRequest("Некорректный ID пользователя", "User ID must be positive")
@@ -34,91 +49,212 @@ func AddStore(userID int, storeType, apiToken string) (*models.Store, error) {
 		return nil, errors.BadRequest("API токен слишком короткий", "API token is too short, minimum length is 10 characters")
 	}
 
-	// Шифруем токен
-	encryptedToken, err := utils.EncryptString(apiToken, cfg.EncryptionKey)
+	// Проверяем, что хранилище токенов задано
+	if s.secrets == nil {
+		return nil, errors.InternalServerError("Хранилище токенов не инициализировано", "Store service secret store not initialized - cannot save token")
+	}
+
+	if _, err := requireMember(ctx, s.repos.Organizations, organizationID, userID, rbac.StoresWrite); err != nil {
+		if err == errNoAccess {
+			return nil, errors.Forbidden("Недостаточно прав для добавления магазина", "User cannot add stores to this organization")
+		}
+		return nil, errors.InternalServerError("Ошибка проверки членства", err.Error())
+	}
+
+	// Сохраняем токен в хранилище, в БД записываем ссылку на него
+	tokenRef, err := s.secrets.Put(ctx, apiToken)
 	if err != nil {
-		return nil, errors.InternalServerError("Ошибка шифрования токена", err.Error())
+		return nil, errors.InternalServerError("Ошибка сохранения токена", err.Error())
 	}
 
 	// Добавляем магазин в БД
-	var storeID int
-	err = database.DB.QueryRow(
-		"INSERT INTO stores (user_id, store_type, api_token) VALUES ($1, $2, $3) RETURNING id",
-		userID, storeType, encryptedToken,
-	).Scan(&storeID)
+	var store *models.Store
+	err = s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		var err error
+		if store, err = tx.Stores.Create(ctx, organizationID, userID, storeType, tokenRef); err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx.Audit, audit.Entry{Action: "store_created", TargetType: "store", TargetID: store.ID, After: store})
+	})
 	if err != nil {
+		s.deleteToken(ctx, tokenRef)
 		return nil, errors.InternalServerError("Ошибка сохранения магазина в БД", err.Error())
 	}
 
-	return &models.Store{
-		ID:     storeID,
-		UserID: userID,
-		Type:   storeType,
-	}, nil
+	return store, nil
 }
 
-func GetStoresByUser(userID int) ([]*models.Store, error) {
-	rows, err := database.DB.Query("SELECT id, user_id, store_type FROM stores WHERE user_id = $1", userID)
+// GetStoresByOrganization возвращает магазины организации
+func (s *StoreService) GetStoresByOrganization(ctx context.Context, organizationID int) ([]*models.Store, error) {
+	stores, err := s.stores.ListByOrganization(ctx, organizationID)
 	if err != nil {
-		return nil, errors.InternalServerError("Ошибка получения магазинов пользователя", err.Error())
+		return nil, errors.InternalServerError("Ошибка получения магазинов организации", err.Error())
 	}
-	defer rows.Close()
 
-	var stores []*models.Store
-	for rows.Next() {
-		var store models.Store
-		err := rows.Scan(&store.ID, &store.UserID, &store.Type)
-		if err != nil {
-			return nil, errors.InternalServerError("Ошибка сканирования магазина", err.Error())
-		}
-		stores = append(stores, &store)
+	return stores, nil
+}
+
+// GetSyncableStores возвращает магазины организации, данные которых можно
+// синхронизировать с маркетплейсом. Синхронизация приостанавливается, пока в
+// организации нет ни одного владельца, который не заблокирован и не удален;
+// кто из участников добавил магазин, не важно. Этим методом пользуются все
+// фоновые и ручные загрузки.
+func (s *StoreService) GetSyncableStores(ctx context.Context, organizationID int) ([]*models.Store, error) {
+	stores, err := s.GetStoresByOrganization(ctx, organizationID)
+	if err != nil || len(stores) == 0 {
+		return stores, err
 	}
 
+	active, err := s.hasActiveOwner(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if !active {
+		slog.InfoContext(ctx, "Синхронизация магазинов приостановлена: в организации нет активного владельца", "organization_id", organizationID)
+		return nil, nil
+	}
 	return stores, nil
 }
 
-func GetStoreToken(storeID, userID int) (string, error) {
-	var encryptedToken string
-	err := database.DB.QueryRow(
-		"SELECT api_token FROM stores WHERE id = $1 AND user_id = $2",
-		storeID, userID,
-	).Scan(&encryptedToken)
+// hasActiveOwner сообщает, есть ли в организации владелец, который не
+// заблокирован и не удален
+func (s *StoreService) hasActiveOwner(ctx context.Context, organizationID int) (bool, error) {
+	members, err := s.repos.Organizations.ListMembers(ctx, organizationID)
+	if err != nil {
+		return false, errors.InternalServerError("Ошибка получения участников организации", err.Error())
+	}
+	for _, member := range members {
+		if member.Role != rbac.RoleOwner {
+			continue
+		}
+		user, err := s.repos.Users.GetByID(ctx, member.UserID)
+		if err == repository.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return false, errors.InternalServerError("Ошибка получения пользователя", err.Error())
+		}
+		if user.Status != models.UserSuspended && user.Status != models.UserDeleted {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// GetStoreToken возвращает токен магазина из хранилища. Токен доступен
+// любому участнику организации, которой принадлежит магазин.
+func (s *StoreService) GetStoreToken(ctx context.Context, storeID, userID int) (string, error) {
+	store, err := s.stores.GetByID(ctx, storeID)
+	if err != nil {
+		return "", errors.NotFound("Магазин не найден или не принадлежит пользователю", err.Error())
+	}
+	if _, err := requireMember(ctx, s.repos.Organizations, store.OrganizationID, userID, rbac.StoresRead); err != nil {
+		return "", errors.NotFound("Магазин не найден или не принадлежит пользователю", err.Error())
+	}
+
+	tokenRef, err := s.stores.GetToken(ctx, storeID)
 	if err != nil {
 		return "", errors.NotFound("Магазин не найден или не принадлежит пользователю", err.Error())
 	}
 
-	// Проверяем, что cfg не nil
-	if cfg == nil {
-		return "", errors.InternalServerError("Конфигурация сервиса не инициализирована", "Store service configuration not initialized - cannot decrypt token")
+	// Проверяем, что хранилище токенов задано
+	if s.secrets == nil {
+		return "", errors.InternalServerError("Хранилище токенов не инициализировано", "Store service secret store not initialized - cannot read token")
 	}
 
-	// Расшифровываем токен
-	token, err := utils.DecryptString(encryptedToken, cfg.EncryptionKey)
+	// Получаем токен из хранилища
+	token, err := s.secrets.Get(ctx, tokenRef)
 	if err != nil {
-		return "", errors.InternalServerError("Ошибка расшифровки токена", err.Error())
+		return "", errors.InternalServerError("Ошибка получения токена", err.Error())
 	}
 
 	return token, nil
 }
 
-// DeleteStore удаляет магазин по ID
-func DeleteStore(storeID, userID int) error {
-	result, err := database.DB.Exec(
-		"DELETE FROM stores WHERE id = $1 AND user_id = $2",
-		storeID, userID,
-	)
+// DeleteStore удаляет магазин по ID вместе с его товарами и сопоставлениями.
+// Удалить магазин может участник организации-владельца с правом stores:write.
+// Все удаления выполняются в одной транзакции, токен удаляется из хранилища
+// после ее фиксации.
+func (s *StoreService) DeleteStore(ctx context.Context, storeID, userID int) error {
+	var tokenRef string
+	err := s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		store, err := tx.Stores.GetByID(ctx, storeID)
+		if err != nil {
+			return err
+		}
+		if _, err := requireMember(ctx, tx.Organizations, store.OrganizationID, userID, rbac.StoresWrite); err != nil {
+			if err == errNoAccess {
+				return repository.ErrNotFound
+			}
+			return err
+		}
+		tokenRef, err = deleteStoreRecords(ctx, tx, store)
+		return err
+	})
+	if err == repository.ErrNotFound {
+		return errors.Forbidden("Магазин не найден или не принадлежит пользователю", "Store not found or does not belong to user")
+	}
 	if err != nil {
 		return errors.InternalServerError("Ошибка при удалении магазина", err.Error())
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	s.deleteToken(ctx, tokenRef)
+
+	return nil
+}
+
+// DeleteStoreAsAdmin удаляет магазин по ID вместе с его товарами,
+// сопоставлениями и токеном без проверки членства в организации: права
+// проверяет маршрут администратора. Возвращает repository.ErrNotFound, если
+// магазина нет.
+func (s *StoreService) DeleteStoreAsAdmin(ctx context.Context, storeID int) error {
+	var tokenRef string
+	err := s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		store, err := tx.Stores.GetByID(ctx, storeID)
+		if err != nil {
+			return err
+		}
+		tokenRef, err = deleteStoreRecords(ctx, tx, store)
+		return err
+	})
 	if err != nil {
-		return errors.InternalServerError("Ошибка проверки количества измененных строк", err.Error())
+		return err
 	}
 
-	if rowsAffected == 0 {
-		return errors.Forbidden("Магазин не найден или не принадлежит пользователю", "Store not found or does not belong to user")
-	}
+	s.deleteToken(ctx, tokenRef)
 
 	return nil
-}
\ No newline at end of file
+}
+
+// deleteStoreRecords удаляет магазин вместе с товарами и сопоставлениями в
+// транзакции tx и возвращает ссылку на его токен, который вызывающий удаляет
+// из хранилища после фиксации транзакции
+func deleteStoreRecords(ctx context.Context, tx *repository.Repositories, store *models.Store) (string, error) {
+	tokenRef, err := tx.Stores.GetToken(ctx, store.ID)
+	if err != nil {
+		return "", err
+	}
+
+	// Сначала удаляем зависимые записи, чтобы не нарушить внешние ключи
+	if err := tx.Mappings.DeleteByStore(ctx, store.ID); err != nil {
+		return "", err
+	}
+	if err := tx.Products.DeleteByStore(ctx, store.ID); err != nil {
+		return "", err
+	}
+	if err := tx.Stores.DeleteByID(ctx, store.ID); err != nil {
+		return "", err
+	}
+	if err := audit.Record(ctx, tx.Audit, audit.Entry{Action: "store_deleted", TargetType: "store", TargetID: store.ID, Before: store}); err != nil {
+		return "", err
+	}
+	return tokenRef, nil
+}
+
+// deleteToken удаляет токен из хранилища. Ошибка только журналируется:
+// магазина уже нет, а оставшийся токен не доступен через API.
+func (s *StoreService) deleteToken(ctx context.Context, tokenRef string) {
+	if err := s.secrets.Delete(ctx, tokenRef); err != nil {
+		slog.ErrorContext(ctx, "Ошибка удаления токена магазина из хранилища", "error", err)
+	}
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"kursovaya_backend/internal/repository"
+	"kursovaya_backend/internal/secrets"
+	"kursovaya_backend/pkg/utils"
+)
+
+// useTestKeyring задает ключи шифрования токенов и секретов для теста
+func useTestKeyring(t *testing.T) {
+	t.Helper()
+	keyring, err := utils.NewKeyring("test", map[string]string{"test": "test-encryption-key"}, "")
+	if err != nil {
+		t.Fatalf("Ошибка создания ключей шифрования: %v", err)
+	}
+	utils.SetEncryptionKeyring(keyring)
+}
+
+// Тест удаления магазина вместе с товарами и сопоставлениями
+func TestDeleteStoreCascade(t *testing.T) {
+	// Подготовка
+	repos := repository.NewMemory()
+	userID, _, products := seedMappingFixtures(t, repos)
+	if _, err := NewMappingService(repos).CreateMapping(context.Background(), products[0], products[1], userID); err != nil {
+		t.Fatalf("Ошибка создания сопоставления: %v", err)
+	}
+	product, _ := repos.Products.GetByID(context.Background(), products[0])
+	storeService := NewStoreService(repos, secrets.NewDatabaseStore())
+
+	// Выполнение
+	err := storeService.DeleteStore(context.Background(), product.StoreID, userID)
+
+	// Проверка
+	if err != nil {
+		t.Fatalf("Ожидается успешное удаление магазина, получена ошибка: %v", err)
+	}
+	if count, _ := repos.Mappings.Count(context.Background()); count != 0 {
+		t.Errorf("Ожидается удаление сопоставлений магазина, осталось %d", count)
+	}
+	if count, _ := repos.Products.Count(context.Background()); count != 2 {
+		t.Errorf("Ожидается, что останутся только товары другого пользователя, осталось %d", count)
+	}
+}
+
+// Тест отказа в удалении чужого магазина без изменения данных
+func TestDeleteStoreForeignKeepsData(t *testing.T) {
+	// Подготовка
+	repos := repository.NewMemory()
+	_, otherUserID, products := seedMappingFixtures(t, repos)
+	product, _ := repos.Products.GetByID(context.Background(), products[0])
+	storeService := NewStoreService(repos, secrets.NewDatabaseStore())
+
+	// Выполнение
+	err := storeService.DeleteStore(context.Background(), product.StoreID, otherUserID)
+
+	// Проверка
+	if err == nil {
+		t.Error("Ожидается ошибка при удалении чужого магазина, получено nil")
+	}
+	if count, _ := repos.Products.Count(context.Background()); count != 4 {
+		t.Errorf("Ожидается, что товары не изменятся, найдено %d", count)
+	}
+}
+
+// Тест хранения токена во внешнем хранилище: в БД только ссылка,
+// токен удаляется из хранилища вместе с магазином
+func TestStoreTokenInSecretStore(t *testing.T) {
+	// Подготовка
+	ctx := context.Background()
+	repos := repository.NewMemory()
+	userID, otherUserID, _ := seedMappingFixtures(t, repos)
+	membership, _ := repos.Organizations.GetDefaultMembership(ctx, userID)
+	secretStore := secrets.NewFileStore(t.TempDir())
+	storeService := NewStoreService(repos, secretStore)
+
+	// Выполнение
+	store, err := storeService.AddStore(ctx, membership.OrganizationID, userID, "ozon", "ozon-token-123")
+	if err != nil {
+		t.Fatalf("Ошибка добавления магазина: %v", err)
+	}
+
+	// Проверка
+	ref, _ := repos.Stores.GetToken(ctx, store.ID)
+	if !secrets.External(ref) || strings.Contains(ref, "ozon-token-123") {
+		t.Errorf("Ожидается ссылка на хранилище вместо токена, получено %q", ref)
+	}
+	if token, err := storeService.GetStoreToken(ctx, store.ID, userID); err != nil || token != "ozon-token-123" {
+		t.Errorf("Ожидается токен из хранилища, получено %q, %v", token, err)
+	}
+	if _, err := storeService.GetStoreToken(ctx, store.ID, otherUserID); err == nil {
+		t.Error("Ожидается, что чужой пользователь не получит токен")
+	}
+
+	if err := storeService.DeleteStore(ctx, store.ID, userID); err != nil {
+		t.Fatalf("Ошибка удаления магазина: %v", err)
+	}
+	if _, err := secretStore.Get(ctx, ref); err != secrets.ErrNotFound {
+		t.Errorf("Ожидается удаление токена из хранилища, получено %v", err)
+	}
+}
@@ -0,0 +1,355 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	stderrors "errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"kursovaya_backend/internal/audit"
+	"kursovaya_backend/internal/config"
+	"kursovaya_backend/internal/errors"
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/repository"
+	"kursovaya_backend/pkg/utils"
+)
+
+const (
+	// totpIssuer отображается в приложении-аутентификаторе рядом с именем учетной записи
+	totpIssuer = "Marketplace Tracker"
+	// recoveryCodeCount - сколько кодов восстановления выдается за раз
+	recoveryCodeCount = 10
+	// recoveryCodeLength - длина кода восстановления без дефиса
+	recoveryCodeLength = 10
+	// mfaChallengeTTL - время, за которое нужно ввести код после проверки пароля
+	mfaChallengeTTL = 5 * time.Minute
+)
+
+// TwoFactorSetup - данные для подключения приложения-аутентификатора
+type TwoFactorSetup struct {
+	Secret string `json:"secret"`
+	// ProvisioningURI - otpauth:// URI для QR-кода
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// MFAChallenge - токен второго шага входа, выдаваемый после проверки пароля
+type MFAChallenge struct {
+	Token string
+	// ExpiresIn - время жизни токена в секундах
+	ExpiresIn int
+}
+
+// TwoFactorService управляет двухфакторной аутентификацией (TOTP, RFC 6238)
+// пользователей и администраторов. subjectType - utils.SubjectUser или utils.SubjectAdmin.
+type TwoFactorService struct {
+	repos *repository.Repositories
+	cfg   *config.Config
+	now   func() time.Time
+}
+
+// NewTwoFactorService создает новый сервис двухфакторной аутентификации
+func NewTwoFactorService(repos *repository.Repositories, cfg *config.Config) *TwoFactorService {
+	return &TwoFactorService{
+		repos: repos,
+		cfg:   cfg,
+		now:   time.Now,
+	}
+}
+
+// Required сообщает, обязательна ли двухфакторная аутентификация для субъекта
+func (s *TwoFactorService) Required(subjectType string) bool {
+	return subjectType == utils.SubjectAdmin && s.cfg.AdminRequire2FA
+}
+
+// Setup создает новый секрет. Пока он не подтвержден кодом через Confirm,
+// вход выполняется только по паролю, а повторный Setup заменяет секрет.
+func (s *TwoFactorService) Setup(ctx context.Context, subjectType string, subjectID int) (*TwoFactorSetup, error) {
+	account, err := s.accountName(ctx, subjectType, subjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, errors.InternalServerError("Ошибка генерации секрета", err.Error())
+	}
+	encrypted, err := utils.EncryptString(secret)
+	if err != nil {
+		return nil, errors.InternalServerError("Ошибка шифрования секрета", err.Error())
+	}
+
+	err = s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		existing, err := tx.TwoFactor.Get(ctx, subjectType, subjectID)
+		if err != nil && err != repository.ErrNotFound {
+			return err
+		}
+		if existing != nil {
+			if existing.ConfirmedAt != nil {
+				return errors.BadRequest("Двухфакторная аутентификация уже включена", "Two-factor authentication is already enabled")
+			}
+			if err := tx.TwoFactor.Delete(ctx, subjectType, subjectID); err != nil {
+				return err
+			}
+		}
+		return tx.TwoFactor.Create(ctx, &models.TwoFactor{
+			SubjectType: subjectType,
+			SubjectID:   subjectID,
+			Secret:      encrypted,
+		})
+	})
+	if err != nil {
+		return nil, appError(err, "Ошибка настройки двухфакторной аутентификации")
+	}
+
+	return &TwoFactorSetup{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(totpIssuer, account, secret),
+	}, nil
+}
+
+// Confirm включает двухфакторную аутентификацию после проверки первого кода
+// и возвращает коды восстановления. Они показываются только один раз.
+func (s *TwoFactorService) Confirm(ctx context.Context, subjectType string, subjectID int, code string) ([]string, error) {
+	var codes []string
+	err := s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		twoFactor, err := tx.TwoFactor.Get(ctx, subjectType, subjectID)
+		if err == repository.ErrNotFound {
+			return errors.BadRequest("Настройка двухфакторной аутентификации не начата", "Call setup before confirming")
+		}
+		if err != nil {
+			return err
+		}
+		if twoFactor.ConfirmedAt != nil {
+			return errors.BadRequest("Двухфакторная аутентификация уже включена", "Two-factor authentication is already enabled")
+		}
+
+		if err := s.checkTOTP(ctx, tx, twoFactor, code); err != nil {
+			return err
+		}
+		if err := tx.TwoFactor.Confirm(ctx, subjectType, subjectID, s.now()); err != nil {
+			return err
+		}
+		if codes, err = replaceRecoveryCodes(ctx, tx, subjectType, subjectID); err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx.Audit, audit.Entry{Action: "two_factor_enabled", TargetType: subjectType, TargetID: subjectID})
+	})
+	if err != nil {
+		return nil, appError(err, "Ошибка подтверждения двухфакторной аутентификации")
+	}
+	return codes, nil
+}
+
+// Enabled сообщает, включена ли двухфакторная аутентификация
+func (s *TwoFactorService) Enabled(ctx context.Context, subjectType string, subjectID int) (bool, error) {
+	twoFactor, err := s.repos.TwoFactor.Get(ctx, subjectType, subjectID)
+	if err == repository.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.InternalServerError("Ошибка проверки двухфакторной аутентификации", err.Error())
+	}
+	return twoFactor.ConfirmedAt != nil, nil
+}
+
+// Verify проверяет код из приложения или код восстановления. Каждый код
+// принимается только один раз.
+func (s *TwoFactorService) Verify(ctx context.Context, subjectType string, subjectID int, code string) error {
+	err := s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		return s.verify(ctx, tx, subjectType, subjectID, code)
+	})
+	if err != nil {
+		return appError(err, "Ошибка проверки кода")
+	}
+	return nil
+}
+
+// Disable отключает двухфакторную аутентификацию после проверки кода.
+// Администраторы не могут отключить ее, если она обязательна.
+func (s *TwoFactorService) Disable(ctx context.Context, subjectType string, subjectID int, code string) error {
+	if s.Required(subjectType) {
+		return errors.Forbidden("Двухфакторная аутентификация обязательна для администраторов", "ADMIN_REQUIRE_2FA is enabled")
+	}
+
+	err := s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		if err := s.verify(ctx, tx, subjectType, subjectID, code); err != nil {
+			return err
+		}
+		if err := tx.TwoFactor.Delete(ctx, subjectType, subjectID); err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx.Audit, audit.Entry{Action: "two_factor_disabled", TargetType: subjectType, TargetID: subjectID})
+	})
+	if err != nil {
+		return appError(err, "Ошибка отключения двухфакторной аутентификации")
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes выдает новый набор кодов восстановления взамен старого
+func (s *TwoFactorService) RegenerateRecoveryCodes(ctx context.Context, subjectType string, subjectID int, code string) ([]string, error) {
+	var codes []string
+	err := s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		if err := s.verify(ctx, tx, subjectType, subjectID, code); err != nil {
+			return err
+		}
+		var err error
+		if codes, err = replaceRecoveryCodes(ctx, tx, subjectType, subjectID); err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx.Audit, audit.Entry{Action: "recovery_codes_regenerated", TargetType: subjectType, TargetID: subjectID})
+	})
+	if err != nil {
+		return nil, appError(err, "Ошибка генерации кодов восстановления")
+	}
+	return codes, nil
+}
+
+// Reset отключает двухфакторную аутентификацию без кода. Используется
+// администратором, когда пользователь потерял доступ к приложению и кодам.
+func (s *TwoFactorService) Reset(ctx context.Context, subjectType string, subjectID int) error {
+	err := s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		if err := tx.TwoFactor.Delete(ctx, subjectType, subjectID); err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx.Audit, audit.Entry{Action: "two_factor_reset", TargetType: subjectType, TargetID: subjectID})
+	})
+	if err == repository.ErrNotFound {
+		return errors.NotFound("Двухфакторная аутентификация не включена", "Two-factor authentication is not configured")
+	}
+	if err != nil {
+		return errors.InternalServerError("Ошибка сброса двухфакторной аутентификации", err.Error())
+	}
+	return nil
+}
+
+// Challenge выдает токен второго шага входа после успешной проверки пароля
+func (s *TwoFactorService) Challenge(subjectType string, subjectID int) (*MFAChallenge, error) {
+	token, err := utils.GenerateMFAToken(subjectType, subjectID, rand.Text(), mfaChallengeTTL)
+	if err != nil {
+		return nil, errors.InternalServerError("Ошибка генерации токена", err.Error())
+	}
+	return &MFAChallenge{Token: token, ExpiresIn: int(mfaChallengeTTL.Seconds())}, nil
+}
+
+// CompleteChallenge проверяет токен второго шага и код, возвращая ID субъекта.
+// Код проверяется под защитой lockout, как пароль: неверный код учитывается
+// как неудачная попытка входа учетной записи и IP-адреса ip, а после
+// нескольких неверных кодов токен перестает приниматься.
+func (s *TwoFactorService) CompleteChallenge(ctx context.Context, lockout *LockoutService, subjectType, token, code, ip string) (int, error) {
+	claims, err := utils.ParseMFAToken(token, subjectType)
+	if err == nil && claims.ID == "" {
+		err = stderrors.New("challenge ID is missing")
+	}
+	if err != nil {
+		return 0, errors.Unauthorized("Недействительный или просроченный токен входа", err.Error())
+	}
+
+	account, err := s.accountName(ctx, subjectType, claims.UserID)
+	if err != nil {
+		return 0, err
+	}
+	if err := lockout.CheckChallenge(ctx, subjectType, account, ip, claims.ID); err != nil {
+		return 0, err
+	}
+
+	if err := s.Verify(ctx, subjectType, claims.UserID, code); err != nil {
+		if appErr, ok := err.(*errors.AppError); ok && appErr.Code == http.StatusUnauthorized {
+			if lockErr := lockout.ChallengeFailure(ctx, subjectType, account, ip, claims.ID); lockErr != nil {
+				errors.LogError(ctx, lockErr)
+			}
+		}
+		return 0, err
+	}
+	if err := lockout.Success(ctx, subjectType, account); err != nil {
+		errors.LogError(ctx, err)
+	}
+	return claims.UserID, nil
+}
+
+// verify проверяет код в транзакции tx. Код вида XXXXX-XXXXX считается кодом
+// восстановления, остальные - кодом TOTP.
+func (s *TwoFactorService) verify(ctx context.Context, tx *repository.Repositories, subjectType string, subjectID int, code string) error {
+	twoFactor, err := tx.TwoFactor.Get(ctx, subjectType, subjectID)
+	if err == repository.ErrNotFound || (err == nil && twoFactor.ConfirmedAt == nil) {
+		return errors.BadRequest("Двухфакторная аутентификация не включена", "Two-factor authentication is not enabled")
+	}
+	if err != nil {
+		return err
+	}
+
+	if normalized := normalizeRecoveryCode(code); len(normalized) == recoveryCodeLength {
+		err := tx.TwoFactor.UseRecoveryCode(ctx, subjectType, subjectID, hashToken(normalized), s.now())
+		if err == repository.ErrNotFound {
+			return errors.Unauthorized("Неверный код", "Recovery code is invalid or already used")
+		}
+		return err
+	}
+	return s.checkTOTP(ctx, tx, twoFactor, code)
+}
+
+// checkTOTP проверяет код TOTP и запоминает его шаг, чтобы код нельзя было повторить
+func (s *TwoFactorService) checkTOTP(ctx context.Context, tx *repository.Repositories, twoFactor *models.TwoFactor, code string) error {
+	secret, err := utils.DecryptString(twoFactor.Secret)
+	if err != nil {
+		return errors.InternalServerError("Ошибка расшифровки секрета", err.Error())
+	}
+
+	step, ok := utils.ValidateTOTP(secret, strings.TrimSpace(code), s.now())
+	if !ok {
+		return errors.Unauthorized("Неверный код", "TOTP code is invalid")
+	}
+	err = tx.TwoFactor.MarkStepUsed(ctx, twoFactor.SubjectType, twoFactor.SubjectID, step)
+	if err == repository.ErrNotFound {
+		return errors.Unauthorized("Код уже использован", "TOTP code has already been used")
+	}
+	return err
+}
+
+// accountName возвращает имя учетной записи для приложения-аутентификатора
+func (s *TwoFactorService) accountName(ctx context.Context, subjectType string, subjectID int) (string, error) {
+	switch subjectType {
+	case utils.SubjectUser:
+		user, err := s.repos.Users.GetByID(ctx, subjectID)
+		if err != nil {
+			return "", errors.InternalServerError("Ошибка получения пользователя", err.Error())
+		}
+		return user.Email, nil
+	case utils.SubjectAdmin:
+		admin, err := s.repos.Admins.GetByID(ctx, subjectID)
+		if err != nil {
+			return "", errors.InternalServerError("Ошибка получения администратора", err.Error())
+		}
+		return admin.Username, nil
+	}
+	return "", errors.BadRequest("Неизвестный тип учетной записи", "Unknown subject type: "+subjectType)
+}
+
+// replaceRecoveryCodes генерирует новые коды восстановления и сохраняет их хеши
+func replaceRecoveryCodes(ctx context.Context, tx *repository.Repositories, subjectType string, subjectID int) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := base32.StdEncoding.EncodeToString(b)[:recoveryCodeLength]
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hashToken(code)
+	}
+
+	if err := tx.TwoFactor.ReplaceRecoveryCodes(ctx, subjectType, subjectID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode убирает дефисы и пробелы, чтобы код можно было ввести в любом виде
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
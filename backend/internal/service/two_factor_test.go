@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"kursovaya_backend/internal/config"
+	"kursovaya_backend/internal/rbac"
+	"kursovaya_backend/internal/repository"
+	"kursovaya_backend/pkg/utils"
+)
+
+// newTestTwoFactorService создает сервис с управляемыми часами и одного пользователя
+func newTestTwoFactorService(t *testing.T, cfg *config.Config) (*TwoFactorService, *repository.Repositories, *time.Time) {
+	t.Helper()
+	utils.SetJWTKey("test-secret-key-with-at-least-32-characters")
+	useTestKeyring(t)
+
+	repos := repository.NewMemory()
+	if _, err := repos.Users.Create(context.Background(), "mfa@example.com", "hash"); err != nil {
+		t.Fatalf("Ошибка создания пользователя: %v", err)
+	}
+	if _, err := repos.Admins.Create(context.Background(), "admin", "hash", rbac.DefaultAdminRole, false); err != nil {
+		t.Fatalf("Ошибка создания администратора: %v", err)
+	}
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	service := NewTwoFactorService(repos, cfg)
+	service.now = func() time.Time { return now }
+	return service, repos, &now
+}
+
+// enableTwoFactor проходит настройку и возвращает секрет и коды восстановления
+func enableTwoFactor(t *testing.T, service *TwoFactorService, subjectType string, subjectID int) (string, []string) {
+	t.Helper()
+	ctx := context.Background()
+
+	setup, err := service.Setup(ctx, subjectType, subjectID)
+	if err != nil {
+		t.Fatalf("Ошибка настройки 2FA: %v", err)
+	}
+	codes, err := service.Confirm(ctx, subjectType, subjectID, totpAt(t, setup.Secret, service.now()))
+	if err != nil {
+		t.Fatalf("Ошибка подтверждения 2FA: %v", err)
+	}
+	return setup.Secret, codes
+}
+
+// totpAt возвращает код TOTP для момента времени
+func totpAt(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+	code, err := utils.TOTPCode(secret, utils.TOTPStep(at))
+	if err != nil {
+		t.Fatalf("Ошибка вычисления кода: %v", err)
+	}
+	return code
+}
+
+func TestTwoFactorSetupAndConfirm(t *testing.T) {
+	service, repos, _ := newTestTwoFactorService(t, &config.Config{})
+	ctx := context.Background()
+
+	setup, err := service.Setup(ctx, utils.SubjectUser, 1)
+	if err != nil {
+		t.Fatalf("Ошибка настройки 2FA: %v", err)
+	}
+	if !strings.HasPrefix(setup.ProvisioningURI, "otpauth://totp/Marketplace%20Tracker:mfa@example.com?") {
+		t.Errorf("Неожиданный URI: %s", setup.ProvisioningURI)
+	}
+	stored, _ := repos.TwoFactor.Get(ctx, utils.SubjectUser, 1)
+	if stored.Secret == setup.Secret {
+		t.Error("Секрет должен храниться в зашифрованном виде")
+	}
+
+	// До подтверждения 2FA не действует
+	if enabled, _ := service.Enabled(ctx, utils.SubjectUser, 1); enabled {
+		t.Error("Ожидается, что неподтвержденная 2FA не включена")
+	}
+	_, err = service.Confirm(ctx, utils.SubjectUser, 1, "000000")
+	expectCode(t, err, 401)
+
+	codes, err := service.Confirm(ctx, utils.SubjectUser, 1, totpAt(t, setup.Secret, service.now()))
+	if err != nil {
+		t.Fatalf("Ошибка подтверждения 2FA: %v", err)
+	}
+	if len(codes) != recoveryCodeCount || len(codes[0]) != 11 || codes[0][5] != '-' {
+		t.Errorf("Ожидается %d кодов вида XXXXX-XXXXX, получено %v", recoveryCodeCount, codes)
+	}
+	if enabled, _ := service.Enabled(ctx, utils.SubjectUser, 1); !enabled {
+		t.Error("Ожидается, что 2FA включена")
+	}
+
+	// Повторная настройка включенной 2FA запрещена
+	_, err = service.Setup(ctx, utils.SubjectUser, 1)
+	expectCode(t, err, 400)
+}
+
+// Тест защиты от повтора: код принимается один раз, следующий шаг - снова
+func TestTwoFactorRejectsReplayedCode(t *testing.T) {
+	service, _, now := newTestTwoFactorService(t, &config.Config{})
+	ctx := context.Background()
+	secret, _ := enableTwoFactor(t, service, utils.SubjectUser, 1)
+
+	// Код, которым была подтверждена настройка, уже использован
+	expectCode(t, service.Verify(ctx, utils.SubjectUser, 1, totpAt(t, secret, *now)), 401)
+
+	*now = now.Add(30 * time.Second)
+	code := totpAt(t, secret, *now)
+	if err := service.Verify(ctx, utils.SubjectUser, 1, code); err != nil {
+		t.Fatalf("Ожидается, что код следующего шага принят, получено %v", err)
+	}
+	expectCode(t, service.Verify(ctx, utils.SubjectUser, 1, code), 401)
+}
+
+func TestTwoFactorRecoveryCodes(t *testing.T) {
+	service, _, _ := newTestTwoFactorService(t, &config.Config{})
+	ctx := context.Background()
+	_, codes := enableTwoFactor(t, service, utils.SubjectUser, 1)
+
+	// Код принимается без дефиса и в нижнем регистре, но только один раз
+	relaxed := strings.ToLower(strings.ReplaceAll(codes[0], "-", ""))
+	if err := service.Verify(ctx, utils.SubjectUser, 1, relaxed); err != nil {
+		t.Fatalf("Ожидается, что код восстановления принят, получено %v", err)
+	}
+	expectCode(t, service.Verify(ctx, utils.SubjectUser, 1, codes[0]), 401)
+
+	// Новые коды заменяют старые
+	fresh, err := service.RegenerateRecoveryCodes(ctx, utils.SubjectUser, 1, codes[1])
+	if err != nil {
+		t.Fatalf("Ошибка генерации кодов: %v", err)
+	}
+	expectCode(t, service.Verify(ctx, utils.SubjectUser, 1, codes[2]), 401)
+	if err := service.Verify(ctx, utils.SubjectUser, 1, fresh[0]); err != nil {
+		t.Errorf("Ожидается, что новый код принят, получено %v", err)
+	}
+}
+
+// Тест второго шага входа: токен пользователя не подходит для входа администратора
+func TestTwoFactorChallenge(t *testing.T) {
+	service, _, now := newTestTwoFactorService(t, &config.Config{})
+	ctx := context.Background()
+	secret, codes := enableTwoFactor(t, service, utils.SubjectUser, 1)
+
+	challenge, err := service.Challenge(utils.SubjectUser, 1)
+	if err != nil {
+		t.Fatalf("Ошибка выдачи токена: %v", err)
+	}
+	if challenge.ExpiresIn != 300 {
+		t.Errorf("Ожидается срок жизни 300 секунд, получено %d", challenge.ExpiresIn)
+	}
+
+	// Без блокировок учетной записи и пауз, чтобы проверить только лимит токена
+	lockout := NewLockoutService(repository.NewMemoryLoginAttempts(), repository.NewMemory().Audit, &config.Config{LoginFailureWindow: 15 * time.Minute})
+
+	_, err = service.CompleteChallenge(ctx, lockout, utils.SubjectAdmin, challenge.Token, codes[0], "192.0.2.1")
+	expectCode(t, err, 401)
+	_, err = service.CompleteChallenge(ctx, lockout, utils.SubjectUser, "invalid", codes[0], "192.0.2.1")
+	expectCode(t, err, 401)
+
+	// После maxChallengeFailures неверных кодов токен не принимается даже с верным кодом
+	for i := 0; i < maxChallengeFailures; i++ {
+		_, err = service.CompleteChallenge(ctx, lockout, utils.SubjectUser, challenge.Token, "00000", "192.0.2.1")
+		expectCode(t, err, 401)
+	}
+	*now = now.Add(30 * time.Second)
+	_, err = service.CompleteChallenge(ctx, lockout, utils.SubjectUser, challenge.Token, totpAt(t, secret, *now), "192.0.2.1")
+	expectCode(t, err, 401)
+
+	// Новый токен после повторного ввода пароля принимается
+	challenge, err = service.Challenge(utils.SubjectUser, 1)
+	if err != nil {
+		t.Fatalf("Ошибка выдачи токена: %v", err)
+	}
+	userID, err := service.CompleteChallenge(ctx, lockout, utils.SubjectUser, challenge.Token, totpAt(t, secret, *now), "192.0.2.1")
+	if err != nil || userID != 1 {
+		t.Fatalf("Ожидается вход пользователя 1, получено %d, %v", userID, err)
+	}
+}
+
+// Неверные коды второго шага блокируют учетную запись так же, как неверные пароли
+func TestTwoFactorChallengeLockout(t *testing.T) {
+	service, repos, now := newTestTwoFactorService(t, &config.Config{})
+	ctx := context.Background()
+	secret, _ := enableTwoFactor(t, service, utils.SubjectUser, 1)
+	lockout, lockoutNow := newTestLockoutService()
+
+	for i := 0; i < 3; i++ {
+		// Каждый раз новый токен: лимит учетной записи действует на все токены
+		challenge, err := service.Challenge(utils.SubjectUser, 1)
+		if err != nil {
+			t.Fatalf("Ошибка выдачи токена: %v", err)
+		}
+		*lockoutNow = lockoutNow.Add(time.Minute)
+		_, err = service.CompleteChallenge(ctx, lockout, utils.SubjectUser, challenge.Token, "00000", "192.0.2.1")
+		expectCode(t, err, 401)
+	}
+
+	challenge, err := service.Challenge(utils.SubjectUser, 1)
+	if err != nil {
+		t.Fatalf("Ошибка выдачи токена: %v", err)
+	}
+	_, err = service.CompleteChallenge(ctx, lockout, utils.SubjectUser, challenge.Token, totpAt(t, secret, *now), "192.0.2.2")
+	expectCode(t, err, 429)
+	// Блокировка действует и на ввод пароля
+	user, _ := repos.Users.GetByID(ctx, 1)
+	expectCode(t, lockout.Check(ctx, utils.SubjectUser, user.Email, "192.0.2.2"), 429)
+}
+
+func TestTwoFactorDisableAndReset(t *testing.T) {
+	service, _, now := newTestTwoFactorService(t, &config.Config{AdminRequire2FA: true})
+	ctx := context.Background()
+	userSecret, _ := enableTwoFactor(t, service, utils.SubjectUser, 1)
+	_, adminCodes := enableTwoFactor(t, service, utils.SubjectAdmin, 1)
+
+	// Обязательную 2FA администратор отключить не может
+	expectCode(t, service.Disable(ctx, utils.SubjectAdmin, 1, adminCodes[0]), 403)
+
+	expectCode(t, service.Disable(ctx, utils.SubjectUser, 1, "000000"), 401)
+	*now = now.Add(30 * time.Second)
+	if err := service.Disable(ctx, utils.SubjectUser, 1, totpAt(t, userSecret, *now)); err != nil {
+		t.Fatalf("Ошибка отключения 2FA: %v", err)
+	}
+	if enabled, _ := service.Enabled(ctx, utils.SubjectUser, 1); enabled {
+		t.Error("Ожидается, что 2FA пользователя отключена")
+	}
+
+	// Сброс без кода и повторный сброс
+	if err := service.Reset(ctx, utils.SubjectAdmin, 1); err != nil {
+		t.Fatalf("Ошибка сброса 2FA: %v", err)
+	}
+	expectCode(t, service.Reset(ctx, utils.SubjectAdmin, 1), 404)
+}
@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"kursovaya_backend/internal/audit"
+	"kursovaya_backend/internal/errors"
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/rbac"
+	"kursovaya_backend/internal/repository"
+	"kursovaya_backend/pkg/utils"
+)
+
+// UserLifecycleService меняет статус учетной записи пользователя: блокирует,
+// восстанавливает, удаляет и безвозвратно удаляет персональные данные
+type UserLifecycleService struct {
+	repos  *repository.Repositories
+	stores *StoreService
+	now    func() time.Time
+}
+
+// NewUserLifecycleService создает новый сервис статусов пользователей.
+// StoreService нужен, чтобы при удалении данных удалить токены магазинов.
+func NewUserLifecycleService(repos *repository.Repositories, stores *StoreService) *UserLifecycleService {
+	return &UserLifecycleService{
+		repos:  repos,
+		stores: stores,
+		now:    time.Now,
+	}
+}
+
+// Suspend блокирует пользователя: вход и запросы с его токенами и API-ключами
+// отклоняются, сессии отзываются, синхронизация его магазинов приостанавливается
+func (s *UserLifecycleService) Suspend(ctx context.Context, userID int, reason string) (*models.User, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, errors.BadRequest("Укажите причину блокировки", "Suspension reason is required")
+	}
+	return s.changeStatus(ctx, userID, models.UserSuspended, reason, "user_suspended", func(user *models.User) error {
+		if user.Status == models.UserSuspended {
+			return errors.BadRequest("Пользователь уже заблокирован", "User is already suspended")
+		}
+		return nil
+	})
+}
+
+// Reactivate снимает блокировку или отменяет удаление учетной записи, если
+// ее персональные данные еще не удалены
+func (s *UserLifecycleService) Reactivate(ctx context.Context, userID int) (*models.User, error) {
+	return s.changeStatus(ctx, userID, models.UserActive, "", "user_reactivated", func(user *models.User) error {
+		if user.Status != models.UserSuspended && user.Status != models.UserDeleted {
+			return errors.BadRequest("Пользователь не заблокирован и не удален", "User is already active")
+		}
+		return nil
+	})
+}
+
+// Delete помечает учетную запись удаленной. Данные пользователя сохраняются,
+// и учетную запись можно восстановить, пока не вызван Erase.
+func (s *UserLifecycleService) Delete(ctx context.Context, userID int, reason string) (*models.User, error) {
+	return s.changeStatus(ctx, userID, models.UserDeleted, strings.TrimSpace(reason), "user_deleted", func(user *models.User) error {
+		if user.Status == models.UserDeleted {
+			return errors.BadRequest("Пользователь уже удален", "User is already deleted")
+		}
+		return nil
+	})
+}
+
+// changeStatus проверяет переход функцией allowed, сохраняет новый статус,
+// отзывает сессии при блокировке или удалении и записывает событие аудита
+func (s *UserLifecycleService) changeStatus(ctx context.Context, userID int, status, reason, action string, allowed func(*models.User) error) (*models.User, error) {
+	var user *models.User
+	err := s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		before, err := tx.Users.GetByID(ctx, userID)
+		if err == repository.ErrNotFound {
+			return errors.NotFound("Пользователь не найден", "User not found")
+		}
+		if err != nil {
+			return err
+		}
+		if before.ErasedAt != nil {
+			return errors.BadRequest("Данные пользователя удалены", "User has been erased")
+		}
+		if err := allowed(before); err != nil {
+			return err
+		}
+
+		now := s.now()
+		if err := tx.Users.SetStatus(ctx, userID, status, reason, now); err != nil {
+			return err
+		}
+		if status != models.UserActive {
+			if err := tx.Sessions.RevokeAllForUser(ctx, userID, now); err != nil {
+				return err
+			}
+		}
+		if user, err = tx.Users.GetByID(ctx, userID); err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx.Audit, audit.Entry{Action: action, TargetType: "user", TargetID: userID,
+			Before: statusSnapshot(before), After: statusSnapshot(user)})
+	})
+	if err != nil {
+		return nil, appError(err, "Ошибка изменения статуса пользователя")
+	}
+	return user, nil
+}
+
+// Erase безвозвратно удаляет персональные данные пользователя (право на
+// забвение). Организации, в которых он единственный участник, удаляются
+// вместе с магазинами, товарами и сопоставлениями, из остальных он
+// исключается. Email заменяется обезличенным адресом, сессии, одноразовые
+// токены, API-ключи и 2FA удаляются или отзываются. Записи журнала аудита
+// сохраняются, но ссылаются только на ID пользователя.
+func (s *UserLifecycleService) Erase(ctx context.Context, userID int) error {
+	var tokenRefs []string
+	err := s.repos.WithinTx(ctx, func(tx *repository.Repositories) error {
+		// Транзакция может быть перезапущена, ссылки прошлой попытки не должны сохраниться
+		tokenRefs = nil
+		user, err := tx.Users.GetByID(ctx, userID)
+		if err == repository.ErrNotFound {
+			return errors.NotFound("Пользователь не найден", "User not found")
+		}
+		if err != nil {
+			return err
+		}
+		if user.ErasedAt != nil {
+			return errors.BadRequest("Данные пользователя уже удалены", "User has already been erased")
+		}
+
+		memberships, err := tx.Organizations.ListByUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+		for _, membership := range memberships {
+			refs, err := s.leaveOrganization(ctx, tx, membership, user.Email)
+			if err != nil {
+				return err
+			}
+			tokenRefs = append(tokenRefs, refs...)
+		}
+		if err := tx.Organizations.DeleteInvitationsByEmail(ctx, user.Email); err != nil {
+			return err
+		}
+
+		now := s.now()
+		if err := tx.Sessions.RevokeAllForUser(ctx, userID, now); err != nil {
+			return err
+		}
+		for _, purpose := range []string{models.TokenPurposePasswordReset, models.TokenPurposeEmailVerification} {
+			if err := tx.UserTokens.InvalidateForUser(ctx, userID, purpose, now); err != nil {
+				return err
+			}
+		}
+		keys, err := tx.APIKeys.ListByUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if key.RevokedAt != nil {
+				continue
+			}
+			if err := tx.APIKeys.Revoke(ctx, userID, key.ID, now); err != nil {
+				return err
+			}
+		}
+		if err := tx.TwoFactor.Delete(ctx, utils.SubjectUser, userID); err != nil && err != repository.ErrNotFound {
+			return err
+		}
+		if err := tx.LoginAttempts.Reset(ctx, AccountKey(utils.SubjectUser, user.Email)); err != nil && err != repository.ErrNotFound {
+			return err
+		}
+		if err := tx.Users.Erase(ctx, userID, now); err != nil {
+			return err
+		}
+
+		// Прежний email в журнал не попадает
+		return audit.Record(ctx, tx.Audit, audit.Entry{Action: "user_erased", TargetType: "user", TargetID: userID,
+			Before: statusSnapshot(user), After: map[string]any{"status": models.UserDeleted, "erased_at": now.UTC()}})
+	})
+	if err != nil {
+		return appError(err, "Ошибка удаления данных пользователя")
+	}
+
+	for _, tokenRef := range tokenRefs {
+		s.stores.deleteToken(ctx, tokenRef)
+	}
+	return nil
+}
+
+// leaveOrganization удаляет организацию, если пользователь в ней один, или
+// исключает его из нее, передавая права владельца другому участнику, если
+// он был последним владельцем. Возвращает ссылки на токены удаленных магазинов.
+func (s *UserLifecycleService) leaveOrganization(ctx context.Context, tx *repository.Repositories, membership models.OrganizationMember, email string) ([]string, error) {
+	organizationID := membership.OrganizationID
+	members, err := tx.Organizations.ListMembers(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(members) > 1 {
+		if membership.Role == rbac.RoleOwner {
+			owners, err := tx.Organizations.CountMembersWithRole(ctx, organizationID, rbac.RoleOwner)
+			if err != nil {
+				return nil, err
+			}
+			if owners <= 1 {
+				if err := promoteSuccessor(ctx, tx, organizationID, members, membership.UserID); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if err := tx.Organizations.RemoveMember(ctx, organizationID, membership.UserID); err != nil {
+			return nil, err
+		}
+		// Личная организация названа по email, а он остается у других участников
+		if membership.OrganizationName == email {
+			return nil, tx.Organizations.Rename(ctx, organizationID, fmt.Sprintf("Организация %d", organizationID))
+		}
+		return nil, nil
+	}
+
+	stores, err := tx.Stores.ListByOrganization(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	var tokenRefs []string
+	for _, store := range stores {
+		tokenRef, err := deleteStoreRecords(ctx, tx, store)
+		if err != nil {
+			return nil, err
+		}
+		tokenRefs = append(tokenRefs, tokenRef)
+	}
+	mappings, err := tx.Mappings.ListByOrganization(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	for _, mapping := range mappings {
+		if err := tx.Mappings.DeleteByID(ctx, mapping.ID); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Organizations.Delete(ctx, organizationID); err != nil {
+		return nil, err
+	}
+	return tokenRefs, nil
+}
+
+// promoteSuccessor назначает владельцем первого участника, кроме leavingID
+func promoteSuccessor(ctx context.Context, tx *repository.Repositories, organizationID int, members []models.OrganizationMember, leavingID int) error {
+	for _, member := range members {
+		if member.UserID != leavingID {
+			return tx.Organizations.SetMemberRole(ctx, organizationID, member.UserID, rbac.RoleOwner)
+		}
+	}
+	return nil
+}
+
+// statusSnapshot - сведения о статусе пользователя для журнала аудита, без email
+func statusSnapshot(user *models.User) map[string]any {
+	return map[string]any{"status": user.Status, "status_reason": user.StatusReason}
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"kursovaya_backend/internal/models"
+	"kursovaya_backend/internal/rbac"
+	"kursovaya_backend/internal/repository"
+	"kursovaya_backend/internal/secrets"
+)
+
+// Тест блокировки, восстановления и мягкого удаления пользователя
+func TestSuspendAndReactivateUser(t *testing.T) {
+	ctx := context.Background()
+	repos := repository.NewMemory()
+	userID, _, _ := seedMappingFixtures(t, repos)
+	hash, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	repos.Users.SetPassword(ctx, userID, string(hash))
+	repos.Sessions.Create(ctx, &models.Session{UserID: userID, FamilyID: "family", TokenHash: "hash", ExpiresAt: time.Now().Add(time.Hour)})
+	membership, _ := repos.Organizations.GetDefaultMembership(ctx, userID)
+
+	stores := NewStoreService(repos, secrets.NewDatabaseStore())
+	lifecycle := NewUserLifecycleService(repos, stores)
+	auth := NewAuthService(repos)
+
+	_, err := lifecycle.Suspend(ctx, userID, " ")
+	expectCode(t, err, http.StatusBadRequest)
+	user, err := lifecycle.Suspend(ctx, userID, "Нарушение правил")
+	if err != nil {
+		t.Fatalf("Ошибка блокировки: %v", err)
+	}
+	if user.Status != models.UserSuspended || user.StatusReason != "Нарушение правил" {
+		t.Errorf("Ожидается заблокированный пользователь с причиной, получено %+v", user)
+	}
+	_, err = lifecycle.Suspend(ctx, userID, "Повторно")
+	expectCode(t, err, http.StatusBadRequest)
+
+	// Сессии отозваны, вход отклоняется с причиной, синхронизация магазинов приостановлена
+	if revoked, _ := repos.Sessions.IsFamilyRevoked(ctx, "family"); !revoked {
+		t.Error("Ожидается отзыв сессий заблокированного пользователя")
+	}
+	_, err = auth.AuthenticateUser(ctx, "owner@example.com", "password")
+	expectCode(t, err, http.StatusForbidden)
+	if syncable, _ := stores.GetSyncableStores(ctx, membership.OrganizationID); len(syncable) != 0 {
+		t.Errorf("Ожидается приостановка синхронизации, получено %+v", syncable)
+	}
+
+	if _, err := lifecycle.Reactivate(ctx, userID); err != nil {
+		t.Fatalf("Ошибка восстановления: %v", err)
+	}
+	_, err = lifecycle.Reactivate(ctx, userID)
+	expectCode(t, err, http.StatusBadRequest)
+	if _, err := auth.AuthenticateUser(ctx, "owner@example.com", "password"); err != nil {
+		t.Errorf("Ожидается вход после восстановления, получено %v", err)
+	}
+	if syncable, _ := stores.GetSyncableStores(ctx, membership.OrganizationID); len(syncable) != 1 {
+		t.Errorf("Ожидается возобновление синхронизации, получено %+v", syncable)
+	}
+
+	// Удаленный пользователь не отличим от несуществующего, но его можно восстановить
+	if _, err := lifecycle.Delete(ctx, userID, ""); err != nil {
+		t.Fatalf("Ошибка удаления: %v", err)
+	}
+	_, err = auth.AuthenticateUser(ctx, "owner@example.com", "password")
+	expectCode(t, err, http.StatusNotFound)
+	if user, err := lifecycle.Reactivate(ctx, userID); err != nil || user.Status == models.UserDeleted {
+		t.Errorf("Ожидается восстановление удаленного пользователя, получено %+v, %v", user, err)
+	}
+	_, err = lifecycle.Suspend(ctx, userID+100, "Спам")
+	expectCode(t, err, http.StatusNotFound)
+
+	page, _ := NewAuditService(repos).List(ctx, repository.AuditFilter{})
+	actions := []string{"user_reactivated", "user_deleted", "user_reactivated", "user_suspended"}
+	for i, action := range actions {
+		if page.Events[i].Action != action {
+			t.Errorf("Событие %d: ожидается %s, получено %s", i, action, page.Events[i].Action)
+		}
+	}
+}
+
+// Тест приостановки синхронизации: решает состояние владельцев организации,
+// а не пользователя, добавившего магазин
+func TestSyncableStoresFollowOrganizationOwners(t *testing.T) {
+	ctx := context.Background()
+	repos := repository.NewMemory()
+	stores := NewStoreService(repos, secrets.NewDatabaseStore())
+	lifecycle := NewUserLifecycleService(repos, stores)
+
+	owner, _ := repos.Users.Create(ctx, "owner@example.com", "hash")
+	creator, _ := repos.Users.Create(ctx, "creator@example.com", "hash")
+	organization, err := createOrganization(ctx, repos, owner.ID, "Общая")
+	if err != nil {
+		t.Fatalf("Ошибка создания организации: %v", err)
+	}
+	repos.Organizations.AddMember(ctx, organization.ID, creator.ID, rbac.RoleEditor)
+	if _, err := repos.Stores.Create(ctx, organization.ID, creator.ID, "wb", "token"); err != nil {
+		t.Fatalf("Ошибка создания магазина: %v", err)
+	}
+
+	// Блокировка участника, добавившего магазин, синхронизацию не останавливает
+	if _, err := lifecycle.Suspend(ctx, creator.ID, "Нарушение правил"); err != nil {
+		t.Fatalf("Ошибка блокировки: %v", err)
+	}
+	if syncable, err := stores.GetSyncableStores(ctx, organization.ID); err != nil || len(syncable) != 1 {
+		t.Errorf("Ожидается синхронизация магазина, получено %+v, %v", syncable, err)
+	}
+
+	// Без активного владельца синхронизация приостанавливается
+	if _, err := lifecycle.Suspend(ctx, owner.ID, "Нарушение правил"); err != nil {
+		t.Fatalf("Ошибка блокировки: %v", err)
+	}
+	if syncable, err := stores.GetSyncableStores(ctx, organization.ID); err != nil || len(syncable) != 0 {
+		t.Errorf("Ожидается приостановка синхронизации, получено %+v, %v", syncable, err)
+	}
+}
+
+// Тест удаления персональных данных: личные организации удаляются вместе с
+// магазинами, из общих пользователь исключается с передачей прав владельца
+func TestEraseUser(t *testing.T) {
+	useTestKeyring(t)
+	ctx := context.Background()
+	repos := repository.NewMemory()
+	userID, otherUserID, _ := seedMappingFixtures(t, repos)
+	shared := inviteAndAccept(t, repos, userID, otherUserID, "other@example.com", rbac.RoleEditor)
+
+	secretStore := secrets.NewDatabaseStore()
+	stores := NewStoreService(repos, secretStore)
+	private, _ := createOrganization(ctx, repos, userID, "Личная")
+	store, err := stores.AddStore(ctx, private.ID, userID, "ozon", "ozon-token-123")
+	if err != nil {
+		t.Fatalf("Ошибка добавления магазина: %v", err)
+	}
+	repos.Sessions.Create(ctx, &models.Session{UserID: userID, FamilyID: "family", TokenHash: "hash", ExpiresAt: time.Now().Add(time.Hour)})
+	lifecycle := NewUserLifecycleService(repos, stores)
+
+	if err := lifecycle.Erase(ctx, userID); err != nil {
+		t.Fatalf("Ошибка удаления данных: %v", err)
+	}
+
+	user, _ := repos.Users.GetByID(ctx, userID)
+	if user.Email != repository.ErasedEmail(userID) || user.Status != models.UserDeleted || user.ErasedAt == nil {
+		t.Errorf("Ожидается обезличенный пользователь, получено %+v", user)
+	}
+	if revoked, _ := repos.Sessions.IsFamilyRevoked(ctx, "family"); !revoked {
+		t.Error("Ожидается отзыв сессий")
+	}
+
+	// Организация, где пользователь был единственным участником, удалена вместе с магазином
+	if _, err := repos.Organizations.GetByID(ctx, private.ID); err != repository.ErrNotFound {
+		t.Errorf("Ожидается удаление личной организации, получено %v", err)
+	}
+	if _, err := repos.Stores.GetByID(ctx, store.ID); err != repository.ErrNotFound {
+		t.Errorf("Ожидается удаление магазина, получено %v", err)
+	}
+
+	// В общей организации владельцем стал оставшийся участник, название без email
+	if member, err := repos.Organizations.GetMember(ctx, shared, otherUserID); err != nil || member.Role != rbac.RoleOwner {
+		t.Errorf("Ожидается передача прав владельца, получено %+v, %v", member, err)
+	}
+	if _, err := repos.Organizations.GetMember(ctx, shared, userID); err != repository.ErrNotFound {
+		t.Errorf("Ожидается исключение пользователя, получено %v", err)
+	}
+	if organization, _ := repos.Organizations.GetByID(ctx, shared); strings.Contains(organization.Name, "@") {
+		t.Errorf("Название организации не должно содержать email, получено %q", organization.Name)
+	}
+
+	// Журнал сохраняется, но не содержит email
+	page, _ := NewAuditService(repos).List(ctx, repository.AuditFilter{Action: "user_erased"})
+	if len(page.Events) != 1 || strings.Contains(string(page.Events[0].Before), "owner@example.com") {
+		t.Errorf("Ожидается событие без email, получено %+v", page.Events)
+	}
+
+	// Данные удалены безвозвратно
+	err = lifecycle.Erase(ctx, userID)
+	expectCode(t, err, http.StatusBadRequest)
+	_, err = lifecycle.Reactivate(ctx, userID)
+	expectCode(t, err, http.StatusBadRequest)
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// StatusError - ответ маркетплейса с кодом, отличным от 200
+type StatusError struct {
+	Marketplace string
+	StatusCode  int
+	Body        string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("ошибка API %s: %d, тело: %s", e.Marketplace, e.StatusCode, e.Body)
+}
+
+// Категории ошибок запросов к маркетплейсам для статистики загрузок
+const (
+	ErrorAuth      = "auth"       // 401 и 403: токен неверен или отозван
+	ErrorRateLimit = "rate_limit" // 429: превышен лимит запросов маркетплейса
+	ErrorServer    = "server"     // 5xx: сбой на стороне маркетплейса
+	ErrorNetwork   = "network"    // таймаут или сбой соединения
+	ErrorResponse  = "response"   // неожиданный код ответа или некорректный JSON
+	ErrorOther     = "other"
+)
+
+// ErrorCategory относит ошибку GetProducts к одной из категорий ErrorAuth и т. д.
+func ErrorCategory(err error) string {
+	var statusErr *StatusError
+	var netErr net.Error
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &statusErr):
+		switch {
+		case statusErr.StatusCode == http.StatusUnauthorized, statusErr.StatusCode == http.StatusForbidden:
+			return ErrorAuth
+		case statusErr.StatusCode == http.StatusTooManyRequests:
+			return ErrorRateLimit
+		case statusErr.StatusCode >= http.StatusInternalServerError:
+			return ErrorServer
+		default:
+			return ErrorResponse
+		}
+	case errors.As(err, &netErr):
+		return ErrorNetwork
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+		return ErrorResponse
+	default:
+		return ErrorOther
+	}
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+)
+
+// timeoutError имитирует таймаут соединения
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// Тест отнесения ошибок запросов к категориям
+func TestErrorCategory(t *testing.T) {
+	var syntaxErr error = json.Unmarshal([]byte("{"), &struct{}{})
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"401", &StatusError{Marketplace: "WB", StatusCode: 401}, ErrorAuth},
+		{"403", &StatusError{Marketplace: "Ozon", StatusCode: 403}, ErrorAuth},
+		{"429", &StatusError{Marketplace: "WB", StatusCode: 429}, ErrorRateLimit},
+		{"503", &StatusError{Marketplace: "WB", StatusCode: 503}, ErrorServer},
+		{"404", &StatusError{Marketplace: "WB", StatusCode: 404}, ErrorResponse},
+		{"Таймаут", fmt.Errorf("ошибка выполнения запроса: %w", &url.Error{Op: "Post", URL: "https://example.com", Err: timeoutError{}}), ErrorNetwork},
+		{"Некорректный JSON", fmt.Errorf("ошибка парсинга ответа: %w", syntaxErr), ErrorResponse},
+		{"Прочее", errors.New("токен WB не установлен"), ErrorOther},
+	}
+	for _, tt := range tests {
+		if got := ErrorCategory(tt.err); got != tt.want {
+			t.Errorf("%s: ожидается %s, получено %s", tt.name, tt.want, got)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package api
 
+import "context"
+
 // Product интерфейс для товара, универсальный для всех маркетплейсов
 type Product struct {
 	ID        string `json:"id"`          // Уникальный идентификатор товара в маркетплейсе
@@ -11,7 +13,8 @@ type Product struct {
 	UpdatedAt string `json:"updated_at"`  // Дата обновления (не используется везде)
 }
 
-// APIClient интерфейс для работы с API маркетплейсов
+// APIClient интерфейс для работы с API маркетплейсов. Запросы выполняются
+// в контексте ctx и передают маркетплейсу идентификатор запроса из него.
 type APIClient interface {
-	GetProducts() ([]Product, error)
+	GetProducts(ctx context.Context) ([]Product, error)
 }
\ No newline at end of file
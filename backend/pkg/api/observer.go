@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// RequestObserver получает результат запроса к маркетплейсу: маркетплейс
+// ("wb" или "ozon"), путь запроса, код ответа (0, если ответ не получен)
+// и время выполнения
+type RequestObserver func(marketplace, endpoint string, status int, duration time.Duration)
+
+var requestObserver atomic.Pointer[RequestObserver]
+
+// SetRequestObserver задает функцию, которой клиенты маркетплейсов сообщают
+// о каждом запросе, например для метрик. nil отключает наблюдение.
+func SetRequestObserver(observer RequestObserver) {
+	if observer == nil {
+		requestObserver.Store(nil)
+		return
+	}
+	requestObserver.Store(&observer)
+}
+
+// observedTransport сообщает RequestObserver о запросах клиента маркетплейса
+type observedTransport struct {
+	marketplace string
+	next        http.RoundTripper
+}
+
+func newObservedTransport(marketplace string) http.RoundTripper {
+	return &observedTransport{marketplace: marketplace, next: http.DefaultTransport}
+}
+
+func (t *observedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	if observer := requestObserver.Load(); observer != nil {
+		status := 0
+		if err == nil {
+			status = resp.StatusCode
+		}
+		(*observer)(t.marketplace, req.URL.Path, status, time.Since(start))
+	}
+	return resp, err
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Тест сообщений о запросах к маркетплейсам
+func TestObservedTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	type call struct {
+		marketplace, endpoint string
+		status                int
+	}
+	var calls []call
+	SetRequestObserver(func(marketplace, endpoint string, status int, duration time.Duration) {
+		calls = append(calls, call{marketplace, endpoint, status})
+	})
+	defer SetRequestObserver(nil)
+
+	client := &http.Client{Transport: newObservedTransport("wb")}
+	resp, err := client.Get(server.URL + "/content/v2/cards/list?limit=10")
+	if err != nil {
+		t.Fatalf("Ошибка запроса: %v", err)
+	}
+	resp.Body.Close()
+
+	// Ответ не получен: код 0
+	server.Close()
+	if _, err := client.Get(server.URL + "/ping"); err == nil {
+		t.Fatal("Запрос к остановленному серверу выполнен")
+	}
+
+	want := []call{{"wb", "/content/v2/cards/list", http.StatusTooManyRequests}, {"wb", "/ping", 0}}
+	if len(calls) != len(want) || calls[0] != want[0] || calls[1] != want[1] {
+		t.Errorf("Получены запросы %v, ожидались %v", calls, want)
+	}
+}
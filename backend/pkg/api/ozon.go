@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,6 +10,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"kursovaya_backend/pkg/utils"
 )
 
 // OzonClient для работы с Ozon API
@@ -52,13 +55,14 @@ func NewOzonClient(token, clientID string) *OzonClient {
 		Token:    token,
 		ClientID: clientID,
 		Client: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:   30 * time.Second,
+			Transport: newObservedTransport("ozon"),
 		},
 	}
 }
 
 // GetProducts получает список товаров из Ozon
-func (o *OzonClient) GetProducts() ([]Product, error) {
+func (o *OzonClient) GetProducts(ctx context.Context) ([]Product, error) {
 	// Валидация данных
 	if o.Token == "" {
 		return nil, fmt.Errorf("токен Ozon не установлен")
@@ -88,7 +92,7 @@ func (o *OzonClient) GetProducts() ([]Product, error) {
 		return nil, fmt.Errorf("ошибка подготовки тела запроса: %v", err)
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonBody))
 	if err != nil {
 		return nil, fmt.Errorf("ошибка создания запроса: %v", err)
 	}
@@ -96,21 +100,24 @@ func (o *OzonClient) GetProducts() ([]Product, error) {
 	req.Header.Set("Client-Id", o.ClientID)
 	req.Header.Set("Api-Key", o.Token)
 	req.Header.Set("Content-Type", "application/json")
+	if id := utils.RequestIDFromContext(ctx); id != "" {
+		req.Header.Set(utils.RequestIDHeader, id)
+	}
 
 	resp, err := o.Client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка выполнения запроса: %v", err)
+		return nil, fmt.Errorf("ошибка выполнения запроса: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("ошибка API Ozon: %d, тело: %s", resp.StatusCode, string(body))
+		return nil, &StatusError{Marketplace: "Ozon", StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	var ozonResponse OzonProductResponse
 	if err := json.NewDecoder(resp.Body).Decode(&ozonResponse); err != nil {
-		return nil, fmt.Errorf("ошибка парсинга ответа: %v", err)
+		return nil, fmt.Errorf("ошибка парсинга ответа: %w", err)
 	}
 
 	// Преобразуем в универсальный формат с валидацией
@@ -2,11 +2,14 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"time"
+
+	"kursovaya_backend/pkg/utils"
 )
 
 // WBClient для работы с Wildberries API
@@ -49,13 +52,14 @@ func NewWBClient(token string) *WBClient {
 	return &WBClient{
 		Token: token,
 		Client: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:   30 * time.Second,
+			Transport: newObservedTransport("wb"),
 		},
 	}
 }
 
 // GetProducts получает список товаров из WB
-func (w *WBClient) GetProducts() ([]Product, error) {
+func (w *WBClient) GetProducts(ctx context.Context) ([]Product, error) {
 	// Валидация токена
 	if w.Token == "" {
 		return nil, fmt.Errorf("токен WB не установлен")
@@ -82,28 +86,31 @@ func (w *WBClient) GetProducts() ([]Product, error) {
 		return nil, fmt.Errorf("ошибка подготовки тела запроса: %v", err)
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonBody))
 	if err != nil {
 		return nil, fmt.Errorf("ошибка создания запроса: %v", err)
 	}
 
 	req.Header.Set("Authorization", w.Token)
 	req.Header.Set("Content-Type", "application/json")
+	if id := utils.RequestIDFromContext(ctx); id != "" {
+		req.Header.Set(utils.RequestIDHeader, id)
+	}
 
 	resp, err := w.Client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка выполнения запроса: %v", err)
+		return nil, fmt.Errorf("ошибка выполнения запроса: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("ошибка API WB: %d, тело: %s", resp.StatusCode, string(body))
+		return nil, &StatusError{Marketplace: "WB", StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	var wbProductsResponse WBProductsResponse
 	if err := json.NewDecoder(resp.Body).Decode(&wbProductsResponse); err != nil {
-		return nil, fmt.Errorf("ошибка парсинга ответа: %v", err)
+		return nil, fmt.Errorf("ошибка парсинга ответа: %w", err)
 	}
 
 	// Преобразуем в универсальный формат с валидацией
@@ -3,60 +3,217 @@ package utils
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hkdf"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"errors"
-	"io"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
 )
 
-// Шифрование строки с использованием AES
-func EncryptString(plaintext, key string) (string, error) {
-	// Преобразуем ключ в нужный размер (32 байта для AES-256)
-	keyBytes := []byte(key)
-	if len(keyBytes) > 32 {
-		keyBytes = keyBytes[:32] // Обрезаем до 32 байт
-	} else if len(keyBytes) < 32 {
-		// Дополняем до 32 байт
-		padded := make([]byte, 32)
-		copy(padded, keyBytes)
-		keyBytes = padded
+// ciphertextVersion - префикс текущего формата шифротекста:
+// "v1:<id ключа>:<base64url(nonce || шифротекст || тег)>"
+const ciphertextVersion = "v1"
+
+// keyIDPattern ограничивает идентификаторы ключей, чтобы они не содержали разделитель ":"
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// Keyring шифрует данные AES-256-GCM основным ключом и расшифровывает любым
+// из известных ключей, поэтому ключ можно сменить, не теряя старые данные:
+// новый ключ становится основным, старый остается в списке до перешифрования.
+// Ключи AES выводятся из секретов через HKDF-SHA256.
+type Keyring struct {
+	primaryID string
+	keys      map[string]cipher.AEAD
+	// legacyKey - ключ прежнего формата AES-CFB без префикса версии
+	legacyKey []byte
+}
+
+// NewKeyring создает набор ключей. secrets - секреты по идентификаторам,
+// primaryID - ключ для шифрования новых данных. legacySecret нужен только
+// для чтения данных, зашифрованных до появления версий; пустая строка
+// отключает такое чтение.
+func NewKeyring(primaryID string, secrets map[string]string, legacySecret string) (*Keyring, error) {
+	if _, ok := secrets[primaryID]; !ok {
+		return nil, fmt.Errorf("primary encryption key %q is not configured", primaryID)
 	}
 
-	block, err := aes.NewCipher(keyBytes)
-	if err != nil {
+	keyring := &Keyring{primaryID: primaryID, keys: make(map[string]cipher.AEAD, len(secrets))}
+	for id, secret := range secrets {
+		if !keyIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("invalid encryption key id %q: use letters, digits, '-' and '_'", id)
+		}
+		if secret == "" {
+			return nil, fmt.Errorf("encryption key %q is empty", id)
+		}
+		key, err := hkdf.Key(sha256.New, []byte(secret), nil, "marketplace-tracker encryption key "+id, 32)
+		if err != nil {
+			return nil, err
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		keyring.keys[id] = aead
+	}
+	if legacySecret != "" {
+		keyring.legacyKey = legacyKeyBytes(legacySecret)
+	}
+	return keyring, nil
+}
+
+// ParseEncryptionKeys разбирает список ключей вида "id1:секрет1,id2:секрет2"
+func ParseEncryptionKeys(spec string) (map[string]string, error) {
+	secrets := make(map[string]string)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, secret, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("encryption key entry must look like id:secret")
+		}
+		if _, exists := secrets[id]; exists {
+			return nil, fmt.Errorf("encryption key %q is listed twice", id)
+		}
+		secrets[id] = secret
+	}
+	return secrets, nil
+}
+
+// PrimaryKeyID возвращает идентификатор ключа, которым шифруются новые данные
+func (k *Keyring) PrimaryKeyID() string {
+	return k.primaryID
+}
+
+// Encrypt шифрует строку основным ключом
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	aead := k.keys[k.primaryID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
 		return "", err
 	}
 
-	plaintextBytes := []byte(plaintext)
-	ciphertext := make([]byte, aes.BlockSize+len(plaintextBytes))
-	iv := ciphertext[:aes.BlockSize]
-	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+	// Префикс с версией и ключом входит в аутентифицируемые данные,
+	// поэтому подменить его без обнаружения нельзя
+	prefix := ciphertextVersion + ":" + k.primaryID + ":"
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(prefix))
+	return prefix + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt расшифровывает строку ключом, указанным в ее префиксе, или
+// прежним форматом без префикса
+func (k *Keyring) Decrypt(ciphertext string) (string, error) {
+	keyID, payload, versioned := splitCiphertext(ciphertext)
+	if !versioned {
+		if k.legacyKey == nil {
+			return "", errors.New("legacy ciphertext cannot be decrypted: no legacy key configured")
+		}
+		return decryptLegacy(ciphertext, k.legacyKey)
+	}
+
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return "", fmt.Errorf("encryption key %q is not configured", keyID)
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
 		return "", err
 	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("ciphertext too short")
+	}
 
-	stream := cipher.NewCFBEncrypter(block, iv)
-	stream.XORKeyStream(ciphertext[aes.BlockSize:], plaintextBytes)
+	nonce, sealed := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, sealed, []byte(ciphertextVersion+":"+keyID+":"))
+	if err != nil {
+		return "", errors.New("ciphertext authentication failed")
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotation сообщает, что строка зашифрована не основным ключом
+// или в прежнем формате и ее нужно перешифровать
+func (k *Keyring) NeedsRotation(ciphertext string) bool {
+	keyID, _, versioned := splitCiphertext(ciphertext)
+	return !versioned || keyID != k.primaryID
+}
+
+// IsCiphertext сообщает, что строка - шифротекст текущего формата с
+// идентификатором ключа
+func IsCiphertext(value string) bool {
+	_, _, versioned := splitCiphertext(value)
+	return versioned
+}
+
+// splitCiphertext выделяет идентификатор ключа из строки текущего формата
+func splitCiphertext(ciphertext string) (keyID, payload string, versioned bool) {
+	rest, ok := strings.CutPrefix(ciphertext, ciphertextVersion+":")
+	if !ok {
+		return "", "", false
+	}
+	keyID, payload, ok = strings.Cut(rest, ":")
+	return keyID, payload, ok
+}
+
+var (
+	defaultKeyringMu sync.RWMutex
+	defaultKeyring   *Keyring
+)
 
-	return base64.URLEncoding.EncodeToString(ciphertext), nil
+// SetEncryptionKeyring задает ключи для EncryptString и DecryptString
+func SetEncryptionKeyring(keyring *Keyring) {
+	defaultKeyringMu.Lock()
+	defer defaultKeyringMu.Unlock()
+	defaultKeyring = keyring
 }
 
-// Расшифровка строки с использованием AES
-func DecryptString(ciphertext, key string) (string, error) {
+// EncryptString шифрует строку основным ключом, заданным SetEncryptionKeyring
+func EncryptString(plaintext string) (string, error) {
+	defaultKeyringMu.RLock()
+	keyring := defaultKeyring
+	defaultKeyringMu.RUnlock()
+	if keyring == nil {
+		return "", errors.New("encryption keys are not configured")
+	}
+	return keyring.Encrypt(plaintext)
+}
+
+// DecryptString расшифровывает строку ключами, заданными SetEncryptionKeyring
+func DecryptString(ciphertext string) (string, error) {
+	defaultKeyringMu.RLock()
+	keyring := defaultKeyring
+	defaultKeyringMu.RUnlock()
+	if keyring == nil {
+		return "", errors.New("encryption keys are not configured")
+	}
+	return keyring.Decrypt(ciphertext)
+}
+
+// legacyKeyBytes приводит ключ прежнего формата к 32 байтам: обрезает
+// длинный и дополняет нулями короткий
+func legacyKeyBytes(key string) []byte {
+	keyBytes := make([]byte, 32)
+	copy(keyBytes, key)
+	return keyBytes
+}
+
+// decryptLegacy расшифровывает строку прежнего формата AES-CFB
+func decryptLegacy(ciphertext string, key []byte) (string, error) {
 	ciphertextBytes, err := base64.URLEncoding.DecodeString(ciphertext)
 	if err != nil {
 		return "", err
 	}
 
-	keyBytes := []byte(key)
-	if len(keyBytes) > 32 {
-		keyBytes = keyBytes[:32]
-	} else if len(keyBytes) < 32 {
-		padded := make([]byte, 32)
-		copy(padded, keyBytes)
-		keyBytes = padded
-	}
-
-	block, err := aes.NewCipher(keyBytes)
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return "", err
 	}
@@ -72,4 +229,4 @@ func DecryptString(ciphertext, key string) (string, error) {
 	stream.XORKeyStream(ciphertextBytes, ciphertextBytes)
 
 	return string(ciphertextBytes), nil
-}
\ No newline at end of file
+}
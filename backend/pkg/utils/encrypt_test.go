@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// encryptLegacy шифрует строку прежним форматом AES-CFB без префикса версии
+func encryptLegacy(t *testing.T, plaintext, key string) string {
+	t.Helper()
+	block, err := aes.NewCipher(legacyKeyBytes(key))
+	if err != nil {
+		t.Fatalf("Ошибка создания шифра: %v", err)
+	}
+	ciphertext := make([]byte, aes.BlockSize+len(plaintext))
+	if _, err := rand.Read(ciphertext[:aes.BlockSize]); err != nil {
+		t.Fatalf("Ошибка генерации IV: %v", err)
+	}
+	cipher.NewCFBEncrypter(block, ciphertext[:aes.BlockSize]).XORKeyStream(ciphertext[aes.BlockSize:], []byte(plaintext))
+	return base64.URLEncoding.EncodeToString(ciphertext)
+}
+
+func newTestKeyring(t *testing.T, primaryID string, secrets map[string]string, legacy string) *Keyring {
+	t.Helper()
+	keyring, err := NewKeyring(primaryID, secrets, legacy)
+	if err != nil {
+		t.Fatalf("Ошибка создания ключей: %v", err)
+	}
+	return keyring
+}
+
+// Тест шифрования: префикс версии и ключа, случайный nonce, расшифровка
+func TestKeyringRoundTrip(t *testing.T) {
+	keyring := newTestKeyring(t, "k1", map[string]string{"k1": "first-secret"}, "")
+
+	first, err := keyring.Encrypt("wb-token")
+	if err != nil {
+		t.Fatalf("Ошибка шифрования: %v", err)
+	}
+	second, _ := keyring.Encrypt("wb-token")
+	if !strings.HasPrefix(first, "v1:k1:") {
+		t.Errorf("Ожидается префикс v1:k1:, получено %q", first)
+	}
+	if first == second {
+		t.Error("Одинаковые строки должны шифроваться по-разному")
+	}
+	if plaintext, err := keyring.Decrypt(first); err != nil || plaintext != "wb-token" {
+		t.Errorf("Ожидается исходная строка, получено %q, %v", plaintext, err)
+	}
+}
+
+// Тест обнаружения подмены шифротекста и префикса
+func TestKeyringTamperDetection(t *testing.T) {
+	keyring := newTestKeyring(t, "k1", map[string]string{"k1": "first-secret", "k2": "first-secret"}, "")
+	ciphertext, _ := keyring.Encrypt("wb-token")
+
+	payload := []byte(strings.TrimPrefix(ciphertext, "v1:k1:"))
+	payload[len(payload)-1] ^= 'A' ^ 'B'
+	tests := map[string]string{
+		"измененный шифротекст": "v1:k1:" + string(payload),
+		"подмененный ключ":      "v1:k2:" + strings.TrimPrefix(ciphertext, "v1:k1:"),
+		"неизвестный ключ":      "v1:k3:" + strings.TrimPrefix(ciphertext, "v1:k1:"),
+		"обрезанный":            "v1:k1:AAAA",
+		"без префикса":          strings.TrimPrefix(ciphertext, "v1:k1:"),
+	}
+	for name, tampered := range tests {
+		if _, err := keyring.Decrypt(tampered); err == nil {
+			t.Errorf("%s: ожидается ошибка расшифровки", name)
+		}
+	}
+}
+
+// Тест смены ключа: старые данные читаются, новые шифруются основным ключом
+func TestKeyringRotation(t *testing.T) {
+	old := newTestKeyring(t, "k1", map[string]string{"k1": "first-secret"}, "")
+	ciphertext, _ := old.Encrypt("wb-token")
+
+	keyring := newTestKeyring(t, "k2", map[string]string{"k1": "first-secret", "k2": "second-secret"}, "")
+	if !keyring.NeedsRotation(ciphertext) {
+		t.Error("Строка, зашифрованная старым ключом, требует перешифрования")
+	}
+	if plaintext, err := keyring.Decrypt(ciphertext); err != nil || plaintext != "wb-token" {
+		t.Errorf("Ожидается расшифровка старым ключом, получено %q, %v", plaintext, err)
+	}
+
+	rotated, _ := keyring.Encrypt("wb-token")
+	if !strings.HasPrefix(rotated, "v1:k2:") || keyring.NeedsRotation(rotated) {
+		t.Errorf("Ожидается строка основного ключа k2, получено %q", rotated)
+	}
+	if _, err := old.Decrypt(rotated); err == nil {
+		t.Error("Старый набор не должен расшифровывать данные нового ключа")
+	}
+}
+
+// Тест чтения прежнего формата AES-CFB
+func TestKeyringLegacy(t *testing.T) {
+	legacy := encryptLegacy(t, "wb-token", "legacy-secret")
+
+	keyring := newTestKeyring(t, "k1", map[string]string{"k1": "legacy-secret"}, "legacy-secret")
+	if !keyring.NeedsRotation(legacy) {
+		t.Error("Строка прежнего формата требует перешифрования")
+	}
+	if plaintext, err := keyring.Decrypt(legacy); err != nil || plaintext != "wb-token" {
+		t.Errorf("Ожидается расшифровка прежнего формата, получено %q, %v", plaintext, err)
+	}
+
+	withoutLegacy := newTestKeyring(t, "k1", map[string]string{"k1": "legacy-secret"}, "")
+	if _, err := withoutLegacy.Decrypt(legacy); err == nil {
+		t.Error("Без ключа прежнего формата расшифровка должна завершаться ошибкой")
+	}
+}
+
+// Тест разбора ENCRYPTION_KEYS и проверки набора ключей
+func TestParseEncryptionKeys(t *testing.T) {
+	secrets, err := ParseEncryptionKeys(" k1:first , k2:sec:ond ")
+	if err != nil || len(secrets) != 2 || secrets["k1"] != "first" || secrets["k2"] != "sec:ond" {
+		t.Errorf("Ожидается два ключа, получено %v, %v", secrets, err)
+	}
+
+	for _, spec := range []string{"k1", "k1:a,k1:b"} {
+		if _, err := ParseEncryptionKeys(spec); err == nil {
+			t.Errorf("Ожидается ошибка для %q", spec)
+		}
+	}
+
+	invalid := []struct {
+		primary string
+		secrets map[string]string
+	}{
+		{"k2", map[string]string{"k1": "secret"}},
+		{"k1", map[string]string{"k1": ""}},
+		{"bad id", map[string]string{"bad id": "secret"}},
+	}
+	for _, tt := range invalid {
+		if _, err := NewKeyring(tt.primary, tt.secrets, ""); err == nil {
+			t.Errorf("Ожидается ошибка для основного ключа %q и %v", tt.primary, tt.secrets)
+		}
+	}
+}
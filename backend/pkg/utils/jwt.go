@@ -2,7 +2,8 @@ package utils
 
 import (
 	"errors"
-	"log"
+	"log/slog"
+	"strconv"
 	"time"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
@@ -13,35 +14,98 @@ var jwtKey string
 func SetJWTKey(key string) {
 	// Validate that the key has sufficient length for security
 	if len(key) < 32 {
-		log.Println("[WARNING] JWT key should be at least 32 characters long for security in production")
+		slog.Warn("JWT key should be at least 32 characters long for security in production")
 	}
 	jwtKey = key
 }
 
-func GenerateJWT(userID int, email string) (string, error) {
+// Типы субъектов токена. Токены пользователей и администраторов выпускаются
+// для разных аудиторий, поэтому токен одного типа не принимается там, где
+// ожидается другой, даже если ID в таблицах users и admins совпадают.
+const (
+	SubjectUser  = "user"
+	SubjectAdmin = "admin"
+
+	// Токены второго шага входа: подтверждают пароль, но дают доступ только
+	// к проверке кода двухфакторной аутентификации
+	SubjectUserMFA  = "user-mfa"
+	SubjectAdminMFA = "admin-mfa"
+
+	tokenIssuer = "marketplace-tracker"
+)
+
+// audience возвращает аудиторию токена для типа субъекта
+func audience(subjectType string) string {
+	return tokenIssuer + ":" + subjectType
+}
+
+// Claims - данные, передаваемые в JWT
+type Claims struct {
+	UserID int    `json:"user_id"`
+	Email  string `json:"email,omitempty"` // Using hashed email
+	// SubjectType - "user" или "admin", определяет таблицу, к которой относится UserID
+	SubjectType string `json:"sub_type"`
+	Role        string `json:"role"`
+	// SessionID - семья refresh-токенов, к которой привязан access-токен
+	SessionID string `json:"sid,omitempty"`
+	// Act - администратор, действующий от имени пользователя (claim "act" из RFC 8693)
+	Act *Impersonator `json:"act,omitempty"`
+	// TokenVersion - версия токенов администратора на момент выдачи
+	TokenVersion int `json:"ver,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Impersonator - администратор, выполняющий вход от имени пользователя
+type Impersonator struct {
+	// Subject - "admin:{id}"
+	Subject  string `json:"sub"`
+	AdminID  int    `json:"admin_id"`
+	Username string `json:"username"`
+	// ReadOnly запрещает изменяющие запросы
+	ReadOnly bool `json:"read_only"`
+}
+
+// GenerateJWT issues a token for a subject of the given type. Use GenerateAccessToken
+// and GenerateAdminToken instead of calling it directly.
+func GenerateJWT(subjectType string, id int, email, role, sessionID string, ttl time.Duration) (string, error) {
+	return generateJWT(subjectType, id, email, role, sessionID, nil, ttl)
+}
+
+func generateJWT(subjectType string, id int, email, role, sessionID string, act *Impersonator, ttl time.Duration) (string, error) {
+	return signJWT(Claims{UserID: id, SubjectType: subjectType, Role: role, SessionID: sessionID, Act: act}, email, ttl)
+}
+
+// signJWT fills in the registered claims and signs the token. The email is
+// hashed before it is put into the token; claims.ID is kept as the "jti" claim.
+func signJWT(claims Claims, email string, ttl time.Duration) (string, error) {
 	if jwtKey == "" {
 		return "", errors.New("JWT key not set")
 	}
+	switch claims.SubjectType {
+	case SubjectUser, SubjectAdmin, SubjectUserMFA, SubjectAdminMFA:
+	default:
+		return "", errors.New("unknown token subject type")
+	}
 
 	// Hash the user email to reduce sensitive data exposure in tokens
-	hashedEmail, err := bcrypt.GenerateFromPassword([]byte(email), bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
+	hashedEmail := ""
+	if email != "" {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(email), bcrypt.DefaultCost)
+		if err != nil {
+			return "", err
+		}
+		hashedEmail = string(hashed)
 	}
 
-	// Create custom claims with user ID
-	claims := struct {
-		UserID int    `json:"user_id"`
-		Email  string `json:"email"` // Using hashed email
-		jwt.RegisteredClaims
-	}{
-		UserID: userID,
-		Email:  string(hashedEmail),
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(12 * time.Hour)), // Reduced from 24 to 12 hours
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Issuer:    "marketplace-tracker",
-		},
+	now := time.Now()
+	claims.Email = hashedEmail
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        claims.ID,
+		Subject:   strconv.Itoa(claims.UserID),
+		Audience:  jwt.ClaimStrings{audience(claims.SubjectType)},
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		Issuer:    tokenIssuer,
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -53,33 +117,106 @@ func GenerateJWT(userID int, email string) (string, error) {
 	return tokenString, nil
 }
 
-func ValidateJWT(tokenString string) (int, string, error) {
-	if jwtKey == "" {
-		return 0, "", errors.New("JWT key not set")
+// GenerateAccessToken issues a short-lived user token bound to a session, so it can be revoked on logout.
+// The role is re-read from the database on every refresh.
+func GenerateAccessToken(userID int, email, role, sessionID string, ttl time.Duration) (string, error) {
+	if sessionID == "" {
+		return "", errors.New("session ID is required")
 	}
+	return GenerateJWT(SubjectUser, userID, email, role, sessionID, ttl)
+}
 
-	// Use custom claims
-	claims := struct {
-		UserID int    `json:"user_id"`
-		Email  string `json:"email"`
-		jwt.RegisteredClaims
-	}{}
+// GenerateImpersonationToken issues a short-lived user token for an administrator
+// acting on behalf of the user. The token carries the administrator in the
+// "act" claim and is bound to a session of its own, so it can be ended early.
+func GenerateImpersonationToken(userID int, role, sessionID string, act Impersonator, ttl time.Duration) (string, error) {
+	if sessionID == "" {
+		return "", errors.New("session ID is required")
+	}
+	act.Subject = SubjectAdmin + ":" + strconv.Itoa(act.AdminID)
+	return generateJWT(SubjectUser, userID, "", role, sessionID, &act, ttl)
+}
 
-	token, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
+// GenerateAdminToken issues an administrator token. The token is accepted only
+// while the administrator's token version stays the same, so a password change
+// or disabling the account revokes it.
+func GenerateAdminToken(adminID int, role string, tokenVersion int) (string, error) {
+	claims := Claims{UserID: adminID, SubjectType: SubjectAdmin, Role: role, TokenVersion: tokenVersion}
+	return signJWT(claims, "", 12*time.Hour) // Reduced from 24 to 12 hours
+}
+
+// GenerateMFAToken issues a short-lived challenge token after the password check
+// of a user or an administrator with two-factor authentication enabled.
+// challengeID becomes the "jti" claim, so failed codes can be counted per token.
+func GenerateMFAToken(subjectType string, id int, challengeID string, ttl time.Duration) (string, error) {
+	challengeType, err := mfaSubject(subjectType)
+	if err != nil {
+		return "", err
+	}
+	if challengeID == "" {
+		return "", errors.New("challenge ID is required")
+	}
+	claims := Claims{UserID: id, SubjectType: challengeType}
+	claims.ID = challengeID
+	return signJWT(claims, "", ttl)
+}
+
+// ParseMFAToken validates a challenge token issued for the given subject type
+func ParseMFAToken(tokenString, subjectType string) (*Claims, error) {
+	challengeType, err := mfaSubject(subjectType)
+	if err != nil {
+		return nil, err
+	}
+	return parseJWT(tokenString, challengeType)
+}
+
+// mfaSubject returns the challenge subject type for a user or admin subject type
+func mfaSubject(subjectType string) (string, error) {
+	switch subjectType {
+	case SubjectUser:
+		return SubjectUserMFA, nil
+	case SubjectAdmin:
+		return SubjectAdminMFA, nil
+	}
+	return "", errors.New("unknown token subject type")
+}
+
+// ParseUserToken validates a token issued to a regular user
+func ParseUserToken(tokenString string) (*Claims, error) {
+	return parseJWT(tokenString, SubjectUser)
+}
+
+// ParseAdminToken validates a token issued to an administrator
+func ParseAdminToken(tokenString string) (*Claims, error) {
+	return parseJWT(tokenString, SubjectAdmin)
+}
+
+// parseJWT checks the token signature, expiry, issuer and audience of the expected subject type
+func parseJWT(tokenString, subjectType string) (*Claims, error) {
+	if jwtKey == "" {
+		return nil, errors.New("JWT key not set")
+	}
+
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 		// Validate the signing method
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("unexpected signing method")
 		}
 		return []byte(jwtKey), nil
-	})
+	},
+		jwt.WithAudience(audience(subjectType)),
+		jwt.WithIssuer(tokenIssuer),
+		jwt.WithExpirationRequired(),
+	)
 
 	if err != nil {
-		return 0, "", err
+		return nil, err
 	}
 
-	if token.Valid {
-		return claims.UserID, claims.Email, nil
+	if !token.Valid || claims.SubjectType != subjectType {
+		return nil, errors.New("invalid token")
 	}
 
-	return 0, "", errors.New("invalid token")
-}
\ No newline at end of file
+	return claims, nil
+}
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"regexp"
+)
+
+// RequestIDHeader - заголовок с идентификатором запроса, по которому
+// связываются записи лога, ответ клиенту и запросы к маркетплейсам
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDPattern ограничивает идентификаторы, принятые от клиента, чтобы
+// они не искажали лог и заголовки исходящих запросов
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+type requestIDKey struct{}
+
+// NewRequestID генерирует случайный идентификатор запроса
+func NewRequestID() string {
+	return rand.Text()
+}
+
+// ValidRequestID проверяет идентификатор запроса, переданный клиентом
+func ValidRequestID(id string) bool {
+	return requestIDPattern.MatchString(id)
+}
+
+// WithRequestID добавляет идентификатор запроса в контекст
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext возвращает идентификатор запроса из контекста или пустую строку
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
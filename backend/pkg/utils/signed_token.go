@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// GenerateSignedToken возвращает случайный токен вида "значение.подпись".
+// Подпись HMAC-SHA256 на ключе JWT привязывает токен к назначению, поэтому
+// токен сброса пароля нельзя предъявить как токен подтверждения email,
+// а поддельный токен отклоняется без обращения к базе.
+func GenerateSignedToken(purpose string) (string, error) {
+	if jwtKey == "" {
+		return "", errors.New("JWT key not set")
+	}
+
+	value := make([]byte, 32)
+	if _, err := rand.Read(value); err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(value)
+	return encoded + "." + tokenSignature(purpose, encoded), nil
+}
+
+// VerifySignedToken проверяет подпись токена для назначения purpose
+func VerifySignedToken(token, purpose string) bool {
+	if jwtKey == "" {
+		return false
+	}
+
+	value, signature, ok := strings.Cut(token, ".")
+	if !ok || value == "" {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(tokenSignature(purpose, value)))
+}
+
+func tokenSignature(purpose, value string) string {
+	mac := hmac.New(sha256.New, []byte(jwtKey))
+	mac.Write([]byte(purpose + ":" + value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
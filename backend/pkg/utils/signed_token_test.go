@@ -0,0 +1,31 @@
+package utils
+
+import "testing"
+
+func TestSignedToken(t *testing.T) {
+	SetJWTKey("test-secret-key-with-at-least-32-characters")
+
+	token, err := GenerateSignedToken("password_reset")
+	if err != nil {
+		t.Fatalf("Ошибка генерации токена: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		purpose string
+		want    bool
+	}{
+		{"подлинный токен", token, "password_reset", true},
+		{"другое назначение", token, "email_verification", false},
+		{"измененный токен", "x" + token, "password_reset", false},
+		{"без подписи", "value", "password_reset", false},
+		{"пустой токен", "", "password_reset", false},
+	}
+
+	for _, tt := range tests {
+		if got := VerifySignedToken(tt.token, tt.purpose); got != tt.want {
+			t.Errorf("%s: ожидается %v, получено %v", tt.name, tt.want, got)
+		}
+	}
+}
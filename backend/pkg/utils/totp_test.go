@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Тест по эталонным значениям RFC 6238 (приложение B, SHA-1, последние 6 цифр)
+func TestTOTPCodeRFC6238(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		got, err := TOTPCode(secret, TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Ошибка вычисления кода: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("Время %d: ожидается код %s, получен %s", tt.unix, tt.want, got)
+		}
+	}
+}
+
+// Тест окна проверки: принимается соседний шаг, но не более далекий
+func TestValidateTOTPWindow(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("Ошибка генерации секрета: %v", err)
+	}
+	now := time.Now()
+	code, _ := TOTPCode(secret, TOTPStep(now)-1)
+
+	if step, ok := ValidateTOTP(secret, code, now); !ok || step != TOTPStep(now)-1 {
+		t.Errorf("Ожидается, что код предыдущего шага принят, получено %d, %v", step, ok)
+	}
+	if _, ok := ValidateTOTP(secret, code, now.Add(2*time.Minute)); ok {
+		t.Error("Ожидается, что устаревший код отклонен")
+	}
+	if _, ok := ValidateTOTP(secret, "12345", now); ok {
+		t.Error("Ожидается, что код неверной длины отклонен")
+	}
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri := TOTPProvisioningURI("Marketplace Tracker", "user@example.com", "JBSWY3DPEHPK3PXP")
+	if !strings.HasPrefix(uri, "otpauth://totp/Marketplace%20Tracker:user@example.com?") {
+		t.Errorf("Неожиданная метка в URI: %s", uri)
+	}
+	if !strings.Contains(uri, "secret=JBSWY3DPEHPK3PXP") || !strings.Contains(uri, "issuer=Marketplace+Tracker") {
+		t.Errorf("Ожидаются secret и issuer в URI: %s", uri)
+	}
+}
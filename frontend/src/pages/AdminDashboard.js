@@ -108,7 +108,7 @@ function AdminDashboard() {
 
   const fetchStats = async () => {
     try {
-      const response = await adminManagementAPI.getStats(adminToken);
+      const response = await adminManagementAPI.getStats();
       setStats(response.data);
     } catch (err) {
       console.error('Error fetching stats:', err);
@@ -116,25 +116,28 @@ function AdminDashboard() {
     }
   };
 
+  // Загружается первая страница максимального размера, дальше фильтры и страницы применяются на клиенте
+  const listParams = { limit: 500, order: 'desc' };
+
   const fetchData = async (tab) => {
     setLoading(true);
     try {
       switch (tab) {
         case 'users':
-          const usersResponse = await adminManagementAPI.getUsers(adminToken);
-          setUsers(usersResponse.data);
+          const usersResponse = await adminManagementAPI.getUsers(listParams);
+          setUsers(usersResponse.data.items);
           break;
         case 'stores':
-          const storesResponse = await adminManagementAPI.getStores(adminToken);
-          setStores(storesResponse.data);
+          const storesResponse = await adminManagementAPI.getStores(listParams);
+          setStores(storesResponse.data.items);
           break;
         case 'products':
-          const productsResponse = await adminManagementAPI.getProducts(adminToken);
-          setProducts(productsResponse.data);
+          const productsResponse = await adminManagementAPI.getProducts(listParams);
+          setProducts(productsResponse.data.items);
           break;
         case 'mappings':
-          const mappingsResponse = await adminManagementAPI.getMappings(adminToken);
-          setMappings(mappingsResponse.data);
+          const mappingsResponse = await adminManagementAPI.getMappings(listParams);
+          setMappings(mappingsResponse.data.items);
           break;
         default:
           break;
@@ -1,5 +1,5 @@
 import { createSlice, createAsyncThunk } from '@reduxjs/toolkit';
-import { authAPI } from '../../services/api';
+import { authAPI, saveSessionTokens, clearSessionTokens } from '../../services/api';
 
 const initialState = {
   user: null,
@@ -17,9 +17,8 @@ export const login = createAsyncThunk(
       const response = await authAPI.login(email, password);
       const { token, user } = response.data;
 
-      // Сохраняем токен в localStorage
-      localStorage.setItem('token', token);
-      localStorage.setItem('auth-token', token);
+      // Сохраняем токены сессии в localStorage
+      saveSessionTokens(response.data);
 
       return { token, user };
     } catch (error) {
@@ -36,9 +35,8 @@ export const register = createAsyncThunk(
       const response = await authAPI.register(email, password);
       const { token, user } = response.data;
 
-      // Сохраняем токен в localStorage
-      localStorage.setItem('token', token);
-      localStorage.setItem('auth-token', token);
+      // Сохраняем токены сессии в localStorage
+      saveSessionTokens(response.data);
 
       return { token, user };
     } catch (error) {
@@ -47,18 +45,23 @@ export const register = createAsyncThunk(
   }
 );
 
+// Асинхронный thunk для выхода: сессия завершается и на сервере
+export const logout = createAsyncThunk('auth/logout', async () => {
+  const refreshToken = localStorage.getItem('refresh_token');
+  if (refreshToken) {
+    try {
+      await authAPI.logout(refreshToken);
+    } catch (error) {
+      // Локальный выход выполняется, даже если сервер недоступен
+      console.error('Logout error:', error);
+    }
+  }
+});
+
 const authSlice = createSlice({
   name: 'auth',
   initialState,
   reducers: {
-    logout: (state) => {
-      state.user = null;
-      state.token = null;
-      localStorage.removeItem('token');
-      localStorage.removeItem('auth-token');
-      // Также удаляем админ-токен при выходе
-      localStorage.removeItem('admin_token');
-    },
     setAdminToken: (state, action) => {
       state.adminToken = action.payload;
       localStorage.setItem('admin_token', action.payload);
@@ -73,6 +76,14 @@ const authSlice = createSlice({
   },
   extraReducers: (builder) => {
     builder
+      // Обработка выхода
+      .addCase(logout.fulfilled, (state) => {
+        state.user = null;
+        state.token = null;
+        clearSessionTokens();
+        // Также удаляем админ-токен при выходе
+        localStorage.removeItem('admin_token');
+      })
       // Обработка логина
       .addCase(login.pending, (state) => {
         state.loading = true;
@@ -104,5 +115,5 @@ const authSlice = createSlice({
   },
 });
 
-export const { logout, setAdminToken, clearAdminToken, clearError } = authSlice.actions;
+export const { setAdminToken, clearAdminToken, clearError } = authSlice.actions;
 export default authSlice.reducer;
\ No newline at end of file
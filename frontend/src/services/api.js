@@ -30,6 +30,11 @@ api.interceptors.request.use(
     if (token) {
       config.headers.Authorization = `Bearer ${token}`;
     }
+    // Активная организация; без заголовка сервер использует личную организацию
+    const organizationId = localStorage.getItem('organization_id');
+    if (organizationId) {
+      config.headers['X-Organization-ID'] = organizationId;
+    }
     return config;
   },
   (error) => {
@@ -38,14 +43,70 @@ api.interceptors.request.use(
   }
 );
 
+// Сохраняет пару токенов, выданную при входе или обновлении сессии
+export const saveSessionTokens = ({ token, refresh_token: refreshToken }) => {
+  localStorage.setItem('token', token);
+  localStorage.setItem('auth-token', token);
+  if (refreshToken) {
+    localStorage.setItem('refresh_token', refreshToken);
+  }
+};
+
+// Удаляет токены сессии пользователя
+export const clearSessionTokens = () => {
+  localStorage.removeItem('token');
+  localStorage.removeItem('auth-token');
+  localStorage.removeItem('refresh_token');
+  localStorage.removeItem('organization_id');
+};
+
+// Один запрос обновления на все параллельные запросы: refresh-токен одноразовый,
+// и повторное его использование сервер считает кражей и завершает сессию
+let refreshPromise = null;
+
+const refreshSession = () => {
+  if (!refreshPromise) {
+    const refreshToken = localStorage.getItem('refresh_token');
+    refreshPromise = axios
+      .post(`${API_BASE_URL}/api/auth/refresh`, { refresh_token: refreshToken })
+      .then((response) => {
+        saveSessionTokens(response.data);
+        return response.data.token;
+      })
+      .finally(() => {
+        refreshPromise = null;
+      });
+  }
+  return refreshPromise;
+};
+
 // Перехватчик ответов для обработки ошибок
 api.interceptors.response.use(
   (response) => response,
-  (error) => {
+  async (error) => {
+    const originalRequest = error.config;
+
+    // Access-токен истек: обновляем сессию и повторяем запрос один раз
+    if (
+      error.response?.status === 401 &&
+      originalRequest &&
+      !originalRequest._retry &&
+      !originalRequest.url?.startsWith('/auth/') &&
+      localStorage.getItem('refresh_token')
+    ) {
+      originalRequest._retry = true;
+      try {
+        const token = await refreshSession();
+        originalRequest.headers.Authorization = `Bearer ${token}`;
+        return api(originalRequest);
+      } catch (refreshError) {
+        console.error('Session refresh error:', refreshError);
+      }
+    }
+
     // Обработка ошибок аутентификации
     if (error.response?.status === 401) {
-      localStorage.removeItem('token');
-      localStorage.removeItem('auth-token');
+      clearSessionTokens();
       // Only redirect if not already on login page
       if (window.location.pathname !== '/login' && !window.location.pathname.startsWith('/admin')) {
         window.location.href = '/login';
@@ -61,6 +122,37 @@ api.interceptors.response.use(
 export const authAPI = {
   register: (email, password) => api.post('/auth/register', { email, password }),
   login: (email, password) => api.post('/auth/login', { email, password }),
+  // Второй шаг входа, если ответ на вход содержит mfa_required
+  verifyMFA: (mfaToken, code) => api.post('/auth/mfa/verify', { mfa_token: mfaToken, code }),
+  logout: (refreshToken) => api.post('/auth/logout', { refresh_token: refreshToken }),
+  forgotPassword: (email) => api.post('/auth/password/forgot', { email }),
+  resetPassword: (token, password) => api.post('/auth/password/reset', { token, password }),
+  verifyEmail: (token) => api.post('/auth/email/verify', { token }),
+  resendVerification: () => api.post('/auth/email/resend'),
+  // Завершение входа администратора от имени пользователя (с токеном такого входа)
+  endImpersonation: (token) => api.post('/impersonation/end', null, { headers: { Authorization: `Bearer ${token}` } }),
+};
+
+// Двухфакторная аутентификация пользователя
+export const twoFactorAPI = {
+  getStatus: () => api.get('/auth/2fa'),
+  setup: () => api.post('/auth/2fa/setup'),
+  confirm: (code) => api.post('/auth/2fa/confirm', { code }),
+  disable: (code) => api.post('/auth/2fa/disable', { code }),
+  regenerateRecoveryCodes: (code) => api.post('/auth/2fa/recovery-codes', { code }),
+};
+
+// Персональные API-ключи для скриптов
+export const apiKeysAPI = {
+  getAll: () => api.get('/api-keys'),
+  // Открытый ключ есть только в ответе на создание
+  create: (name, scopes, expiresInDays) => api.post('/api-keys', { name, scopes, expires_in_days: expiresInDays }),
+  revoke: (id) => api.delete(`/api-keys/${id}`),
+};
+
+// История действий с учетной записью
+export const activityAPI = {
+  getAll: (params) => api.get('/me/activity', { params }),
 };
 
 // Админ-аутентификация (отдельный экземпляр для админ-токенов)
@@ -107,32 +199,74 @@ adminApi.interceptors.response.use(
 // Админ-аутентификация
 export const adminAPI = {
   login: (username, password) => adminApi.post('/admin/login', { username, password }),
+  verifyMFA: (mfaToken, code) => adminApi.post('/admin/login/mfa', { mfa_token: mfaToken, code }),
+
+  // Двухфакторная аутентификация администратора
+  getTwoFactorStatus: () => adminApi.get('/admin/2fa'),
+  setupTwoFactor: () => adminApi.post('/admin/2fa/setup'),
+  confirmTwoFactor: (code) => adminApi.post('/admin/2fa/confirm', { code }),
+  disableTwoFactor: (code) => adminApi.post('/admin/2fa/disable', { code }),
+  regenerateRecoveryCodes: (code) => adminApi.post('/admin/2fa/recovery-codes', { code }),
 };
 
 // Админ-управление (отдельный экземпляр с админ-токеном)
 export const adminManagementAPI = {
   // Статистика
-  getStats: () => adminApi.get('/admin/stats'),
+  // Итоги, рост по дням, маркетплейсы, топ пользователей и неактивные магазины; params: { days, inactive_days }
+  getStats: (params) => adminApi.get('/admin/stats', { params }),
+
+  // Списки возвращают { items, total, next_cursor }; params - limit, offset,
+  // cursor, sort, order и фильтры (email, status, name, store_type, user_id, created_from, created_to)
+  // Выгрузка списка в CSV с теми же фильтрами: list - users, stores, products или mappings
+  exportList: (list, params) => adminApi.get(`/admin/${list}/export`, { params: { ...params, format: 'csv' }, responseType: 'blob' }),
 
   // Управление пользователями
-  getUsers: () => adminApi.get('/admin/users'),
+  getUsers: (params) => adminApi.get('/admin/users', { params }),
   getUser: (userId) => adminApi.get(`/admin/users/${userId}`),
+  suspendUser: (userId, reason) => adminApi.post(`/admin/users/${userId}/suspend`, { reason }),
+  reactivateUser: (userId) => adminApi.post(`/admin/users/${userId}/reactivate`),
   deleteUser: (userId) => adminApi.delete(`/admin/users/${userId}`),
+  // Безвозвратное удаление персональных данных пользователя
+  eraseUser: (userId) => adminApi.post(`/admin/users/${userId}/erase`),
+  // Короткоживущий токен пользователя; write разрешает изменения
+  impersonateUser: (userId, reason, write = false) => adminApi.post(`/admin/users/${userId}/impersonate`, { reason, write }),
+  resetUserTwoFactor: (userId) => adminApi.delete(`/admin/users/${userId}/2fa`),
+
+  // Блокировки входа после неудачных попыток
+  getLockouts: () => adminApi.get('/admin/lockouts'),
+  unlock: (key) => adminApi.post('/admin/lockouts/unlock', { key }),
+
+  // Журнал аудита: params - фильтры и cursor из next_cursor
+  getAuditEvents: (params) => adminApi.get('/admin/audit', { params }),
 
   // Управление магазинами
-  getStores: () => adminApi.get('/admin/stores'),
+  getStores: (params) => adminApi.get('/admin/stores', { params }),
   getStore: (storeId) => adminApi.get(`/admin/stores/${storeId}`),
   deleteStore: (storeId) => adminApi.delete(`/admin/stores/${storeId}`),
 
   // Управление товарами
-  getProducts: () => adminApi.get('/admin/products'),
+  getProducts: (params) => adminApi.get('/admin/products', { params }),
   getProduct: (productId) => adminApi.get(`/admin/products/${productId}`),
   deleteProduct: (productId) => adminApi.delete(`/admin/products/${productId}`),
 
   // Управление сопоставлениями
-  getMappings: () => adminApi.get('/admin/mappings'),
+  getMappings: (params) => adminApi.get('/admin/mappings', { params }),
   getMapping: (mappingId) => adminApi.get(`/admin/mappings/${mappingId}`),
   deleteMapping: (mappingId) => adminApi.delete(`/admin/mappings/${mappingId}`),
+
+  // Роли и разрешения
+  getRoles: () => adminApi.get('/admin/roles'),
+  setUserRole: (userId, role) => adminApi.put(`/admin/users/${userId}/role`, { role }),
+  setAdminRole: (adminId, role) => adminApi.put(`/admin/admins/${adminId}/role`, { role }),
+
+  // Учетные записи администраторов
+  changePassword: (currentPassword, newPassword) => adminApi.post('/admin/password', { current_password: currentPassword, new_password: newPassword }),
+  getAdmins: () => adminApi.get('/admin/admins'),
+  createAdmin: (username, password, role) => adminApi.post('/admin/admins', { username, password, role }),
+  resetAdminPassword: (adminId, password) => adminApi.put(`/admin/admins/${adminId}/password`, { password }),
+  disableAdmin: (adminId) => adminApi.post(`/admin/admins/${adminId}/disable`),
+  enableAdmin: (adminId) => adminApi.post(`/admin/admins/${adminId}/enable`),
+  deleteAdmin: (adminId) => adminApi.delete(`/admin/admins/${adminId}`),
 };
 
 // Магазины
@@ -155,4 +289,23 @@ export const mappingsAPI = {
   deleteMapping: (mappingId) => api.delete(`/mappings/${mappingId}`),
 };
 
+// Организации и участники
+export const organizationsAPI = {
+  getOrganizations: () => api.get('/organizations'),
+  createOrganization: (name) => api.post('/organizations', { name }),
+  getMembers: (organizationId) => api.get(`/organizations/${organizationId}/members`),
+  inviteMember: (organizationId, email, role) => api.post(`/organizations/${organizationId}/invitations`, { email, role }),
+  setMemberRole: (organizationId, userId, role) => api.put(`/organizations/${organizationId}/members/${userId}`, { role }),
+  removeMember: (organizationId, userId) => api.delete(`/organizations/${organizationId}/members/${userId}`),
+  acceptInvitation: (token) => api.post('/invitations/accept', { token }),
+  // Выбор активной организации для последующих запросов
+  setActiveOrganization: (organizationId) => {
+    if (organizationId) {
+      localStorage.setItem('organization_id', String(organizationId));
+    } else {
+      localStorage.removeItem('organization_id');
+    }
+  },
+};
+
 export default api;
\ No newline at end of file